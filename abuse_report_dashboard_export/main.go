@@ -0,0 +1,23 @@
+// Command abuse_report_dashboard_export runs the
+// abuse_report_dashboard_export tool; see package
+// abusereportdashboardexport in tools/abuse_report_dashboard_export.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/abuse_report_dashboard_export"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := abusereportdashboardexport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
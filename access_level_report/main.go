@@ -0,0 +1,22 @@
+// Command access_level_report runs the access_level_report tool; see
+// package accesslevelreport in tools/access_level_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/access_level_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := accesslevelreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
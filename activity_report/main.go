@@ -0,0 +1,22 @@
+// Command activity_report runs the activity_report tool; see package
+// activityreport in tools/activity_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/activity_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := activityreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command admin_alert_subscription_manager runs the
+// admin_alert_subscription_manager tool; see package
+// adminalertsubscriptionmanager in tools/admin_alert_subscription_manager.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/admin_alert_subscription_manager"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := adminalertsubscriptionmanager.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
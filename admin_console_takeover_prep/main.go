@@ -0,0 +1,23 @@
+// Command admin_console_takeover_prep runs the admin_console_takeover_prep
+// tool; see package adminconsoletakeoverprep in
+// tools/admin_console_takeover_prep.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/admin_console_takeover_prep"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := adminconsoletakeoverprep.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
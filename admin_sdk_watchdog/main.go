@@ -0,0 +1,22 @@
+// Command admin_sdk_watchdog runs the admin_sdk_watchdog tool; see package
+// adminsdkwatchdog in tools/admin_sdk_watchdog.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/admin_sdk_watchdog"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := adminsdkwatchdog.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command audit_2sv_exceptions runs the audit_2sv_exceptions tool; see
+// package audit2svexceptions in tools/audit_2sv_exceptions.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/audit_2sv_exceptions"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := audit2svexceptions.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
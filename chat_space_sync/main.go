@@ -0,0 +1,22 @@
+// Command chat_space_sync runs the chat_space_sync tool; see package
+// chatspacesync in tools/chat_space_sync.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/chat_space_sync"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := chatspacesync.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
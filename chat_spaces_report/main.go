@@ -0,0 +1,22 @@
+// Command chat_spaces_report runs the chat_spaces_report tool; see package
+// chatspacesreport in tools/chat_spaces_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/chat_spaces_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := chatspacesreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command chrome_devices_report runs the chrome_devices_report tool; see
+// package chromedevicesreport in tools/chrome_devices_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/chrome_devices_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := chromedevicesreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
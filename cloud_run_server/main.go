@@ -0,0 +1,22 @@
+// Command cloud_run_server runs the cloud_run_server tool; see package
+// cloudrunserver in tools/cloud_run_server.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/cloud_run_server"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := cloudrunserver.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command contact_delegation_report runs the contact_delegation_report
+// tool; see package contactdelegationreport in
+// tools/contact_delegation_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/contact_delegation_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := contactdelegationreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
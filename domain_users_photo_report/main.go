@@ -0,0 +1,23 @@
+// Command domain_users_photo_report runs the domain_users_photo_report
+// tool; see package domainusersphotoreport in
+// tools/domain_users_photo_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/domain_users_photo_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := domainusersphotoreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command domain_wide_delegation_inventory runs the
+// domain_wide_delegation_inventory tool; see package
+// domainwidedelegationinventory in tools/domain_wide_delegation_inventory.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/domain_wide_delegation_inventory"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := domainwidedelegationinventory.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
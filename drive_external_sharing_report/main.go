@@ -0,0 +1,23 @@
+// Command drive_external_sharing_report runs the
+// drive_external_sharing_report tool; see package
+// driveexternalsharingreport in tools/drive_external_sharing_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/drive_external_sharing_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := driveexternalsharingreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
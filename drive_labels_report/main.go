@@ -0,0 +1,22 @@
+// Command drive_labels_report runs the drive_labels_report tool; see
+// package drivelabelsreport in tools/drive_labels_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/drive_labels_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := drivelabelsreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command duplicate_account_detector runs the duplicate_account_detector
+// tool; see package duplicateaccountdetector in
+// tools/duplicate_account_detector.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/duplicate_account_detector"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := duplicateaccountdetector.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
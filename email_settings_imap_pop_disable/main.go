@@ -0,0 +1,23 @@
+// Command email_settings_imap_pop_disable runs the
+// email_settings_imap_pop_disable tool; see package
+// emailsettingsimappopdisable in tools/email_settings_imap_pop_disable.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/email_settings_imap_pop_disable"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := emailsettingsimappopdisable.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
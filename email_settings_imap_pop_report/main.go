@@ -0,0 +1,23 @@
+// Command email_settings_imap_pop_report runs the
+// email_settings_imap_pop_report tool; see package
+// emailsettingsimappopreport in tools/email_settings_imap_pop_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/email_settings_imap_pop_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := emailsettingsimappopreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command endpoint_verification_report runs the
+// endpoint_verification_report tool; see package endpointverificationreport
+// in tools/endpoint_verification_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/endpoint_verification_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := endpointverificationreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
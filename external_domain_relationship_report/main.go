@@ -0,0 +1,24 @@
+// Command external_domain_relationship_report runs the
+// external_domain_relationship_report tool; see package
+// externaldomainrelationshipreport in
+// tools/external_domain_relationship_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/external_domain_relationship_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := externaldomainrelationshipreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+// Command gapps is one entry point for every tool in the repository:
+// "gapps group members report -domain example.com" runs
+// group_members_report with the flags that follow. The words of a command
+// are the words of the tool's name, and a few shorter aliases such as
+// "gapps groups report" are accepted too.
+//
+// Every tool is built into gapps, which runs it in process with the flags
+// that follow the command. The flags most tools share, such as
+// -credentials-file and -impersonated-email, and -config, may be given
+// once before the command instead:
+//
+//	gapps -config prod -impersonated-email admin@example.com users report
+//
+// A tool's own flags still win, and a tool without one of them ignores it.
+// The tools are also built as their own binaries, which run the same code.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jburnham/google_apps_tools/pkg/catalog"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+// aliases are the commands that don't spell out the tool's name, for the
+// tools people reach for most.
+var aliases = map[string]string{
+	"groups report":   "group_members_report",
+	"groups sync":     "group_members_sync",
+	"groups settings": "group_settings_report",
+	"licenses report": "license_report",
+	"devices report":  "mobile_devices_report",
+	"drives report":   "shared_drive_report",
+}
+
+// lookup finds the tool named by the leading words of args, preferring the
+// longest match, and returns it with the arguments left for the tool.
+func lookup(args []string) (*catalog.Tool, []string) {
+	words := 0
+	for words < len(args) && !strings.HasPrefix(args[words], "-") {
+		words++
+	}
+	for n := words; n > 0; n-- {
+		name := strings.Join(args[:n], "_")
+		if alias, ok := aliases[strings.Join(args[:n], " ")]; ok {
+			name = alias
+		}
+		if t := catalog.Lookup(name); t != nil && t.Name != "gapps" {
+			return t, args[n:]
+		}
+	}
+	return nil, nil
+}
+
+// command is how t is run through gapps.
+func command(t *catalog.Tool) string {
+	return strings.Replace(t.Name, "_", " ", -1)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gapps [shared flags] <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Shared flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr)
+	kinds := []string{catalog.KindReport, catalog.KindSync, catalog.KindService}
+	for _, kind := range kinds {
+		fmt.Fprintf(os.Stderr, "%s commands:\n", strings.Title(kind))
+		w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+		for _, t := range catalog.Tools {
+			if t.Kind == kind && t.Name != "gapps" {
+				fmt.Fprintf(w, "  %s\t%s\n", command(t), t.Summary)
+			}
+		}
+		w.Flush()
+		fmt.Fprintln(os.Stderr)
+	}
+	names := []string{}
+	for a := range aliases {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Aliases:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	for _, a := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", a, command(catalog.Lookup(aliases[a])))
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `Run "gapps <command> -h" for a command's flags.`)
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	versionFlag := flag.Bool("version", false, "Show version information.")
+	applyShared := config.RegisterShared(flag.CommandLine)
+	flag.Parse()
+	version.Git = gitVersion
+
+	if *versionFlag {
+		fmt.Println("gapps", gitVersion)
+		return
+	}
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(1)
+	}
+	switch flag.Arg(0) {
+	case "version":
+		fmt.Println("gapps", gitVersion)
+		return
+	case "help":
+		usage()
+		return
+	}
+	t, args := lookup(flag.Args())
+	if t == nil {
+		fmt.Fprintf(os.Stderr, "gapps: unknown command %q\n\n", strings.Join(flag.Args(), " "))
+		usage()
+		os.Exit(1)
+	}
+	applyShared()
+	if err := runs[t.Name](args); err != nil {
+		log.Fatalf("gapps %s: %v", command(t), err)
+	}
+}
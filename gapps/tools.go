@@ -0,0 +1,120 @@
+package main
+
+import (
+	"github.com/jburnham/google_apps_tools/tools/abuse_report_dashboard_export"
+	"github.com/jburnham/google_apps_tools/tools/access_level_report"
+	"github.com/jburnham/google_apps_tools/tools/activity_report"
+	"github.com/jburnham/google_apps_tools/tools/admin_alert_subscription_manager"
+	"github.com/jburnham/google_apps_tools/tools/admin_console_takeover_prep"
+	"github.com/jburnham/google_apps_tools/tools/admin_sdk_watchdog"
+	"github.com/jburnham/google_apps_tools/tools/audit_2sv_exceptions"
+	"github.com/jburnham/google_apps_tools/tools/calendar_delegation_report"
+	"github.com/jburnham/google_apps_tools/tools/chat_space_sync"
+	"github.com/jburnham/google_apps_tools/tools/chat_spaces_report"
+	"github.com/jburnham/google_apps_tools/tools/chrome_devices_report"
+	"github.com/jburnham/google_apps_tools/tools/cloud_run_server"
+	"github.com/jburnham/google_apps_tools/tools/contact_delegation_report"
+	"github.com/jburnham/google_apps_tools/tools/deleted_users_report"
+	"github.com/jburnham/google_apps_tools/tools/domain_users_photo_report"
+	"github.com/jburnham/google_apps_tools/tools/domain_wide_delegation_inventory"
+	"github.com/jburnham/google_apps_tools/tools/drive_external_sharing_report"
+	"github.com/jburnham/google_apps_tools/tools/drive_labels_report"
+	"github.com/jburnham/google_apps_tools/tools/duplicate_account_detector"
+	"github.com/jburnham/google_apps_tools/tools/email_settings_imap_pop_disable"
+	"github.com/jburnham/google_apps_tools/tools/email_settings_imap_pop_report"
+	"github.com/jburnham/google_apps_tools/tools/endpoint_verification_report"
+	"github.com/jburnham/google_apps_tools/tools/external_domain_relationship_report"
+	"github.com/jburnham/google_apps_tools/tools/gat"
+	"github.com/jburnham/google_apps_tools/tools/gcp_iam_google_group_usage_report"
+	"github.com/jburnham/google_apps_tools/tools/gmail_settings_report"
+	"github.com/jburnham/google_apps_tools/tools/group_description_backfill"
+	"github.com/jburnham/google_apps_tools/tools/group_members_report"
+	"github.com/jburnham/google_apps_tools/tools/group_members_sync"
+	"github.com/jburnham/google_apps_tools/tools/group_membership_expiring_access_report"
+	"github.com/jburnham/google_apps_tools/tools/group_membership_graph_export"
+	"github.com/jburnham/google_apps_tools/tools/group_purge"
+	"github.com/jburnham/google_apps_tools/tools/group_settings_bulk_set"
+	"github.com/jburnham/google_apps_tools/tools/group_settings_report"
+	"github.com/jburnham/google_apps_tools/tools/group_spam_moderation_stats"
+	"github.com/jburnham/google_apps_tools/tools/group_welcome_message_manager"
+	"github.com/jburnham/google_apps_tools/tools/hr_roster_sync"
+	"github.com/jburnham/google_apps_tools/tools/hr_webhook_receiver"
+	"github.com/jburnham/google_apps_tools/tools/inbound_sso_profile_assign"
+	"github.com/jburnham/google_apps_tools/tools/inbound_sso_profile_report"
+	"github.com/jburnham/google_apps_tools/tools/license_auto_assign"
+	"github.com/jburnham/google_apps_tools/tools/license_report"
+	"github.com/jburnham/google_apps_tools/tools/matching_rules"
+	"github.com/jburnham/google_apps_tools/tools/mobile_devices_report"
+	"github.com/jburnham/google_apps_tools/tools/orgunits_report"
+	"github.com/jburnham/google_apps_tools/tools/per_ou_group_report"
+	"github.com/jburnham/google_apps_tools/tools/pronouns_and_profile_field_bulk_update"
+	"github.com/jburnham/google_apps_tools/tools/shared_contacts_sync"
+	"github.com/jburnham/google_apps_tools/tools/shared_drive_report"
+	"github.com/jburnham/google_apps_tools/tools/storage_quota_alerts"
+	"github.com/jburnham/google_apps_tools/tools/takeover_unmanaged_accounts"
+	"github.com/jburnham/google_apps_tools/tools/tasks_and_keep_usage_report"
+	"github.com/jburnham/google_apps_tools/tools/user_creation_date_report"
+	"github.com/jburnham/google_apps_tools/tools/user_language_and_timezone_bulk_set"
+	"github.com/jburnham/google_apps_tools/tools/user_provision"
+	"github.com/jburnham/google_apps_tools/tools/users_report"
+)
+
+// runs are the tools gapps is built with, by name.
+var runs = map[string]func(args []string) error{
+	"abuse_report_dashboard_export":           abusereportdashboardexport.Run,
+	"access_level_report":                     accesslevelreport.Run,
+	"activity_report":                         activityreport.Run,
+	"admin_alert_subscription_manager":        adminalertsubscriptionmanager.Run,
+	"admin_console_takeover_prep":             adminconsoletakeoverprep.Run,
+	"admin_sdk_watchdog":                      adminsdkwatchdog.Run,
+	"audit_2sv_exceptions":                    audit2svexceptions.Run,
+	"calendar_delegation_report":              calendardelegationreport.Run,
+	"chat_space_sync":                         chatspacesync.Run,
+	"chat_spaces_report":                      chatspacesreport.Run,
+	"chrome_devices_report":                   chromedevicesreport.Run,
+	"cloud_run_server":                        cloudrunserver.Run,
+	"contact_delegation_report":               contactdelegationreport.Run,
+	"deleted_users_report":                    deletedusersreport.Run,
+	"domain_users_photo_report":               domainusersphotoreport.Run,
+	"domain_wide_delegation_inventory":        domainwidedelegationinventory.Run,
+	"drive_external_sharing_report":           driveexternalsharingreport.Run,
+	"drive_labels_report":                     drivelabelsreport.Run,
+	"duplicate_account_detector":              duplicateaccountdetector.Run,
+	"email_settings_imap_pop_disable":         emailsettingsimappopdisable.Run,
+	"email_settings_imap_pop_report":          emailsettingsimappopreport.Run,
+	"endpoint_verification_report":            endpointverificationreport.Run,
+	"external_domain_relationship_report":     externaldomainrelationshipreport.Run,
+	"gat":                                     gat.Run,
+	"gcp_iam_google_group_usage_report":       gcpiamgooglegroupusagereport.Run,
+	"gmail_settings_report":                   gmailsettingsreport.Run,
+	"group_description_backfill":              groupdescriptionbackfill.Run,
+	"group_members_report":                    groupmembersreport.Run,
+	"group_members_sync":                      groupmemberssync.Run,
+	"group_membership_expiring_access_report": groupmembershipexpiringaccessreport.Run,
+	"group_membership_graph_export":           groupmembershipgraphexport.Run,
+	"group_purge":                             grouppurge.Run,
+	"group_settings_bulk_set":                 groupsettingsbulkset.Run,
+	"group_settings_report":                   groupsettingsreport.Run,
+	"group_spam_moderation_stats":             groupspammoderationstats.Run,
+	"group_welcome_message_manager":           groupwelcomemessagemanager.Run,
+	"hr_roster_sync":                          hrrostersync.Run,
+	"hr_webhook_receiver":                     hrwebhookreceiver.Run,
+	"inbound_sso_profile_assign":              inboundssoprofileassign.Run,
+	"inbound_sso_profile_report":              inboundssoprofilereport.Run,
+	"license_auto_assign":                     licenseautoassign.Run,
+	"license_report":                          licensereport.Run,
+	"matching_rules":                          matchingrules.Run,
+	"mobile_devices_report":                   mobiledevicesreport.Run,
+	"orgunits_report":                         orgunitsreport.Run,
+	"per_ou_group_report":                     perougroupreport.Run,
+	"pronouns_and_profile_field_bulk_update":  pronounsandprofilefieldbulkupdate.Run,
+	"shared_contacts_sync":                    sharedcontactssync.Run,
+	"shared_drive_report":                     shareddrivereport.Run,
+	"storage_quota_alerts":                    storagequotaalerts.Run,
+	"takeover_unmanaged_accounts":             takeoverunmanagedaccounts.Run,
+	"tasks_and_keep_usage_report":             tasksandkeepusagereport.Run,
+	"user_creation_date_report":               usercreationdatereport.Run,
+	"user_language_and_timezone_bulk_set":     userlanguageandtimezonebulkset.Run,
+	"user_provision":                          userprovision.Run,
+	"users_report":                            usersreport.Run,
+}
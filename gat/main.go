@@ -0,0 +1,21 @@
+// Command gat runs the gat tool; see package gat in tools/gat.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/gat"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := gat.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
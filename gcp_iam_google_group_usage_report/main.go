@@ -0,0 +1,23 @@
+// Command gcp_iam_google_group_usage_report runs the
+// gcp_iam_google_group_usage_report tool; see package
+// gcpiamgooglegroupusagereport in tools/gcp_iam_google_group_usage_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/gcp_iam_google_group_usage_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := gcpiamgooglegroupusagereport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
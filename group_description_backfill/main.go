@@ -0,0 +1,23 @@
+// Command group_description_backfill runs the group_description_backfill
+// tool; see package groupdescriptionbackfill in
+// tools/group_description_backfill.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_description_backfill"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupdescriptionbackfill.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
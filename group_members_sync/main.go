@@ -0,0 +1,22 @@
+// Command group_members_sync runs the group_members_sync tool; see package
+// groupmemberssync in tools/group_members_sync.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_members_sync"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupmemberssync.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
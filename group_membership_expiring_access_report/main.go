@@ -0,0 +1,24 @@
+// Command group_membership_expiring_access_report runs the
+// group_membership_expiring_access_report tool; see package
+// groupmembershipexpiringaccessreport in
+// tools/group_membership_expiring_access_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_membership_expiring_access_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupmembershipexpiringaccessreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
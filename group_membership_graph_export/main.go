@@ -0,0 +1,23 @@
+// Command group_membership_graph_export runs the
+// group_membership_graph_export tool; see package
+// groupmembershipgraphexport in tools/group_membership_graph_export.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_membership_graph_export"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupmembershipgraphexport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command group_purge runs the group_purge tool; see package grouppurge in
+// tools/group_purge.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_purge"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := grouppurge.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
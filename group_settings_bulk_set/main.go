@@ -0,0 +1,22 @@
+// Command group_settings_bulk_set runs the group_settings_bulk_set tool;
+// see package groupsettingsbulkset in tools/group_settings_bulk_set.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_settings_bulk_set"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupsettingsbulkset.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command group_settings_report runs the group_settings_report tool; see
+// package groupsettingsreport in tools/group_settings_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_settings_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupsettingsreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
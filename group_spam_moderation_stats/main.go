@@ -0,0 +1,23 @@
+// Command group_spam_moderation_stats runs the group_spam_moderation_stats
+// tool; see package groupspammoderationstats in
+// tools/group_spam_moderation_stats.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_spam_moderation_stats"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupspammoderationstats.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command group_welcome_message_manager runs the
+// group_welcome_message_manager tool; see package
+// groupwelcomemessagemanager in tools/group_welcome_message_manager.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/group_welcome_message_manager"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := groupwelcomemessagemanager.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
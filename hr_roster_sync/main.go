@@ -0,0 +1,22 @@
+// Command hr_roster_sync runs the hr_roster_sync tool; see package
+// hrrostersync in tools/hr_roster_sync.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/hr_roster_sync"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := hrrostersync.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
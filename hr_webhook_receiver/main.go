@@ -0,0 +1,22 @@
+// Command hr_webhook_receiver runs the hr_webhook_receiver tool; see
+// package hrwebhookreceiver in tools/hr_webhook_receiver.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/hr_webhook_receiver"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := hrwebhookreceiver.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
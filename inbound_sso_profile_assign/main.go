@@ -0,0 +1,23 @@
+// Command inbound_sso_profile_assign runs the inbound_sso_profile_assign
+// tool; see package inboundssoprofileassign in
+// tools/inbound_sso_profile_assign.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/inbound_sso_profile_assign"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := inboundssoprofileassign.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command inbound_sso_profile_report runs the inbound_sso_profile_report
+// tool; see package inboundssoprofilereport in
+// tools/inbound_sso_profile_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/inbound_sso_profile_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := inboundssoprofilereport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
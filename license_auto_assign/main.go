@@ -0,0 +1,22 @@
+// Command license_auto_assign runs the license_auto_assign tool; see
+// package licenseautoassign in tools/license_auto_assign.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/license_auto_assign"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := licenseautoassign.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
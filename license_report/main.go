@@ -0,0 +1,22 @@
+// Command license_report runs the license_report tool; see package
+// licensereport in tools/license_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/license_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := licensereport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
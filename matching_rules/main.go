@@ -0,0 +1,22 @@
+// Command matching_rules runs the matching_rules tool; see package
+// matchingrules in tools/matching_rules.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/matching_rules"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := matchingrules.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
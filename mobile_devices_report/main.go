@@ -0,0 +1,22 @@
+// Command mobile_devices_report runs the mobile_devices_report tool; see
+// package mobiledevicesreport in tools/mobile_devices_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/mobile_devices_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := mobiledevicesreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command orgunits_report runs the orgunits_report tool; see package
+// orgunitsreport in tools/orgunits_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/orgunits_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := orgunitsreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command per_ou_group_report runs the per_ou_group_report tool; see
+// package perougroupreport in tools/per_ou_group_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/per_ou_group_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := perougroupreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
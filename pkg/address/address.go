@@ -0,0 +1,51 @@
+// Package address normalizes email addresses so that the same mailbox
+// written different ways (case, aliases, plus-addressing) compares equal.
+package address
+
+import "strings"
+
+// StripPlus removes a "+tag" suffix from the local part of email.
+func StripPlus(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	if plus := strings.Index(email[:at], "+"); plus >= 0 {
+		return email[:plus] + email[at:]
+	}
+	return email
+}
+
+// Normalizer maps addresses to a canonical form: lowercased, optionally
+// without plus tags, and with known aliases replaced by their primary
+// address. The zero value only lowercases.
+type Normalizer struct {
+	StripPlus bool
+	aliases   map[string]string
+}
+
+// AddAliases records that aliases all belong to primary.
+func (n *Normalizer) AddAliases(primary string, aliases ...string) {
+	if n.aliases == nil {
+		n.aliases = map[string]string{}
+	}
+	primary = strings.ToLower(primary)
+	for _, a := range aliases {
+		n.aliases[strings.ToLower(a)] = primary
+	}
+}
+
+// Normalize returns the canonical form of email.
+func (n *Normalizer) Normalize(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if primary, ok := n.aliases[email]; ok {
+		return primary
+	}
+	if n.StripPlus {
+		email = StripPlus(email)
+		if primary, ok := n.aliases[email]; ok {
+			return primary
+		}
+	}
+	return email
+}
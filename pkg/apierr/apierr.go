@@ -0,0 +1,93 @@
+// Package apierr sorts Google API errors into a few classes, so tools and
+// library callers can branch on what went wrong with errors.Is instead of
+// matching messages:
+//
+//	if errors.Is(err, apierr.ErrNotFound) {
+//		// the group was deleted since it was listed
+//	}
+//
+// pkg/rest and the pkg/directory listings return classified errors. An
+// error straight from a generated client can be classified with Wrap.
+package apierr
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// The error classes.
+var (
+	// ErrQuotaExceeded is a rate limit or quota: slow down, or wait for
+	// the quota to reset.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrForbidden is a missing scope, role or delegation, or a failed
+	// sign-in: retrying won't help until access is granted.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is an object that doesn't exist, or that the caller
+	// can't see.
+	ErrNotFound = errors.New("not found")
+	// ErrTransient is a server-side failure worth retrying.
+	ErrTransient = errors.New("transient error")
+)
+
+// quotaReasons and transientReasons are the error reasons that put a 403
+// in another class than ErrForbidden.
+var (
+	quotaReasons     = []string{"rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded", "dailyLimitExceeded"}
+	transientReasons = []string{"backendError"}
+)
+
+// Error is a *googleapi.Error with its class. errors.Is matches it against
+// the class and errors.As still finds the *googleapi.Error.
+type Error struct {
+	Class error
+	Err   *googleapi.Error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+// Unwrap returns the *googleapi.Error.
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is e's class.
+func (e *Error) Is(target error) bool { return target == e.Class }
+
+// Wrap returns err as an *Error if it is a *googleapi.Error of one of the
+// classes, and err unchanged otherwise.
+func Wrap(err error) error {
+	var e *googleapi.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	if class := classify(e); class != nil {
+		return &Error{Class: class, Err: e}
+	}
+	return err
+}
+
+func classify(e *googleapi.Error) error {
+	switch {
+	case e.Code == http.StatusTooManyRequests || hasReason(e, quotaReasons):
+		return ErrQuotaExceeded
+	case e.Code >= 500 || hasReason(e, transientReasons):
+		return ErrTransient
+	case e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden:
+		return ErrForbidden
+	case e.Code == http.StatusNotFound:
+		return ErrNotFound
+	}
+	return nil
+}
+
+func hasReason(e *googleapi.Error, reasons []string) bool {
+	for _, item := range e.Errors {
+		for _, r := range reasons {
+			if item.Reason == r {
+				return true
+			}
+		}
+	}
+	return false
+}
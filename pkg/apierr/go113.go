@@ -0,0 +1,9 @@
+//go:build !go1.13
+// +build !go1.13
+
+package apierr
+
+// The classes are matched with errors.Is and errors.As and wrapped with %w,
+// which need Go 1.13, so an older compiler stops here with this name in
+// its error rather than at the first wrapped error it misreads.
+var _ = thisPackageNeedsGo1_13OrLater
@@ -0,0 +1,94 @@
+// Package auth builds authorized HTTP clients for the Google APIs used by the
+// tools in this repository.
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+)
+
+// ClientFromFile returns an HTTP client acting as subject, using the service
+// account key in the JSON file at path and domain-wide delegation. path may
+// instead be an IAMPrefix value naming the service account.
+func ClientFromFile(ctx context.Context, path, subject string, scopes ...string) (*http.Client, error) {
+	data, err := ReadCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	return ClientFromJSON(ctx, data, subject, scopes...)
+}
+
+// ClientFromJSON is like ClientFromFile but takes the service account key
+// material, or what ReadCredentials returned, directly.
+func ClientFromJSON(ctx context.Context, data []byte, subject string, scopes ...string) (*http.Client, error) {
+	if account := parseIAMCredentials(data); account != "" {
+		return newClient(ctx, func() oauth2.TokenSource {
+			return newIAMSource(ctx, account, subject, scopes)
+		}), nil
+	}
+	conf, err := google.JWTConfigFromJSON(data, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("can't load Google credentials: %v", err)
+	}
+	conf.Subject = subject
+	return newClient(ctx, func() oauth2.TokenSource {
+		return conf.TokenSource(ctx)
+	}), nil
+}
+
+// newClient returns a client authorized by the tokens of newSource, which
+// drops a token the API rejects and retries as withRetries does.
+func newClient(ctx context.Context, newSource func() oauth2.TokenSource) *http.Client {
+	source := &refreshingSource{ctx: ctx, newSource: newSource}
+	client := oauth2.NewClient(ctx, source)
+	client.Transport = &invalidating{base: client.Transport, source: source}
+	return withRetries(client)
+}
+
+// readFile reads a key file, with an error saying what it was for.
+func readFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read Google credentials file: %v", err)
+	}
+	return data, nil
+}
+
+// withRetries makes client back off and retry calls that hit rate limits,
+// so a long run slows down rather than failing partway.
+func withRetries(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &retry.Transport{Base: base}
+	return client
+}
+
+// Impersonator hands out clients acting as any user in the domain, for tools
+// that read each user's own data (mail settings, contacts, Drive files).
+type Impersonator struct {
+	data   []byte
+	scopes []string
+}
+
+// NewImpersonator loads the service account key at path once for reuse.
+func NewImpersonator(path string, scopes ...string) (*Impersonator, error) {
+	data, err := ReadCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Impersonator{data: data, scopes: scopes}, nil
+}
+
+// Client returns an HTTP client acting as subject.
+func (i *Impersonator) Client(ctx context.Context, subject string) (*http.Client, error) {
+	return ClientFromJSON(ctx, i.data, subject, i.scopes...)
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/oauth2"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+// IAMPrefix marks a -credentials-file value that names a service account
+// instead of a key file: "iam:dwd@project.iam.gserviceaccount.com". The
+// tools then run as the operator's Application Default Credentials and
+// have the IAM Credentials API sign for that account, so no key for it
+// ever needs to exist. The operator needs the Service Account Token
+// Creator role on it.
+const IAMPrefix = "iam:"
+
+// iamCredentialsType is the type of the credentials JSON ReadCredentials
+// makes for an IAMPrefix value, so ClientFromJSON can tell it from a key.
+const iamCredentialsType = "iam_impersonation"
+
+const (
+	iamCredentialsBase = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/"
+	tokenURL           = "https://oauth2.googleapis.com/token"
+)
+
+// iamCredentials is what ReadCredentials returns for an IAMPrefix value.
+type iamCredentials struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+}
+
+// ReadCredentials returns the credentials named by a -credentials-file
+// value: the key file's contents, or for an IAMPrefix value a small JSON
+// document naming the account, which ClientFromJSON understands.
+//
+// With ModeADC there is no key file, so path may only be empty or an
+// IAMPrefix value, and the credentials name that account, ServiceAccount
+// or the Application Default Credentials' own account. With
+// CredentialsSecret or CredentialsEnv set the key comes from there.
+func ReadCredentials(path string) ([]byte, error) {
+	if Mode == ModeADC {
+		if path != "" && !strings.HasPrefix(path, IAMPrefix) {
+			return nil, fmt.Errorf("-auth-mode %s uses no key file, so -credentials-file %s can't be used; give -credentials-file %sNAME@PROJECT.iam.gserviceaccount.com or -service-account to name the account, or drop -auth-mode %s to use the key", ModeADC, path, IAMPrefix, ModeADC)
+		}
+		return adcCredentials(strings.TrimPrefix(path, IAMPrefix))
+	}
+	if CredentialsSecret != "" || CredentialsEnv != "" {
+		return readSource()
+	}
+	if strings.HasPrefix(path, IAMPrefix) {
+		account := strings.TrimPrefix(path, IAMPrefix)
+		if !strings.Contains(account, "@") {
+			return nil, fmt.Errorf("%q doesn't name a service account: use %sNAME@PROJECT.iam.gserviceaccount.com", path, IAMPrefix)
+		}
+		return json.Marshal(&iamCredentials{Type: iamCredentialsType, ClientEmail: account})
+	}
+	return readFile(path)
+}
+
+// parseIAMCredentials returns the service account of data if it came from
+// an IAMPrefix value, or "".
+func parseIAMCredentials(data []byte) string {
+	c := &iamCredentials{}
+	if json.Unmarshal(data, c) != nil || c.Type != iamCredentialsType {
+		return ""
+	}
+	return c.ClientEmail
+}
+
+// iamSource fetches tokens for account through the IAM Credentials API,
+// calling it with the Application Default Credentials.
+type iamSource struct {
+	ctx     context.Context
+	account string
+	subject string
+	scopes  []string
+}
+
+// newIAMSource returns a token source acting as subject through account's
+// domain-wide delegation, or as account itself if subject is "".
+func newIAMSource(ctx context.Context, account, subject string, scopes []string) *iamSource {
+	return &iamSource{ctx: ctx, account: account, subject: subject, scopes: scopes}
+}
+
+func (s *iamSource) Token() (*oauth2.Token, error) {
+	client, err := DefaultClient(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get default credentials to impersonate %s: %v", s.account, err)
+	}
+	if s.subject == "" {
+		return s.accessToken(client)
+	}
+	return s.delegatedToken(client)
+}
+
+// accessToken asks generateAccessToken for a token of the account itself.
+func (s *iamSource) accessToken(client *http.Client) (*oauth2.Token, error) {
+	r := &struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}{}
+	in := map[string]interface{}{"scope": s.scopes, "lifetime": "3600s"}
+	// Asking again for a token creates nothing, so server errors are
+	// retried too.
+	if err := rest.DoIdempotent(s.ctx, client, "POST", iamCredentialsBase+url.PathEscape(s.account)+":generateAccessToken", in, r); err != nil {
+		return nil, fmt.Errorf("can't impersonate %s: %v", s.account, err)
+	}
+	return &oauth2.Token{AccessToken: r.AccessToken, TokenType: "Bearer", Expiry: r.ExpireTime}, nil
+}
+
+// delegatedToken acts as subject. generateAccessToken can't name a
+// subject, so the account signs the same JWT assertion a key would with
+// signJwt, and it is exchanged for a token as usual.
+func (s *iamSource) delegatedToken(client *http.Client) (*oauth2.Token, error) {
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   s.account,
+		"sub":   s.subject,
+		"scope": strings.Join(s.scopes, " "),
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	signed := &struct {
+		SignedJWT string `json:"signedJwt"`
+	}{}
+	in := map[string]string{"payload": string(claims)}
+	if err := rest.DoIdempotent(s.ctx, client, "POST", iamCredentialsBase+url.PathEscape(s.account)+":signJwt", in, signed); err != nil {
+		return nil, fmt.Errorf("can't sign as %s: %v", s.account, err)
+	}
+
+	res, err := ctxhttp.PostForm(s.ctx, http.DefaultClient, tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signed.SignedJWT},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	r := &struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(r)
+	if res.StatusCode != http.StatusOK {
+		return nil, &tokenEndpointError{res.StatusCode, fmt.Sprintf("can't get token for %s as %s: %s: %s: %s", s.subject, s.account, res.Status, r.Error, r.Description)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read token for %s: %v", s.subject, err)
+	}
+	return &oauth2.Token{AccessToken: r.AccessToken, TokenType: r.TokenType,
+		Expiry: now.Add(time.Duration(r.ExpiresIn) * time.Second)}, nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/cloud/compute/metadata"
+)
+
+// The values of -auth-mode.
+const (
+	// ModeKey authenticates with the service account key named by
+	// -credentials-file.
+	ModeKey = "key"
+	// ModeADC authenticates as the Application Default Credentials, such
+	// as GKE workload identity, and gets domain-wide delegation by having
+	// the IAM Credentials API sign for the service account.
+	ModeADC = "adc"
+)
+
+// Mode and ServiceAccount are set by RegisterFlags.
+var (
+	Mode = ModeKey
+	// ServiceAccount is the service account signed for with ModeADC. If
+	// empty it is the one the Application Default Credentials belong to.
+	ServiceAccount string
+)
+
+// RegisterFlags defines -auth-mode, -service-account, -credentials-secret
+// and -credentials-env on fs, setting Mode, ServiceAccount,
+// CredentialsSecret and CredentialsEnv.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&Mode, "auth-mode", Mode, "How to authenticate: key (the -credentials-file service account key) or adc (Application Default Credentials, e.g. workload identity, with no key file).")
+	fs.StringVar(&ServiceAccount, "service-account", ServiceAccount, "With -auth-mode adc, the domain-wide delegation service account to sign for. Defaults to the account the credentials belong to.")
+	fs.StringVar(&CredentialsSecret, "credentials-secret", CredentialsSecret, "Read the service account key from this Secret Manager secret (projects/P/secrets/S) instead of -credentials-file.")
+	fs.StringVar(&CredentialsEnv, "credentials-env", CredentialsEnv, "Read the service account key, as JSON or base64, from this environment variable instead of -credentials-file.")
+}
+
+// CheckMode returns an error if -auth-mode isn't valid or the credentials
+// flags conflict.
+func CheckMode() error {
+	switch Mode {
+	case ModeKey, ModeADC:
+		return checkSource()
+	}
+	return fmt.Errorf("unknown -auth-mode %q: use key or adc", Mode)
+}
+
+// Keyless reports whether the tools authenticate without -credentials-file.
+func Keyless() bool {
+	return Mode == ModeADC || CredentialsSecret != "" || CredentialsEnv != ""
+}
+
+// adcCredentials returns the credentials ReadCredentials gives for
+// ModeADC. account, from an IAMPrefix -credentials-file, overrides the
+// default account.
+func adcCredentials(account string) ([]byte, error) {
+	if ServiceAccount != "" {
+		account = ServiceAccount
+	}
+	if account == "" {
+		var key []byte
+		var err error
+		if account, key, err = defaultAccount(); err != nil {
+			return nil, err
+		}
+		if key != nil {
+			// The default credentials are themselves a key, which can
+			// sign for itself.
+			return key, nil
+		}
+	}
+	return json.Marshal(&iamCredentials{Type: iamCredentialsType, ClientEmail: account})
+}
+
+// defaultAccount finds the service account the Application Default
+// Credentials belong to, looking where google.DefaultTokenSource does. If
+// they are a key file its contents are returned too.
+func defaultAccount() (string, []byte, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), ".config", "gcloud", "application_default_credentials.json")
+		if _, err := os.Stat(path); err != nil {
+			path = ""
+		}
+	}
+	if path == "" {
+		if !metadata.OnGCE() {
+			return "", nil, fmt.Errorf("no Application Default Credentials found; run gcloud auth application-default login and set -service-account to the account to sign for, which user credentials can't name, or point $GOOGLE_APPLICATION_CREDENTIALS at a service account key")
+		}
+		// On GCE, GKE with workload identity, Cloud Run and the like the
+		// metadata server knows the account.
+		email, err := metadata.Get("instance/service-accounts/default/email")
+		if err != nil {
+			return "", nil, fmt.Errorf("can't get the default service account from the metadata server: %v", err)
+		}
+		return strings.TrimSpace(email), nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("can't read Application Default Credentials: %v", err)
+	}
+	c := &struct {
+		Type                           string `json:"type"`
+		ClientEmail                    string `json:"client_email"`
+		ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	}{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return "", nil, fmt.Errorf("can't parse Application Default Credentials %s: %v", path, err)
+	}
+	switch c.Type {
+	case "service_account":
+		return c.ClientEmail, data, nil
+	case "impersonated_service_account":
+		// .../serviceAccounts/NAME@PROJECT.iam.gserviceaccount.com:generateAccessToken
+		u := c.ServiceAccountImpersonationURL
+		u = u[strings.LastIndex(u, "/")+1:]
+		if i := strings.Index(u, ":"); i >= 0 {
+			u = u[:i]
+		}
+		return u, nil, nil
+	}
+	return "", nil, fmt.Errorf("the Application Default Credentials in %s are a %s, not a service account; set -service-account to the one to sign for", path, c.Type)
+}
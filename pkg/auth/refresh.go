@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+// refreshMargin is how long before expiry a token is replaced, so no
+// request goes out with a token that expires while it is in flight.
+const refreshMargin = 5 * time.Minute
+
+// tokenAttempts is how many times fetching a token is tried before the
+// error is passed on.
+const tokenAttempts = 4
+
+// refreshingSource hands out tokens, fetching a new one well before the
+// current one expires. A fetch that fails for a reason that may pass is
+// tried again, each time from a fresh token source, so an invalid_grant
+// (seen on long runs after clock drift or key rotation) re-creates the
+// credentials flow rather than failing every later request. Other
+// failures, such as a malformed key, are returned at once, as is ctx's
+// error once it is done.
+type refreshingSource struct {
+	ctx       context.Context
+	mu        sync.Mutex
+	newSource func() oauth2.TokenSource
+	tok       *oauth2.Token
+}
+
+// invalidate drops tok if it is still the token handed out, so the next
+// Token fetches a new one rather than sending a rejected token again.
+func (s *refreshingSource) invalidate(tok string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok != nil && s.tok.AccessToken == tok {
+		s.tok = nil
+	}
+}
+
+func (s *refreshingSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok != nil && s.tok.Expiry.Sub(time.Now()) > refreshMargin {
+		return s.tok, nil
+	}
+	var err error
+	for attempt := 0; attempt < tokenAttempts; attempt++ {
+		if attempt > 0 {
+			wait := time.Duration(1<<uint(attempt)) * time.Second
+			log.Printf("Fetching token failed, retrying in %s: %v", wait, err)
+			select {
+			case <-time.After(wait):
+			case <-s.ctx.Done():
+				return nil, s.ctx.Err()
+			}
+		}
+		var tok *oauth2.Token
+		if tok, err = s.newSource().Token(); err == nil {
+			s.tok = tok
+			return tok, nil
+		}
+		if !transientTokenError(err) {
+			break
+		}
+	}
+	// A token that hasn't quite expired is still better than nothing.
+	if s.tok != nil && s.tok.Valid() {
+		return s.tok, nil
+	}
+	return nil, err
+}
+
+// invalidating drops the token a request carried when the API rejects it
+// with a 401, so that retry.OnAuthError's next attempt goes out with a new
+// one. It sits under retry.Transport and over the oauth2 transport, which
+// sets the Authorization header.
+type invalidating struct {
+	base   http.RoundTripper
+	source *refreshingSource
+}
+
+func (t *invalidating) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err == nil && res.StatusCode == http.StatusUnauthorized && res.Request != nil {
+		if tok := res.Request.Header.Get("Authorization"); strings.HasPrefix(tok, "Bearer ") {
+			t.source.invalidate(strings.TrimPrefix(tok, "Bearer "))
+		}
+	}
+	return res, err
+}
+
+// tokenEndpointError is a token endpoint's refusal of a signed assertion.
+type tokenEndpointError struct {
+	status  int
+	message string
+}
+
+func (e *tokenEndpointError) Error() string { return e.message }
+
+// fetchStatus finds the status the vendored oauth2 package quotes when the
+// token endpoint refuses a key's assertion, as it reports no typed error:
+// "oauth2: cannot fetch token: 503 Service Unavailable".
+var fetchStatus = regexp.MustCompile(`^oauth2: cannot fetch token: (\d{3}) `)
+
+// transientTokenError reports whether fetching a token failed on the
+// network, on the token endpoint's rate limit or server error, or with an
+// invalid_grant, all of which can pass. The IAM Credentials calls are
+// retried by retry.Transport and aren't retried again here.
+func transientTokenError(err error) bool {
+	var urlErr *url.Error
+	var endpointErr *tokenEndpointError
+	msg := err.Error()
+	switch {
+	case errors.As(err, &endpointErr):
+		return endpointErr.status >= 500 || endpointErr.status == http.StatusTooManyRequests ||
+			strings.Contains(endpointErr.message, "invalid_grant")
+	case errors.As(err, &urlErr):
+		return true
+	case strings.Contains(msg, "invalid_grant"):
+		return true
+	}
+	if m := fetchStatus.FindStringSubmatch(msg); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		return status >= 500 || status == http.StatusTooManyRequests
+	}
+	// A failed connection, quoted as the *url.Error "Post ...: dial tcp ...".
+	return strings.HasPrefix(msg, "oauth2: cannot fetch token: Post ")
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+// CloudPlatformScope is what Secret Manager and Cloud Storage accept from
+// the runtime's own service account.
+const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// DefaultClient returns a client using Application Default Credentials:
+// the attached service account when running on Cloud Run or Cloud
+// Functions, or gcloud's credentials on a workstation. Without scopes it
+// asks for CloudPlatformScope.
+func DefaultClient(ctx context.Context, scopes ...string) (*http.Client, error) {
+	if len(scopes) == 0 {
+		scopes = []string{CloudPlatformScope}
+	}
+	client, err := google.DefaultClient(ctx, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	return withRetries(client), nil
+}
+
+// AccessSecret returns the payload of a Secret Manager secret version.
+// name is "projects/P/secrets/S/versions/V"; a name without a version
+// reads the latest one.
+func AccessSecret(ctx context.Context, client *http.Client, name string) ([]byte, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	r := &struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}{}
+	if err := rest.Get(ctx, client, "https://secretmanager.googleapis.com/v1/"+name+":access", r); err != nil {
+		return nil, fmt.Errorf("can't access secret %s: %v", name, err)
+	}
+	return base64.StdEncoding.DecodeString(r.Payload.Data)
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// CredentialsSecret and CredentialsEnv are set by RegisterFlags. Either
+// replaces -credentials-file, so the service account key is never a
+// plaintext file on disk.
+var (
+	// CredentialsSecret is the Secret Manager secret holding the key,
+	// "projects/P/secrets/S" or a version of it. It is read as the
+	// Application Default Credentials.
+	CredentialsSecret string
+	// CredentialsEnv is the environment variable holding the key, as
+	// JSON or base64-encoded JSON.
+	CredentialsEnv string
+)
+
+// checkSource returns an error if the credentials flags conflict.
+func checkSource() error {
+	switch {
+	case CredentialsSecret != "" && CredentialsEnv != "":
+		return fmt.Errorf("-credentials-secret and -credentials-env can't both be used")
+	case (CredentialsSecret != "" || CredentialsEnv != "") && Mode == ModeADC:
+		return fmt.Errorf("-auth-mode adc uses no key, so -credentials-secret and -credentials-env can't be used with it")
+	case CredentialsSecret != "" && !strings.HasPrefix(CredentialsSecret, "projects/"):
+		return fmt.Errorf("-credentials-secret %q isn't projects/PROJECT/secrets/SECRET", CredentialsSecret)
+	}
+	return nil
+}
+
+// The key read from the secret or environment, kept for the clients built
+// after the first.
+var (
+	sourceMu   sync.Mutex
+	sourceData []byte
+)
+
+// readSource returns the key named by CredentialsSecret or
+// CredentialsEnv.
+func readSource() ([]byte, error) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	if sourceData != nil {
+		return sourceData, nil
+	}
+	var data []byte
+	var err error
+	if CredentialsSecret != "" {
+		data, err = secretCredentials(CredentialsSecret)
+	} else {
+		data, err = envCredentials(CredentialsEnv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sourceData = data
+	return data, nil
+}
+
+func secretCredentials(name string) ([]byte, error) {
+	ctx := context.Background()
+	client, err := DefaultClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't read -credentials-secret: %v", err)
+	}
+	return AccessSecret(ctx, client, name)
+}
+
+// envCredentials reads the key from the environment variable name. Keys
+// are often stored base64-encoded to keep them on one line, so anything
+// not starting with "{" is decoded first.
+func envCredentials(name string) ([]byte, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return nil, fmt.Errorf("-credentials-env %s is empty or not set", name)
+	}
+	if strings.HasPrefix(value, "{") {
+		return []byte(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, fmt.Errorf("-credentials-env %s is neither a JSON key nor base64 of one", name)
+	}
+	return data, nil
+}
@@ -0,0 +1,82 @@
+// Package breaker implements a circuit breaker that ends a run early once
+// API calls are failing too often, e.g. because domain-wide delegation is
+// missing a scope and every call returns 403, and a Budget of retries for
+// the whole run, so that failing calls reach the breaker without each
+// being retried to its limit first.
+package breaker
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Breaker tracks the outcome of calls and trips once the error rate exceeds
+// MaxErrorRate after at least MinCalls calls. It is safe for concurrent use.
+type Breaker struct {
+	MinCalls     int
+	MaxErrorRate float64
+
+	mu       sync.Mutex
+	calls    int
+	failures int
+	last     error
+	tripped  bool
+}
+
+// New returns a Breaker. A maxErrorRate of 0 or less never trips.
+func New(minCalls int, maxErrorRate float64) *Breaker {
+	return &Breaker{MinCalls: minCalls, MaxErrorRate: maxErrorRate}
+}
+
+// TrippedError is returned by Record once the breaker has tripped.
+type TrippedError struct {
+	Calls, Failures int
+	// Last is the most recent failure, usually representative of the rest.
+	Last error
+}
+
+func (e *TrippedError) Error() string {
+	return fmt.Sprintf("aborting: %d of %d calls failed (%.0f%%), last error: %v",
+		e.Failures, e.Calls, float64(e.Failures)*100/float64(e.Calls), e.Last)
+}
+
+// Record notes the outcome of a call. It returns a *TrippedError if the
+// breaker is (or already was) tripped, and nil otherwise.
+func (b *Breaker) Record(err error) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls++
+	if err != nil {
+		b.failures++
+		b.last = err
+	}
+	if b.trippedLocked() {
+		return &TrippedError{Calls: b.calls, Failures: b.failures, Last: b.last}
+	}
+	return nil
+}
+
+// Tripped reports whether the breaker has tripped.
+func (b *Breaker) Tripped() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trippedLocked()
+}
+
+// trippedLocked latches: once tripped, later successes don't reset it.
+func (b *Breaker) trippedLocked() bool {
+	if b.tripped {
+		return true
+	}
+	if b.MaxErrorRate <= 0 || b.calls < b.MinCalls || b.calls == 0 {
+		return false
+	}
+	b.tripped = float64(b.failures)/float64(b.calls) > b.MaxErrorRate
+	return b.tripped
+}
@@ -0,0 +1,45 @@
+package breaker
+
+import "sync"
+
+// Budget caps the retries a whole run may make, so that when most calls
+// are failing the run ends after the breaker's MinCalls rather than after
+// every call has been retried to its own limit. It is safe for concurrent
+// use.
+type Budget struct {
+	// Max is the number of retries allowed; 0 or less allows any number.
+	Max int
+
+	mu    sync.Mutex
+	spent int
+}
+
+// NewBudget returns a Budget of max retries.
+func NewBudget(max int) *Budget {
+	return &Budget{Max: max}
+}
+
+// Spend takes a retry from the budget, reporting false once it is spent.
+// A nil Budget is unlimited.
+func (b *Budget) Spend() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Max > 0 && b.spent >= b.Max {
+		return false
+	}
+	b.spent++
+	return true
+}
+
+// Spent returns the number of retries taken.
+func (b *Budget) Spent() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
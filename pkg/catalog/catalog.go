@@ -0,0 +1,647 @@
+// Package catalog describes every tool for gat reports list: what it does,
+// the OAuth scopes its service account must be granted, roughly how long it
+// takes, and the files it writes. A new tool is added here alongside its
+// README entry.
+package catalog
+
+import (
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/devices"
+	"github.com/jburnham/google_apps_tools/pkg/groupsettings"
+	"github.com/jburnham/google_apps_tools/pkg/inboundsso"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+)
+
+// Kinds of tool.
+const (
+	// KindReport tools only read.
+	KindReport = "report"
+	// KindSync tools change the domain, and take -dry-run or similar.
+	KindSync = "sync"
+	// KindService tools run as long-lived servers.
+	KindService = "service"
+)
+
+// Scopes used only by the tool that declares them, which can't be imported
+// from a main package.
+const (
+	calendarScope            = "https://www.googleapis.com/auth/calendar"
+	cloudPlatformReadonly    = "https://www.googleapis.com/auth/cloud-platform.read-only"
+	calendarACLScope         = "https://www.googleapis.com/auth/calendar.acls.readonly"
+	calendarEventsScope      = "https://www.googleapis.com/auth/calendar.events.readonly"
+	calendarResourceScope    = "https://www.googleapis.com/auth/admin.directory.resource.calendar.readonly"
+	chatMembershipsScope     = "https://www.googleapis.com/auth/chat.admin.memberships.readonly"
+	chatMembershipsEditScope = "https://www.googleapis.com/auth/chat.admin.memberships"
+	chatSpacesScope          = "https://www.googleapis.com/auth/chat.admin.spaces.readonly"
+	contactDelegationScope   = "https://www.googleapis.com/auth/admin.contact.delegation.readonly"
+	contactsScope            = "https://www.google.com/m8/feeds"
+	driveLabelsScope         = "https://www.googleapis.com/auth/drive.admin.labels.readonly"
+	driveMetadataScope       = "https://www.googleapis.com/auth/drive.metadata.readonly"
+	driveReadonlyScope       = "https://www.googleapis.com/auth/drive.readonly"
+	alertsScope              = "https://www.googleapis.com/auth/apps.alerts"
+	gmailReadonlyScope       = "https://www.googleapis.com/auth/gmail.readonly"
+	gmailSendScope           = "https://www.googleapis.com/auth/gmail.send"
+	gmailSettingsScope       = "https://www.googleapis.com/auth/gmail.settings.basic"
+	groupsReadonlyScope      = "https://www.googleapis.com/auth/cloud-identity.groups.readonly"
+	invitationsScope         = "https://www.googleapis.com/auth/cloud-identity.userinvitations"
+	licensingScope           = "https://www.googleapis.com/auth/apps.licensing"
+	otherContactsScope       = "https://www.googleapis.com/auth/contacts.other.readonly"
+	policiesScope            = "https://www.googleapis.com/auth/cloud-identity.policies.readonly"
+	invitationsReadonlyScope = invitationsScope + ".readonly"
+)
+
+// Tool is one command in the repository.
+type Tool struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Summary string `json:"summary"`
+	// Scopes are every scope the tool may request, including those only
+	// needed by optional flags. Domain-wide delegation must grant them all.
+	Scopes []string `json:"scopes"`
+	// Runtime is a rough guide for a domain of a few thousand users.
+	Runtime string    `json:"typical_runtime"`
+	Outputs []*Output `json:"outputs,omitempty"`
+}
+
+// Output is a file a tool writes.
+type Output struct {
+	// Flag names the file, e.g. "output-file". It is empty for output
+	// written to stdout.
+	Flag    string `json:"flag"`
+	Default string `json:"default,omitempty"`
+	// Schema is the stamp in the file's schema_version column, if it is a
+	// report (see package schema).
+	Schema  string   `json:"schema,omitempty"`
+	Columns []string `json:"columns,omitempty"`
+}
+
+// report describes a stamped report file.
+func report(name, flag, file string, columns ...string) *Output {
+	return &Output{Flag: flag, Default: file, Schema: schema.Stamp(name), Columns: columns}
+}
+
+// file describes an unstamped file, such as a rollback record.
+func file(flag, file string, columns ...string) *Output {
+	return &Output{Flag: flag, Default: file, Columns: columns}
+}
+
+var provisionColumns = []string{"action", "email", "given_name", "family_name", "org_unit"}
+
+// Tools are all the tools, in README order.
+var Tools = []*Tool{
+	{
+		Name:    "group_members_report",
+		Kind:    KindReport,
+		Summary: "Every group in one or more domains and its members.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryDomainReadonlyScope, reports.AuditReadonlyScope},
+		Runtime: "1-5 minutes; one call per group, shared among -concurrency workers, or seconds with -group; one call per member with -members-file",
+		Outputs: []*Output{
+			report("group_members_report", "output-file", "report.csv",
+				"domain", "group", "email", "role", "type", "status", "delivery_settings", "via", "added"),
+			report("group_members_report_diff", "diff-file", "membership_changes.csv", "group", "email", "change", "role"),
+		},
+	},
+	{
+		Name:    "users_report",
+		Kind:    KindReport,
+		Summary: "Every user with their OU, status, last login, 2-Step Verification, admin flags and aliases.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope},
+		Runtime: "under a minute per 10,000 users; one call per 500",
+		Outputs: []*Output{report("users_report", "output-file", "users.csv",
+			"email", "name", "org_unit", "suspended", "suspension_reason", "archived", "last_login", "created",
+			"two_step_enrolled", "two_step_enforced", "is_admin", "is_delegated_admin", "aliases")},
+	},
+	{
+		Name:    "orgunits_report",
+		Kind:    KindReport,
+		Summary: "The OU tree, optionally with the active and suspended users in each OU and below it.",
+		Scopes:  []string{admin.AdminDirectoryOrgunitReadonlyScope, admin.AdminDirectoryUserReadonlyScope},
+		Runtime: "seconds, or under a minute per 10,000 users with -count-users",
+		Outputs: []*Output{report("orgunits_report", "output-file", "orgunits.csv",
+			"path", "name", "description", "parent", "block_inheritance",
+			"active_users", "suspended_users", "subtree_active_users", "subtree_suspended_users")},
+	},
+	{
+		Name:    "user_creation_date_report",
+		Kind:    KindReport,
+		Summary: "Users created in a date range, with who created them from the admin audit log.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, reports.AuditReadonlyScope},
+		Runtime: "under a minute per 10,000 users",
+		Outputs: []*Output{report("user_creation_date_report", "output-file", "user_creation_dates.csv",
+			"email", "name", "org_unit", "created", "creator", "creator_type", "suspended")},
+	},
+	{
+		Name:    "hr_webhook_receiver",
+		Kind:    KindService,
+		Summary: "HTTP server turning HR webhooks into Directory user operations, optionally held for approval.",
+		Scopes:  []string{admin.AdminDirectoryUserScope},
+		Runtime: "long-running",
+		Outputs: []*Output{file("queue-file", "approval_queue.json")},
+	},
+	{
+		Name:    "matching_rules",
+		Kind:    KindSync,
+		Summary: "Reconciles group memberships against YAML rules on user attributes.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupMemberScope},
+		Runtime: "1-2 minutes",
+	},
+	{
+		Name:    "storage_quota_alerts",
+		Kind:    KindReport,
+		Summary: "Users above a percentage of their storage quota, optionally emailed a warning.",
+		Scopes:  []string{reports.UsageReadonlyScope, gmailSendScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("storage_quota_alerts", "output-file", "storage_alerts.csv", "email", "used_mb", "quota_mb", "percent_used", "threshold")},
+	},
+	{
+		Name:    "group_membership_graph_export",
+		Kind:    KindReport,
+		Summary: "The group membership graph as Graphviz DOT, GraphML or Neo4j Cypher.",
+		Scopes:  []string{admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryGroupMemberReadonlyScope},
+		Runtime: "5-20 minutes; one call per group, made one after another",
+		Outputs: []*Output{file("output-file", "memberships.<format>")},
+	},
+	{
+		Name:    "gat",
+		Kind:    KindReport,
+		Summary: "Membership queries and what-if simulations from a cached snapshot, group audit history, report conversion and Kubernetes manifests.",
+		Scopes:  []string{admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryGroupMemberReadonlyScope, reports.AuditReadonlyScope},
+		Runtime: "seconds from a snapshot; taking one is like group_membership_graph_export",
+		Outputs: []*Output{
+			file("snapshot", "membership_snapshot.json"),
+			report("gat_whohas", "", "", "member", "via"),
+			report("gat_memberof", "", "", "group", "via"),
+			report("gat_whatif", "", "", "member", "group", "change", "via"),
+			report("gat_group_history", "", "", "time", "actor", "log", "event", "member", "role", "setting", "old_value", "new_value"),
+		},
+	},
+	{
+		// gapps runs the other tools, so it may change the domain through
+		// them, with their scopes.
+		Name:    "gapps",
+		Kind:    KindSync,
+		Summary: "One binary that runs any other tool by the words of its name.",
+		Scopes:  []string{},
+		Runtime: "that of the tool it runs",
+	},
+	{
+		Name:    "domain_users_photo_report",
+		Kind:    KindReport,
+		Summary: "Users with no profile photo, with per-OU totals.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope},
+		Runtime: "1-3 minutes; one call per user",
+		Outputs: []*Output{
+			report("domain_users_photo_report", "output-file", "users_without_photos.csv", "email", "name", "org_unit"),
+			report("domain_users_photo_report_by_ou", "summary-file", "photos_by_ou.csv", "org_unit", "users", "without_photo", "percent_without_photo"),
+		},
+	},
+	{
+		Name:    "deleted_users_report",
+		Kind:    KindReport,
+		Summary: "Recently deleted users, which can be restored in bulk.",
+		Scopes:  []string{admin.AdminDirectoryUserScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("deleted_users_report", "output-file", "deleted_users.csv", "email", "id", "deletion_time", "org_unit")},
+	},
+	{
+		Name:    "group_purge",
+		Kind:    KindSync,
+		Summary: "Deletes groups after exporting each one's settings and members.",
+		Scopes:  []string{admin.AdminDirectoryGroupScope, groupsettings.Scope},
+		Runtime: "seconds per group",
+		Outputs: []*Output{file("export-dir", "")},
+	},
+	{
+		Name:    "contact_delegation_report",
+		Kind:    KindReport,
+		Summary: "Per-user Other contacts counts and contact delegates.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, contactDelegationScope, otherContactsScope},
+		Runtime: "5-20 minutes; calls as every user",
+		Outputs: []*Output{report("contact_delegation_report", "output-file", "contact_delegation.csv", "email", "org_unit", "other_contacts", "delegates", "error")},
+	},
+	{
+		Name:    "duplicate_account_detector",
+		Kind:    KindReport,
+		Summary: "Likely duplicate accounts and, with a roster, active users HR doesn't know.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("duplicate_account_detector", "output-file", "duplicate_accounts.csv", "finding", "email", "related", "detail")},
+	},
+	{
+		Name:    "hr_roster_sync",
+		Kind:    KindReport,
+		Summary: "Compares an HR roster with the directory and writes the actions for user_provision.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{file("output-file", "roster_actions.csv", provisionColumns...)},
+	},
+	{
+		Name:    "user_provision",
+		Kind:    KindSync,
+		Summary: "Applies an actions csv of hires, terminations, transfers and sparse updates.",
+		Scopes:  []string{admin.AdminDirectoryUserScope},
+		Runtime: "about a second per action",
+	},
+	{
+		Name:    "group_description_backfill",
+		Kind:    KindSync,
+		Summary: "Reports groups with empty descriptions, then fills them in.",
+		Scopes:  []string{admin.AdminDirectoryGroupScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("group_description_backfill", "report-file", "groups_without_descriptions.csv", "email", "name", "members")},
+	},
+	{
+		Name:    "admin_console_takeover_prep",
+		Kind:    KindReport,
+		Summary: "Handover checklist for a departing super admin.",
+		Scopes: []string{admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryRolemanagementReadonlyScope, calendarResourceScope,
+			gmailSettingsScope, driveMetadataScope, calendarACLScope},
+		Runtime: "1-2 minutes",
+		Outputs: []*Output{report("admin_console_takeover_prep", "output-file", "takeover_checklist.csv", "category", "item", "detail", "handover")},
+	},
+	{
+		Name:    "cloud_run_server",
+		Kind:    KindService,
+		Summary: "Runs the read-only reports over HTTP for Cloud Run, writing them to Cloud Storage.",
+		Scopes:  []string{auth.CloudPlatformScope},
+		Runtime: "long-running",
+	},
+	{
+		Name:    "audit_2sv_exceptions",
+		Kind:    KindReport,
+		Summary: "Users not enrolled in 2-Step Verification, with why each is exempt.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryOrgunitReadonlyScope, admin.AdminDirectoryGroupReadonlyScope, policiesScope},
+		Runtime: "1-2 minutes",
+		Outputs: []*Output{report("audit_2sv_exceptions", "output-file", "2sv_exceptions.csv", "email", "org_unit", "enforced", "policy", "reason")},
+	},
+	{
+		Name:    "group_settings_bulk_set",
+		Kind:    KindSync,
+		Summary: "Sets Groups Settings attributes across a list of groups, recording the old values.",
+		Scopes:  []string{groupsettings.Scope},
+		Runtime: "about a second per group",
+		Outputs: []*Output{file("rollback-file", "group_settings_rollback.csv", "email", "attribute", "value")},
+	},
+	{
+		Name:    "group_settings_report",
+		Kind:    KindReport,
+		Summary: "Each group's access settings from the Groups Settings API, for access reviews.",
+		Scopes:  []string{admin.AdminDirectoryGroupReadonlyScope, groupsettings.Scope},
+		Runtime: "about a second per group",
+		Outputs: []*Output{report("group_settings_report", "output-file", "group_settings.csv",
+			"domain", "group", "name", "who_can_join", "who_can_view_membership", "who_can_view_group", "who_can_post_message",
+			"who_can_contact_owner", "who_can_leave_group", "allow_external_members", "allow_web_posting",
+			"include_in_global_address_list", "message_moderation_level", "spam_moderation_level", "is_archived", "error")},
+	},
+	{
+		Name:    "group_welcome_message_manager",
+		Kind:    KindSync,
+		Summary: "Audits or standardizes groups' message footers and rejection replies from templates.",
+		Scopes:  []string{admin.AdminDirectoryGroupReadonlyScope, groupsettings.Scope},
+		Runtime: "about a second per group",
+		Outputs: []*Output{
+			report("group_welcome_message_manager", "report-file", "group_welcome_messages.csv", "email", "name", "include_custom_footer",
+				"custom_footer_text", "send_message_deny_notification", "default_message_deny_notification_text", "standard"),
+			file("rollback-file", "welcome_message_rollback.csv", "email", "attribute", "value"),
+		},
+	},
+	{
+		Name:    "domain_wide_delegation_inventory",
+		Kind:    KindReport,
+		Summary: "Service accounts with domain-wide delegation, their scopes and recent use.",
+		Scopes:  []string{auth.CloudPlatformScope, reports.AuditReadonlyScope},
+		Runtime: "1-5 minutes; one audit query per service account",
+		Outputs: []*Output{report("domain_wide_delegation_inventory", "output-file", "domain_wide_delegation.csv",
+			"project", "service_account", "client_id", "disabled", "delegation", "scopes", "granted_by", "granted", "last_used", "impersonated_users", "used_scopes")},
+	},
+	{
+		Name:    "drive_labels_report",
+		Kind:    KindReport,
+		Summary: "The Drive Labels taxonomy and the files carrying each label.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, driveLabelsScope, driveMetadataScope},
+		Runtime: "5-30 minutes; searches every user's Drive",
+		Outputs: []*Output{
+			report("drive_labels_report", "output-file", "labeled_files.csv", "owner", "file_id", "file", "mime_type", "label_id", "label", "field", "value"),
+			report("drive_labels_report_taxonomy", "taxonomy-file", "drive_labels.csv", "label_id", "label", "type", "state", "field_id", "field", "field_type", "choice_id", "choice"),
+		},
+	},
+	{
+		Name:    "endpoint_verification_report",
+		Kind:    KindReport,
+		Summary: "Endpoint Verification devices and their security posture gaps.",
+		Scopes:  []string{devices.ReadonlyScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("endpoint_verification_report", "output-file", "endpoint_verification.csv",
+			"email", "device_id", "device_type", "model", "hostname", "serial_number", "os_version", "encryption", "screen_lock", "management", "compromised", "last_sync", "gaps")},
+	},
+	{
+		Name:    "access_level_report",
+		Kind:    KindReport,
+		Summary: "Which users and devices satisfy each context-aware access level.",
+		Scopes:  []string{auth.CloudPlatformScope, devices.ReadonlyScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("access_level_report", "output-file", "access_levels.csv", "level", "level_name", "email", "device_id", "device_type", "result", "reasons")},
+	},
+	{
+		Name:    "group_spam_moderation_stats",
+		Kind:    KindReport,
+		Summary: "Per-group counts of moderated, approved, rejected and spam messages.",
+		Scopes:  []string{reports.AuditReadonlyScope},
+		Runtime: "1-2 minutes",
+		Outputs: []*Output{report("group_spam_moderation_stats", "output-file", "group_moderation_stats.csv",
+			"group", "moderated", "approved", "rejected", "spam", "other", "bans", "spam_percent")},
+	},
+	{
+		Name:    "user_language_and_timezone_bulk_set",
+		Kind:    KindSync,
+		Summary: "Sets users' language and Calendar timezone, recording the old values.",
+		Scopes:  []string{admin.AdminDirectoryUserScope, calendarScope},
+		Runtime: "about a second per user",
+		Outputs: []*Output{file("rollback-file", "locale_rollback.csv", "email", "setting", "value")},
+	},
+	{
+		Name:    "pronouns_and_profile_field_bulk_update",
+		Kind:    KindSync,
+		Summary: "Sets users' pronouns, job title, department and location from an HR csv, recording the old values.",
+		Scopes:  []string{admin.AdminDirectoryUserScope},
+		Runtime: "about a second per user",
+		Outputs: []*Output{file("rollback-file", "profile_rollback.csv", "email", "field", "value", "set")},
+	},
+	{
+		Name:    "shared_contacts_sync",
+		Kind:    KindSync,
+		Summary: "Syncs Domain Shared Contacts from a csv.",
+		Scopes:  []string{contactsScope},
+		Runtime: "about a second per change",
+	},
+	{
+		Name:    "takeover_unmanaged_accounts",
+		Kind:    KindSync,
+		Summary: "Lists unmanaged consumer accounts using the domain and sends them transfer invitations.",
+		Scopes:  []string{admin.AdminDirectoryCustomerReadonlyScope, invitationsReadonlyScope, invitationsScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("takeover_unmanaged_accounts", "output-file", "unmanaged_accounts.csv",
+			"email", "state", "mails_sent", "last_updated", "days_since_update", "action")},
+	},
+	{
+		Name:    "license_auto_assign",
+		Kind:    KindSync,
+		Summary: "Assigns, changes and removes license SKUs from YAML rules on OU, group membership and user attributes.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupMemberReadonlyScope, licensingScope},
+		Runtime: "1-2 minutes, plus about a second per change",
+	},
+	{
+		Name:    "calendar_delegation_report",
+		Kind:    KindReport,
+		Summary: "Users whose primary calendars grant writer or owner access to others.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, calendarACLScope},
+		Runtime: "5-20 minutes; calls as every user",
+		Outputs: []*Output{report("calendar_delegation_report", "output-file", "calendar_delegation.csv",
+			"email", "org_unit", "delegate", "delegate_type", "delegate_status", "role", "error")},
+	},
+	{
+		Name:    "gcp_iam_google_group_usage_report",
+		Kind:    KindReport,
+		Summary: "IAM bindings on GCP organizations, folders and projects that name Google Groups, with each group's members.",
+		Scopes:  []string{cloudPlatformReadonly, admin.AdminDirectoryGroupMemberReadonlyScope},
+		Runtime: "under a minute; one call per resource and group",
+		Outputs: []*Output{report("gcp_iam_google_group_usage_report", "output-file", "gcp_iam_groups.csv",
+			"resource", "role", "group", "condition", "member_count", "members", "error")},
+	},
+	{
+		Name:    "abuse_report_dashboard_export",
+		Kind:    KindReport,
+		Summary: "A weekly digest of user-reported phishing and spam from the Alert Center, by sender.",
+		Scopes:  []string{alertsScope, gmailReadonlyScope},
+		Runtime: "under a minute; with -check-mailboxes, about a second per reported message",
+		Outputs: []*Output{report("abuse_report_dashboard_export", "output-file", "abuse_reports.csv",
+			"week", "alert_type", "sender", "alerts", "messages", "recipients", "in_inbox", "subjects", "first_reported", "last_reported", "alert_ids")},
+	},
+	{
+		Name:    "admin_alert_subscription_manager",
+		Kind:    KindSync,
+		Summary: "Lists Alert Center's Pub/Sub notifications and sets them from a YAML file, to repeat one tenant's setup in another.",
+		Scopes:  []string{alertsScope},
+		Runtime: "seconds",
+		Outputs: []*Output{report("admin_alert_subscription_manager", "output-file", "alert_subscriptions.csv", "topic", "payload_format", "change"),
+			file("export-file", "")},
+	},
+	{
+		Name:    "per_ou_group_report",
+		Kind:    KindReport,
+		Summary: "How many of each group's members are in each OU.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope},
+		Runtime: "1-5 minutes; one call per group",
+		Outputs: []*Output{report("per_ou_group_report", "output-file", "groups_by_ou.csv", "group", "org_unit", "members", "percent", "group_members")},
+	},
+	{
+		Name:    "email_settings_imap_pop_report",
+		Kind:    KindReport,
+		Summary: "Which users have IMAP or POP enabled in Gmail, with per-OU totals.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, gmailSettingsScope},
+		Runtime: "5-20 minutes; calls as every user",
+		Outputs: []*Output{
+			report("email_settings_imap_pop_report", "output-file", "imap_pop.csv", "email", "org_unit", "imap_enabled", "pop_enabled", "pop_access_window", "error"),
+			report("email_settings_imap_pop_report_by_ou", "summary-file", "imap_pop_by_ou.csv",
+				"org_unit", "users", "imap_enabled", "pop_enabled", "either_enabled", "percent_either_enabled", "errors"),
+		},
+	},
+	{
+		Name:    "email_settings_imap_pop_disable",
+		Kind:    KindSync,
+		Summary: "Turns off IMAP and/or POP for a list of users, a domain or an OU, recording the old settings so they can be restored.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, gmailSettingsScope},
+		Runtime: "about a second per user",
+		Outputs: []*Output{file("rollback-file", "imap_pop_rollback.csv", "email", "setting", "value")},
+	},
+	{
+		Name:    "inbound_sso_profile_report",
+		Kind:    KindReport,
+		Summary: "Inbound SAML and OIDC SSO profiles and the OUs and groups assigned to each.",
+		Scopes: []string{inboundsso.ReadonlyScope, admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryOrgunitReadonlyScope,
+			admin.AdminDirectoryGroupReadonlyScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{
+			report("inbound_sso_profile_report", "output-file", "inbound_sso_profiles.csv", "profile", "type", "display_name",
+				"idp_entity_id", "sso_url", "sign_out_url", "change_password_url", "sp_entity_id", "acs_url", "oidc_issuer", "oidc_client_id", "assignments"),
+			report("inbound_sso_profile_report_assignments", "assignments-file", "inbound_sso_assignments.csv",
+				"target_type", "target", "rank", "sso_mode", "profile", "profile_name", "redirect_condition"),
+		},
+	},
+	{
+		Name:    "inbound_sso_profile_assign",
+		Kind:    KindSync,
+		Summary: "Assigns and unassigns inbound SSO profiles to OUs and groups from a YAML file.",
+		Scopes: []string{inboundsso.Scope, admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryOrgunitReadonlyScope,
+			admin.AdminDirectoryGroupReadonlyScope},
+		Runtime: "a few seconds per assignment",
+		Outputs: []*Output{file("rollback-file", "sso_assignment_rollback.yaml")},
+	},
+	{
+		Name:    "group_membership_expiring_access_report",
+		Kind:    KindReport,
+		Summary: "Group memberships of users with a contract end date that outlive it.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, groupsReadonlyScope},
+		Runtime: "1-5 minutes; one call per user with an end date",
+		Outputs: []*Output{report("group_membership_expiring_access_report", "output-file", "expiring_access.csv",
+			"email", "name", "org_unit", "suspended", "end_date", "group", "group_name", "role",
+			"membership_expires", "outlives_end_date", "days_past_end_date")},
+	},
+	{
+		Name:    "shared_drive_report",
+		Kind:    KindReport,
+		Summary: "Every Shared Drive with its organizers, members and sharing restrictions.",
+		Scopes:  []string{driveReadonlyScope, admin.AdminDirectoryDomainReadonlyScope},
+		Runtime: "1-10 minutes; one call per Shared Drive",
+		Outputs: []*Output{
+			report("shared_drive_report", "output-file", "shared_drives.csv",
+				"drive_id", "name", "created", "hidden", "organizers", "members", "external_members",
+				"admin_managed_restrictions", "copy_requires_writer_permission", "domain_users_only",
+				"drive_members_only", "sharing_folders_requires_organizer_permission", "error"),
+			report("shared_drive_report_permissions", "permissions-file", "shared_drive_permissions.csv",
+				"drive_id", "drive", "member", "type", "role", "display_name", "external", "deleted"),
+		},
+	},
+	{
+		Name:    "drive_external_sharing_report",
+		Kind:    KindReport,
+		Summary: "Files users own that are shared outside the organization or with anyone with the link.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryDomainReadonlyScope, driveMetadataScope},
+		Runtime: "hours for a large domain; one listing of every file per user, shared among -concurrency workers",
+		Outputs: []*Output{report("drive_external_sharing_report", "output-file", "external_sharing.csv",
+			"owner", "file_id", "file", "mime_type", "link", "permission_type", "role", "shared_with", "discoverable")},
+	},
+	{
+		Name:    "gmail_settings_report",
+		Kind:    KindReport,
+		Summary: "Each user's mail delegates, auto-forwarding, forwarding filters and send-as aliases, flagging those outside the organization.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryDomainReadonlyScope, gmailSettingsScope},
+		Runtime: "hours for a large domain; four Gmail calls per user, shared among -concurrency workers",
+		Outputs: []*Output{report("gmail_settings_report", "output-file", "gmail_settings.csv",
+			"email", "org_unit", "setting", "address", "detail", "external", "error")},
+	},
+	{
+		Name:    "chat_spaces_report",
+		Kind:    KindReport,
+		Summary: "Every Chat space with its settings and managers, and a second report of each space's members.",
+		Scopes: []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope,
+			chatSpacesScope, chatMembershipsScope},
+		Runtime: "minutes; one member listing per space",
+		Outputs: []*Output{
+			report("chat_spaces_report", "output-file", "chat_spaces.csv",
+				"space", "display_name", "created", "last_active", "access", "history", "external_users_allowed",
+				"managers", "members", "groups", "unknown_members", "error"),
+			report("chat_spaces_report_members", "members-file", "chat_space_members.csv",
+				"space", "display_name", "member", "type", "role", "state", "joined", "known"),
+		},
+	},
+	{
+		Name:    "chat_space_sync",
+		Kind:    KindSync,
+		Summary: "Mirrors a group's members into a Chat space, and optionally the space's members back into the group.",
+		Scopes: []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupMemberScope,
+			chatMembershipsEditScope},
+		Runtime: "under a minute, plus about a second per change",
+	},
+	{
+		Name:    "license_report",
+		Kind:    KindReport,
+		Summary: "License assignments per SKU, flagging those held by suspended, never-signed-in or inactive users.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, licensingScope},
+		Runtime: "1-2 minutes",
+		Outputs: []*Output{
+			report("license_report", "output-file", "licenses.csv",
+				"email", "product", "product_name", "sku", "sku_name", "org_unit", "suspended", "created", "last_login", "flag"),
+			report("license_report_by_sku", "summary-file", "licenses_by_sku.csv",
+				"product", "product_name", "sku", "sku_name", "assigned", "suspended", "never_logged_in", "inactive",
+				"not_in_directory", "flagged"),
+		},
+	},
+	{
+		Name:    "mobile_devices_report",
+		Kind:    KindReport,
+		Summary: "Inventory of mobile devices with their model, OS, status, users and sync times.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryDeviceMobileReadonlyScope},
+		Runtime: "1-2 minutes",
+		Outputs: []*Output{report("mobile_devices_report", "output-file", "mobile_devices.csv",
+			"device_id", "type", "model", "os", "build_number", "serial_number", "imei", "status", "compromised",
+			"user", "name", "org_unit", "first_sync", "last_sync", "user_agent")},
+	},
+	{
+		Name:    "chrome_devices_report",
+		Kind:    KindReport,
+		Summary: "Inventory of Chrome OS devices with their model, OS, status, OU, users and enrollment.",
+		Scopes:  []string{admin.AdminDirectoryDeviceChromeosReadonlyScope},
+		Runtime: "under a minute",
+		Outputs: []*Output{report("chrome_devices_report", "output-file", "chrome_devices.csv",
+			"device_id", "serial_number", "model", "os_version", "platform_version", "firmware_version", "status",
+			"org_unit", "annotated_user", "recent_user", "asset_id", "location", "last_sync", "last_enrollment",
+			"support_end_date", "boot_mode", "mac_address")},
+	},
+	{
+		Name:    "tasks_and_keep_usage_report",
+		Kind:    KindReport,
+		Summary: "Each user's use of Keep and Tasks from the audit logs, with the share of each OU using them.",
+		Scopes:  []string{admin.AdminDirectoryUserReadonlyScope, reports.AuditReadonlyScope},
+		Runtime: "minutes; grows with the -last range and how much Keep and Tasks are used",
+		Outputs: []*Output{
+			report("tasks_and_keep_usage_report", "output-file", "tasks_and_keep_usage.csv",
+				"email", "org_unit", "keep_events", "keep_last_used", "tasks_events", "tasks_last_used"),
+			report("tasks_and_keep_usage_report_by_ou", "summary-file", "tasks_and_keep_usage_by_ou.csv",
+				"org_unit", "users", "keep_users", "keep_percent", "tasks_users", "tasks_percent"),
+		},
+	},
+	{
+		Name:    "activity_report",
+		Kind:    KindReport,
+		Summary: "Exports one audit log (logins, admin console changes, token grants, ...) over a date range.",
+		Scopes:  []string{reports.AuditReadonlyScope},
+		Runtime: "seconds to minutes, depending on the log and the -last range",
+		Outputs: []*Output{report("activity_report", "output-file", "activity.csv",
+			"time", "application", "actor", "actor_type", "ip_address", "event_type", "event", "parameters")},
+	},
+	{
+		Name:    "group_members_sync",
+		Kind:    KindSync,
+		Summary: "Converges the members and roles of groups to a csv or YAML manifest.",
+		Scopes:  []string{admin.AdminDirectoryGroupMemberScope},
+		Runtime: "a few seconds per group, plus about a second per change",
+	},
+	{
+		Name:    "admin_sdk_watchdog",
+		Kind:    KindReport,
+		Summary: "Samples Directory API latency and errors over a window and alerts when Google looks throttled or degraded.",
+		Scopes: []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope,
+			admin.AdminDirectoryOrgunitReadonlyScope, admin.AdminDirectoryDomainReadonlyScope},
+		Runtime: "the -window, 5 minutes by default",
+		Outputs: []*Output{report("admin_sdk_watchdog", "output-file", "admin_sdk_samples.csv",
+			"time", "probe", "latency_ms", "result", "error")},
+	},
+	{
+		Name:    "external_domain_relationship_report",
+		Kind:    KindReport,
+		Summary: "External domains ranked by how many group members, Drive shares and meeting guests tie us to them.",
+		Scopes: []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope,
+			admin.AdminDirectoryDomainReadonlyScope, driveMetadataScope, calendarEventsScope},
+		Runtime: "hours for a large domain; every group's members, then each user's files and meetings shared among -concurrency workers",
+		Outputs: []*Output{
+			report("external_domain_relationship_report", "output-file", "external_domains.csv",
+				"domain", "exposure", "group_members", "drive_shares", "calendar_invites", "external_addresses", "internal_parties"),
+			report("external_domain_relationship_report_details", "details-file", "",
+				"domain", "source", "internal", "external", "item"),
+		},
+	},
+}
+
+// Lookup returns the named tool, or nil.
+func Lookup(name string) *Tool {
+	for _, t := range Tools {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"flag"
+	"log"
+)
+
+// alias is an old name a flag is still accepted under.
+type alias struct {
+	old, name, since string
+}
+
+// aliases are the deprecated names registered on each flag set.
+var aliases = map[*flag.FlagSet][]*alias{}
+
+// Alias makes old a deprecated name for fs's flag name, for a flag that has
+// been renamed, usually to match the other tools as they are folded into
+// gat. Parse sets name when old is given and logs a warning saying since
+// when old has been deprecated. An alias is kept for at least two releases
+// after since, so cron jobs and scripts using the old name have time to
+// move; it isn't listed by -h.
+//
+// Alias must be called before Parse, and name must already be defined.
+func Alias(fs *flag.FlagSet, old, name, since string) {
+	if fs.Lookup(name) == nil {
+		panic("config: no flag -" + name)
+	}
+	if fs.Lookup(old) != nil {
+		panic("config: -" + old + " is still a flag")
+	}
+	aliases[fs] = append(aliases[fs], &alias{old: old, name: name, since: since})
+}
+
+// defineAliases adds fs's aliases to the shadow set Parse parses with.
+func defineAliases(fs, shadow *flag.FlagSet) {
+	for _, a := range aliases[fs] {
+		f := fs.Lookup(a.name)
+		shadow.Var(f.Value, a.old, "Deprecated: use -"+a.name+".")
+	}
+}
+
+// checkAliases warns about each deprecated name used, and records a
+// problem if a flag was also given under its current name, since which
+// one wins would depend on their order. It returns the flags given, by
+// their current names.
+func (c *Checker) checkAliases(shadow *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	shadow.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, a := range aliases[c.fs] {
+		if !set[a.old] {
+			continue
+		}
+		if set[a.name] {
+			c.Problemf("-%s and -%s are the same flag; give only -%s", a.old, a.name, a.name)
+			continue
+		}
+		log.Printf("Warning: -%s is deprecated since %s and will be removed; use -%s", a.old, a.since, a.name)
+		set[a.name] = true
+	}
+	return set
+}
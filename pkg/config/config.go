@@ -0,0 +1,214 @@
+// Package config checks a tool's flags before it starts work. Parse keeps
+// going past unknown flags and bad values, and the tool adds its own checks
+// (required flags, formats, combinations) to the same Checker, so a run
+// with several mistakes reports them all at once rather than one per
+// attempt.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+)
+
+// Checker collects the problems with a tool's flags.
+type Checker struct {
+	fs       *flag.FlagSet
+	problems []string
+}
+
+// Parse parses args into fs like fs.Parse, but records errors in the
+// returned Checker instead of stopping at the first. -h and -help print
+// fs's usage and exit. It first adds the flags every tool shares, which
+// tune how API calls are retried (see package retry) and how the tool
+// authenticates (see package auth), and -config. Then the shared flags
+// not given are filled in from the environment or a -config profile.
+func Parse(fs *flag.FlagSet, args []string) *Checker {
+	c := &Checker{fs: fs}
+	if fs.Lookup("config") == nil {
+		fs.String("config", os.Getenv("GAT_CONFIG"), "The profile in the config file ($GAT_CONFIG_FILE or ~/.config/google_apps_tools/config.yaml) giving the credentials, impersonated email and domain not given as flags. Defaults to the file's default profile.")
+	}
+	if fs.Lookup("max-retries") == nil {
+		retry.RegisterFlags(fs)
+	}
+	if fs.Lookup("auth-mode") == nil {
+		auth.RegisterFlags(fs)
+	}
+	// fs may exit on the first error, so parsing is done by a shadow set
+	// sharing fs's values.
+	shadow := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	shadow.SetOutput(ioutil.Discard)
+	fs.VisitAll(func(f *flag.Flag) {
+		shadow.Var(f.Value, f.Name, f.Usage)
+	})
+	defineAliases(fs, shadow)
+	for {
+		err := shadow.Parse(args)
+		if err == nil {
+			break
+		}
+		if err == flag.ErrHelp {
+			usage(fs)
+			os.Exit(0)
+		}
+		c.parseProblem(err)
+		// The flag package has consumed the offending argument, so parsing
+		// resumes after it. An unknown flag's value can't be told from a
+		// positional argument, so one that looks like a value is skipped
+		// too rather than ending the parse.
+		consumed := args[len(args)-len(shadow.Args())-1]
+		args = shadow.Args()
+		if strings.HasPrefix(err.Error(), undefinedPrefix) && !strings.Contains(consumed, "=") &&
+			len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	// Mark fs parsed, leaving the positional arguments in fs.Args().
+	fs.Parse(append([]string{"--"}, shadow.Args()...))
+	given := c.checkAliases(shadow)
+	c.applyDefaults(given, fs.Lookup("config").Value.String())
+	c.Check(auth.CheckMode())
+	if f := fs.Lookup("credentials-file"); f != nil && f.Value.String() != "" && (auth.CredentialsSecret != "" || auth.CredentialsEnv != "") {
+		c.Problemf("-credentials-file can't be used with -credentials-secret or -credentials-env")
+	}
+	return c
+}
+
+func usage(fs *flag.FlagSet) {
+	switch {
+	case fs == flag.CommandLine:
+		flag.Usage()
+	case fs.Usage != nil:
+		fs.Usage()
+	default:
+		fs.PrintDefaults()
+	}
+}
+
+const undefinedPrefix = "flag provided but not defined: -"
+
+func (c *Checker) parseProblem(err error) {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, undefinedPrefix) {
+		c.problems = append(c.problems, msg)
+		return
+	}
+	name := strings.TrimPrefix(msg, undefinedPrefix)
+	if s := c.suggest(name); s != "" {
+		c.Problemf("unknown flag -%s; did you mean -%s?", name, s)
+		return
+	}
+	c.Problemf("unknown flag -%s", name)
+}
+
+// suggest returns the defined flag closest to the misspelt name, or "" if
+// none is close enough to be a likely typo.
+func (c *Checker) suggest(name string) string {
+	best, bestDistance := "", len(name)/3+1
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if d := distance(name, f.Name); d <= bestDistance {
+			best, bestDistance = f.Name, d
+		}
+		// A shortened name ("-credentials" for "-credentials-file") is
+		// as likely a mistake as a typo.
+		if best == "" && len(name) >= 3 && strings.HasPrefix(f.Name, name) {
+			best = f.Name
+		}
+	})
+	return best
+}
+
+// distance is the Levenshtein edit distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Required records a problem for each named flag left empty, quoting the
+// flag's help so the message says what to pass.
+func (c *Checker) Required(names ...string) {
+	for _, name := range names {
+		f := c.fs.Lookup(name)
+		if f == nil {
+			panic("config: no flag -" + name)
+		}
+		// Keyless authentication has no key file to name.
+		if name == "credentials-file" && auth.Keyless() {
+			continue
+		}
+		if f.Value.String() == "" {
+			c.Problemf("-%s is required: %s", name, f.Usage)
+		}
+	}
+}
+
+// RequireOne records a problem if all the named flags are empty.
+func (c *Checker) RequireOne(names ...string) {
+	for _, name := range names {
+		if f := c.fs.Lookup(name); f != nil && f.Value.String() != "" {
+			return
+		}
+	}
+	c.Problemf("one of -%s is required", strings.Join(names, " or -"))
+}
+
+// Check records err, if it isn't nil.
+func (c *Checker) Check(err error) {
+	if err != nil {
+		c.problems = append(c.problems, err.Error())
+	}
+}
+
+// Problemf records a problem.
+func (c *Checker) Problemf(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// Done prints the problems and exits if there are any.
+func (c *Checker) Done() {
+	if len(c.problems) == 0 {
+		return
+	}
+	name := filepath.Base(c.fs.Name())
+	noun := "problem"
+	if len(c.problems) > 1 {
+		noun = "problems"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d %s with the flags:\n", name, len(c.problems), noun)
+	for _, p := range c.problems {
+		fmt.Fprintf(os.Stderr, "  %s\n", p)
+	}
+	fmt.Fprintf(os.Stderr, "Run %q for the full list of flags.\n", name+" -h")
+	os.Exit(2)
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The flags given on every run, which can be given once in the config file
+// or the environment instead. A flag given on the command line wins over
+// the environment, which wins over the config file.
+var sharedFlags = []string{
+	"credentials-file", "credentials-secret", "credentials-env", "auth-mode", "service-account",
+	"impersonated-email", "domain",
+}
+
+// credentialFlags together say how to authenticate, so they are taken from
+// one place: a key file from the config file isn't added to a
+// -credentials-secret given on the command line.
+var credentialFlags = map[string]bool{
+	"credentials-file": true, "credentials-secret": true, "credentials-env": true,
+	"auth-mode": true, "service-account": true,
+}
+
+// domainSelectors choose what a tool works on in place of -domain, and many
+// tools refuse them with it, so a default -domain isn't applied when one of
+// them is given.
+var domainSelectors = []string{"all-domains", "group", "members-file", "users", "users-file", "restore-file"}
+
+// configFile is where the profiles are read from: $GAT_CONFIG_FILE, or
+// ~/.config/google_apps_tools/config.yaml.
+func configFile() string {
+	if path := os.Getenv("GAT_CONFIG_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "google_apps_tools", "config.yaml")
+}
+
+// profiles is the config file: sets of shared flags by name, for example
+// one per domain, and the one used when -config isn't given.
+//
+//	default: example
+//	profiles:
+//	  example:
+//	    credentials-file: /etc/gat/example.json
+//	    impersonated-email: admin@example.com
+//	    domain: example.com
+type profiles struct {
+	Default  string                       `yaml:"default"`
+	Profiles map[string]map[string]string `yaml:"profiles"`
+}
+
+// envName is the environment variable giving flag name, e.g.
+// GAT_IMPERSONATED_EMAIL for -impersonated-email.
+func envName(name string) string {
+	return "GAT_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// profile returns the shared flags of the named profile, or of the file's
+// default profile if name is empty. With no name and no config file there
+// is no profile and no error.
+func profile(name string) (map[string]string, error) {
+	path := configFile()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && name == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read the config file for -config %s: %v", name, err)
+	}
+	p := &profiles{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("config file %s: %v", path, err)
+	}
+	if name == "" {
+		name = p.Default
+	}
+	if name == "" {
+		return nil, nil
+	}
+	values, ok := p.Profiles[name]
+	if !ok {
+		names := []string{}
+		for n := range p.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("config file %s has no profile %q; it has %s", path, name, strings.Join(names, ", "))
+	}
+	shared := map[string]bool{}
+	for _, f := range sharedFlags {
+		shared[f] = true
+	}
+	for key := range values {
+		if !shared[key] {
+			return nil, fmt.Errorf("config file %s: profile %s sets %s, but only %s can be set there", path, name, key, strings.Join(sharedFlags, ", "))
+		}
+	}
+	return values, nil
+}
+
+// applyDefaults sets the shared flags of fs that weren't given on the
+// command line from the environment or the -config profile. given holds
+// the flags that were. A shared flag fs doesn't define is skipped, so one
+// profile serves every tool.
+func (c *Checker) applyDefaults(given map[string]bool, name string) {
+	values, err := profile(name)
+	if err != nil {
+		c.Check(err)
+		return
+	}
+	env := map[string]string{}
+	for _, f := range sharedFlags {
+		if v := os.Getenv(envName(f)); v != "" && c.fs.Lookup(f) != nil {
+			env[f] = v
+		}
+	}
+	// Where the credential flags come from: the first of the command line,
+	// the environment and the profile that gives any of them.
+	source := ""
+	for f := range credentialFlags {
+		if given[f] && c.fs.Lookup(f) != nil {
+			source = "flags"
+		}
+	}
+	for f := range credentialFlags {
+		if source == "" && env[f] != "" {
+			source = "env"
+		}
+	}
+	for _, f := range sharedFlags {
+		if given[f] || c.fs.Lookup(f) == nil {
+			continue
+		}
+		if f == "domain" && anyGiven(given, domainSelectors) {
+			continue
+		}
+		value, from := env[f], "$"+envName(f)
+		if value == "" && (!credentialFlags[f] || source == "") {
+			value, from = values[f], "the config file"
+		}
+		if value == "" || credentialFlags[f] && source == "flags" {
+			continue
+		}
+		if err := c.fs.Set(f, value); err != nil {
+			c.Problemf("invalid value %q for -%s from %s: %v", value, f, from, err)
+		}
+	}
+}
+
+func anyGiven(given map[string]bool, names []string) bool {
+	for _, name := range names {
+		if given[name] {
+			return true
+		}
+	}
+	return false
+}
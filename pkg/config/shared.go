@@ -0,0 +1,37 @@
+package config
+
+import (
+	"flag"
+	"os"
+)
+
+// CredentialsFile defines -credentials-file on fs, with the help every
+// tool gives it.
+func CredentialsFile(fs *flag.FlagSet) *string {
+	return fs.String("credentials-file", "", "The json file from Google that contains the service account private material.")
+}
+
+// ImpersonatedEmail defines -impersonated-email on fs, with the help every
+// tool gives it.
+func ImpersonatedEmail(fs *flag.FlagSet) *string {
+	return fs.String("impersonated-email", "", "The admin user email to impersonate for access.")
+}
+
+// RegisterShared defines the shared flags and -config on fs, for a command
+// such as gapps that runs the tools and takes them once, before naming the
+// tool. The returned func, called after fs is parsed, passes the ones
+// given on to the tool run next through the environment, so that the
+// tool's own flags still win and a tool without one of them ignores it.
+func RegisterShared(fs *flag.FlagSet) func() {
+	values := map[string]*string{}
+	for _, name := range append([]string{"config"}, sharedFlags...) {
+		values[name] = fs.String(name, "", "Passed to the tool as -"+name+" unless it gives its own.")
+	}
+	return func() {
+		for name, value := range values {
+			if *value != "" {
+				os.Setenv(envName(name), *value)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package devices is a client for the Cloud Identity Devices API, which
+// holds the endpoints (Endpoint Verification desktops, managed mobiles) that
+// context-aware access decisions are based on.
+package devices
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const basePath = "https://cloudidentity.googleapis.com/v1/"
+
+// ReadonlyScope is the OAuth2 scope for reading devices.
+const ReadonlyScope = "https://www.googleapis.com/auth/cloud-identity.devices.readonly"
+
+// Device is one endpoint known to the organization.
+type Device struct {
+	// Name is "devices/<id>".
+	Name            string `json:"name"`
+	DeviceType      string `json:"deviceType"`
+	OwnerType       string `json:"ownerType"`
+	Model           string `json:"model"`
+	Manufacturer    string `json:"manufacturer"`
+	Hostname        string `json:"hostname"`
+	SerialNumber    string `json:"serialNumber"`
+	OSVersion       string `json:"osVersion"`
+	EncryptionState string `json:"encryptionState"`
+	ManagementState string `json:"managementState"`
+	// CompromisedState is "COMPROMISED" for rooted or jailbroken mobiles.
+	CompromisedState string `json:"compromisedState"`
+	CreateTime       string `json:"createTime"`
+	LastSyncTime     string `json:"lastSyncTime"`
+	// EndpointVerification is set on devices reporting through the
+	// Endpoint Verification extension.
+	EndpointVerification *struct {
+		BrowserAttributes []struct {
+			ChromeBrowserInfo struct {
+				BrowserVersion string `json:"browserVersion"`
+			} `json:"chromeBrowserInfo"`
+		} `json:"browserAttributes"`
+	} `json:"endpointVerificationSpecificAttributes"`
+}
+
+// ID returns the device ID from the resource name.
+func (d *Device) ID() string {
+	return strings.TrimPrefix(d.Name, "devices/")
+}
+
+// User is a user signed in on a device.
+type User struct {
+	// Name is "devices/<device id>/deviceUsers/<id>".
+	Name            string `json:"name"`
+	UserEmail       string `json:"userEmail"`
+	ManagementState string `json:"managementState"`
+	// PasswordState is "PASSWORD_SET" if the device has a screen lock.
+	PasswordState    string `json:"passwordState"`
+	CompromisedState string `json:"compromisedState"`
+	LastSyncTime     string `json:"lastSyncTime"`
+}
+
+// Device returns the resource name of the user's device.
+func (u *User) Device() string {
+	if i := strings.Index(u.Name, "/deviceUsers/"); i >= 0 {
+		return u.Name[:i]
+	}
+	return ""
+}
+
+// List returns the customer's user-assigned devices.
+func List(ctx context.Context, client *http.Client) ([]*Device, error) {
+	all := []*Device{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Devices       []*Device `json:"devices"`
+			NextPageToken string    `json:"nextPageToken"`
+		}{}
+		params := url.Values{
+			"customer":  {"customers/my_customer"},
+			"pageSize":  {"100"},
+			"pageToken": {pageToken},
+		}
+		if err := rest.Get(ctx, client, rest.URL(basePath, "devices", params), r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Devices...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ListUsers returns the users of every device, keyed by the device's
+// resource name.
+func ListUsers(ctx context.Context, client *http.Client) (map[string][]*User, error) {
+	all := map[string][]*User{}
+	pageToken := ""
+	for {
+		r := &struct {
+			DeviceUsers   []*User `json:"deviceUsers"`
+			NextPageToken string  `json:"nextPageToken"`
+		}{}
+		params := url.Values{
+			"customer":  {"customers/my_customer"},
+			"pageSize":  {"20"},
+			"pageToken": {pageToken},
+		}
+		// "devices/-" lists the users of all devices at once.
+		if err := rest.Get(ctx, client, rest.URL(basePath, "devices/-/deviceUsers", params), r); err != nil {
+			return nil, err
+		}
+		for _, u := range r.DeviceUsers {
+			all[u.Device()] = append(all[u.Device()], u)
+		}
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
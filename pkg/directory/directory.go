@@ -0,0 +1,545 @@
+// Package directory holds helpers for the Admin SDK Directory API that are
+// shared between tools: paginated listing and access to loosely typed user
+// fields. It never exits, so it can be embedded in other programs, and its
+// only logging is package retry's line for each request it retries. The
+// functions that call the API take a context first and make every request
+// with it, so they stop paging with its error once it is done, and API
+// errors are classified as in package apierr.
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/apierr"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+)
+
+// ListGroups returns every group in domain.
+func ListGroups(ctx context.Context, service *admin.Service, domain string) ([]*admin.Group, error) {
+	groups := []*admin.Group{}
+	err := ListGroupsPages(ctx, service, domain, "", func(page []*admin.Group, _ string) error {
+		groups = append(groups, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ListGroupsPages calls fn with each page of the groups in domain, starting
+// from pageToken ("" for the first), and the token of the page after it,
+// which is "" after the last. A run that records the tokens can resume its
+// listing part way. An error from fn stops the listing and is returned.
+func ListGroupsPages(ctx context.Context, service *admin.Service, domain, pageToken string, fn func(groups []*admin.Group, next string) error) error {
+	for {
+		req := service.Groups.List().Domain(domain).Context(ctx)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		var r *admin.Groups
+		err := retry.OnAuthError(ctx, func() (err error) {
+			r, err = req.Do()
+			return err
+		})
+		if err != nil {
+			return apierr.Wrap(err)
+		}
+		if err := fn(r.Groups, r.NextPageToken); err != nil {
+			return err
+		}
+		if r.NextPageToken == "" {
+			return nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ListGroupsQuery returns the groups in domain matching query, in the
+// Directory API's group search syntax (e.g. "email:eng-*"). It goes through
+// the REST API, since the generated client has no query parameter.
+func ListGroupsQuery(ctx context.Context, client *http.Client, domain, query string) ([]*admin.Group, error) {
+	groups := []*admin.Group{}
+	pageToken := ""
+	for {
+		r := &admin.Groups{}
+		u := rest.URL("https://www.googleapis.com/admin/directory/v1/", "groups", url.Values{
+			"domain":     {domain},
+			"query":      {query},
+			"maxResults": {"200"},
+			"pageToken":  {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		groups = append(groups, r.Groups...)
+		if r.NextPageToken == "" {
+			return groups, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ListMemberGroups returns the groups memberKey (an email address or ID) is
+// a direct member of.
+func ListMemberGroups(ctx context.Context, service *admin.Service, memberKey string) ([]*admin.Group, error) {
+	groups := []*admin.Group{}
+	pageToken := ""
+	for {
+		req := service.Groups.List().UserKey(memberKey).Context(ctx)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		var r *admin.Groups
+		err := retry.OnAuthError(ctx, func() (err error) {
+			r, err = req.Do()
+			return err
+		})
+		if err != nil {
+			return nil, apierr.Wrap(err)
+		}
+		groups = append(groups, r.Groups...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return groups, nil
+}
+
+// DomainGroup is a group and the domain it was listed from.
+type DomainGroup struct {
+	Domain string
+	*admin.Group
+}
+
+// ListDomainGroups lists the groups of every domain at once, one goroutine
+// per domain, since a customer with many secondary domains would otherwise
+// wait for each listing in turn. The groups come back in domain order, so
+// the result is the same however the listings interleave. count, if not
+// nil, is told how many groups each domain has as its listing is used.
+func ListDomainGroups(ctx context.Context, service *admin.Service, domains []string, count func(domain string, groups int)) ([]*DomainGroup, error) {
+	return listDomainGroups(domains, count, func(domain string) ([]*admin.Group, error) {
+		return ListGroups(ctx, service, domain)
+	})
+}
+
+// ListDomainGroupsQuery is like ListDomainGroups but lists only the groups
+// matching query (see ListGroupsQuery).
+func ListDomainGroupsQuery(ctx context.Context, client *http.Client, domains []string, query string, count func(domain string, groups int)) ([]*DomainGroup, error) {
+	return listDomainGroups(domains, count, func(domain string) ([]*admin.Group, error) {
+		return ListGroupsQuery(ctx, client, domain, query)
+	})
+}
+
+func listDomainGroups(domains []string, count func(domain string, groups int), list func(domain string) ([]*admin.Group, error)) ([]*DomainGroup, error) {
+	type result struct {
+		groups []*admin.Group
+		err    error
+	}
+	results := make([]chan result, len(domains))
+	for i, domain := range domains {
+		results[i] = make(chan result, 1)
+		go func(domain string, out chan<- result) {
+			groups, err := list(domain)
+			out <- result{groups, err}
+		}(domain, results[i])
+	}
+	all := []*DomainGroup{}
+	var first error
+	for i, domain := range domains {
+		r := <-results[i]
+		if r.err != nil {
+			if first == nil {
+				first = fmt.Errorf("%s: %w", domain, r.err)
+			}
+			continue
+		}
+		if count != nil {
+			count(domain, len(r.groups))
+		}
+		for _, g := range r.groups {
+			all = append(all, &DomainGroup{domain, g})
+		}
+	}
+	if first != nil {
+		return nil, first
+	}
+	return all, nil
+}
+
+// ListUsers returns every user in domain. projection is passed through to the
+// API; use "full" to include custom schema fields.
+func ListUsers(ctx context.Context, service *admin.Service, domain, projection string) ([]*admin.User, error) {
+	return listUsers(ctx, service, domain, func(req *admin.UsersListCall) {
+		if projection != "" {
+			req.Projection(projection)
+		}
+	})
+}
+
+// ListDeletedUsers returns the users in domain deleted within the last 20
+// days, which are the ones that can still be restored.
+func ListDeletedUsers(ctx context.Context, service *admin.Service, domain string) ([]*admin.User, error) {
+	return listUsers(ctx, service, domain, func(req *admin.UsersListCall) {
+		req.ShowDeleted("true")
+	})
+}
+
+// ListUsersInOrgUnit returns the users in domain in the OU at path and the
+// OUs below it.
+func ListUsersInOrgUnit(ctx context.Context, service *admin.Service, domain, path string) ([]*admin.User, error) {
+	return listUsers(ctx, service, domain, func(req *admin.UsersListCall) {
+		req.Query("orgUnitPath='" + strings.Replace(path, "'", `\'`, -1) + "'")
+	})
+}
+
+func listUsers(ctx context.Context, service *admin.Service, domain string, configure func(*admin.UsersListCall)) ([]*admin.User, error) {
+	users := []*admin.User{}
+	pageToken := ""
+	for {
+		req := service.Users.List().Domain(domain).MaxResults(500).Context(ctx)
+		configure(req)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		var r *admin.Users
+		err := retry.OnAuthError(ctx, func() (err error) {
+			r, err = req.Do()
+			return err
+		})
+		if err != nil {
+			return nil, apierr.Wrap(err)
+		}
+		users = append(users, r.Users...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return users, nil
+}
+
+// ListMembers returns the direct members of the group identified by groupKey
+// (its email address or ID).
+func ListMembers(ctx context.Context, service *admin.Service, groupKey string) ([]*admin.Member, error) {
+	members := []*admin.Member{}
+	pageToken := ""
+	for {
+		req := service.Members.List(groupKey).Context(ctx)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		var r *admin.Members
+		err := retry.OnAuthError(ctx, func() (err error) {
+			r, err = req.Do()
+			return err
+		})
+		if err != nil {
+			return nil, apierr.Wrap(err)
+		}
+		members = append(members, r.Members...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return members, nil
+}
+
+// Member is a group member with the status and delivery settings the
+// vendored client predates.
+type Member struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	// Role is OWNER, MANAGER or MEMBER.
+	Role string `json:"role"`
+	// Type is USER, GROUP, CUSTOMER or EXTERNAL.
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	// DeliverySettings is how the member gets the group's mail, e.g.
+	// ALL_MAIL, DIGEST or NONE.
+	DeliverySettings string `json:"delivery_settings"`
+}
+
+// ListMemberDetails is like ListMembers but lists the members
+// through the REST API, so they have every field of Member.
+func ListMemberDetails(ctx context.Context, client *http.Client, groupKey string) ([]*Member, error) {
+	members := []*Member{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Members       []*Member `json:"members"`
+			NextPageToken string    `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/admin/directory/v1/", "groups/"+url.PathEscape(groupKey)+"/members", url.Values{
+			"maxResults": {"200"},
+			"pageToken":  {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		members = append(members, r.Members...)
+		if r.NextPageToken == "" {
+			return members, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// GetMember returns memberKey's membership of groupKey, with every field of
+// Member.
+func GetMember(ctx context.Context, client *http.Client, groupKey, memberKey string) (*Member, error) {
+	m := &Member{}
+	u := rest.URL("https://www.googleapis.com/admin/directory/v1/",
+		"groups/"+url.PathEscape(groupKey)+"/members/"+url.PathEscape(memberKey), nil)
+	if err := rest.Get(ctx, client, u, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PrimaryOrganization returns the user's primary organization entry (or the
+// first one if none is marked primary). The generated client leaves
+// Organizations untyped, so it is round-tripped through JSON here.
+func PrimaryOrganization(u *admin.User) admin.UserOrganization {
+	var orgs []admin.UserOrganization
+	if u.Organizations != nil {
+		data, err := json.Marshal(u.Organizations)
+		if err == nil {
+			json.Unmarshal(data, &orgs)
+		}
+	}
+	for _, o := range orgs {
+		if o.Primary {
+			return o
+		}
+	}
+	if len(orgs) > 0 {
+		return orgs[0]
+	}
+	return admin.UserOrganization{}
+}
+
+// CustomField returns the value of a custom schema field given as
+// "Schema.Field". Users must have been fetched with the "full" projection.
+// Multi-valued fields are joined with ";".
+func CustomField(u *admin.User, name string) string {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	props, ok := u.CustomSchemas[parts[0]]
+	if !ok {
+		return ""
+	}
+	fields, ok := props.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	return stringValue(fields[parts[1]])
+}
+
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		vals := []string{}
+		for _, item := range v {
+			// Multi-valued fields come back as [{"type": ..., "value": ...}].
+			if m, ok := item.(map[string]interface{}); ok {
+				vals = append(vals, stringValue(m["value"]))
+			} else {
+				vals = append(vals, stringValue(item))
+			}
+		}
+		return strings.Join(vals, ";")
+	}
+	return fmt.Sprint(v)
+}
+
+// RecoveryEmails returns each user's recovery address keyed by lowercased
+// primary address. The vendored client predates the recoveryEmail field,
+// so this lists users through the REST API directly.
+func RecoveryEmails(ctx context.Context, client *http.Client, domain string) (map[string]string, error) {
+	recovery := map[string]string{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Users []struct {
+				PrimaryEmail  string `json:"primaryEmail"`
+				RecoveryEmail string `json:"recoveryEmail"`
+			} `json:"users"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/admin/directory/v1/", "users", url.Values{
+			"domain":     {domain},
+			"maxResults": {"500"},
+			"fields":     {"users(primaryEmail,recoveryEmail),nextPageToken"},
+			"pageToken":  {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, user := range r.Users {
+			if user.RecoveryEmail != "" {
+				recovery[strings.ToLower(user.PrimaryEmail)] = user.RecoveryEmail
+			}
+		}
+		if r.NextPageToken == "" {
+			return recovery, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ListOrgUnits returns every OU below the root. The root itself isn't
+// included; its ID is the ParentOrgUnitId of the top-level OUs.
+func ListOrgUnits(ctx context.Context, service *admin.Service) ([]*admin.OrgUnit, error) {
+	var r *admin.OrgUnits
+	err := retry.OnAuthError(ctx, func() (err error) {
+		r, err = service.Orgunits.List("my_customer").Type("all").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, apierr.Wrap(err)
+	}
+	return r.OrganizationUnits, nil
+}
+
+// ListMobileDevices returns the customer's mobile devices matching query
+// (the admin console's device search syntax, "" for all).
+func ListMobileDevices(ctx context.Context, service *admin.Service, query string) ([]*admin.MobileDevice, error) {
+	devices := []*admin.MobileDevice{}
+	pageToken := ""
+	for {
+		req := service.Mobiledevices.List("my_customer").Projection("FULL").MaxResults(100).Context(ctx)
+		if query != "" {
+			req.Query(query)
+		}
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		var r *admin.MobileDevices
+		err := retry.OnAuthError(ctx, func() (err error) {
+			r, err = req.Do()
+			return err
+		})
+		if err != nil {
+			return nil, apierr.Wrap(err)
+		}
+		devices = append(devices, r.Mobiledevices...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return devices, nil
+}
+
+// ListChromeOSDevices returns the customer's Chrome OS devices matching
+// query (the admin console's device search syntax, "" for all).
+func ListChromeOSDevices(ctx context.Context, service *admin.Service, query string) ([]*admin.ChromeOsDevice, error) {
+	devices := []*admin.ChromeOsDevice{}
+	pageToken := ""
+	for {
+		req := service.Chromeosdevices.List("my_customer").Projection("FULL").MaxResults(200).Context(ctx)
+		if query != "" {
+			req.Query(query)
+		}
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		var r *admin.ChromeOsDevices
+		err := retry.OnAuthError(ctx, func() (err error) {
+			r, err = req.Do()
+			return err
+		})
+		if err != nil {
+			return nil, apierr.Wrap(err)
+		}
+		devices = append(devices, r.Chromeosdevices...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return devices, nil
+}
+
+// ListDomains returns the customer's verified domains, primary first.
+// Domain aliases aren't included: their users and groups belong to the
+// domain they alias.
+func ListDomains(ctx context.Context, service *admin.Service) ([]string, error) {
+	var r *admin.Domains2
+	err := retry.OnAuthError(ctx, func() (err error) {
+		r, err = service.Domains.List("my_customer").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, apierr.Wrap(err)
+	}
+	domains := []string{}
+	for _, d := range r.Domains {
+		switch {
+		case !d.Verified:
+		case d.IsPrimary:
+			domains = append([]string{d.DomainName}, domains...)
+		default:
+			domains = append(domains, d.DomainName)
+		}
+	}
+	return domains, nil
+}
+
+// TwoStep is a user's 2-Step Verification state.
+type TwoStep struct {
+	Enrolled bool
+	// Enforced is true when policy requires the user to use 2SV.
+	Enforced bool
+}
+
+// TwoStepStatus returns each user's 2SV state keyed by lowercased primary
+// address. Like RecoveryEmails it uses the REST API, since the vendored
+// client has no 2SV fields.
+func TwoStepStatus(ctx context.Context, client *http.Client, domain string) (map[string]TwoStep, error) {
+	status := map[string]TwoStep{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Users []struct {
+				PrimaryEmail    string `json:"primaryEmail"`
+				IsEnrolledIn2Sv bool   `json:"isEnrolledIn2Sv"`
+				IsEnforcedIn2Sv bool   `json:"isEnforcedIn2Sv"`
+			} `json:"users"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/admin/directory/v1/", "users", url.Values{
+			"domain":     {domain},
+			"maxResults": {"500"},
+			"fields":     {"users(primaryEmail,isEnrolledIn2Sv,isEnforcedIn2Sv),nextPageToken"},
+			"pageToken":  {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, user := range r.Users {
+			status[strings.ToLower(user.PrimaryEmail)] = TwoStep{Enrolled: user.IsEnrolledIn2Sv, Enforced: user.IsEnforcedIn2Sv}
+		}
+		if r.NextPageToken == "" {
+			return status, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
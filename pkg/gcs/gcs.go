@@ -0,0 +1,111 @@
+// Package gcs uploads report files to Google Cloud Storage through the JSON
+// API's simple upload, and deletes the ones staged for BigQuery.
+package gcs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/googleapi"
+
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+)
+
+// ParseURL splits "gs://bucket/path/to/object" into bucket and object.
+func ParseURL(u string) (bucket, object string, err error) {
+	if !strings.HasPrefix(u, "gs://") {
+		return "", "", fmt.Errorf("%q is not a gs:// URL", u)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u, "gs://"), "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("%q has no bucket", u)
+	}
+	if len(parts) == 2 {
+		object = parts[1]
+	}
+	return parts[0], object, nil
+}
+
+// Upload writes the contents of r to bucket/object, replacing any existing
+// object.
+func Upload(ctx context.Context, client *http.Client, bucket, object, contentType string, r io.Reader) error {
+	req, err := http.NewRequest("POST", uploadURL(bucket, object), r)
+	if err != nil {
+		return err
+	}
+	return send(ctx, client, req, bucket, object, contentType)
+}
+
+func uploadURL(bucket, object string) string {
+	return "https://storage.googleapis.com/upload/storage/v1/b/" + url.QueryEscape(bucket) + "/o?" + url.Values{
+		"uploadType": {"media"},
+		"name":       {object},
+	}.Encode()
+}
+
+// send uploads req. Writing the same contents to the same name twice leaves
+// one object, so the upload is safe to retry after a server error.
+func send(ctx context.Context, client *http.Client, req *http.Request, bucket, object, contentType string) error {
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "google_apps_tools")
+	retry.MarkIdempotent(req)
+	res, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return fmt.Errorf("uploading gs://%s/%s: %v", bucket, object, err)
+	}
+	return nil
+}
+
+// UploadFile writes the file at path to bucket/object. Unlike Upload, the
+// request can be replayed, so an upload turned away by a rate limit or a
+// transient error is retried by the client rather than failing.
+func UploadFile(ctx context.Context, client *http.Client, bucket, object, contentType, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", uploadURL(bucket, object), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.GetBody = func() (io.ReadCloser, error) { return os.Open(path) }
+	return send(ctx, client, req, bucket, object, contentType)
+}
+
+// Delete removes bucket/object. An object that doesn't exist isn't an
+// error.
+func Delete(ctx context.Context, client *http.Client, bucket, object string) error {
+	req, err := http.NewRequest("DELETE", "https://storage.googleapis.com/storage/v1/b/"+url.PathEscape(bucket)+"/o/"+url.PathEscape(object), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "google_apps_tools")
+	res, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err := googleapi.CheckResponse(res); err != nil {
+		return fmt.Errorf("deleting gs://%s/%s: %v", bucket, object, err)
+	}
+	return nil
+}
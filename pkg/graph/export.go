@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats accepted by Write.
+var Formats = []string{"dot", "graphml", "cypher"}
+
+// CheckFormat returns an error if format is not one of Formats.
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown graph format %q (want one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// Write exports g to w in the named format.
+func Write(w io.Writer, g *Graph, format string) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "dot":
+		writeDOT(bw, g)
+	case "graphml":
+		writeGraphML(bw, g)
+	case "cypher":
+		writeCypher(bw, g)
+	}
+	return bw.Flush()
+}
+
+// writeDOT writes a Graphviz digraph with groups drawn as boxes.
+func writeDOT(w *bufio.Writer, g *Graph) {
+	fmt.Fprintln(w, "digraph memberships {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, n := range g.SortedNodes() {
+		shape := "ellipse"
+		if n.Type == TypeGroup {
+			shape = "box"
+		}
+		fmt.Fprintf(w, "  %s [shape=%s];\n", strconv.Quote(n.Key), shape)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s -> %s [label=%s];\n", strconv.Quote(e.Group), strconv.Quote(e.Member), strconv.Quote(e.Role))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeGraphML(w *bufio.Writer, g *Graph) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="type" for="node" attr.name="type" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="role" for="edge" attr.name="role" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <graph id="memberships" edgedefault="directed">`)
+	for _, n := range g.SortedNodes() {
+		fmt.Fprintf(w, "    <node id=\"%s\"><data key=\"type\">%s</data></node>\n", xmlEscape(n.Key), xmlEscape(n.Type))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "    <edge source=\"%s\" target=\"%s\"><data key=\"role\">%s</data></edge>\n",
+			xmlEscape(e.Group), xmlEscape(e.Member), xmlEscape(e.Role))
+	}
+	fmt.Fprintln(w, "  </graph>")
+	fmt.Fprintln(w, "</graphml>")
+}
+
+// writeCypher writes idempotent MERGE statements for loading into Neo4j.
+func writeCypher(w *bufio.Writer, g *Graph) {
+	for _, n := range g.SortedNodes() {
+		label := "User"
+		switch n.Type {
+		case TypeGroup:
+			label = "Group"
+		case TypeCustomer:
+			label = "Customer"
+		}
+		fmt.Fprintf(w, "MERGE (:%s {email: %s});\n", label, cypherString(n.Key))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "MATCH (g:Group {email: %s}), (m {email: %s}) MERGE (m)-[:MEMBER_OF {role: %s}]->(g);\n",
+			cypherString(e.Group), cypherString(e.Member), cypherString(e.Role))
+	}
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func cypherString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
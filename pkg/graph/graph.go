@@ -0,0 +1,110 @@
+// Package graph models group membership as a directed graph (group ->
+// member) so nesting can be exported and queried.
+package graph
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+)
+
+// Node types, matching the Directory API member types.
+const (
+	TypeGroup    = "GROUP"
+	TypeUser     = "USER"
+	TypeCustomer = "CUSTOMER"
+)
+
+// Node is a group, user or customer in the graph, keyed by lowercased email
+// (or ID for customers, which have no address).
+type Node struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+}
+
+// Edge records that Member is a direct member of Group with Role.
+type Edge struct {
+	Group  string `json:"group"`
+	Member string `json:"member"`
+	Role   string `json:"role"`
+}
+
+// Graph is a membership graph.
+type Graph struct {
+	Domain  string           `json:"domain,omitempty"`
+	Fetched time.Time        `json:"fetched"`
+	Nodes   map[string]*Node `json:"nodes"`
+	Edges   []*Edge          `json:"edges"`
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{Nodes: map[string]*Node{}}
+}
+
+// AddNode adds a node if it isn't already present and returns its key.
+// A node first seen as a member of unknown type is upgraded when its real
+// type is learned.
+func (g *Graph) AddNode(key, nodeType string) string {
+	key = strings.ToLower(key)
+	if n, ok := g.Nodes[key]; ok {
+		if n.Type == "" {
+			n.Type = nodeType
+		}
+		return key
+	}
+	g.Nodes[key] = &Node{Key: key, Type: nodeType}
+	return key
+}
+
+// AddMembership records a direct membership.
+func (g *Graph) AddMembership(group, member, memberType, role string) {
+	group = g.AddNode(group, TypeGroup)
+	member = g.AddNode(member, memberType)
+	g.Edges = append(g.Edges, &Edge{Group: group, Member: member, Role: role})
+}
+
+// SortedNodes returns the nodes ordered by key, for stable output.
+func (g *Graph) SortedNodes() []*Node {
+	keys := make([]string, 0, len(g.Nodes))
+	for k := range g.Nodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	nodes := make([]*Node, len(keys))
+	for i, k := range keys {
+		nodes[i] = g.Nodes[k]
+	}
+	return nodes
+}
+
+// Fetch builds the membership graph of every group in domain.
+func Fetch(ctx context.Context, service *admin.Service, domain string) (*Graph, error) {
+	groups, err := directory.ListGroups(ctx, service, domain)
+	if err != nil {
+		return nil, err
+	}
+	g := New()
+	g.Domain = domain
+	g.Fetched = time.Now()
+	for _, group := range groups {
+		g.AddNode(group.Email, TypeGroup)
+		members, err := directory.ListMembers(ctx, service, group.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			key := m.Email
+			if key == "" {
+				key = m.Id
+			}
+			g.AddMembership(group.Email, key, m.Type, m.Role)
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Save writes g to path as JSON so it can be queried later without
+// refetching.
+func Save(path string, g *Graph) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads a graph written by Save.
+func Load(path string) (*Graph, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g := New()
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Path lists the intermediate groups through which a membership arises,
+// starting from the one nearest the queried node. It is empty for a direct
+// membership.
+type Path []string
+
+func (p Path) String() string {
+	return strings.Join(p, " > ")
+}
+
+// Result is a single query answer: Key is related to the queried node
+// through Path.
+type Result struct {
+	Key  string
+	Path Path
+}
+
+func (g *Graph) index() (members, groups map[string][]string) {
+	members = map[string][]string{}
+	groups = map[string][]string{}
+	for _, e := range g.Edges {
+		members[e.Group] = append(members[e.Group], e.Member)
+		groups[e.Member] = append(groups[e.Member], e.Group)
+	}
+	return members, groups
+}
+
+// EffectiveMembers returns every non-group node that is a member of group
+// directly or through nested groups, with the shortest path for each.
+func (g *Graph) EffectiveMembers(group string) []Result {
+	members, _ := g.index()
+	return g.walk(strings.ToLower(group), members, func(n *Node) bool {
+		return n.Type != TypeGroup
+	})
+}
+
+// MemberOf returns the groups member belongs to. With effective set,
+// groups reached through nesting are included, with the path for each.
+func (g *Graph) MemberOf(member string, effective bool) []Result {
+	member = strings.ToLower(member)
+	_, groups := g.index()
+	if !effective {
+		results := []Result{}
+		for _, group := range groups[member] {
+			results = append(results, Result{Key: group, Path: Path{}})
+		}
+		sortResults(results)
+		return results
+	}
+	return g.walk(member, groups, func(n *Node) bool {
+		return n.Type == TypeGroup
+	})
+}
+
+// walk does a breadth first search from start along next, collecting the
+// nodes that satisfy want. Visited nodes are skipped, so membership cycles
+// terminate.
+func (g *Graph) walk(start string, next map[string][]string, want func(*Node) bool) []Result {
+	type item struct {
+		key  string
+		path Path
+	}
+	seen := map[string]bool{start: true}
+	queue := []item{{start, Path{}}}
+	results := []Result{}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, key := range next[cur.key] {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			path := cur.path
+			if cur.key != start {
+				path = append(append(Path{}, cur.path...), cur.key)
+			}
+			if n := g.Nodes[key]; n != nil && want(n) {
+				results = append(results, Result{Key: key, Path: path})
+			}
+			queue = append(queue, item{key, path})
+		}
+	}
+	sortResults(results)
+	return results
+}
+
+type byKey []Result
+
+func (r byKey) Len() int           { return len(r) }
+func (r byKey) Less(i, j int) bool { return r[i].Key < r[j].Key }
+func (r byKey) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func sortResults(r []Result) {
+	sort.Sort(byKey(r))
+}
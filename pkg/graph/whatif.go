@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change is a proposed direct membership change.
+type Change struct {
+	// Remove is true to take Member out of Group, false to add it.
+	Remove bool
+	Group  string
+	Member string
+	// Type is the member's node type when it is added and not already in
+	// the graph. Defaults to TypeUser.
+	Type string
+}
+
+// Clone returns a copy of g that can be changed without affecting g.
+func (g *Graph) Clone() *Graph {
+	c := New()
+	c.Domain = g.Domain
+	c.Fetched = g.Fetched
+	for k, n := range g.Nodes {
+		copied := *n
+		c.Nodes[k] = &copied
+	}
+	for _, e := range g.Edges {
+		copied := *e
+		c.Edges = append(c.Edges, &copied)
+	}
+	return c
+}
+
+// Apply makes the changes to g. Adding a membership that exists, or
+// removing one that doesn't, is an error, since the change set was
+// probably written against a different state of the domain.
+func (g *Graph) Apply(changes []*Change) error {
+	for _, c := range changes {
+		group, member := strings.ToLower(c.Group), strings.ToLower(c.Member)
+		at := -1
+		for i, e := range g.Edges {
+			if e.Group == group && e.Member == member {
+				at = i
+				break
+			}
+		}
+		switch {
+		case c.Remove && at < 0:
+			return fmt.Errorf("%s is not a member of %s", member, group)
+		case c.Remove:
+			g.Edges = append(g.Edges[:at], g.Edges[at+1:]...)
+		case at >= 0:
+			return fmt.Errorf("%s is already a member of %s", member, group)
+		default:
+			memberType := c.Type
+			if n := g.Nodes[member]; n != nil && n.Type != "" {
+				memberType = n.Type
+			}
+			if memberType == "" {
+				memberType = TypeUser
+			}
+			g.AddMembership(group, member, memberType, "MEMBER")
+		}
+	}
+	return nil
+}
+
+// AccessChange is an effective membership a member gains or loses.
+type AccessChange struct {
+	Member string
+	Group  string
+	Gained bool
+	// Path is how the membership arises: after the change for a gain,
+	// before it for a loss.
+	Path Path
+}
+
+type byMemberGroup []*AccessChange
+
+func (a byMemberGroup) Len() int { return len(a) }
+func (a byMemberGroup) Less(i, j int) bool {
+	if a[i].Member != a[j].Member {
+		return a[i].Member < a[j].Member
+	}
+	return a[i].Group < a[j].Group
+}
+func (a byMemberGroup) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// Simulate applies changes to a copy of g and returns the effective
+// memberships each non-group member gains or loses, nesting included. A
+// change can only affect the members below the member it adds or removes,
+// so only they are compared.
+func (g *Graph) Simulate(changes []*Change) ([]*AccessChange, error) {
+	after := g.Clone()
+	if err := after.Apply(changes); err != nil {
+		return nil, err
+	}
+	affected := map[string]bool{}
+	for _, c := range changes {
+		member := strings.ToLower(c.Member)
+		for _, graph := range []*Graph{g, after} {
+			if n := graph.Nodes[member]; n != nil && n.Type != TypeGroup {
+				affected[member] = true
+			}
+			for _, r := range graph.EffectiveMembers(member) {
+				affected[r.Key] = true
+			}
+		}
+	}
+	diffs := []*AccessChange{}
+	for member := range affected {
+		was := map[string]Result{}
+		for _, r := range g.MemberOf(member, true) {
+			was[r.Key] = r
+		}
+		is := map[string]Result{}
+		for _, r := range after.MemberOf(member, true) {
+			is[r.Key] = r
+			if _, ok := was[r.Key]; !ok {
+				diffs = append(diffs, &AccessChange{Member: member, Group: r.Key, Gained: true, Path: r.Path})
+			}
+		}
+		for _, r := range was {
+			if _, ok := is[r.Key]; !ok {
+				diffs = append(diffs, &AccessChange{Member: member, Group: r.Key, Path: r.Path})
+			}
+		}
+	}
+	sort.Sort(byMemberGroup(diffs))
+	return diffs, nil
+}
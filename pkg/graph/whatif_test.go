@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testGraph is all@ > eng@ > alice, with bob in no group.
+func testGraph() *Graph {
+	g := New()
+	g.AddMembership("all@example.com", "eng@example.com", TypeGroup, "MEMBER")
+	g.AddMembership("eng@example.com", "alice@example.com", TypeUser, "MEMBER")
+	g.AddNode("bob@example.com", TypeUser)
+	return g
+}
+
+// describe renders a change as "+member group (path)" for a gain and
+// "-member group (path)" for a loss, the path being empty for a direct
+// membership.
+func describe(diffs []*AccessChange) []string {
+	out := []string{}
+	for _, d := range diffs {
+		sign := "-"
+		if d.Gained {
+			sign = "+"
+		}
+		out = append(out, fmt.Sprintf("%s%s %s (%s)", sign, d.Member, d.Group, d.Path))
+	}
+	return out
+}
+
+func TestSimulate(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []*Change
+		want    []string
+		err     string
+	}{{
+		name:    "add gains nested",
+		changes: []*Change{{Group: "eng@example.com", Member: "Bob@example.com"}},
+		want: []string{
+			"+bob@example.com all@example.com (eng@example.com)",
+			"+bob@example.com eng@example.com ()",
+		},
+	}, {
+		name:    "remove nested group loses through it",
+		changes: []*Change{{Remove: true, Group: "all@example.com", Member: "eng@example.com"}},
+		want:    []string{"-alice@example.com all@example.com (eng@example.com)"},
+	}, {
+		name: "remove and add back",
+		changes: []*Change{
+			{Remove: true, Group: "eng@example.com", Member: "alice@example.com"},
+			{Group: "eng@example.com", Member: "alice@example.com"},
+		},
+		want: []string{},
+	}, {
+		name:    "add existing",
+		changes: []*Change{{Group: "eng@example.com", Member: "alice@example.com"}},
+		err:     "alice@example.com is already a member of eng@example.com",
+	}, {
+		name:    "remove missing",
+		changes: []*Change{{Remove: true, Group: "all@example.com", Member: "bob@example.com"}},
+		err:     "bob@example.com is not a member of all@example.com",
+	}}
+	for _, tt := range tests {
+		g := testGraph()
+		edges := len(g.Edges)
+		diffs, err := g.Simulate(tt.changes)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := describe(diffs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if len(g.Edges) != edges {
+			t.Errorf("%s: Simulate changed the graph: %d edges, want %d", tt.name, len(g.Edges), edges)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	g := testGraph()
+	err := g.Apply([]*Change{
+		{Group: "eng@example.com", Member: "ops@example.com", Type: TypeGroup},
+		{Remove: true, Group: "eng@example.com", Member: "alice@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := g.Nodes["ops@example.com"]; n == nil || n.Type != TypeGroup {
+		t.Errorf("ops@example.com is %+v, want a %s node", n, TypeGroup)
+	}
+	got := []string{}
+	for _, e := range g.Edges {
+		got = append(got, e.Group+" > "+e.Member)
+	}
+	want := []string{"all@example.com > eng@example.com", "eng@example.com > ops@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got edges %q, want %q", got, want)
+	}
+}
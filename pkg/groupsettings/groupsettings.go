@@ -0,0 +1,64 @@
+// Package groupsettings is a client for the Groups Settings API.
+package groupsettings
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const basePath = "https://www.googleapis.com/groups/v1/groups/"
+
+// Scope is the OAuth2 scope for reading and changing group settings.
+const Scope = "https://www.googleapis.com/auth/apps.groups.settings"
+
+// Settings holds a group's settings keyed by API attribute name, e.g.
+// "whoCanPostMessage". The API returns nearly every value as a string.
+type Settings map[string]interface{}
+
+// String returns the named attribute formatted as a string.
+func (s Settings) String(name string) string {
+	v, ok := s[name]
+	if !ok || v == nil {
+		return ""
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprint(v)
+}
+
+// Names returns the attribute names in s, sorted.
+func (s Settings) Names() []string {
+	names := make([]string, 0, len(s))
+	for k := range s {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the settings of the group with address email.
+func Get(ctx context.Context, client *http.Client, email string) (Settings, error) {
+	s := Settings{}
+	u := rest.URL(basePath, url.QueryEscape(email), url.Values{"alt": {"json"}})
+	if err := rest.Get(ctx, client, u, &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Patch updates the attributes present in s, leaving the rest unchanged.
+func Patch(ctx context.Context, client *http.Client, email string, s Settings) (Settings, error) {
+	updated := Settings{}
+	u := rest.URL(basePath, url.QueryEscape(email), url.Values{"alt": {"json"}})
+	if err := rest.Do(ctx, client, "PATCH", u, s, &updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,241 @@
+// Package inboundsso is a client for the Cloud Identity Inbound SSO API:
+// the SAML and OIDC identity providers users sign in through, and the
+// assignments saying which OUs and groups use which.
+package inboundsso
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const basePath = "https://cloudidentity.googleapis.com/v1/"
+
+// The OAuth2 scopes for reading, and reading and changing, SSO settings.
+const (
+	ReadonlyScope = "https://www.googleapis.com/auth/cloud-identity.inboundsso.readonly"
+	Scope         = "https://www.googleapis.com/auth/cloud-identity.inboundsso"
+)
+
+// The SSO modes an assignment can have.
+const (
+	ModeOff        = "SSO_OFF"
+	ModeSAML       = "SAML_SSO"
+	ModeOIDC       = "OIDC_SSO"
+	ModeDomainWide = "DOMAIN_WIDE_SAML_IF_ENABLED"
+)
+
+// Modes are the SSO modes an assignment can be given.
+var Modes = []string{ModeOff, ModeSAML, ModeOIDC, ModeDomainWide}
+
+// Profile is an inbound SAML or OIDC SSO profile, with the parts of both
+// kinds' configuration that say which IdP it trusts.
+type Profile struct {
+	// Name is "inboundSamlSsoProfiles/<id>" or "inboundOidcSsoProfiles/<id>".
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	IdpConfig   struct {
+		// SAML
+		EntityID               string `json:"entityId"`
+		SingleSignOnServiceURI string `json:"singleSignOnServiceUri"`
+		LogoutRedirectURI      string `json:"logoutRedirectUri"`
+		// OIDC
+		IssuerURI string `json:"issuerUri"`
+		// Both
+		ChangePasswordURI string `json:"changePasswordUri"`
+	} `json:"idpConfig"`
+	SpConfig struct {
+		EntityID                    string `json:"entityId"`
+		AssertionConsumerServiceURI string `json:"assertionConsumerServiceUri"`
+	} `json:"spConfig"`
+	RpConfig struct {
+		ClientID string `json:"clientId"`
+	} `json:"rpConfig"`
+	// Kind is "saml" or "oidc", from the listing the profile came from.
+	Kind string `json:"-"`
+}
+
+// Mode returns the SSO mode an assignment of p has.
+func (p *Profile) Mode() string {
+	if p.Kind == "oidc" {
+		return ModeOIDC
+	}
+	return ModeSAML
+}
+
+// Assignment says which profile, if any, the users of an OU or group
+// sign in with.
+type Assignment struct {
+	// Name is "inboundSsoAssignments/<id>", and empty when creating one.
+	Name     string `json:"name,omitempty"`
+	Customer string `json:"customer,omitempty"`
+	// TargetGroup is "groups/<id>" and TargetOrgUnit "orgUnits/<id>".
+	TargetGroup   string `json:"targetGroup,omitempty"`
+	TargetOrgUnit string `json:"targetOrgUnit,omitempty"`
+	// Rank orders group assignments, 1 first; OU assignments have none.
+	Rank           float64         `json:"rank,omitempty"`
+	SsoMode        string          `json:"ssoMode,omitempty"`
+	SamlSsoInfo    *SAMLSSOInfo    `json:"samlSsoInfo,omitempty"`
+	OidcSsoInfo    *OIDCSSOInfo    `json:"oidcSsoInfo,omitempty"`
+	SignInBehavior *SignInBehavior `json:"signInBehavior,omitempty"`
+}
+
+// SAMLSSOInfo names the profile of a ModeSAML assignment.
+type SAMLSSOInfo struct {
+	InboundSamlSsoProfile string `json:"inboundSamlSsoProfile"`
+}
+
+// OIDCSSOInfo names the profile of a ModeOIDC assignment.
+type OIDCSSOInfo struct {
+	InboundOidcSsoProfile string `json:"inboundOidcSsoProfile"`
+}
+
+// SignInBehavior says when users are sent to the IdP.
+type SignInBehavior struct {
+	RedirectCondition string `json:"redirectCondition,omitempty"`
+}
+
+// Target returns the assignment's TargetGroup or TargetOrgUnit.
+func (a *Assignment) Target() string {
+	if a.TargetGroup != "" {
+		return a.TargetGroup
+	}
+	return a.TargetOrgUnit
+}
+
+// ProfileName returns the name of the profile a assigns, or "".
+func (a *Assignment) ProfileName() string {
+	switch {
+	case a.SsoMode == ModeSAML && a.SamlSsoInfo != nil:
+		return a.SamlSsoInfo.InboundSamlSsoProfile
+	case a.SsoMode == ModeOIDC && a.OidcSsoInfo != nil:
+		return a.OidcSsoInfo.InboundOidcSsoProfile
+	}
+	return ""
+}
+
+// RedirectCondition returns the assignment's redirect condition, or "".
+func (a *Assignment) RedirectCondition() string {
+	if a.SignInBehavior == nil {
+		return ""
+	}
+	return a.SignInBehavior.RedirectCondition
+}
+
+// customerFilter is the list filter selecting customerID's objects.
+func customerFilter(customerID string) string {
+	return `customer=="customers/` + customerID + `"`
+}
+
+// ListProfiles returns the customer's SAML and then OIDC profiles.
+func ListProfiles(ctx context.Context, client *http.Client, customerID string) ([]*Profile, error) {
+	all := []*Profile{}
+	for _, kind := range []struct{ name, collection string }{
+		{"saml", "inboundSamlSsoProfiles"},
+		{"oidc", "inboundOidcSsoProfiles"},
+	} {
+		pageToken := ""
+		for {
+			// Each listing fills the field named after its collection.
+			r := &struct {
+				SAML          []*Profile `json:"inboundSamlSsoProfiles"`
+				OIDC          []*Profile `json:"inboundOidcSsoProfiles"`
+				NextPageToken string     `json:"nextPageToken"`
+			}{}
+			u := rest.URL(basePath, kind.collection, url.Values{
+				"filter":    {customerFilter(customerID)},
+				"pageToken": {pageToken},
+			})
+			if err := rest.Get(ctx, client, u, r); err != nil {
+				return nil, err
+			}
+			for _, p := range append(r.SAML, r.OIDC...) {
+				p.Kind = kind.name
+				all = append(all, p)
+			}
+			if r.NextPageToken == "" {
+				break
+			}
+			pageToken = r.NextPageToken
+		}
+	}
+	return all, nil
+}
+
+// ListAssignments returns the customer's SSO assignments.
+func ListAssignments(ctx context.Context, client *http.Client, customerID string) ([]*Assignment, error) {
+	all := []*Assignment{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Assignments   []*Assignment `json:"inboundSsoAssignments"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(basePath, "inboundSsoAssignments", url.Values{
+			"filter":    {customerFilter(customerID)},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Assignments...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// CreateAssignment adds a, which must have its Customer set.
+func CreateAssignment(ctx context.Context, client *http.Client, a *Assignment) error {
+	return do(ctx, client, "POST", basePath+"inboundSsoAssignments", a)
+}
+
+// UpdateAssignment replaces the mode, profile, rank and sign-in behavior
+// of the assignment a.Name.
+func UpdateAssignment(ctx context.Context, client *http.Client, a *Assignment) error {
+	u := rest.URL(basePath, a.Name, url.Values{"updateMask": {"rank,ssoMode,samlSsoInfo,oidcSsoInfo,signInBehavior"}})
+	return do(ctx, client, "PATCH", u, a)
+}
+
+// DeleteAssignment removes the assignment named name, after which its
+// target inherits its parent OU's.
+func DeleteAssignment(ctx context.Context, client *http.Client, name string) error {
+	return do(ctx, client, "DELETE", basePath+name, nil)
+}
+
+// operation is the long-running operation the API answers changes with.
+type operation struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// do makes a change and waits for its operation to finish.
+func do(ctx context.Context, client *http.Client, method, u string, in interface{}) error {
+	op := &operation{}
+	if err := rest.Do(ctx, client, method, u, in, op); err != nil {
+		return err
+	}
+	for !op.Done && op.Name != "" {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := rest.Get(ctx, client, basePath+op.Name, op); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("%s", op.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Package listfile reads the lists of addresses that tools take through
+// flags like -groups and -groups-file.
+package listfile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Split returns the non-empty, trimmed items of a comma separated list.
+func Split(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Read returns the items in the file at path. A .csv file is read as a
+// table and the items are taken from column; anything else has one item per
+// line, with blank lines and #comments skipped.
+func Read(path, column string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	items := []string{}
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		records, err := csv.NewReader(file).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return items, nil
+		}
+		col := -1
+		for i, name := range records[0] {
+			if strings.TrimSpace(name) == column {
+				col = i
+			}
+		}
+		if col < 0 {
+			return nil, fmt.Errorf("%s has no %q column", path, column)
+		}
+		for _, r := range records[1:] {
+			if col < len(r) && strings.TrimSpace(r[col]) != "" {
+				items = append(items, strings.TrimSpace(r[col]))
+			}
+		}
+		return items, nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			items = append(items, line)
+		}
+	}
+	return items, scanner.Err()
+}
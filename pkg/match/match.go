@@ -0,0 +1,95 @@
+// Package match decides whether a directory user matches a rule's
+// attribute conditions, for the rules-driven tools (matching_rules,
+// license_auto_assign).
+package match
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+)
+
+// Condition lists the attributes a user must have. Every field that is set
+// must match. OrgUnit matches the OU and everything beneath it; the other
+// fields are regular expressions. CustomSchema keys are "Schema.Field".
+// Compile must be called before Matches.
+type Condition struct {
+	OrgUnit      string            `yaml:"org_unit"`
+	Title        string            `yaml:"title"`
+	Department   string            `yaml:"department"`
+	Location     string            `yaml:"location"`
+	CustomSchema map[string]string `yaml:"custom_schema"`
+
+	title, department, location *regexp.Regexp
+	custom                      map[string]*regexp.Regexp
+}
+
+// Compile checks and compiles the regular expressions.
+func (c *Condition) Compile() error {
+	var err error
+	if c.title, err = compileOptional(c.Title); err != nil {
+		return err
+	}
+	if c.department, err = compileOptional(c.Department); err != nil {
+		return err
+	}
+	if c.location, err = compileOptional(c.Location); err != nil {
+		return err
+	}
+	c.custom = map[string]*regexp.Regexp{}
+	for field, expr := range c.CustomSchema {
+		if !strings.Contains(field, ".") {
+			return fmt.Errorf("custom schema field %q must be Schema.Field", field)
+		}
+		if c.custom[field], err = regexp.Compile(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Empty reports whether no condition is set, so that every user would
+// match. Rules refuse it: a rule meant for everyone says org_unit: /.
+func (c *Condition) Empty() bool {
+	return c.OrgUnit == "" && c.Title == "" && c.Department == "" && c.Location == "" && len(c.CustomSchema) == 0
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// Matches reports whether u satisfies every condition that is set.
+func (c *Condition) Matches(u *admin.User) bool {
+	if c.OrgUnit != "" && !UnderOrgUnit(u.OrgUnitPath, c.OrgUnit) {
+		return false
+	}
+	org := directory.PrimaryOrganization(u)
+	if c.title != nil && !c.title.MatchString(org.Title) {
+		return false
+	}
+	if c.department != nil && !c.department.MatchString(org.Department) {
+		return false
+	}
+	if c.location != nil && !c.location.MatchString(org.Location) {
+		return false
+	}
+	for field, re := range c.custom {
+		if !re.MatchString(directory.CustomField(u, field)) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnderOrgUnit reports whether the OU at path is parent or beneath it.
+func UnderOrgUnit(path, parent string) bool {
+	parent = strings.TrimSuffix(parent, "/")
+	return parent == "" || path == parent || strings.HasPrefix(path, parent+"/")
+}
@@ -0,0 +1,249 @@
+package output
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/googleapi"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/gcs"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+func init() {
+	RegisterScheme("bq", &Scheme{Check: checkBigQuery, Open: openBigQuery})
+}
+
+const bigQueryBasePath = "https://bigquery.googleapis.com/bigquery/v2/projects/"
+
+// bigQueryName is what BigQuery accepts as a column name.
+var bigQueryName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseBigQueryURL splits "bq://project/dataset/table", optionally
+// followed by "?staging=gs://bucket/prefix/".
+func parseBigQueryURL(target string) (project, dataset, table, staging string, err error) {
+	path, query := target, ""
+	if i := strings.Index(target, "?"); i >= 0 {
+		path, query = target[:i], target[i+1:]
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "bq://"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", "", fmt.Errorf("%q is not bq://PROJECT/DATASET/TABLE", target)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("%q: %v", target, err)
+	}
+	for k := range params {
+		if k != "staging" {
+			return "", "", "", "", fmt.Errorf("%q: unknown parameter %q; only staging is accepted", target, k)
+		}
+	}
+	staging = params.Get("staging")
+	if staging != "" {
+		if _, _, err := gcs.ParseURL(staging); err != nil {
+			return "", "", "", "", fmt.Errorf("%q: staging: %v", target, err)
+		}
+	}
+	return parts[0], parts[1], parts[2], staging, nil
+}
+
+func checkBigQuery(target string) error {
+	_, _, _, _, err := parseBigQueryURL(target)
+	return err
+}
+
+// bigQueryJob is the part of a BigQuery job resource the load needs.
+type bigQueryJob struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Status struct {
+		State       string `json:"state"`
+		ErrorResult *struct {
+			Message string `json:"message"`
+		} `json:"errorResult"`
+	} `json:"status"`
+}
+
+// bigQueryWriter loads the report into a table as a load job, replacing
+// the table's contents, with every column a nullable STRING. The rows
+// stream from a pipe into the job's upload, and the table only changes
+// when the job succeeds. A report too large for one upload is staged in
+// Cloud Storage instead; see stagedBigQueryWriter.
+type bigQueryWriter struct {
+	ctx     context.Context
+	client  *http.Client
+	project string
+	columns []string
+	pw      *io.PipeWriter
+	mw      *multipart.Writer
+	rows    *json.Encoder
+	done    chan error
+	job     *bigQueryJob
+}
+
+func openBigQuery(target string, columns []string, _ string) (Writer, error) {
+	project, dataset, table, staging, err := parseBigQueryURL(target)
+	if err != nil {
+		return nil, err
+	}
+	fields := []map[string]string{}
+	for _, c := range columns {
+		if !bigQueryName.MatchString(c) {
+			return nil, fmt.Errorf("can't load %s: column %q isn't a BigQuery column name", target, c)
+		}
+		fields = append(fields, map[string]string{"name": c, "type": "STRING", "mode": "NULLABLE"})
+	}
+	ctx := context.Background()
+	client, err := auth.DefaultClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't load %s: %v", target, err)
+	}
+	load := map[string]interface{}{
+		"destinationTable": map[string]string{"projectId": project, "datasetId": dataset, "tableId": table},
+		"schema":           map[string]interface{}{"fields": fields},
+		"sourceFormat":     "NEWLINE_DELIMITED_JSON",
+		"writeDisposition": "WRITE_TRUNCATE",
+	}
+	if staging != "" {
+		return openStagedBigQuery(ctx, client, project, table, staging, columns, load)
+	}
+	config := map[string]interface{}{
+		"jobReference":  map[string]string{"projectId": project, "jobId": newJobID(table)},
+		"configuration": map[string]interface{}{"load": load},
+	}
+
+	pr, pw := io.Pipe()
+	w := &bigQueryWriter{ctx: ctx, client: client, project: project, columns: columns, pw: pw,
+		mw: multipart.NewWriter(pw), done: make(chan error, 1), job: &bigQueryJob{}}
+	u := "https://bigquery.googleapis.com/upload/bigquery/v2/projects/" + url.PathEscape(project) + "/jobs?uploadType=multipart"
+	req, err := http.NewRequest("POST", u, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+w.mw.Boundary())
+	req.Header.Set("User-Agent", "google_apps_tools")
+	go func() {
+		res, err := ctxhttp.Do(ctx, client, req)
+		if err == nil {
+			defer googleapi.CloseBody(res)
+			if err = googleapi.CheckResponse(res); err == nil {
+				err = json.NewDecoder(res.Body).Decode(w.job)
+			}
+		}
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	part, err := w.mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err == nil {
+		err = json.NewEncoder(part).Encode(config)
+	}
+	if err == nil {
+		part, err = w.mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	}
+	if err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("can't load %s: %v", target, err)
+	}
+	w.rows = json.NewEncoder(part)
+	return w, nil
+}
+
+func (w *bigQueryWriter) Write(row []string) error {
+	r := map[string]string{}
+	for i, c := range w.columns {
+		if i < len(row) {
+			r[c] = row[i]
+		}
+	}
+	return w.rows.Encode(r)
+}
+
+func (w *bigQueryWriter) Flush() error { return nil }
+
+// Close finishes the upload and waits for the load job to complete.
+func (w *bigQueryWriter) Close() error {
+	if err := w.mw.Close(); err != nil {
+		w.Abort()
+		return err
+	}
+	w.pw.Close()
+	if err := <-w.done; err != nil {
+		return fmt.Errorf("starting BigQuery load: %v", err)
+	}
+	return waitForJob(w.ctx, w.client, w.project, w.job)
+}
+
+// newJobID returns a job ID for a load into table. Jobs are created with an
+// ID chosen here, so a retried insert can't start a second job: it fails
+// as a duplicate, and the first job is used.
+func newJobID(table string) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return fmt.Sprintf("gat_%s_%s_%x", table, time.Now().UTC().Format("20060102T150405"), b)
+}
+
+// insertJob starts a job with configuration and returns it.
+func insertJob(ctx context.Context, client *http.Client, project string, configuration map[string]interface{}) (*bigQueryJob, error) {
+	table := ""
+	if load, ok := configuration["load"].(map[string]interface{}); ok {
+		if dest, ok := load["destinationTable"].(map[string]string); ok {
+			table = dest["tableId"]
+		}
+	}
+	id := newJobID(table)
+	job := &bigQueryJob{}
+	u := bigQueryBasePath + url.PathEscape(project) + "/jobs"
+	body := map[string]interface{}{
+		"jobReference":  map[string]string{"projectId": project, "jobId": id},
+		"configuration": configuration,
+	}
+	err := rest.DoIdempotent(ctx, client, "POST", u, body, job)
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusConflict {
+		// An earlier attempt created the job before failing.
+		job.JobReference.JobID = id
+		return job, nil
+	}
+	return job, err
+}
+
+// waitForJob polls job until it is done, returning its error if it failed.
+func waitForJob(ctx context.Context, client *http.Client, project string, job *bigQueryJob) error {
+	for job.Status.State != "DONE" {
+		time.Sleep(2 * time.Second)
+		u := rest.URL(bigQueryBasePath,
+			url.PathEscape(project)+"/jobs/"+url.PathEscape(job.JobReference.JobID),
+			url.Values{"location": {job.JobReference.Location}})
+		if err := rest.Get(ctx, client, u, job); err != nil {
+			return fmt.Errorf("waiting for BigQuery job %s: %v", job.JobReference.JobID, err)
+		}
+	}
+	if e := job.Status.ErrorResult; e != nil {
+		return fmt.Errorf("BigQuery job %s failed: %s", job.JobReference.JobID, e.Message)
+	}
+	return nil
+}
+
+// Abort abandons the upload, so no job is created and the table is left
+// as it was.
+func (w *bigQueryWriter) Abort() {
+	w.pw.CloseWithError(errAborted)
+	<-w.done
+}
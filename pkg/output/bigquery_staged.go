@@ -0,0 +1,166 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/gcs"
+)
+
+// bigQueryChunkBytes is the size at which a staged report moves on to its
+// next object. Each object is uploaded as soon as it's full, so a failed
+// upload only resends one chunk and only one chunk is on disk.
+const bigQueryChunkBytes = 256 << 20
+
+// stagedBigQueryWriter loads a report through Cloud Storage, for reports
+// larger than a load job's upload takes: the rows are written to
+// newline-delimited JSON objects under a prefix unique to the run, then
+// loaded by one load job naming them all with a wildcard, so the table is
+// replaced in one step or not at all. The objects are deleted afterwards.
+type stagedBigQueryWriter struct {
+	ctx     context.Context
+	client  *http.Client
+	project string
+	load    map[string]interface{}
+	columns []string
+	bucket  string
+	prefix  string
+	file    *chunkFile
+	buf     *bufio.Writer
+	rows    *json.Encoder
+	objects []string
+}
+
+// chunkFile is the temporary file holding the chunk being written, counting
+// its size for bigQueryChunkBytes.
+type chunkFile struct {
+	*os.File
+	size int
+}
+
+func (f *chunkFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.size += n
+	return n, err
+}
+
+func openStagedBigQuery(ctx context.Context, client *http.Client, project, table, staging string, columns []string, load map[string]interface{}) (Writer, error) {
+	bucket, prefix, err := gcs.ParseURL(staging)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	file, err := ioutil.TempFile("", "bq-"+table+"-")
+	if err != nil {
+		return nil, err
+	}
+	w := &stagedBigQueryWriter{
+		ctx:     ctx,
+		client:  client,
+		project: project,
+		load:    load,
+		columns: columns,
+		bucket:  bucket,
+		prefix:  prefix + table + "-" + time.Now().UTC().Format("20060102T150405") + "-",
+		file:    &chunkFile{File: file},
+	}
+	w.reset()
+	return w, nil
+}
+
+// reset starts the next chunk in the same temporary file.
+func (w *stagedBigQueryWriter) reset() {
+	w.file.size = 0
+	w.buf = bufio.NewWriter(w.file)
+	w.rows = json.NewEncoder(w.buf)
+}
+
+func (w *stagedBigQueryWriter) Write(row []string) error {
+	r := map[string]string{}
+	for i, c := range w.columns {
+		if i < len(row) {
+			r[c] = row[i]
+		}
+	}
+	if err := w.rows.Encode(r); err != nil {
+		return err
+	}
+	if w.file.size+w.buf.Buffered() >= bigQueryChunkBytes {
+		return w.upload()
+	}
+	return nil
+}
+
+func (w *stagedBigQueryWriter) Flush() error { return nil }
+
+// upload sends the chunk in the temporary file as the next object.
+func (w *stagedBigQueryWriter) upload() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	object := fmt.Sprintf("%s%05d.json", w.prefix, len(w.objects)+1)
+	if err := gcs.UploadFile(w.ctx, w.client, w.bucket, object, "application/x-ndjson", w.file.Name()); err != nil {
+		return fmt.Errorf("staging BigQuery load: %v", err)
+	}
+	w.objects = append(w.objects, object)
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, 0); err != nil {
+		return err
+	}
+	w.reset()
+	return nil
+}
+
+// Close uploads the last chunk and runs the load job. The table only
+// changes if the job succeeds.
+func (w *stagedBigQueryWriter) Close() error {
+	if w.file.size+w.buf.Buffered() > 0 || len(w.objects) == 0 {
+		if err := w.upload(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	defer w.cleanUp()
+	load := map[string]interface{}{}
+	for k, v := range w.load {
+		load[k] = v
+	}
+	// A wildcard counts as one source URI however many objects it matches,
+	// and the prefix is only this run's.
+	load["sourceUris"] = []string{"gs://" + w.bucket + "/" + w.prefix + "*"}
+	job, err := insertJob(w.ctx, w.client, w.project, map[string]interface{}{"load": load})
+	if err != nil {
+		return fmt.Errorf("starting BigQuery load: %v", err)
+	}
+	return waitForJob(w.ctx, w.client, w.project, job)
+}
+
+// Abort deletes what was staged, so no job is created and the table is
+// left as it was.
+func (w *stagedBigQueryWriter) Abort() { w.cleanUp() }
+
+// cleanUp removes the temporary file and the staged objects. A staged
+// object that can't be deleted is logged rather than failing the report.
+func (w *stagedBigQueryWriter) cleanUp() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+	for _, o := range w.objects {
+		if err := gcs.Delete(w.ctx, w.client, w.bucket, o); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	w.objects = nil
+}
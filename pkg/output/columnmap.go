@@ -0,0 +1,109 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+)
+
+// MappedColumn is one entry of a column map: a column to write ahead of
+// the rest, under a new name if As is set.
+type MappedColumn struct {
+	Name string
+	As   string
+}
+
+// mapColumns applies the ColumnMap to the columns a projection keeps,
+// given as indexes into header. The mapped columns come first, in the
+// map's order and under their new names, then the rest in their own order.
+// Mapped columns left out by -fields or redaction are skipped. The schema
+// column can be renamed but stays last; its name is returned.
+func (o *Options) mapColumns(header []string, keep []int) ([]int, []string, string, error) {
+	stamp := schema.Column
+	at := map[string]int{}
+	for n, i := range keep {
+		at[header[i]] = n
+	}
+	known := map[string]bool{}
+	for _, name := range header {
+		known[name] = true
+	}
+	mapped := map[int]bool{}
+	order, names := []int{}, []string{}
+	for _, m := range o.ColumnMap {
+		if m.Name == schema.Column && o.Schema != "" {
+			if m.As != "" {
+				stamp = m.As
+			}
+			continue
+		}
+		if !known[m.Name] {
+			return nil, nil, "", fmt.Errorf("-column-map: no column %q: the columns are %s", m.Name, strings.Join(header, ", "))
+		}
+		n, ok := at[m.Name]
+		if !ok {
+			continue
+		}
+		if mapped[n] {
+			return nil, nil, "", fmt.Errorf("-column-map names %s twice", m.Name)
+		}
+		mapped[n] = true
+		name := m.Name
+		if m.As != "" {
+			name = m.As
+		}
+		order = append(order, keep[n])
+		names = append(names, name)
+	}
+	for n, i := range keep {
+		if !mapped[n] {
+			order = append(order, i)
+			names = append(names, header[i])
+		}
+	}
+	all := names
+	if o.Schema != "" {
+		all = append(all[:len(all):len(all)], stamp)
+	}
+	seen := map[string]bool{}
+	for _, name := range all {
+		if seen[name] {
+			return nil, nil, "", fmt.Errorf("-column-map: two columns would be named %s", name)
+		}
+		seen[name] = true
+	}
+	return order, names, stamp, nil
+}
+
+type columnMapValue []MappedColumn
+
+func (v *columnMapValue) String() string {
+	pairs := []string{}
+	for _, m := range *v {
+		if m.As == "" {
+			pairs = append(pairs, m.Name)
+		} else {
+			pairs = append(pairs, m.Name+"="+m.As)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v *columnMapValue) Set(s string) error {
+	*v = nil
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		m := MappedColumn{Name: pair}
+		if i := strings.Index(pair, "="); i >= 0 {
+			m.Name, m.As = strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+			if m.Name == "" || m.As == "" {
+				return fmt.Errorf("%q is not old=new", pair)
+			}
+		}
+		*v = append(*v, m)
+	}
+	return nil
+}
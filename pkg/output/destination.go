@@ -0,0 +1,279 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/gcs"
+)
+
+// Stdout is the target, usable as an -output-file or -output, that
+// streams the report to standard output.
+const Stdout = "-"
+
+// fileScheme writes local files, and is used for any target without a
+// registered scheme.
+var fileScheme = &Scheme{
+	Check: func(string) error { return nil },
+	Open: func(target string, columns []string, format string) (Writer, error) {
+		if target == Stdout {
+			w, err := newStreamWriter(stdoutSink{}, columns, format)
+			if err != nil {
+				return nil, err
+			}
+			return &flushingWriter{w}, nil
+		}
+		s, err := createFile(target)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamWriter(s, columns, format)
+	},
+}
+
+func init() {
+	RegisterScheme("gs", &Scheme{Check: checkGCS, Open: openGCS})
+}
+
+// sink is an open byte stream that one of the Formats encodes a report
+// to: a local file, or a gs://bucket/object URL uploaded with Application
+// Default Credentials.
+type sink interface {
+	io.Writer
+	// Close finishes the destination, which for an upload is when it is
+	// written.
+	Close() error
+	// Abort gives up on the destination after a failure. An upload in
+	// progress is abandoned rather than left holding a partial report.
+	Abort()
+}
+
+// streamWriter encodes rows to a sink.
+type streamWriter struct {
+	RowWriter
+	s sink
+}
+
+func newStreamWriter(s sink, columns []string, format string) (Writer, error) {
+	enc, err := formats[format].New(s, columns)
+	if err != nil {
+		s.Abort()
+		return nil, err
+	}
+	return &streamWriter{RowWriter: enc, s: s}, nil
+}
+
+func (w *streamWriter) Close() error {
+	if err := w.RowWriter.Close(); err != nil {
+		w.s.Abort()
+		return err
+	}
+	return w.s.Close()
+}
+
+func (w *streamWriter) Abort() { w.s.Abort() }
+
+// unclosed is a Writer over a stream the caller owns, so Abort has nothing
+// to give up.
+type unclosed struct {
+	RowWriter
+}
+
+func (unclosed) Abort() {}
+
+func checkGCS(target string) error {
+	_, object, err := gcs.ParseURL(target)
+	if err == nil && (object == "" || strings.HasSuffix(object, "/")) {
+		err = fmt.Errorf("%q names a bucket or folder, not an object", target)
+	}
+	return err
+}
+
+func openGCS(target string, columns []string, format string) (Writer, error) {
+	bucket, object, err := gcs.ParseURL(target)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	client, err := auth.DefaultClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't upload %s: %v", target, err)
+	}
+	// The upload streams from a pipe, so a large report is never held in
+	// memory.
+	pr, pw := io.Pipe()
+	s := &gcsSink{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := gcs.Upload(ctx, client, bucket, object, formats[format].ContentType, pr)
+		pr.CloseWithError(err)
+		s.done <- err
+	}()
+	return newStreamWriter(s, columns, format)
+}
+
+// stdoutSink writes to standard output, which outlives the report.
+type stdoutSink struct{}
+
+func (stdoutSink) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdoutSink) Close() error                { return nil }
+func (stdoutSink) Abort()                      {}
+
+// flushingWriter flushes every row, so whatever reads a pipe sees each row
+// as the tool produces it rather than when the buffer fills.
+type flushingWriter struct {
+	Writer
+}
+
+func (w *flushingWriter) Write(row []string) error {
+	if err := w.Writer.Write(row); err != nil {
+		return err
+	}
+	return w.Writer.Flush()
+}
+
+// fileSink writes a local file under a temporary name that Close renames
+// to the target, so that an aborted report leaves the previous one in
+// place.
+type fileSink struct {
+	*os.File
+	// target is the name the file is renamed to, or "" when it is written
+	// in place.
+	target string
+}
+
+func createFile(target string) (*fileSink, error) {
+	// A device or pipe such as /dev/stderr can't be replaced, so it is
+	// written in place.
+	if info, err := os.Stat(target); err == nil && !info.Mode().IsRegular() {
+		file, err := os.Create(target)
+		if err != nil {
+			return nil, err
+		}
+		return &fileSink{File: file}, nil
+	}
+	file, err := os.Create(target + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{File: file, target: target}, nil
+}
+
+func (s *fileSink) Close() error {
+	if err := s.File.Close(); err != nil {
+		s.remove()
+		return err
+	}
+	if s.target == "" {
+		return nil
+	}
+	if err := os.Rename(s.Name(), s.target); err != nil {
+		s.remove()
+		return err
+	}
+	return nil
+}
+
+func (s *fileSink) Abort() {
+	s.File.Close()
+	s.remove()
+}
+
+// remove deletes the temporary file.
+func (s *fileSink) remove() {
+	if s.target != "" {
+		os.Remove(s.Name())
+	}
+}
+
+var errAborted = errors.New("report abandoned")
+
+type gcsSink struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (s *gcsSink) Write(p []byte) (int, error) { return s.pw.Write(p) }
+
+func (s *gcsSink) Close() error {
+	s.pw.Close()
+	return <-s.done
+}
+
+func (s *gcsSink) Abort() {
+	s.pw.CloseWithError(errAborted)
+	<-s.done
+}
+
+// fanout writes each row to several reports, then finishes the
+// destinations behind them.
+type fanout struct {
+	o          *Options
+	writers    []*projection
+	verifier   *verifier
+	incomplete bool
+}
+
+func (f *fanout) Write(row []string) error {
+	if f.verifier != nil {
+		f.verifier.record(row)
+	}
+	for _, w := range f.writers {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fanout) Flush() error {
+	for _, w := range f.writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes every report and destination, returning the first error.
+// When verifying, the checks run first and the report is added to the
+// manifest; with VerifyStrict a failed check aborts the destinations. A
+// report marked incomplete is kept, as the checks are bound to fail.
+func (f *fanout) Close() error {
+	var e *ManifestEntry
+	if f.verifier != nil {
+		e = f.verifier.entry()
+		e.Incomplete = f.incomplete
+		if !e.OK && f.o.VerifyStrict && !f.incomplete {
+			f.abort()
+			e.Aborted = true
+			if err := f.o.addToManifest(e); err != nil {
+				return err
+			}
+			return fmt.Errorf("report %s failed verification and was not written", f.o.Schema)
+		}
+	}
+	var first error
+	for _, w := range f.writers {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	if e != nil {
+		if err := f.o.addToManifest(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f *fanout) abort() {
+	for _, w := range f.writers {
+		w.w.Abort()
+	}
+}
@@ -0,0 +1,577 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/catalog"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+)
+
+func init() {
+	RegisterFormat("csv", &Format{ContentType: "text/csv", New: newCSVEncoder(',')})
+	RegisterFormat("tsv", &Format{ContentType: "text/tab-separated-values", New: newCSVEncoder('\t')})
+	RegisterFormat("json", &Format{ContentType: "application/json", New: newJSONEncoder})
+	RegisterFormat("jsonl", &Format{ContentType: "application/x-ndjson", New: newJSONLinesEncoder})
+}
+
+// Options are the output controls every report shares: which columns to
+// keep (-fields), which rows to keep (-filter), what to call the columns
+// and in what order (-column-map), how to encode the result (-format, or
+// -output-format), what to redact (-redact-rules) and where to write it
+// (-output).
+type Options struct {
+	Fields  []string
+	Filters []*Filter
+	// ColumnMap renames the columns it names and writes them first, in
+	// its order. Fields, filters and redaction rules use the report's own
+	// names.
+	ColumnMap []MappedColumn
+	Format    string
+	// Schema, if set, is written as a final schema.Column on every row.
+	Schema string
+	// Redact, if set, is applied to the report. With RedactedFile the
+	// report is written in full and a redacted copy to RedactedFile.
+	Redact       *RedactRules
+	RedactedFile string
+	// Destinations, if set, replace the path given to Create and
+	// WriteFile, so one fetch can feed several consumers.
+	Destinations []string
+	// ManifestFile, if set, is where each report's row counts and checks
+	// are written (see Manifest). With VerifyStrict a report that fails a
+	// check is aborted rather than committed.
+	ManifestFile string
+	VerifyStrict bool
+
+	expected map[expectation]int
+	manifest Manifest
+}
+
+// RegisterFlags defines -fields, -format (and its alias -output-format),
+// -filter, -column-map, -redact-rules, -redacted-output-file, -output,
+// -manifest-file and -verify-strict on fs and returns the Options they
+// populate. report names the report's schema (see package schema) and
+// format is the default encoding.
+func RegisterFlags(fs *flag.FlagSet, report, format string) *Options {
+	o := &Options{Format: format, Schema: schema.Stamp(report)}
+	fs.Var((*fieldsValue)(&o.Fields), "fields", "Comma separated columns to output, in order. Defaults to all columns.")
+	fs.StringVar(&o.Format, "format", format, "The output format: "+strings.Join(Formats, ", ")+".")
+	fs.StringVar(&o.Format, "output-format", format, "Same as -format.")
+	fs.Var((*filtersValue)(&o.Filters), "filter", "Only output rows matching column=value, column!=value, column~regexp, column!~regexp, or column>n (also <, >=, <=). Repeat to require several.")
+	fs.Var((*columnMapValue)(&o.ColumnMap), "column-map", "Comma separated old=new column renames, e.g. email=user_email, written first in the order given; a column named without =new is only moved. The schema_version column can be renamed but stays last.")
+	fs.Var(redactValue{&o.Redact}, "redact-rules", "A YAML file of columns to drop, blank, mask or hash, for reports shared beyond the admins.")
+	fs.StringVar(&o.RedactedFile, "redacted-output-file", "", "With -redact-rules, write the report in full and a redacted copy to this file or gs:// URL.")
+	fs.Var((*destinationsValue)(&o.Destinations), "output", "Write the report to this file or gs://bucket/object instead of -output-file. Repeat to write several copies from one fetch; a .csv, .tsv, .json, .jsonl or .parquet extension overrides -format. Also sheets://, bq:// and sqlite:// destinations.")
+	fs.StringVar(&o.ManifestFile, "manifest-file", "", "Write a JSON manifest of the report's row count, empty fields, duplicates and checks against the counts the API reports.")
+	fs.BoolVar(&o.VerifyStrict, "verify-strict", false, "Abort a report that fails a check rather than committing it, so uploads and tables keep their previous contents, and exit with an error.")
+	return o
+}
+
+// CheckFormat returns an error if the format isn't one of Formats, a
+// destination is malformed, a redacted copy is asked for without rules,
+// the report has no schema version, or -fields or -filter name a column
+// the catalog doesn't list for the report, so a mistake is reported before
+// a long fetch rather than after it.
+func (o *Options) CheckFormat() error {
+	if err := schema.Check(o.Schema); err != nil {
+		return err
+	}
+	if err := o.checkColumns(); err != nil {
+		return err
+	}
+	if o.RedactedFile != "" && o.Redact == nil {
+		return fmt.Errorf("-redacted-output-file needs -redact-rules")
+	}
+	for _, target := range append([]string{o.RedactedFile}, o.Destinations...) {
+		if err := checkDestination(target); err != nil {
+			return err
+		}
+	}
+	return checkFormat(o.Format)
+}
+
+// checkColumns checks the columns -fields and -filter name against those
+// the catalog lists for the report. The catalog lists every column the
+// report can have, so one that only some flags add is accepted here and
+// checked against the actual header when the report is created.
+func (o *Options) checkColumns() error {
+	var columns []string
+	for _, t := range catalog.Tools {
+		for _, out := range t.Outputs {
+			if o.Schema != "" && out.Schema == o.Schema {
+				columns = out.Columns
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, c := range columns {
+		known[c] = true
+	}
+	for _, name := range o.Fields {
+		if !known[name] {
+			return fmt.Errorf("-fields: no column %q: the columns are %s", name, strings.Join(columns, ", "))
+		}
+	}
+	for _, f := range o.Filters {
+		if !known[f.Column] {
+			return fmt.Errorf("-filter: no column %q: the columns are %s", f.Column, strings.Join(columns, ", "))
+		}
+	}
+	return nil
+}
+
+// WriteFile writes rows, header first, to path (or the Destinations).
+func (o *Options) WriteFile(path string, rows [][]string) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("no header for %s", path)
+	}
+	w, err := o.create(path, rows[0])
+	if err != nil {
+		return err
+	}
+	for _, row := range rows[1:] {
+		if err := w.Write(row); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// RowWriter is a report being written a row at a time.
+type RowWriter interface {
+	// Write adds a row, which has the header's columns in the header's
+	// order. Rows excluded by a filter are silently dropped.
+	Write(row []string) error
+	// Flush writes buffered rows through to the underlying writer.
+	Flush() error
+	// Close flushes and finishes the encoding. It does not close the
+	// underlying writer.
+	Close() error
+}
+
+// Create opens path, or each of the Destinations if there are any, and
+// returns a RowWriter writing the report with the given header to all of
+// them, plus the redacted copy if there is a RedactedFile. Its Close
+// closes the files and completes the uploads.
+func (o *Options) Create(path string, header []string) (RowWriter, error) {
+	f, err := o.create(path, header)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (o *Options) create(path string, header []string) (*fanout, error) {
+	if err := o.CheckFormat(); err != nil {
+		return nil, err
+	}
+	targets := o.Destinations
+	if len(targets) == 0 {
+		targets = []string{path}
+	}
+	rules := o.Redact
+	if o.RedactedFile != "" {
+		rules = nil
+	}
+	// Header problems are found before anything is created.
+	if _, _, err := o.newProjection(header, o.Redact); err != nil {
+		return nil, err
+	}
+	f := &fanout{o: o}
+	if o.verifying() {
+		var err error
+		if f.verifier, err = o.newVerifier(targets, header); err != nil {
+			return nil, err
+		}
+	}
+	add := func(target string, rules *RedactRules) error {
+		p, columns, err := o.newProjection(header, rules)
+		if err != nil {
+			return err
+		}
+		if p.w, err = openDestination(target, columns, formatFor(target, o.Format)); err != nil {
+			return err
+		}
+		f.writers = append(f.writers, p)
+		return nil
+	}
+	for _, target := range targets {
+		if err := add(target, rules); err != nil {
+			f.abort()
+			return nil, err
+		}
+	}
+	if o.RedactedFile != "" {
+		if err := add(o.RedactedFile, o.Redact); err != nil {
+			f.abort()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// NewWriter returns a RowWriter that encodes reports with the given header
+// to w, applying the fields, filters and redaction. It fails if they name
+// columns the header doesn't have. With RedactedFile, that file is created
+// and the redacted copy written to it alongside. Destinations are ignored;
+// use Create to honor them.
+func (o *Options) NewWriter(w io.Writer, header []string) (RowWriter, error) {
+	if err := o.CheckFormat(); err != nil {
+		return nil, err
+	}
+	rules := o.Redact
+	if o.RedactedFile != "" {
+		rules = nil
+	}
+	full, columns, err := o.newProjection(header, rules)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := formats[o.Format].New(w, columns)
+	if err != nil {
+		return nil, err
+	}
+	full.w = unclosed{enc}
+	if o.RedactedFile == "" {
+		return full, nil
+	}
+	redacted, columns, err := o.newProjection(header, o.Redact)
+	if err != nil {
+		return nil, err
+	}
+	if redacted.w, err = openDestination(o.RedactedFile, columns, formatFor(o.RedactedFile, o.Format)); err != nil {
+		return nil, err
+	}
+	return &fanout{o: o, writers: []*projection{full, redacted}}, nil
+}
+
+// newProjection returns the projection of header the fields, filters and
+// rules call for, and the columns it writes. The caller sets its Writer.
+func (o *Options) newProjection(header []string, rules *RedactRules) (*projection, []string, error) {
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[name] = i
+	}
+	unknown := func(name string) error {
+		return fmt.Errorf("no column %q: the columns are %s", name, strings.Join(header, ", "))
+	}
+	p := &projection{}
+	for _, f := range o.Filters {
+		i, ok := cols[f.Column]
+		if !ok {
+			return nil, nil, unknown(f.Column)
+		}
+		p.filters = append(p.filters, f)
+		p.filterCols = append(p.filterCols, i)
+	}
+	if len(o.Fields) == 0 {
+		for i := range header {
+			p.keep = append(p.keep, i)
+		}
+	}
+	for _, name := range o.Fields {
+		i, ok := cols[name]
+		if !ok {
+			return nil, nil, unknown(name)
+		}
+		p.keep = append(p.keep, i)
+	}
+	if rules != nil {
+		p.redact = map[int]func(string) string{}
+		keep := []int{}
+		for _, i := range p.keep {
+			redact, drop := rules.redactor(header[i])
+			if drop {
+				continue
+			}
+			if redact != nil {
+				p.redact[i] = redact
+			}
+			keep = append(keep, i)
+		}
+		p.keep = keep
+	}
+	names := make([]string, len(p.keep))
+	for n, i := range p.keep {
+		names[n] = header[i]
+	}
+	stamp := schema.Column
+	if len(o.ColumnMap) > 0 {
+		var err error
+		if p.keep, names, stamp, err = o.mapColumns(header, p.keep); err != nil {
+			return nil, nil, err
+		}
+	}
+	if o.Schema != "" {
+		p.stamp = o.Schema
+		names = append(names, stamp)
+	}
+	return p, names, nil
+}
+
+// projection applies the filters, column selection and redaction before
+// handing rows to the encoder. Filters see the unredacted values.
+type projection struct {
+	filters    []*Filter
+	filterCols []int
+	keep       []int
+	redact     map[int]func(string) string
+	stamp      string
+	w          Writer
+}
+
+func (p *projection) project(row []string) []string {
+	out := make([]string, len(p.keep))
+	for n, i := range p.keep {
+		if i < len(row) {
+			out[n] = row[i]
+		}
+		if redact, ok := p.redact[i]; ok {
+			out[n] = redact(out[n])
+		}
+	}
+	return out
+}
+
+func (p *projection) Write(row []string) error {
+	for n, f := range p.filters {
+		value := ""
+		if i := p.filterCols[n]; i < len(row) {
+			value = row[i]
+		}
+		if !f.Match(value) {
+			return nil
+		}
+	}
+	out := p.project(row)
+	if p.stamp != "" {
+		out = append(out, p.stamp)
+	}
+	return p.w.Write(out)
+}
+
+func (p *projection) Flush() error { return p.w.Flush() }
+func (p *projection) Close() error { return p.w.Close() }
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+// newCSVEncoder returns a Format's New for comma separated values, or
+// tab separated ones with '\t'.
+func newCSVEncoder(comma rune) func(io.Writer, []string) (RowWriter, error) {
+	return func(w io.Writer, columns []string) (RowWriter, error) {
+		c := csv.NewWriter(w)
+		c.Comma = comma
+		if err := c.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{c}, nil
+	}
+}
+
+func (e *csvEncoder) Write(row []string) error { return e.w.Write(row) }
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error { return e.Flush() }
+
+// jsonEncoder writes an array of objects whose keys are in column order.
+type jsonEncoder struct {
+	w     *bufio.Writer
+	names []string
+	rows  int
+}
+
+func newJSONEncoder(w io.Writer, columns []string) (RowWriter, error) {
+	return &jsonEncoder{w: bufio.NewWriter(w), names: columns}, nil
+}
+
+func (e *jsonEncoder) Write(row []string) error {
+	sep := ",\n"
+	if e.rows == 0 {
+		sep = "[\n"
+	}
+	e.rows++
+	e.w.WriteString(sep + "  ")
+	return writeObject(e.w, e.names, row)
+}
+
+// writeObject writes row as a JSON object keyed by names, in order.
+func writeObject(w *bufio.Writer, names, row []string) error {
+	w.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		k, _ := json.Marshal(name)
+		v, _ := json.Marshal(row[i])
+		w.Write(k)
+		w.WriteString(": ")
+		w.Write(v)
+	}
+	_, err := w.WriteString("}")
+	return err
+}
+
+func (e *jsonEncoder) Flush() error { return e.w.Flush() }
+
+func (e *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if e.rows == 0 {
+		end = "[]\n"
+	}
+	e.w.WriteString(end)
+	return e.w.Flush()
+}
+
+// jsonLinesEncoder writes newline-delimited JSON, one object per row, which
+// BigQuery and most log pipelines load directly.
+type jsonLinesEncoder struct {
+	w     *bufio.Writer
+	names []string
+}
+
+func newJSONLinesEncoder(w io.Writer, columns []string) (RowWriter, error) {
+	return &jsonLinesEncoder{w: bufio.NewWriter(w), names: columns}, nil
+}
+
+func (e *jsonLinesEncoder) Write(row []string) error {
+	if err := writeObject(e.w, e.names, row); err != nil {
+		return err
+	}
+	_, err := e.w.WriteString("\n")
+	return err
+}
+
+func (e *jsonLinesEncoder) Flush() error { return e.w.Flush() }
+func (e *jsonLinesEncoder) Close() error { return e.w.Flush() }
+
+// Filter is one -filter condition on a column.
+type Filter struct {
+	Column string
+	Op     string
+	Value  string
+	re     *regexp.Regexp
+	num    float64
+}
+
+// filterOps are checked longest first so "!=" isn't read as "!" then "=".
+var filterOps = []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"}
+
+// ParseFilter parses a -filter expression such as "role=OWNER".
+func ParseFilter(expr string) (*Filter, error) {
+	i := strings.IndexAny(expr, "=!~<>")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid filter %q: expected column, operator and value, e.g. role=OWNER", expr)
+	}
+	f := &Filter{Column: strings.TrimSpace(expr[:i])}
+	for _, op := range filterOps {
+		if strings.HasPrefix(expr[i:], op) {
+			f.Op = op
+			f.Value = expr[i+len(op):]
+			break
+		}
+	}
+	var err error
+	switch f.Op {
+	case "":
+		return nil, fmt.Errorf("invalid filter %q: unknown operator", expr)
+	case "~", "!~":
+		if f.re, err = regexp.Compile(f.Value); err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %v", expr, err)
+		}
+	case ">", "<", ">=", "<=":
+		if f.num, err = strconv.ParseFloat(f.Value, 64); err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %s needs a number", expr, f.Op)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether value satisfies the filter. Equality ignores case,
+// since most columns are addresses; numeric comparisons fail on values that
+// aren't numbers.
+func (f *Filter) Match(value string) bool {
+	switch f.Op {
+	case "=":
+		return strings.EqualFold(value, f.Value)
+	case "!=":
+		return !strings.EqualFold(value, f.Value)
+	case "~":
+		return f.re.MatchString(value)
+	case "!~":
+		return !f.re.MatchString(value)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	switch f.Op {
+	case ">":
+		return n > f.num
+	case "<":
+		return n < f.num
+	case ">=":
+		return n >= f.num
+	}
+	return n <= f.num
+}
+
+func (f *Filter) String() string { return f.Column + f.Op + f.Value }
+
+type fieldsValue []string
+
+func (v *fieldsValue) String() string { return strings.Join(*v, ",") }
+
+func (v *fieldsValue) Set(s string) error {
+	*v = nil
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*v = append(*v, name)
+		}
+	}
+	return nil
+}
+
+type destinationsValue []string
+
+func (v *destinationsValue) String() string { return strings.Join(*v, " ") }
+
+func (v *destinationsValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+type filtersValue []*Filter
+
+func (v *filtersValue) String() string {
+	exprs := []string{}
+	for _, f := range *v {
+		exprs = append(exprs, f.String())
+	}
+	return strings.Join(exprs, " ")
+}
+
+func (v *filtersValue) Set(s string) error {
+	f, err := ParseFilter(s)
+	if err != nil {
+		return err
+	}
+	*v = append(*v, f)
+	return nil
+}
@@ -0,0 +1,50 @@
+package output
+
+import "fmt"
+
+// IncompleteMarker is the first value of the row MarkIncomplete writes. A
+// parser reading a report should treat a row starting with it as the end.
+const IncompleteMarker = "#incomplete"
+
+// marker is a RowWriter that can end its report with an incomplete row.
+type marker interface {
+	markIncomplete(reason string) error
+}
+
+// MarkIncomplete writes a last row to w saying the report stopped early
+// and why, e.g. because the run was interrupted, so a partial file is
+// still a valid report but can't be mistaken for a whole one. The row has
+// the marker in the first column and the reason in the second, and is
+// written whatever the filters. When verifying, the manifest entry is
+// marked incomplete rather than failed. Call it before Close.
+func MarkIncomplete(w RowWriter, reason string) error {
+	m, ok := w.(marker)
+	if !ok {
+		return fmt.Errorf("%T can't mark a report incomplete", w)
+	}
+	return m.markIncomplete(reason)
+}
+
+func (f *fanout) markIncomplete(reason string) error {
+	f.incomplete = true
+	for _, w := range f.writers {
+		if err := w.markIncomplete(reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *projection) markIncomplete(reason string) error {
+	out := make([]string, len(p.keep))
+	if len(out) > 0 {
+		out[0] = IncompleteMarker
+	}
+	if len(out) > 1 {
+		out[1] = reason
+	}
+	if p.stamp != "" {
+		out = append(out, p.stamp)
+	}
+	return p.w.Write(out)
+}
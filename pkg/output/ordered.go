@@ -0,0 +1,62 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OrderedWriter writes numbered batches of rows in sequence order, however
+// out of order they are submitted. Batch n is written as soon as batches 0
+// through n-1 have been, so the rows of one batch (e.g. one group's members)
+// stay contiguous and the file is laid out exactly as a sequential run
+// would lay it out. It is safe for concurrent use.
+type OrderedWriter struct {
+	mu      sync.Mutex
+	w       RowWriter
+	next    int
+	pending map[int][][]string
+}
+
+// NewOrderedWriter returns an OrderedWriter whose first batch is 0.
+func NewOrderedWriter(w RowWriter) *OrderedWriter {
+	return &OrderedWriter{w: w, pending: map[int][][]string{}}
+}
+
+// Write submits batch seq. A batch with no rows must still be submitted so
+// later batches aren't held back waiting for it.
+func (o *OrderedWriter) Write(seq int, rows [][]string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if seq < o.next {
+		return fmt.Errorf("batch %d already written", seq)
+	}
+	if _, dup := o.pending[seq]; dup {
+		return fmt.Errorf("batch %d submitted twice", seq)
+	}
+	o.pending[seq] = rows
+	for {
+		batch, ok := o.pending[o.next]
+		if !ok {
+			break
+		}
+		delete(o.pending, o.next)
+		o.next++
+		for _, row := range batch {
+			if err := o.w.Write(row); err != nil {
+				return err
+			}
+		}
+		// Flush per batch so a partial file is always made of whole batches.
+		if err := o.w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Held returns the number of batches waiting on an earlier one.
+func (o *OrderedWriter) Held() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
@@ -0,0 +1,49 @@
+// Package output writes the tabular reports produced by the tools.
+// Destinations are registered by scheme (RegisterScheme) and byte
+// encodings by name (RegisterFormat), so a new one is added in this
+// package without touching any tool.
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+)
+
+// WriteCSV writes rows, header first, to a CSV file at path.
+func WriteCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// CreateNew creates a file at path, failing if there already is one, so a
+// rollback file from an earlier run is never overwritten.
+func CreateNew(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("%s already exists; move it aside or choose another file", path)
+	}
+	return file, err
+}
+
+// WriteNewCSV is WriteCSV to a file that mustn't exist yet.
+func WriteNewCSV(path string, rows [][]string) error {
+	file, err := CreateNew(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,260 @@
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+func init() {
+	RegisterFormat("parquet", &Format{ContentType: "application/vnd.apache.parquet", New: newParquetEncoder})
+}
+
+// parquetRowGroup is how many rows are buffered before they are written
+// out as a row group. Parquet stores a group column by column, so rows
+// can't be written as they arrive.
+const parquetRowGroup = 50000
+
+// Parquet thrift enum values.
+const (
+	parquetByteArray    = 6
+	parquetRequired     = 0
+	parquetUTF8         = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// parquetEncoder writes an uncompressed Parquet file whose columns are all
+// required UTF8 strings, one data page per column per row group.
+type parquetEncoder struct {
+	w       *bufio.Writer
+	offset  int64
+	columns []string
+	rows    [][]string
+	groups  []*parquetGroup
+	total   int64
+	err     error
+}
+
+// parquetGroup records where a row group's column chunks were written,
+// for the footer.
+type parquetGroup struct {
+	rows    int64
+	offsets []int64
+	sizes   []int64
+}
+
+func newParquetEncoder(w io.Writer, columns []string) (RowWriter, error) {
+	e := &parquetEncoder{w: bufio.NewWriter(w), columns: columns}
+	e.write([]byte("PAR1"))
+	return e, e.err
+}
+
+func (e *parquetEncoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.Write(p)
+	e.offset += int64(n)
+	e.err = err
+}
+
+func (e *parquetEncoder) Write(row []string) error {
+	e.rows = append(e.rows, row)
+	if len(e.rows) >= parquetRowGroup {
+		e.writeGroup()
+	}
+	return e.err
+}
+
+// Flush writes nothing: a row group is only written once it is full, or
+// on Close.
+func (e *parquetEncoder) Flush() error { return e.err }
+
+func (e *parquetEncoder) writeGroup() {
+	if len(e.rows) == 0 {
+		return
+	}
+	g := &parquetGroup{rows: int64(len(e.rows))}
+	for c := range e.columns {
+		data := []byte{}
+		var size [4]byte
+		for _, row := range e.rows {
+			v := ""
+			if c < len(row) {
+				v = row[c]
+			}
+			binary.LittleEndian.PutUint32(size[:], uint32(len(v)))
+			data = append(data, size[:]...)
+			data = append(data, v...)
+		}
+		t := &thriftCompact{}
+		t.i32(1, parquetDataPage)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(data)))
+		t.beginStruct(5)
+		t.i32(1, int32(len(e.rows)))
+		t.i32(2, parquetPlain)
+		t.i32(3, parquetRLE)
+		t.i32(4, parquetRLE)
+		t.endStruct()
+		t.stop()
+		g.offsets = append(g.offsets, e.offset)
+		g.sizes = append(g.sizes, int64(len(t.buf)+len(data)))
+		e.write(t.buf)
+		e.write(data)
+	}
+	e.groups = append(e.groups, g)
+	e.total += g.rows
+	e.rows = nil
+}
+
+func (e *parquetEncoder) Close() error {
+	e.writeGroup()
+	t := &thriftCompact{}
+	t.i32(1, 1)
+	t.listHeader(2, thriftStruct, len(e.columns)+1)
+	t.beginElem()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(e.columns)))
+	t.endStruct()
+	for _, name := range e.columns {
+		t.beginElem()
+		t.i32(1, parquetByteArray)
+		t.i32(3, parquetRequired)
+		t.binary(4, name)
+		t.i32(6, parquetUTF8)
+		t.endStruct()
+	}
+	t.i64(3, e.total)
+	t.listHeader(4, thriftStruct, len(e.groups))
+	for _, g := range e.groups {
+		t.beginElem()
+		var bytes int64
+		t.listHeader(1, thriftStruct, len(e.columns))
+		for c, name := range e.columns {
+			t.beginElem()
+			t.i64(2, g.offsets[c])
+			t.beginStruct(3)
+			t.i32(1, parquetByteArray)
+			t.listHeader(2, thriftI32, 2)
+			t.varint(zigzag(parquetPlain))
+			t.varint(zigzag(parquetRLE))
+			t.listHeader(3, thriftBinary, 1)
+			t.varint(uint64(len(name)))
+			t.buf = append(t.buf, name...)
+			t.i32(4, parquetUncompressed)
+			t.i64(5, g.rows)
+			t.i64(6, g.sizes[c])
+			t.i64(7, g.sizes[c])
+			t.i64(9, g.offsets[c])
+			t.endStruct()
+			t.endStruct()
+			bytes += g.sizes[c]
+		}
+		t.i64(2, bytes)
+		t.i64(3, g.rows)
+		t.endStruct()
+	}
+	t.binary(6, "google_apps_tools")
+	t.stop()
+	e.write(t.buf)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(t.buf)))
+	e.write(size[:])
+	e.write([]byte("PAR1"))
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// Thrift compact protocol type codes.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact encodes the thrift compact protocol, which Parquet's page
+// headers and footer use. Only the types those need are supported.
+type thriftCompact struct {
+	buf []byte
+	// last is the previous field id in each struct being written.
+	last []int16
+}
+
+func (t *thriftCompact) field(id int16, typ byte) {
+	if len(t.last) == 0 {
+		t.last = []int16{0}
+	}
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftCompact) varint(v uint64) {
+	for v >= 0x80 {
+		t.buf = append(t.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	t.buf = append(t.buf, byte(v))
+}
+
+func zigzag(v int64) uint64 { return uint64((v << 1) ^ (v >> 63)) }
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftCompact) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+func (t *thriftCompact) listHeader(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elem)
+	t.varint(uint64(n))
+}
+
+// beginStruct starts a struct field; beginElem starts a struct list
+// element. Both are finished by endStruct.
+func (t *thriftCompact) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+func (t *thriftCompact) beginElem() {
+	if len(t.last) == 0 {
+		t.last = []int16{0}
+	}
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftCompact) endStruct() {
+	t.stop()
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftCompact) stop() { t.buf = append(t.buf, 0) }
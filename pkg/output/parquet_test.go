@@ -0,0 +1,200 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// thriftReader decodes the thrift compact protocol into maps of field id
+// to value: int64 for integers, string for binary, []interface{} for lists
+// and map[int16]interface{} for structs.
+type thriftReader struct {
+	buf []byte
+	err error
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.buf) == 0 {
+		r.err = fmt.Errorf("unexpected end of thrift data")
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	var v uint64
+	for shift := uint(0); r.err == nil; shift += 7 {
+		b := r.byte()
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+	}
+	return v
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v := r.varint()
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		n := int(r.varint())
+		if n > len(r.buf) {
+			r.err = fmt.Errorf("binary of %d bytes overruns the data", n)
+			return ""
+		}
+		s := string(r.buf[:n])
+		r.buf = r.buf[n:]
+		return s
+	case thriftList:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.varint())
+		}
+		list := []interface{}{}
+		for i := 0; i < n && r.err == nil; i++ {
+			list = append(list, r.value(h&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	r.err = fmt.Errorf("unsupported thrift type %d", typ)
+	return nil
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var last int16
+	for r.err == nil {
+		h := r.byte()
+		if h == 0 {
+			break
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v := r.varint()
+			id = int16(int64(v>>1) ^ -int64(v&1))
+		}
+		fields[id] = r.value(h & 0x0f)
+		last = id
+	}
+	return fields
+}
+
+// readParquet decodes a file written by parquetEncoder back into its
+// column names and rows.
+func readParquet(data []byte) ([]string, [][]string, error) {
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		return nil, nil, fmt.Errorf("missing PAR1 magic")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if size > len(data)-12 {
+		return nil, nil, fmt.Errorf("footer length %d overruns the file", size)
+	}
+	r := &thriftReader{buf: data[len(data)-8-size : len(data)-8]}
+	meta := r.structure()
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if len(r.buf) != 0 {
+		return nil, nil, fmt.Errorf("%d bytes after the footer", len(r.buf))
+	}
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if root[5].(int64) != int64(len(schema)-1) {
+		return nil, nil, fmt.Errorf("root has %d children, want %d", root[5], len(schema)-1)
+	}
+	columns := []string{}
+	for _, s := range schema[1:] {
+		columns = append(columns, s.(map[int16]interface{})[4].(string))
+	}
+	rows := [][]string{}
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		n := int(group[3].(int64))
+		values := make([][]string, n)
+		for _, c := range group[1].([]interface{}) {
+			chunk := c.(map[int16]interface{})[3].(map[int16]interface{})
+			offset, size := chunk[9].(int64), chunk[6].(int64)
+			page := &thriftReader{buf: data[offset : offset+size]}
+			header := page.structure()
+			if page.err != nil {
+				return nil, nil, page.err
+			}
+			if len(page.buf) != int(header[3].(int64)) {
+				return nil, nil, fmt.Errorf("page at %d holds %d bytes, its header says %d", offset, len(page.buf), header[3])
+			}
+			for i := 0; i < n; i++ {
+				if len(page.buf) < 4 {
+					return nil, nil, fmt.Errorf("page at %d ends at value %d of %d", offset, i, n)
+				}
+				l := int(binary.LittleEndian.Uint32(page.buf))
+				values[i] = append(values[i], string(page.buf[4:4+l]))
+				page.buf = page.buf[4+l:]
+			}
+		}
+		rows = append(rows, values...)
+	}
+	if meta[3].(int64) != int64(len(rows)) {
+		return nil, nil, fmt.Errorf("footer says %d rows, the row groups hold %d", meta[3], len(rows))
+	}
+	return columns, rows, nil
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	wide := []string{}
+	for i := 0; i < 16; i++ {
+		wide = append(wide, fmt.Sprintf("c%d", i))
+	}
+	many := [][]string{}
+	for i := 0; i <= parquetRowGroup; i++ {
+		many = append(many, []string{fmt.Sprint(i), "x"})
+	}
+	tests := []struct {
+		name    string
+		columns []string
+		rows    [][]string
+		want    [][]string
+	}{
+		{"empty", []string{"email"}, nil, [][]string{}},
+		{"short rows are padded", []string{"email", "role"}, [][]string{{"a@example.com", "OWNER"}, {"b@example.com"}, {"", "MEMBER"}},
+			[][]string{{"a@example.com", "OWNER"}, {"b@example.com", ""}, {"", "MEMBER"}}},
+		{"utf8", []string{"name"}, [][]string{{"Zoë"}, {"渡辺"}}, [][]string{{"Zoë"}, {"渡辺"}}},
+		{"long schema list", wide, [][]string{wide}, [][]string{wide}},
+		{"two row groups", []string{"n", "v"}, many, many},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := newParquetEncoder(&buf, tt.columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range tt.rows {
+			if err := w.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		columns, rows, err := readParquet(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(columns, tt.columns) {
+			t.Errorf("%s: got columns %q, want %q", tt.name, columns, tt.columns)
+		}
+		if !reflect.DeepEqual(rows, tt.want) {
+			t.Errorf("%s: the %d rows read back differ from the %d written", tt.name, len(rows), len(tt.want))
+		}
+	}
+}
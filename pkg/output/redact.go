@@ -0,0 +1,156 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Redactions are the actions a redaction rule can take on a column.
+var Redactions = []string{"drop", "blank", "mask", "hash"}
+
+// RedactRules is a redaction rules file, e.g.
+//
+//	salt: something-secret
+//	rules:
+//	  - columns: [recovery_email]
+//	    action: drop
+//	  - columns: ["*phone*"]
+//	    action: mask
+//	    keep: 4
+//	  - columns: [email, manager]
+//	    action: hash
+//
+// A column takes the first rule that matches it; columns no rule matches
+// are written as they are.
+type RedactRules struct {
+	// Salt is mixed into hashed values, so a hash can't be reversed by
+	// hashing a list of likely addresses.
+	Salt  string        `yaml:"salt"`
+	Rules []*RedactRule `yaml:"rules"`
+	path  string
+}
+
+// RedactRule redacts the columns matching any of Columns, which are names
+// or path.Match patterns.
+type RedactRule struct {
+	Columns []string `yaml:"columns"`
+	// Action is one of Redactions: drop removes the column, blank empties
+	// it, mask replaces all but the last Keep characters with "*", and hash
+	// replaces the value with a short salted hash that is the same
+	// wherever the value appears, so redacted reports can still be joined.
+	Action string `yaml:"action"`
+	Keep   int    `yaml:"keep"`
+}
+
+// LoadRedactRules reads and checks a YAML rules file.
+func LoadRedactRules(file string) (*RedactRules, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	r := &RedactRules{path: file}
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", file, err)
+	}
+	if len(r.Rules) == 0 {
+		return nil, fmt.Errorf("%s has no rules", file)
+	}
+	for n, rule := range r.Rules {
+		if len(rule.Columns) == 0 {
+			return nil, fmt.Errorf("%s rule %d: no columns", file, n+1)
+		}
+		for _, pattern := range rule.Columns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s rule %d: bad column pattern %q", file, n+1, pattern)
+			}
+		}
+		known := false
+		for _, a := range Redactions {
+			known = known || rule.Action == a
+		}
+		if !known {
+			return nil, fmt.Errorf("%s rule %d: unknown action %q: use one of %s", file, n+1, rule.Action, strings.Join(Redactions, ", "))
+		}
+		if rule.Keep < 0 {
+			return nil, fmt.Errorf("%s rule %d: keep can't be negative", file, n+1)
+		}
+	}
+	return r, nil
+}
+
+// rule returns the first rule matching column, or nil.
+func (r *RedactRules) rule(column string) *RedactRule {
+	for _, rule := range r.Rules {
+		for _, pattern := range rule.Columns {
+			if ok, _ := path.Match(pattern, column); ok {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// redactor returns the function rewriting the values of column, or nil if
+// the column is written as it is. drop reports whether the column is
+// removed altogether.
+func (r *RedactRules) redactor(column string) (redact func(string) string, drop bool) {
+	rule := r.rule(column)
+	if rule == nil {
+		return nil, false
+	}
+	switch rule.Action {
+	case "drop":
+		return nil, true
+	case "blank":
+		return func(string) string { return "" }, false
+	case "mask":
+		return func(v string) string { return mask(v, rule.Keep) }, false
+	}
+	return func(v string) string { return r.hash(v) }, false
+}
+
+func mask(v string, keep int) string {
+	runes := []rune(v)
+	if keep >= len(runes) {
+		return v
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+// hash returns the first 12 hex digits of the salted SHA-256 of v. Case is
+// ignored, since most redacted columns are addresses.
+func (r *RedactRules) hash(v string) string {
+	if v == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(r.Salt + strings.ToLower(v)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// redactValue is the -redact-rules flag, which loads the file as it is set
+// so a bad file is reported with the other flag problems.
+type redactValue struct {
+	rules **RedactRules
+}
+
+func (v redactValue) String() string {
+	if v.rules == nil || *v.rules == nil {
+		return ""
+	}
+	return (*v.rules).path
+}
+
+func (v redactValue) Set(s string) error {
+	r, err := LoadRedactRules(s)
+	if err != nil {
+		return err
+	}
+	*v.rules = r
+	return nil
+}
@@ -0,0 +1,344 @@
+package output
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	sheetsScope    = "https://www.googleapis.com/auth/spreadsheets"
+	sheetsBasePath = "https://sheets.googleapis.com/v4/spreadsheets/"
+	// sheetsBatchCells is about how many cells are sent in one write,
+	// keeping requests to a couple of megabytes however wide the report.
+	sheetsBatchCells = 50000
+	// sheetsTabRows is the most rows put in one tab. Past it a tab is too
+	// slow to open, sort or filter, so the report carries on in a new tab.
+	sheetsTabRows = 500000
+	// sheetsMaxCells is how many cells a spreadsheet can hold, across all
+	// its tabs.
+	sheetsMaxCells = 10000000
+)
+
+func init() {
+	RegisterScheme("sheets", &Scheme{Check: checkSheets, Open: openSheets})
+}
+
+// parseSheetsURL splits "sheets://SPREADSHEET_ID/Tab name" into the
+// spreadsheet's ID and the tab.
+func parseSheetsURL(target string) (id, tab string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(target, "sheets://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q is not sheets://SPREADSHEET_ID/TAB", target)
+	}
+	return parts[0], parts[1], nil
+}
+
+func checkSheets(target string) error {
+	_, _, err := parseSheetsURL(target)
+	return err
+}
+
+// sheetsWriter replaces the contents of a spreadsheet tab with the report,
+// adding the tab if there isn't one. A report longer than sheetsTabRows
+// continues in tabs named "Tab (2)", "Tab (3)" and so on, each starting
+// with the header. The spreadsheet must be shared with the Application
+// Default Credentials' account.
+//
+// The report is written to new tabs, "Tab (new)", "Tab (new 2)" and so
+// on, which replace the old ones in one batch update on Close, so a run
+// that fails or is interrupted leaves the previous report as it was. Rows
+// are written to fixed ranges after sizing the tab's grid to fit them,
+// rather than appended, so a write retried after a timeout or a server
+// error can't add its rows twice.
+type sheetsWriter struct {
+	ctx     context.Context
+	client  *http.Client
+	id      string
+	tab     string
+	columns []string
+	rows    [][]string
+	// old are the tabs of the previous report, which Close deletes, and
+	// index is the position of its first tab.
+	old   []int
+	index int
+	// staged are the IDs of the new tabs so far. title and sheetID are
+	// the one being written, which holds tabRows rows so far.
+	staged  []int
+	title   string
+	sheetID int
+	tabRows int
+	// otherCells are the cells of the spreadsheet's other tabs, including
+	// the previous report's until Close, and fullCells those of the
+	// report's tabs before this one.
+	otherCells int
+	fullCells  int
+}
+
+// sheetProperties is the part of a tab's properties the writer needs.
+type sheetProperties struct {
+	SheetID        int    `json:"sheetId"`
+	Title          string `json:"title"`
+	Index          int    `json:"index"`
+	GridProperties struct {
+		RowCount    int `json:"rowCount"`
+		ColumnCount int `json:"columnCount"`
+	} `json:"gridProperties"`
+}
+
+func openSheets(target string, columns []string, _ string) (Writer, error) {
+	id, tab, err := parseSheetsURL(target)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	client, err := auth.DefaultClient(ctx, sheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("can't write %s: %v", target, err)
+	}
+	w := &sheetsWriter{ctx: ctx, client: client, id: id, tab: tab, columns: columns, rows: [][]string{columns}, index: -1}
+	if err := w.prepare(); err != nil {
+		return nil, fmt.Errorf("can't write %s: %v", target, err)
+	}
+	return w, nil
+}
+
+// prepare finds the previous report's tabs, deletes the new tabs of an
+// earlier run that didn't finish, and adds the first new tab, so a
+// failure to reach the spreadsheet is found before the report is fetched.
+func (w *sheetsWriter) prepare() error {
+	s := &struct {
+		Sheets []struct {
+			Properties sheetProperties `json:"properties"`
+		} `json:"sheets"`
+	}{}
+	u := rest.URL(sheetsBasePath, url.PathEscape(w.id), url.Values{"fields": {"sheets.properties(sheetId,title,index,gridProperties)"}})
+	if err := rest.Get(w.ctx, w.client, u, s); err != nil {
+		return err
+	}
+	stale := []interface{}{}
+	for _, sheet := range s.Sheets {
+		p := sheet.Properties
+		switch {
+		case w.isStaging(p.Title):
+			stale = append(stale, deleteSheet(p.SheetID))
+			continue
+		case p.Title == w.tab:
+			w.index = p.Index
+			w.old = append(w.old, p.SheetID)
+		case w.isContinuation(p.Title):
+			w.old = append(w.old, p.SheetID)
+		}
+		w.otherCells += p.GridProperties.RowCount * p.GridProperties.ColumnCount
+	}
+	if len(stale) > 0 {
+		if err := w.batchUpdate(stale, nil); err != nil {
+			return err
+		}
+	}
+	return w.addTab()
+}
+
+func deleteSheet(id int) map[string]interface{} {
+	return map[string]interface{}{"deleteSheet": map[string]int{"sheetId": id}}
+}
+
+// tabTitle is the title of the report's nth tab, and stagingTitle that of
+// the new tab written in its place.
+func (w *sheetsWriter) tabTitle(n int) string {
+	if n == 1 {
+		return w.tab
+	}
+	return fmt.Sprintf("%s (%d)", w.tab, n)
+}
+
+func (w *sheetsWriter) stagingTitle(n int) string {
+	if n == 1 {
+		return w.tab + " (new)"
+	}
+	return fmt.Sprintf("%s (new %d)", w.tab, n)
+}
+
+// isStaging reports whether title is one of the new tabs the writer adds.
+func (w *sheetsWriter) isStaging(title string) bool {
+	if title == w.tab+" (new)" {
+		return true
+	}
+	if !strings.HasPrefix(title, w.tab+" (new ") || !strings.HasSuffix(title, ")") {
+		return false
+	}
+	n, err := strconv.Atoi(title[len(w.tab)+6 : len(title)-1])
+	return err == nil && n > 1
+}
+
+// isContinuation reports whether title is one of the "Tab (n)" tabs the
+// writer adds.
+func (w *sheetsWriter) isContinuation(title string) bool {
+	if !strings.HasPrefix(title, w.tab+" (") || !strings.HasSuffix(title, ")") {
+		return false
+	}
+	n, err := strconv.Atoi(title[len(w.tab)+2 : len(title)-1])
+	return err == nil && n > 1
+}
+
+// addTab adds the next new tab and makes it the one being written.
+func (w *sheetsWriter) addTab() error {
+	title := w.stagingTitle(len(w.staged) + 1)
+	reply := &struct {
+		Replies []struct {
+			AddSheet struct {
+				Properties sheetProperties `json:"properties"`
+			} `json:"addSheet"`
+		} `json:"replies"`
+	}{}
+	add := map[string]interface{}{"addSheet": map[string]interface{}{"properties": map[string]string{"title": title}}}
+	if err := w.batchUpdate([]interface{}{add}, reply); err != nil {
+		return err
+	}
+	if len(reply.Replies) == 0 {
+		return fmt.Errorf("adding tab %q: no reply", title)
+	}
+	w.title, w.sheetID, w.tabRows = title, reply.Replies[0].AddSheet.Properties.SheetID, 0
+	w.staged = append(w.staged, w.sheetID)
+	return nil
+}
+
+func (w *sheetsWriter) batchUpdate(requests []interface{}, reply interface{}) error {
+	return rest.Do(w.ctx, w.client, "POST", sheetsBasePath+url.PathEscape(w.id)+":batchUpdate",
+		map[string]interface{}{"requests": requests}, reply)
+}
+
+// rangeURL returns the URL of a range of the tab title followed by
+// suffix, such as ":clear". An empty cell is the whole tab.
+func (w *sheetsWriter) rangeURL(title, cell, suffix string, params url.Values) string {
+	// Quoting the tab name lets it contain spaces and punctuation.
+	r := "'" + strings.Replace(title, "'", "''", -1) + "'"
+	if cell != "" {
+		r += "!" + cell
+	}
+	return rest.URL(sheetsBasePath, url.PathEscape(w.id)+"/values/"+url.PathEscape(r)+suffix, params)
+}
+
+func (w *sheetsWriter) Write(row []string) error {
+	w.rows = append(w.rows, row)
+	if len(w.rows)*len(w.columns) >= sheetsBatchCells {
+		return w.send()
+	}
+	return nil
+}
+
+// Flush sends nothing: rows go in batches of sheetsBatchCells, or on
+// Close, to stay within the Sheets API's write quota.
+func (w *sheetsWriter) Flush() error { return nil }
+
+// send writes the buffered rows below those already in the tab, moving on
+// to a new tab when it's full.
+func (w *sheetsWriter) send() error {
+	for len(w.rows) > 0 {
+		if w.tabRows >= sheetsTabRows {
+			w.fullCells += w.tabRows * len(w.columns)
+			if err := w.addTab(); err != nil {
+				return fmt.Errorf("continuing sheets://%s/%s in a new tab: %v", w.id, w.tab, err)
+			}
+			w.rows = append([][]string{w.columns}, w.rows...)
+		}
+		n := len(w.rows)
+		if n > sheetsTabRows-w.tabRows {
+			n = sheetsTabRows - w.tabRows
+		}
+		if err := w.sendRows(w.rows[:n]); err != nil {
+			return fmt.Errorf("writing to sheets://%s/%s: %v", w.id, w.title, err)
+		}
+		w.rows = w.rows[n:]
+	}
+	w.rows = nil
+	return nil
+}
+
+// sendRows sizes the tab's grid to fit rows below those already written,
+// then writes them there. Both requests give the same result if repeated.
+func (w *sheetsWriter) sendRows(rows [][]string) error {
+	total := w.tabRows + len(rows)
+	if cells := w.otherCells + w.fullCells + total*len(w.columns); cells > sheetsMaxCells {
+		return fmt.Errorf("the report and the one it replaces need more than the %d cells a spreadsheet can hold; write it to bq:// or gs:// instead", sheetsMaxCells)
+	}
+	resize := map[string]interface{}{"updateSheetProperties": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"sheetId":        w.sheetID,
+			"gridProperties": map[string]int{"rowCount": total, "columnCount": len(w.columns)},
+		},
+		"fields": "gridProperties(rowCount,columnCount)",
+	}}
+	if err := w.batchUpdate([]interface{}{resize}, nil); err != nil {
+		return err
+	}
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			values[i][j] = v
+		}
+	}
+	// RAW keeps values such as 00123 and =x as the text the report has.
+	u := w.rangeURL(w.title, fmt.Sprintf("A%d", w.tabRows+1), "", url.Values{"valueInputOption": {"RAW"}})
+	if err := rest.Do(w.ctx, w.client, "PUT", u, map[string]interface{}{"values": values}, nil); err != nil {
+		return err
+	}
+	w.tabRows = total
+	return nil
+}
+
+// Close writes the last rows, then in one batch update deletes the
+// previous report's tabs and gives the new ones their names, the first in
+// the old first tab's place.
+func (w *sheetsWriter) Close() error {
+	if err := w.send(); err != nil {
+		w.Abort()
+		return err
+	}
+	requests := []interface{}{}
+	for _, id := range w.old {
+		requests = append(requests, deleteSheet(id))
+	}
+	for i, id := range w.staged {
+		properties := map[string]interface{}{"sheetId": id, "title": w.tabTitle(i + 1)}
+		fields := "title"
+		if i == 0 && w.index >= 0 {
+			properties["index"] = w.index
+			fields = "title,index"
+		}
+		requests = append(requests, map[string]interface{}{"updateSheetProperties": map[string]interface{}{
+			"properties": properties,
+			"fields":     fields,
+		}})
+	}
+	if err := w.batchUpdate(requests, nil); err != nil {
+		w.Abort()
+		return fmt.Errorf("replacing sheets://%s/%s: %v", w.id, w.tab, err)
+	}
+	return nil
+}
+
+// Abort deletes the new tabs, leaving the previous report as it was.
+func (w *sheetsWriter) Abort() {
+	w.rows = nil
+	requests := []interface{}{}
+	for _, id := range w.staged {
+		requests = append(requests, deleteSheet(id))
+	}
+	w.staged = nil
+	if len(requests) == 0 {
+		return
+	}
+	if err := w.batchUpdate(requests, nil); err != nil {
+		log.Printf("Warning: couldn't delete the unfinished tabs of sheets://%s/%s; the next run will: %v", w.id, w.tab, err)
+	}
+}
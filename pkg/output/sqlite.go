@@ -0,0 +1,100 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	RegisterScheme("sqlite", &Scheme{Check: checkSQLite, Open: openSQLite})
+}
+
+// parseSQLiteURL splits "sqlite://path/to/file.db/table" into the
+// database file and the table. An absolute path has three slashes:
+// sqlite:///var/lib/reports.db/members.
+func parseSQLiteURL(target string) (path, table string, err error) {
+	spec := strings.TrimPrefix(target, "sqlite://")
+	i := strings.LastIndex(spec, "/")
+	if i <= 0 || i == len(spec)-1 {
+		return "", "", fmt.Errorf("%q is not sqlite://FILE/TABLE", target)
+	}
+	return spec[:i], spec[i+1:], nil
+}
+
+func checkSQLite(target string) error {
+	_, _, err := parseSQLiteURL(target)
+	return err
+}
+
+// sqliteWriter replaces a table in a SQLite database with the report,
+// every column TEXT, in one transaction. It feeds SQL to the sqlite3
+// command, which must be on the PATH, so the tools need no C toolchain.
+type sqliteWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	w      *bufio.Writer
+	stderr bytes.Buffer
+	insert string
+}
+
+func openSQLite(target string, columns []string, _ string) (Writer, error) {
+	path, table, err := parseSQLiteURL(target)
+	if err != nil {
+		return nil, err
+	}
+	w := &sqliteWriter{cmd: exec.Command("sqlite3", "-bail", path)}
+	w.cmd.Stderr = &w.stderr
+	if w.stdin, err = w.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if err := w.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("can't write %s: %v", target, err)
+	}
+	w.w = bufio.NewWriter(w.stdin)
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		defs[i] = sqliteIdent(c) + " TEXT"
+	}
+	w.insert = "INSERT INTO " + sqliteIdent(table) + " VALUES ("
+	fmt.Fprintf(w.w, "BEGIN;\nDROP TABLE IF EXISTS %s;\nCREATE TABLE %s (%s);\n",
+		sqliteIdent(table), sqliteIdent(table), strings.Join(defs, ", "))
+	return w, nil
+}
+
+// sqliteIdent quotes a table or column name.
+func sqliteIdent(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+func (w *sqliteWriter) Write(row []string) error {
+	values := make([]string, len(row))
+	for i, v := range row {
+		values[i] = "'" + strings.Replace(v, "'", "''", -1) + "'"
+	}
+	_, err := w.w.WriteString(w.insert + strings.Join(values, ", ") + ");\n")
+	return err
+}
+
+func (w *sqliteWriter) Flush() error { return w.w.Flush() }
+
+// Close commits the transaction and waits for sqlite3 to finish.
+func (w *sqliteWriter) Close() error {
+	w.w.WriteString("COMMIT;\n")
+	err := w.w.Flush()
+	w.stdin.Close()
+	if werr := w.cmd.Wait(); werr != nil {
+		return fmt.Errorf("sqlite3: %v: %s", werr, strings.TrimSpace(w.stderr.String()))
+	}
+	return err
+}
+
+// Abort stops sqlite3 before the transaction commits, which leaves the
+// database as it was.
+func (w *sqliteWriter) Abort() {
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+}
@@ -0,0 +1,185 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCheckDetails is how many mismatches a check lists before summarizing.
+const maxCheckDetails = 20
+
+// Manifest describes the reports a run wrote and the checks run on them,
+// for downstream systems to read before ingesting the reports.
+type Manifest struct {
+	Reports []*ManifestEntry `json:"reports"`
+}
+
+// ManifestEntry is one report in a Manifest.
+type ManifestEntry struct {
+	// Report is the report's schema stamp.
+	Report       string    `json:"report"`
+	Destinations []string  `json:"destinations"`
+	Written      time.Time `json:"written"`
+	Aborted      bool      `json:"aborted,omitempty"`
+	// Incomplete is set if the report ends with MarkIncomplete's row.
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Rows counts the rows the tool produced, before any -filter.
+	Rows          int `json:"rows"`
+	DuplicateRows int `json:"duplicate_rows"`
+	// EmptyFields counts the empty values in each column.
+	EmptyFields map[string]int `json:"empty_fields"`
+	Checks      []*Check       `json:"checks"`
+	OK          bool           `json:"ok"`
+}
+
+// Check is the outcome of one verification of a report.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// expectation is a number of rows the API says a report should have with
+// a value in a column, such as a group's directMembersCount.
+type expectation struct {
+	column string
+	value  string
+}
+
+// ExpectRows records that the report should have n rows whose column is
+// value, for the verification -manifest-file and -verify-strict turn on.
+// Expectations for the same column and value add up. Call it before
+// Create or WriteFile.
+func (o *Options) ExpectRows(column, value string, n int) {
+	if o.expected == nil {
+		o.expected = map[expectation]int{}
+	}
+	o.expected[expectation{column, strings.ToLower(value)}] += n
+}
+
+// verifying reports whether reports are checked as they are written.
+func (o *Options) verifying() bool {
+	return o.ManifestFile != "" || o.VerifyStrict
+}
+
+// verifier gathers the statistics the checks need as rows are written.
+type verifier struct {
+	o          *Options
+	targets    []string
+	header     []string
+	rows       int
+	empty      []int
+	seen       map[uint64]bool
+	duplicates int
+	// columns are the header positions of the expectations' columns.
+	columns map[string]int
+	counts  map[expectation]int
+}
+
+func (o *Options) newVerifier(targets, header []string) (*verifier, error) {
+	v := &verifier{o: o, targets: targets, header: header, empty: make([]int, len(header)),
+		seen: map[uint64]bool{}, columns: map[string]int{}, counts: map[expectation]int{}}
+	for e := range o.expected {
+		if _, ok := v.columns[e.column]; ok {
+			continue
+		}
+		i := -1
+		for n, h := range header {
+			if h == e.column {
+				i = n
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("expected rows by column %q, which the report doesn't have", e.column)
+		}
+		v.columns[e.column] = i
+	}
+	return v, nil
+}
+
+func (v *verifier) record(row []string) {
+	v.rows++
+	for i := range v.header {
+		if i >= len(row) || row[i] == "" {
+			v.empty[i]++
+		}
+	}
+	h := fnv.New64a()
+	for _, value := range row {
+		h.Write([]byte(value))
+		h.Write([]byte{0})
+	}
+	sum := h.Sum64()
+	if v.seen[sum] {
+		v.duplicates++
+	}
+	v.seen[sum] = true
+	for column, i := range v.columns {
+		if i < len(row) {
+			v.counts[expectation{column, strings.ToLower(row[i])}]++
+		}
+	}
+}
+
+// entry runs the checks and returns the report's manifest entry.
+func (v *verifier) entry() *ManifestEntry {
+	e := &ManifestEntry{
+		Report:        v.o.Schema,
+		Destinations:  v.targets,
+		Written:       time.Now().UTC(),
+		Rows:          v.rows,
+		DuplicateRows: v.duplicates,
+		EmptyFields:   map[string]int{},
+		OK:            true,
+	}
+	for i, name := range v.header {
+		e.EmptyFields[name] = v.empty[i]
+	}
+	e.Checks = append(e.Checks, &Check{Name: "duplicate_rows", OK: v.duplicates == 0,
+		Detail: fmt.Sprintf("%d rows repeat an earlier row", v.duplicates)})
+	if len(v.o.expected) > 0 {
+		mismatches := []string{}
+		for x, n := range v.o.expected {
+			if got := v.counts[x]; got != n {
+				mismatches = append(mismatches, fmt.Sprintf("%s=%s has %d rows, the API reports %d", x.column, x.value, got, n))
+			}
+		}
+		sort.Strings(mismatches)
+		c := &Check{Name: "expected_rows", OK: len(mismatches) == 0,
+			Detail: fmt.Sprintf("%d of %d counts differ", len(mismatches), len(v.o.expected))}
+		if len(mismatches) > maxCheckDetails {
+			mismatches = append(mismatches[:maxCheckDetails], fmt.Sprintf("and %d more", len(mismatches)-maxCheckDetails))
+		}
+		if len(mismatches) > 0 {
+			c.Detail += ": " + strings.Join(mismatches, "; ")
+		}
+		e.Checks = append(e.Checks, c)
+	}
+	for _, c := range e.Checks {
+		if !c.OK {
+			e.OK = false
+			log.Printf("Report check %s failed: %s", c.Name, c.Detail)
+		}
+	}
+	return e
+}
+
+// addToManifest adds e to the run's manifest, rewriting -manifest-file so
+// it lists every report written so far.
+func (o *Options) addToManifest(e *ManifestEntry) error {
+	if o.ManifestFile == "" {
+		return nil
+	}
+	o.manifest.Reports = append(o.manifest.Reports, e)
+	data, err := json.MarshalIndent(&o.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(o.ManifestFile, append(data, '\n'), 0644)
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Writer is an open destination receiving a report's rows once the
+// fields, filters and redaction have been applied. Close commits the
+// report, which for an upload or a database is when it appears.
+type Writer interface {
+	RowWriter
+	// Abort gives up on the destination after a failure, leaving nothing
+	// or the previous report behind rather than a partial one.
+	Abort()
+}
+
+// Scheme is a kind of destination, named by the prefix of its targets
+// such as "gs" for gs://bucket/object. Targets without a registered
+// prefix are local files.
+type Scheme struct {
+	// Check returns an error if target is malformed, without opening it.
+	Check func(target string) error
+	// Open starts writing a report with the given columns to target.
+	// format is the report's encoding; destinations that store rows
+	// rather than bytes ignore it.
+	Open func(target string, columns []string, format string) (Writer, error)
+}
+
+// Format is an encoding for destinations that store bytes: files and
+// gs:// objects.
+type Format struct {
+	ContentType string
+	// New returns a RowWriter encoding a report with the given columns to
+	// w. Its Close finishes the encoding but doesn't close w.
+	New func(w io.Writer, columns []string) (RowWriter, error)
+}
+
+var (
+	schemes = map[string]*Scheme{}
+	formats = map[string]*Format{}
+)
+
+// Formats are the encodings -format accepts, in the order they were
+// registered.
+var Formats []string
+
+// RegisterScheme makes targets starting with name:// go to s. It panics
+// if name is already registered, and is meant to be called from init.
+func RegisterScheme(name string, s *Scheme) {
+	if schemes[name] != nil {
+		panic("output: scheme " + name + " registered twice")
+	}
+	schemes[name] = s
+}
+
+// RegisterFormat adds name to Formats. It panics if name is already
+// registered, and is meant to be called from init.
+func RegisterFormat(name string, f *Format) {
+	if formats[name] != nil {
+		panic("output: format " + name + " registered twice")
+	}
+	formats[name] = f
+	Formats = append(Formats, name)
+}
+
+// Schemes returns the registered scheme names, sorted.
+func Schemes() []string {
+	names := []string{}
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// schemeFor returns the scheme target names, or the file scheme.
+func schemeFor(target string) *Scheme {
+	if i := strings.Index(target, "://"); i > 0 {
+		if s := schemes[target[:i]]; s != nil {
+			return s
+		}
+	}
+	return fileScheme
+}
+
+// checkDestination returns an error if target can't be a destination.
+func checkDestination(target string) error {
+	if target == "" {
+		return nil
+	}
+	return schemeFor(target).Check(target)
+}
+
+func openDestination(target string, columns []string, format string) (Writer, error) {
+	return schemeFor(target).Open(target, columns, format)
+}
+
+// formatFor returns the format implied by target's extension, or format
+// if the extension isn't one of Formats.
+func formatFor(target, format string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(target)), ".")
+	if formats[ext] != nil {
+		return ext
+	}
+	return format
+}
+
+func checkFormat(format string) error {
+	if formats[format] == nil {
+		return fmt.Errorf("unknown format %q: use one of %s", format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package preflight checks that Google's APIs are healthy before a long run
+// starts, so an outage shows up as one clear message rather than thousands
+// of failed calls.
+package preflight
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+// StatusURL is the Google Workspace Status Dashboard incident feed.
+const StatusURL = "https://www.google.com/appsstatus/dashboard/incidents.json"
+
+// Modes for Options.Mode.
+const (
+	ModeOff  = "off"
+	ModeWarn = "warn"
+	ModeWait = "wait"
+)
+
+// Options controls a preflight check.
+type Options struct {
+	// Mode is off, warn (log problems and carry on) or wait (retry with
+	// backoff until healthy or MaxWait passes, then fail).
+	Mode    string
+	MaxWait time.Duration
+	// Products are the dashboard product names whose open incidents count
+	// as degraded, matched case-insensitively as substrings.
+	Products []string
+}
+
+// DefaultProducts are the dashboard products the Directory API depends on.
+var DefaultProducts = []string{"Admin console"}
+
+type incident struct {
+	ExternalDesc     string `json:"external_desc"`
+	End              string `json:"end"`
+	AffectedProducts []struct {
+		Title string `json:"title"`
+	} `json:"affected_products"`
+	MostRecentUpdate struct {
+		Status string `json:"status"`
+	} `json:"most_recent_update"`
+}
+
+// Run performs the check. probe should make a single cheap API call with
+// the credentials the run will use; its failure usually means a scope or
+// delegation problem rather than an outage, so it is never waited out.
+func Run(ctx context.Context, probe func() error, opts Options) error {
+	switch opts.Mode {
+	case ModeOff, "":
+		return nil
+	case ModeWarn, ModeWait:
+	default:
+		return fmt.Errorf("unknown preflight mode %q", opts.Mode)
+	}
+	products := opts.Products
+	if len(products) == 0 {
+		products = DefaultProducts
+	}
+
+	deadline := time.Now().Add(opts.MaxWait)
+	delay := 30 * time.Second
+	for {
+		problems := []string{}
+		if err := probe(); err != nil {
+			if opts.Mode == ModeWait {
+				return fmt.Errorf("preflight API call failed: %v", err)
+			}
+			problems = append(problems, fmt.Sprintf("API call failed: %v", err))
+		}
+		open, err := openIncidents(ctx, products)
+		if err != nil {
+			// The dashboard being unreachable says nothing about the API.
+			log.Printf("Preflight: could not read status dashboard: %v", err)
+		}
+		problems = append(problems, open...)
+		if len(problems) == 0 {
+			return nil
+		}
+		for _, p := range problems {
+			log.Printf("Preflight: %s", p)
+		}
+		if opts.Mode == ModeWarn {
+			log.Println("Preflight: continuing anyway")
+			return nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("service still degraded after waiting %s", opts.MaxWait)
+		}
+		log.Printf("Preflight: waiting %s before checking again", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// openIncidents returns a description of each ongoing disruption or outage
+// affecting one of products.
+func openIncidents(ctx context.Context, products []string) ([]string, error) {
+	incidents := []*incident{}
+	if err := rest.Get(ctx, http.DefaultClient, StatusURL, &incidents); err != nil {
+		return nil, err
+	}
+	open := []string{}
+	for _, inc := range incidents {
+		if inc.End != "" || inc.MostRecentUpdate.Status == "SERVICE_INFORMATION" {
+			continue
+		}
+		for _, p := range inc.AffectedProducts {
+			if matchesAny(p.Title, products) {
+				open = append(open, fmt.Sprintf("open incident for %s: %s", p.Title, strings.TrimSpace(inc.ExternalDesc)))
+				break
+			}
+		}
+	}
+	return open, nil
+}
+
+func matchesAny(title string, products []string) bool {
+	title = strings.ToLower(title)
+	for _, p := range products {
+		if strings.Contains(title, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
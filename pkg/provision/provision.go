@@ -0,0 +1,277 @@
+// Package provision defines the joiner/leaver/mover actions shared by the
+// HR-driven tools, and how each is carried out against the Directory API.
+package provision
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+)
+
+// Action kinds.
+const (
+	Hire      = "hire"
+	Terminate = "terminate"
+	Transfer  = "transfer"
+	// Update changes only the attributes the action sets, leaving the rest
+	// to whichever other system owns them.
+	Update = "update"
+)
+
+// Action is a single lifecycle change for one person. Empty attributes are
+// left as they are.
+type Action struct {
+	Kind        string `json:"action"`
+	Email       string `json:"email"`
+	GivenName   string `json:"given_name,omitempty"`
+	FamilyName  string `json:"family_name,omitempty"`
+	OrgUnitPath string `json:"org_unit_path,omitempty"`
+	// The primary organization's attributes.
+	Title      string `json:"title,omitempty"`
+	Department string `json:"department,omitempty"`
+	CostCenter string `json:"cost_center,omitempty"`
+	Location   string `json:"location,omitempty"`
+}
+
+// attributes returns the names of the attributes a sets, in the csv's
+// terms.
+func (a *Action) attributes() []string {
+	names := []string{}
+	for _, f := range []struct{ name, value string }{
+		{"given_name", a.GivenName},
+		{"family_name", a.FamilyName},
+		{"org_unit", a.OrgUnitPath},
+		{"title", a.Title},
+		{"department", a.Department},
+		{"cost_center", a.CostCenter},
+		{"location", a.Location},
+	} {
+		if f.value != "" {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func (a *Action) setsOrganization() bool {
+	return a.Title != "" || a.Department != "" || a.CostCenter != "" || a.Location != ""
+}
+
+// Validate reports whether a has what its kind needs.
+func (a *Action) Validate() error {
+	if a.Email == "" {
+		return fmt.Errorf("%s has no email", a.Kind)
+	}
+	switch a.Kind {
+	case Hire:
+		if a.GivenName == "" || a.FamilyName == "" {
+			return fmt.Errorf("hire for %s is missing a name", a.Email)
+		}
+	case Transfer:
+		if a.OrgUnitPath == "" {
+			return fmt.Errorf("transfer for %s has no org unit", a.Email)
+		}
+	case Update:
+		if len(a.attributes()) == 0 {
+			return fmt.Errorf("update for %s changes nothing", a.Email)
+		}
+	case Terminate:
+	default:
+		return fmt.Errorf("unknown action %q for %s", a.Kind, a.Email)
+	}
+	return nil
+}
+
+// Change returns the directory write that carries out a. Hires get a random
+// password they must change at first login; terminations suspend rather
+// than delete, so data is kept for the offboarding process; updates patch
+// only the attributes they set.
+func Change(service *admin.Service, a *Action) *reconcile.Change {
+	c := &reconcile.Change{Action: a.Kind, Target: a.Email}
+	switch a.Kind {
+	case Hire:
+		c.Apply = func() error {
+			password, err := randomPassword()
+			if err != nil {
+				return err
+			}
+			u := &admin.User{
+				PrimaryEmail:              a.Email,
+				Name:                      &admin.UserName{GivenName: a.GivenName, FamilyName: a.FamilyName},
+				Password:                  password,
+				ChangePasswordAtNextLogin: true,
+				OrgUnitPath:               a.OrgUnitPath,
+			}
+			if a.setsOrganization() {
+				u.Organizations = MergeOrganization(nil, a)
+			}
+			_, err = service.Users.Insert(u).Do()
+			return err
+		}
+	case Terminate:
+		c.Apply = func() error {
+			_, err := service.Users.Patch(a.Email, &admin.User{Suspended: true}).Do()
+			return err
+		}
+	case Transfer:
+		c.Subject = "to " + a.OrgUnitPath
+		c.Apply = func() error {
+			_, err := service.Users.Patch(a.Email, &admin.User{OrgUnitPath: a.OrgUnitPath}).Do()
+			return err
+		}
+	case Update:
+		c.Subject = strings.Join(a.attributes(), ",")
+		c.Apply = func() error { return update(service, a) }
+	default:
+		c.Apply = func() error {
+			return fmt.Errorf("unknown action %q", a.Kind)
+		}
+	}
+	return c
+}
+
+// update patches the attributes a sets. Patch merges name but replaces the
+// whole organizations list, so the user's current organizations are read
+// and only the primary one's set attributes changed.
+func update(service *admin.Service, a *Action) error {
+	u := &admin.User{OrgUnitPath: a.OrgUnitPath}
+	if a.GivenName != "" || a.FamilyName != "" {
+		u.Name = &admin.UserName{GivenName: a.GivenName, FamilyName: a.FamilyName}
+	}
+	if a.setsOrganization() {
+		current, err := service.Users.Get(a.Email).Fields("organizations").Do()
+		if err != nil {
+			return err
+		}
+		u.Organizations = MergeOrganization(current.Organizations, a)
+	}
+	_, err := service.Users.Patch(a.Email, u).Do()
+	return err
+}
+
+// mergeOrganization returns orgs (the untyped Organizations of a user)
+// with a's attributes set on the primary organization, adding one if there
+// is none. Organizations are kept as maps so fields this package doesn't
+// know about survive the round trip.
+func MergeOrganization(orgs interface{}, a *Action) []map[string]interface{} {
+	list := []map[string]interface{}{}
+	if orgs != nil {
+		if data, err := json.Marshal(orgs); err == nil {
+			json.Unmarshal(data, &list)
+		}
+	}
+	primary := -1
+	for i, o := range list {
+		if p, _ := o["primary"].(bool); p {
+			primary = i
+		}
+	}
+	if primary < 0 && len(list) > 0 {
+		primary = 0
+	}
+	if primary < 0 {
+		list = append(list, map[string]interface{}{"primary": true})
+		primary = 0
+	}
+	for key, value := range map[string]string{
+		"title":      a.Title,
+		"department": a.Department,
+		"costCenter": a.CostCenter,
+		"location":   a.Location,
+	} {
+		if value != "" {
+			list[primary][key] = value
+		}
+	}
+	return list
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var csvHeader = []string{"action", "email", "given_name", "family_name", "org_unit"}
+
+// WriteActions writes actions as csv, the format ReadActions accepts.
+func WriteActions(w io.Writer, actions []*Action) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, a := range actions {
+		if err := writer.Write([]string{a.Kind, a.Email, a.GivenName, a.FamilyName, a.OrgUnitPath}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadActions reads an actions csv written by WriteActions (or by hand).
+// Columns are found by header name, so their order doesn't matter, and
+// only action and email are required: an update row changes just the
+// attributes in its non-empty cells, so a sparse csv from one upstream
+// system leaves the attributes other systems own alone. Beyond
+// WriteActions' columns, title, department, cost_center and location set
+// the primary organization.
+func ReadActions(path string) ([]*Action, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	cols := map[string]int{}
+	for i, name := range records[0] {
+		cols[name] = i
+	}
+	for _, name := range csvHeader[:2] {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("%s has no %q column", path, name)
+		}
+	}
+	get := func(r []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	actions := []*Action{}
+	for n, r := range records[1:] {
+		a := &Action{
+			Kind:        get(r, "action"),
+			Email:       get(r, "email"),
+			GivenName:   get(r, "given_name"),
+			FamilyName:  get(r, "family_name"),
+			OrgUnitPath: get(r, "org_unit"),
+			Title:       get(r, "title"),
+			Department:  get(r, "department"),
+			CostCenter:  get(r, "cost_center"),
+			Location:    get(r, "location"),
+		}
+		if err := a.Validate(); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, n+2, err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
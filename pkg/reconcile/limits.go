@@ -0,0 +1,56 @@
+package reconcile
+
+import (
+	"flag"
+	"fmt"
+)
+
+// destructive are the actions that take something away, which
+// MaxDeleteFraction limits.
+var destructive = map[string]bool{"remove": true, "delete": true, "terminate": true}
+
+// Limits stop a run whose change set is implausibly large, such as one
+// computed from an empty or truncated source file that would remove
+// everything the tool manages.
+type Limits struct {
+	// MaxChanges is the most changes a run may make. 0 is no limit.
+	MaxChanges int
+	// MaxDeleteFraction is the largest fraction of the existing objects
+	// (see Options.Existing) a run may remove or delete. 0 is no limit.
+	MaxDeleteFraction float64
+	// Force applies the changes whatever the limits say.
+	Force bool
+}
+
+// RegisterLimitFlags defines -max-changes, -max-delete-fraction and -force
+// on fs and returns the Limits they populate.
+func RegisterLimitFlags(fs *flag.FlagSet) *Limits {
+	l := &Limits{}
+	fs.IntVar(&l.MaxChanges, "max-changes", 0, "Refuse to run if there are more than this many changes (0 disables).")
+	fs.Float64Var(&l.MaxDeleteFraction, "max-delete-fraction", 0.2, "Refuse to run if the changes would remove or delete more than this fraction of what exists (0 disables).")
+	fs.BoolVar(&l.Force, "force", false, "Apply the changes even if they exceed -max-changes or -max-delete-fraction.")
+	return l
+}
+
+// Check returns an error if changes exceed the limits and Force isn't set.
+// existing is the number of objects the destructive changes are counted
+// against; with 0 the fraction isn't checked.
+func (l *Limits) Check(changes []*Change, existing int) error {
+	if l == nil || l.Force {
+		return nil
+	}
+	if l.MaxChanges > 0 && len(changes) > l.MaxChanges {
+		return fmt.Errorf("%d changes is more than -max-changes %d; check the input, or rerun with -force", len(changes), l.MaxChanges)
+	}
+	deletes := 0
+	for _, c := range changes {
+		if destructive[c.Action] {
+			deletes++
+		}
+	}
+	if l.MaxDeleteFraction > 0 && existing > 0 && float64(deletes)/float64(existing) > l.MaxDeleteFraction {
+		return fmt.Errorf("%d of %d (%.0f%%) would be removed, more than -max-delete-fraction %g; check the input, or rerun with -force",
+			deletes, existing, float64(deletes)*100/float64(existing), l.MaxDeleteFraction)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package reconcile
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// The kinds of change in a plan, as terraform shows them.
+const (
+	KindCreate = "create"
+	KindUpdate = "update"
+	KindDelete = "delete"
+)
+
+// creating are the actions that make something new. destructive ones are
+// deletes, and anything else is an update.
+var creating = map[string]bool{"add": true, "assign": true, "create": true, "hire": true, "insert": true, "send": true, "resend": true}
+
+// Kind classifies an action as KindCreate, KindUpdate or KindDelete.
+func Kind(action string) string {
+	switch {
+	case creating[action]:
+		return KindCreate
+	case destructive[action]:
+		return KindDelete
+	}
+	return KindUpdate
+}
+
+var kindSymbols = map[string]string{KindCreate: "+", KindUpdate: "~", KindDelete: "-"}
+
+// PlanOptions writes out the full change set of a run before any of it is
+// applied, so it can be attached to a change ticket and reviewed.
+type PlanOptions struct {
+	// Tool names the tool in the plan.
+	Tool string
+	// File is where the plan is written: a path, or "-" for stdout. If
+	// empty no plan is written.
+	File string
+	// Format is "text" for a terraform-style listing or "json".
+	Format string
+}
+
+// RegisterPlanFlags defines -plan-file and -plan-format on fs and returns
+// the PlanOptions they populate.
+func RegisterPlanFlags(fs *flag.FlagSet, tool string) *PlanOptions {
+	p := &PlanOptions{Tool: tool}
+	fs.StringVar(&p.File, "plan-file", "", "Write the changes the run would make to this file (- for stdout), e.g. with -dry-run for a change ticket.")
+	fs.StringVar(&p.Format, "plan-format", "text", "The -plan-file format: text or json.")
+	return p
+}
+
+func (p *PlanOptions) tool() string {
+	if p == nil {
+		return ""
+	}
+	return p.Tool
+}
+
+// CheckFormat returns an error if Format isn't one PlanOptions can write.
+func (p *PlanOptions) CheckFormat() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Format {
+	case "text", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown -plan-format %q: use text or json", p.Format)
+}
+
+// Plan is the JSON form of a change set.
+type Plan struct {
+	Tool      string `json:"tool"`
+	Generated string `json:"generated"`
+	DryRun    bool   `json:"dry_run"`
+	// Refused is why Limits would stop the run, if they would.
+	Refused string         `json:"refused,omitempty"`
+	Summary map[string]int `json:"summary"`
+	Changes []*PlanChange  `json:"changes"`
+}
+
+// PlanChange is one change in a Plan.
+type PlanChange struct {
+	Kind    string `json:"kind"`
+	Action  string `json:"action"`
+	Target  string `json:"target"`
+	Subject string `json:"subject,omitempty"`
+}
+
+// NewPlan describes changes. refused is the error from Limits.Check, if any.
+func NewPlan(tool string, changes []*Change, dryRun bool, refused error) *Plan {
+	plan := &Plan{
+		Tool:      tool,
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		DryRun:    dryRun,
+		Summary:   map[string]int{KindCreate: 0, KindUpdate: 0, KindDelete: 0},
+		Changes:   []*PlanChange{},
+	}
+	if refused != nil {
+		plan.Refused = refused.Error()
+	}
+	for _, c := range changes {
+		kind := Kind(c.Action)
+		plan.Summary[kind]++
+		plan.Changes = append(plan.Changes, &PlanChange{Kind: kind, Action: c.Action, Target: c.Target, Subject: c.Subject})
+	}
+	return plan
+}
+
+// String is the one line summary of the plan.
+func (plan *Plan) String() string {
+	return fmt.Sprintf("Plan: %d to create, %d to update, %d to delete.",
+		plan.Summary[KindCreate], plan.Summary[KindUpdate], plan.Summary[KindDelete])
+}
+
+// WriteText writes the plan as terraform does: a symbol for the kind of
+// each change, then the summary.
+func (plan *Plan) WriteText(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	verb := "will"
+	if plan.DryRun {
+		verb = "would"
+	}
+	fmt.Fprintf(b, "%s at %s %s make these changes:\n\n", plan.Tool, plan.Generated, verb)
+	if len(plan.Changes) == 0 {
+		fmt.Fprintln(b, "  No changes.")
+	}
+	for _, c := range plan.Changes {
+		line := c.Action + " " + c.Target
+		if c.Subject != "" {
+			line = c.Action + " " + c.Subject + " " + c.Target
+		}
+		fmt.Fprintf(b, "  %s %s\n", kindSymbols[c.Kind], line)
+	}
+	fmt.Fprintf(b, "\n%s\n", plan)
+	if plan.Refused != "" {
+		fmt.Fprintf(b, "\nA real run would stop: %s\n", plan.Refused)
+	}
+	return b.Flush()
+}
+
+// Write writes plan to File in Format. It does nothing if File is empty.
+func (p *PlanOptions) Write(plan *Plan) error {
+	if p == nil || p.File == "" {
+		return nil
+	}
+	if p.File == "-" {
+		return p.encode(os.Stdout, plan)
+	}
+	f, err := os.Create(p.File)
+	if err != nil {
+		return err
+	}
+	if err := p.encode(f, plan); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (p *PlanOptions) encode(w io.Writer, plan *Plan) error {
+	if p.Format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(plan)
+	}
+	return plan.WriteText(w)
+}
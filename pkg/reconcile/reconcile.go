@@ -0,0 +1,147 @@
+// Package reconcile applies change sets computed by the sync tools, so every
+// tool that writes to Google Apps logs and dry-runs changes the same way.
+package reconcile
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+)
+
+// Change is a single write a sync tool wants to make.
+type Change struct {
+	// Action is a short verb such as "add", "remove" or "update".
+	Action string
+	// Target is the object being changed, e.g. a group address.
+	Target string
+	// Subject is what the change is about within Target, e.g. a member
+	// address. It may be empty.
+	Subject string
+	// Apply performs the change.
+	Apply func() error
+}
+
+func (c *Change) String() string {
+	if c.Subject == "" {
+		return fmt.Sprintf("%s %s", c.Action, c.Target)
+	}
+	return fmt.Sprintf("%s %s %s", c.Action, c.Subject, c.Target)
+}
+
+// Options controls how a change set is applied.
+type Options struct {
+	// DryRun logs the changes without applying them.
+	DryRun bool
+	// Canary, if set, limits the run to the first N changes ("25") or the
+	// first N percent of them ("5%"), so a bad input can be caught after
+	// touching only a few objects.
+	Canary string
+	// Breaker, if set, stops the run once too many changes have failed.
+	Breaker *breaker.Breaker
+	// Limits, if set, refuse a change set that is too large before any of
+	// it is applied. A dry run only logs that it would be refused.
+	Limits *Limits
+	// Existing is the number of objects the tool manages, such as the
+	// members of the groups it syncs, for Limits.MaxDeleteFraction.
+	Existing int
+	// Plan, if set, writes out the whole change set, held canary changes
+	// included, before anything is applied.
+	Plan *PlanOptions
+}
+
+// Result summarizes an Apply run.
+type Result struct {
+	Applied int
+	Failed  int
+	// Held is the number of changes not attempted because of Canary.
+	Held int
+}
+
+// ParseCanary converts a Canary value into a number of changes out of
+// total. An empty value means no limit and returns total.
+func ParseCanary(canary string, total int) (int, error) {
+	if canary == "" {
+		return total, nil
+	}
+	if strings.HasSuffix(canary, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(canary, "%"), 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return 0, fmt.Errorf("invalid canary percentage %q", canary)
+		}
+		n := int(float64(total) * pct / 100)
+		if n == 0 && total > 0 {
+			n = 1
+		}
+		return n, nil
+	}
+	n, err := strconv.Atoi(canary)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid canary count %q", canary)
+	}
+	if n > total {
+		n = total
+	}
+	return n, nil
+}
+
+// Apply performs changes in order, logging each one. A failed change is
+// logged and does not stop the remaining changes; the returned error reports
+// how many failed.
+func Apply(changes []*Change, opts Options) (Result, error) {
+	res := Result{}
+	limit, err := ParseCanary(opts.Canary, len(changes))
+	if err != nil {
+		return res, err
+	}
+	refused := opts.Limits.Check(changes, opts.Existing)
+	if opts.Plan != nil || opts.DryRun {
+		plan := NewPlan(opts.Plan.tool(), changes, opts.DryRun, refused)
+		if err := opts.Plan.Write(plan); err != nil {
+			return res, fmt.Errorf("writing plan: %v", err)
+		}
+		if opts.DryRun {
+			log.Printf("[dry-run] %s", plan)
+		}
+	}
+	if refused != nil {
+		if !opts.DryRun {
+			return res, refused
+		}
+		log.Printf("[dry-run] A real run would stop: %v", refused)
+	}
+	if limit < len(changes) {
+		res.Held = len(changes) - limit
+		log.Printf("Canary: applying %d of %d changes", limit, len(changes))
+		changes = changes[:limit]
+	}
+	for _, c := range changes {
+		if opts.DryRun {
+			log.Printf("[dry-run] %s", c)
+			continue
+		}
+		err := c.Apply()
+		tripped := opts.Breaker.Record(err)
+		if err != nil {
+			log.Printf("Error: %s: %v", c, err)
+			res.Failed++
+		}
+		if tripped != nil {
+			return res, tripped
+		}
+		if err != nil {
+			continue
+		}
+		log.Printf("Applied: %s", c)
+		res.Applied++
+	}
+	if res.Held > 0 {
+		log.Printf("Canary complete: %d changes held back. Review the result and rerun without -canary to apply them.", res.Held)
+	}
+	if res.Failed > 0 {
+		return res, fmt.Errorf("%d of %d changes failed", res.Failed, len(changes))
+	}
+	return res, nil
+}
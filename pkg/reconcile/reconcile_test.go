@@ -0,0 +1,93 @@
+package reconcile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+)
+
+// changes returns n changes with action, each applying with err.
+func changes(n int, action string, err error) []*Change {
+	cs := []*Change{}
+	for i := 0; i < n; i++ {
+		cs = append(cs, &Change{Action: action, Target: "group@example.com", Apply: func() error { return err }})
+	}
+	return cs
+}
+
+func TestLimitsCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   *Limits
+		changes  []*Change
+		existing int
+		refused  bool
+	}{
+		{"no limits", nil, changes(100, "remove", nil), 10, false},
+		{"at max-changes", &Limits{MaxChanges: 3}, changes(3, "add", nil), 0, false},
+		{"over max-changes", &Limits{MaxChanges: 3}, changes(4, "add", nil), 0, true},
+		{"max-changes off", &Limits{}, changes(1000, "add", nil), 0, false},
+		{"at max-delete-fraction", &Limits{MaxDeleteFraction: 0.5}, changes(2, "remove", nil), 4, false},
+		{"over max-delete-fraction", &Limits{MaxDeleteFraction: 0.5}, changes(3, "remove", nil), 4, true},
+		{"adds aren't deletes", &Limits{MaxDeleteFraction: 0.5}, changes(4, "add", nil), 4, false},
+		{"nothing existing", &Limits{MaxDeleteFraction: 0.5}, changes(3, "delete", nil), 0, false},
+		{"forced", &Limits{MaxChanges: 1, MaxDeleteFraction: 0.1, Force: true}, changes(3, "delete", nil), 4, false},
+	}
+	for _, tt := range tests {
+		err := tt.limits.Check(tt.changes, tt.existing)
+		if (err != nil) != tt.refused {
+			t.Errorf("%s: got %v, want refused %v", tt.name, err, tt.refused)
+		}
+	}
+}
+
+func TestParseCanary(t *testing.T) {
+	tests := []struct {
+		canary string
+		total  int
+		want   int
+		err    bool
+	}{
+		{"", 10, 10, false},
+		{"1", 10, 1, false},
+		{"10", 10, 10, false},
+		{"11", 10, 10, false},
+		{"0", 10, 0, true},
+		{"-1", 10, 0, true},
+		{"ten", 10, 0, true},
+		{"50%", 10, 5, false},
+		{"100%", 10, 10, false},
+		{"1%", 10, 1, false},
+		{"1%", 0, 0, false},
+		{"0%", 10, 0, true},
+		{"101%", 10, 0, true},
+		{"%", 10, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCanary(tt.canary, tt.total)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseCanary(%q, %d) = %d, %v; want %d, error %v", tt.canary, tt.total, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestApplyStopsWhenBreakerTrips(t *testing.T) {
+	failed := errors.New("forbidden")
+	cs := changes(5, "add", failed)
+	attempted := 0
+	for _, c := range cs {
+		apply := c.Apply
+		c.Apply = func() error {
+			attempted++
+			return apply()
+		}
+	}
+	res, err := Apply(cs, Options{Breaker: breaker.New(2, 0.5)})
+	if _, ok := err.(*breaker.TrippedError); !ok {
+		t.Fatalf("got error %v, want a *breaker.TrippedError", err)
+	}
+	if attempted != 2 || res.Failed != 2 || res.Applied != 0 {
+		t.Errorf("attempted %d changes with %+v, want 2 attempted and failed", attempted, res)
+	}
+}
@@ -0,0 +1,127 @@
+// Package replay serves canned Directory API responses from memory, so the
+// fetch and write pipeline can be tested and benchmarked (see
+// group_members_report's tests) without a domain or the network. Every
+// page is rendered up front, leaving only the client's own work to be
+// timed.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+)
+
+const basePath = "/admin/directory/v1/"
+
+// Directory is an http.RoundTripper answering groups.list and members.list
+// for a synthetic domain of Groups groups with MembersPerGroup members
+// each.
+type Directory struct {
+	Domain          string
+	Groups          int
+	MembersPerGroup int
+	pages           map[string][]byte
+	requests        int64
+}
+
+// NewDirectory renders the responses for a domain of groups groups, each
+// with members members, split into pages of pageSize, which must be
+// positive.
+func NewDirectory(domain string, groups, members, pageSize int) *Directory {
+	d := &Directory{Domain: domain, Groups: groups, MembersPerGroup: members, pages: map[string][]byte{}}
+	all := []interface{}{}
+	for g := 0; g < groups; g++ {
+		email, id := d.GroupEmail(g), fmt.Sprintf("g%07d", g)
+		all = append(all, map[string]interface{}{
+			"kind":               "admin#directory#group",
+			"id":                 id,
+			"email":              email,
+			"name":               fmt.Sprintf("Group %d", g),
+			"directMembersCount": strconv.Itoa(members),
+		})
+		list := []interface{}{}
+		for m := 0; m < members; m++ {
+			list = append(list, map[string]interface{}{
+				"kind":   "admin#directory#member",
+				"id":     fmt.Sprintf("u%07d", m),
+				"email":  fmt.Sprintf("user%05d@%s", m, domain),
+				"role":   "MEMBER",
+				"type":   "USER",
+				"status": "ACTIVE",
+			})
+		}
+		// Members are listed by group address or ID.
+		d.render(basePath+"groups/"+email+"/members", "admin#directory#members", "members", list, pageSize)
+		d.render(basePath+"groups/"+id+"/members", "admin#directory#members", "members", list, pageSize)
+	}
+	d.render(basePath+"groups", "admin#directory#groups", "groups", all, pageSize)
+	return d
+}
+
+// GroupEmail returns the address of group n.
+func (d *Directory) GroupEmail(n int) string {
+	return fmt.Sprintf("group%04d@%s", n, d.Domain)
+}
+
+func (d *Directory) render(path, kind, field string, items []interface{}, pageSize int) {
+	for start, page := 0, 0; start == 0 || start < len(items); start, page = start+pageSize, page+1 {
+		end := start + pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		body := map[string]interface{}{"kind": kind, field: items[start:end]}
+		if end < len(items) {
+			body["nextPageToken"] = strconv.Itoa(page + 1)
+		}
+		token := ""
+		if page > 0 {
+			token = strconv.Itoa(page)
+		}
+		data, _ := json.Marshal(body)
+		d.pages[path+"?"+token] = data
+	}
+}
+
+// Client returns an http.Client served by d.
+func (d *Directory) Client() *http.Client {
+	return &http.Client{Transport: d}
+}
+
+// Requests returns the number of requests served so far.
+func (d *Directory) Requests() int {
+	return int(atomic.LoadInt64(&d.requests))
+}
+
+// RoundTrip answers from the rendered pages, or with a 404 in the API's
+// error format.
+func (d *Directory) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&d.requests, 1)
+	status := http.StatusOK
+	// The generated clients escape the path themselves, leaving "%40" in
+	// URL.Path. The synthetic addresses have nothing else to unescape.
+	path := req.URL.Path
+	if p, err := url.QueryUnescape(path); err == nil {
+		path = p
+	}
+	data, ok := d.pages[path+"?"+req.URL.Query().Get("pageToken")]
+	if !ok || req.Method != "GET" {
+		status = http.StatusNotFound
+		data = []byte(`{"error":{"code":404,"message":"Resource Not Found: ` + req.URL.Path + `"}}`)
+	}
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json; charset=UTF-8"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
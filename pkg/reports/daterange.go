@@ -0,0 +1,168 @@
+package reports
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout is how dates are written on the command line and in usage
+// report paths.
+const DateLayout = "2006-01-02"
+
+// DateRange is the period an activity or usage report covers, from the
+// -start-date, -end-date, -last and -timezone flags the tools reporting on
+// a period share. Dates are whole days in Timezone: the range starts at
+// midnight on the start date and runs to the end of the end date.
+type DateRange struct {
+	StartDate string
+	EndDate   string
+	Last      string
+	Timezone  string
+
+	location *time.Location
+	now      func() time.Time
+}
+
+// RegisterDateFlags adds the date range flags to fs. last is the default
+// -last, such as "30d".
+func RegisterDateFlags(fs *flag.FlagSet, last string) *DateRange {
+	r := &DateRange{now: time.Now}
+	fs.StringVar(&r.StartDate, "start-date", "", "The first day to report (YYYY-MM-DD). Overrides -last.")
+	fs.StringVar(&r.EndDate, "end-date", "", "The last day to report (YYYY-MM-DD). Defaults to today.")
+	fs.StringVar(&r.Last, "last", last, "Without -start-date, report this far back from the end: days (30d), weeks (2w) or hours (12h).")
+	fs.StringVar(&r.Timezone, "timezone", "UTC", "The timezone dates are days in, e.g. America/New_York or Local.")
+	return r
+}
+
+// Check returns an error if the flags don't describe a range, so a mistake
+// is reported before a long fetch rather than after it.
+func (r *DateRange) Check() error {
+	_, _, err := r.Bounds()
+	return err
+}
+
+// Bounds returns the start and end of the range. The end is never later
+// than now, since the Reports API rejects times in the future.
+func (r *DateRange) Bounds() (time.Time, time.Time, error) {
+	loc, err := r.loc()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	now := r.now().In(loc)
+	end := now
+	if r.EndDate != "" {
+		d, err := ParseDate(r.EndDate, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-end-date: %v", err)
+		}
+		if d.After(now) {
+			return time.Time{}, time.Time{}, fmt.Errorf("-end-date %s is in the future", r.EndDate)
+		}
+		if end = d.AddDate(0, 0, 1); end.After(now) {
+			end = now
+		}
+	}
+	var start time.Time
+	if r.StartDate != "" {
+		if start, err = ParseDate(r.StartDate, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-start-date: %v", err)
+		}
+	} else {
+		days, d, err := parseLast(r.Last)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-last: %v", err)
+		}
+		// Days are counted on the calendar, so a range across a daylight
+		// saving change still starts at the same time of day.
+		start = end.AddDate(0, 0, -days).Add(-d)
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the range starts (%s) after it ends (%s)",
+			start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return start, end, nil
+}
+
+// Apply sets q's StartTime and EndTime to the range.
+func (r *DateRange) Apply(q *ActivityQuery) error {
+	start, end, err := r.Bounds()
+	if err != nil {
+		return err
+	}
+	q.StartTime = start.UTC().Format(time.RFC3339)
+	q.EndTime = end.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// Days returns each date in the range, first to last, for usage reports.
+func (r *DateRange) Days() ([]string, error) {
+	start, end, err := r.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	days := []string{}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(DateLayout))
+	}
+	return days, nil
+}
+
+// String describes the range for logs.
+func (r *DateRange) String() string {
+	start, end, err := r.Bounds()
+	if err != nil {
+		return err.Error()
+	}
+	return start.Format(time.RFC3339) + " to " + end.Format(time.RFC3339)
+}
+
+// Location returns -timezone's location, for grouping results by day or
+// week the way the range counts them.
+func (r *DateRange) Location() (*time.Location, error) {
+	return r.loc()
+}
+
+func (r *DateRange) loc() (*time.Location, error) {
+	if r.location == nil {
+		loc, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("-timezone: %v", err)
+		}
+		r.location = loc
+	}
+	return r.location, nil
+}
+
+// ParseDate parses a YYYY-MM-DD date as midnight in loc.
+func ParseDate(s string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(DateLayout, strings.TrimSpace(s), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date", s)
+	}
+	return t, nil
+}
+
+// parseLast parses a -last value: a whole number of days (30d) or weeks
+// (2w), or anything time.ParseDuration accepts.
+func parseLast(s string) (int, time.Duration, error) {
+	s = strings.TrimSpace(s)
+	bad := fmt.Errorf("%q is not a positive period such as 30d, 2w or 12h", s)
+	if strings.HasSuffix(s, "d") || strings.HasSuffix(s, "w") {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n <= 0 {
+			return 0, 0, bad
+		}
+		if strings.HasSuffix(s, "w") {
+			n *= 7
+		}
+		return n, 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, 0, bad
+	}
+	return 0, d, nil
+}
@@ -0,0 +1,185 @@
+// Package reports is a client for the parts of the Admin SDK Reports API the
+// tools use.
+package reports
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const basePath = "https://www.googleapis.com/admin/reports/v1/"
+
+// OAuth2 scopes for the Reports API.
+const (
+	AuditReadonlyScope = "https://www.googleapis.com/auth/admin.reports.audit.readonly"
+	UsageReadonlyScope = "https://www.googleapis.com/auth/admin.reports.usage.readonly"
+)
+
+// UsageReport is one entity's usage on one day.
+type UsageReport struct {
+	Date   string `json:"date"`
+	Entity struct {
+		Type      string `json:"type"`
+		UserEmail string `json:"userEmail"`
+		ProfileID string `json:"profileId"`
+	} `json:"entity"`
+	Parameters []UsageParameter `json:"parameters"`
+}
+
+// UsageParameter is a single named usage value. Only one of the value
+// fields is set, depending on the parameter.
+type UsageParameter struct {
+	Name          string `json:"name"`
+	IntValue      string `json:"intValue"`
+	BoolValue     bool   `json:"boolValue"`
+	StringValue   string `json:"stringValue"`
+	DatetimeValue string `json:"datetimeValue"`
+}
+
+// Int returns the named parameter as an integer and whether it was present.
+func (r *UsageReport) Int(name string) (int64, bool) {
+	for _, p := range r.Parameters {
+		if p.Name == name {
+			n, err := strconv.ParseInt(p.IntValue, 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+type usageReports struct {
+	UsageReports  []*UsageReport `json:"usageReports"`
+	NextPageToken string         `json:"nextPageToken"`
+}
+
+// UserUsage returns every user's usage report for date (YYYY-MM-DD),
+// restricted to the given parameters such as "accounts:used_quota_in_mb".
+func UserUsage(ctx context.Context, client *http.Client, date string, parameters ...string) ([]*UsageReport, error) {
+	all := []*UsageReport{}
+	pageToken := ""
+	for {
+		params := url.Values{
+			"parameters": {strings.Join(parameters, ",")},
+			"pageToken":  {pageToken},
+		}
+		r := &usageReports{}
+		if err := rest.Get(ctx, client, rest.URL(basePath, "usage/users/all/dates/"+date, params), r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.UsageReports...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return all, nil
+}
+
+// Activity is a single audit log entry.
+type Activity struct {
+	ID struct {
+		Time            string `json:"time"`
+		UniqueQualifier string `json:"uniqueQualifier"`
+		ApplicationName string `json:"applicationName"`
+		CustomerID      string `json:"customerId"`
+	} `json:"id"`
+	Actor struct {
+		Email      string `json:"email"`
+		ProfileID  string `json:"profileId"`
+		CallerType string `json:"callerType"`
+	} `json:"actor"`
+	IPAddress string  `json:"ipAddress"`
+	Events    []Event `json:"events"`
+}
+
+// Event is one event within an Activity.
+type Event struct {
+	Type       string           `json:"type"`
+	Name       string           `json:"name"`
+	Parameters []EventParameter `json:"parameters"`
+}
+
+// EventParameter is a named event value. Only one value field is set.
+type EventParameter struct {
+	Name       string   `json:"name"`
+	Value      string   `json:"value"`
+	IntValue   string   `json:"intValue"`
+	BoolValue  bool     `json:"boolValue"`
+	MultiValue []string `json:"multiValue"`
+}
+
+// Param returns the named parameter formatted as a string.
+func (e *Event) Param(name string) string {
+	for _, p := range e.Parameters {
+		if p.Name != name {
+			continue
+		}
+		switch {
+		case p.Value != "":
+			return p.Value
+		case p.IntValue != "":
+			return p.IntValue
+		case len(p.MultiValue) > 0:
+			return strings.Join(p.MultiValue, ";")
+		}
+		return strconv.FormatBool(p.BoolValue)
+	}
+	return ""
+}
+
+// ActivityQuery selects audit log entries. Empty fields are not sent.
+type ActivityQuery struct {
+	// Application is e.g. "admin", "login", "groups" or "token".
+	Application string
+	// UserKey is "all" or a user's address; defaults to "all".
+	UserKey   string
+	EventName string
+	// StartTime and EndTime are RFC 3339 timestamps.
+	StartTime string
+	EndTime   string
+	ActorIP   string
+	Filters   string
+}
+
+type activities struct {
+	Items         []*Activity `json:"items"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+// Activities returns every activity matching q, newest first.
+func Activities(ctx context.Context, client *http.Client, q ActivityQuery) ([]*Activity, error) {
+	userKey := q.UserKey
+	if userKey == "" {
+		userKey = "all"
+	}
+	all := []*Activity{}
+	pageToken := ""
+	for {
+		params := url.Values{
+			"eventName":      {q.EventName},
+			"startTime":      {q.StartTime},
+			"endTime":        {q.EndTime},
+			"actorIpAddress": {q.ActorIP},
+			"filters":        {q.Filters},
+			"maxResults":     {"1000"},
+			"pageToken":      {pageToken},
+		}
+		r := &activities{}
+		path := "activity/users/" + url.QueryEscape(userKey) + "/applications/" + q.Application
+		if err := rest.Get(ctx, client, rest.URL(basePath, path, params), r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Items...)
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return all, nil
+}
@@ -0,0 +1,105 @@
+// Package rest is a minimal JSON client for the Google APIs that have no
+// generated client vendored in Godeps. API errors are *googleapi.Error, the
+// same as the generated clients, wrapped in an *apierr.Error when they
+// fall into one of its classes.
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/googleapi"
+
+	"github.com/jburnham/google_apps_tools/pkg/apierr"
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+)
+
+// Get fetches u and decodes the JSON response into out.
+func Get(ctx context.Context, client *http.Client, u string, out interface{}) error {
+	return Do(ctx, client, "GET", u, nil, out)
+}
+
+// Do sends in (if non-nil) as a JSON body and decodes the response into out
+// (if non-nil). Requests failing on authentication are retried, so paging
+// loops built on Do resume from the page that failed.
+func Do(ctx context.Context, client *http.Client, method, u string, in, out interface{}) error {
+	var data []byte
+	if in != nil {
+		var err error
+		if data, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	return retry.OnAuthError(ctx, func() error {
+		return do(ctx, client, method, u, data, out, false)
+	})
+}
+
+// DoIdempotent is Do for a POST that is safe to send twice, such as one
+// creating a resource under an ID the caller chose, so it is retried after
+// a server error too (see retry.MarkIdempotent).
+func DoIdempotent(ctx context.Context, client *http.Client, method, u string, in, out interface{}) error {
+	var data []byte
+	if in != nil {
+		var err error
+		if data, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	return retry.OnAuthError(ctx, func() error {
+		return do(ctx, client, method, u, data, out, true)
+	})
+}
+
+func do(ctx context.Context, client *http.Client, method, u string, data []byte, out interface{}, idempotent bool) error {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "google_apps_tools")
+	if idempotent {
+		retry.MarkIdempotent(req)
+	}
+	res, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	if err := googleapi.CheckResponse(res); err != nil {
+		return apierr.Wrap(err)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// URL joins base and path and appends the non-empty params as a query
+// string.
+func URL(base, path string, params url.Values) string {
+	u := base + path
+	q := url.Values{}
+	for k, vs := range params {
+		for _, v := range vs {
+			if v != "" {
+				q.Add(k, v)
+			}
+		}
+	}
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
@@ -0,0 +1,190 @@
+package retry
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+)
+
+// Backoff is how hard Transport retries a request the API turned away
+// because of rate limits or a transient backend failure.
+type Backoff struct {
+	// MaxRetries is how many times a request is retried. 0 disables
+	// retrying.
+	MaxRetries int
+	// MaxElapsed bounds the time spent waiting to retry one request.
+	MaxElapsed time.Duration
+	// Budget, if not nil, bounds the retries of every request together.
+	Budget *breaker.Budget
+
+	spent sync.Once
+}
+
+// Default is the Backoff of clients built by package auth, set by
+// RegisterFlags.
+var Default = &Backoff{MaxRetries: 8, MaxElapsed: 10 * time.Minute, Budget: breaker.NewBudget(0)}
+
+// RegisterFlags defines -max-retries, -max-retry-elapsed and -retry-budget
+// on fs, setting Default.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&Default.MaxRetries, "max-retries", Default.MaxRetries, "How many times an API call that hit a rate limit or a transient server error is retried (0 disables).")
+	fs.DurationVar(&Default.MaxElapsed, "max-retry-elapsed", Default.MaxElapsed, "The longest to keep retrying one API call.")
+	fs.IntVar(&Default.Budget.Max, "retry-budget", Default.Budget.Max, "How many retries the whole run may make; once they are spent failing calls fail at once, so -max-error-rate can end the run (0 is unlimited).")
+}
+
+// The wait before retry n is random, up to baseWait doubled n times and at
+// most maxWait, so many workers hitting the same limit don't retry in step.
+const (
+	baseWait = time.Second
+	maxWait  = time.Minute
+)
+
+// limitReasons are the 403 error reasons that mean slow down rather than
+// access denied, and failureReasons those that mean the backend failed.
+var (
+	limitReasons   = []string{"rateLimitExceeded", "userRateLimitExceeded"}
+	failureReasons = []string{"backendError"}
+)
+
+// idempotentMethods can be sent again after a server error without doing
+// anything twice.
+var idempotentMethods = map[string]bool{"GET": true, "HEAD": true, "PUT": true, "DELETE": true, "PATCH": true}
+
+// IdempotentHeader marks a POST that is safe to repeat, such as one creating
+// a resource under a name or ID the caller chose. Transport removes it
+// before sending the request.
+const IdempotentHeader = "X-Gat-Idempotent"
+
+// MarkIdempotent lets Transport retry req after a server error even though
+// it is a POST.
+func MarkIdempotent(req *http.Request) {
+	req.Header.Set(IdempotentHeader, "1")
+}
+
+// Transport retries requests that fail with 429 or a 403 rate limit error,
+// which the API turned away without acting on, and idempotent requests
+// that fail with a 5xx, with exponential backoff and jitter, honoring any
+// Retry-After header. A POST is only retried after a server error if it
+// was marked with MarkIdempotent, since the server may have acted on it
+// before failing. Requests whose body can't be replayed are sent once.
+type Transport struct {
+	Base http.RoundTripper
+	// Backoff, if nil, is Default.
+	Backoff *Backoff
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.Backoff
+	if b == nil {
+		b = Default
+	}
+	idempotent := idempotentMethods[req.Method] || req.Header.Get(IdempotentHeader) != ""
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 || req.Header.Get(IdempotentHeader) != "" {
+			r = req.Clone(req.Context())
+			r.Header.Del(IdempotentHeader)
+		}
+		if attempt > 0 {
+			if req.Body != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+		res, err := t.Base.RoundTrip(r)
+		if err != nil || attempt >= b.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return res, err
+		}
+		reason, limited := transient(res)
+		if reason == "" || !limited && !idempotent {
+			return res, nil
+		}
+		wait := retryAfter(res)
+		if wait == 0 {
+			ceiling := baseWait << uint(attempt)
+			if ceiling > maxWait || ceiling <= 0 {
+				ceiling = maxWait
+			}
+			wait = time.Duration(rand.Int63n(int64(ceiling)))
+		}
+		if time.Since(start)+wait > b.MaxElapsed {
+			return res, nil
+		}
+		if !b.Budget.Spend() {
+			b.spent.Do(func() {
+				log.Printf("The -retry-budget of %d retries is spent; failing API calls are no longer retried", b.Budget.Max)
+			})
+			return res, nil
+		}
+		res.Body.Close()
+		log.Printf("%s %s: %s, retrying in %s (retry %d of %d)", req.Method, req.URL.Path, reason,
+			wait.Round(time.Millisecond), attempt+1, b.MaxRetries)
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// transient returns why res is worth retrying, or "" if it isn't, and
+// whether it was a rate limit rather than a server error. A 403's body is
+// read to find its reason and put back for the caller.
+func transient(res *http.Response) (reason string, limited bool) {
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return res.Status, true
+	case res.StatusCode >= 500:
+		return res.Status, false
+	case res.StatusCode != http.StatusForbidden:
+		return "", false
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "", false
+	}
+	for _, reason := range limitReasons {
+		if strings.Contains(string(body), `"`+reason+`"`) {
+			return "403 " + reason, true
+		}
+	}
+	for _, reason := range failureReasons {
+		if strings.Contains(string(body), `"`+reason+`"`) {
+			return "403 " + reason, false
+		}
+	}
+	return "", false
+}
+
+// retryAfter returns the wait a Retry-After header asks for, or 0.
+func retryAfter(res *http.Response) time.Duration {
+	h := res.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
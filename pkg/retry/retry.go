@@ -0,0 +1,52 @@
+// Package retry re-runs API calls that failed for reasons a second attempt
+// can fix.
+package retry
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+)
+
+// authAttempts is how many times a call failing on authentication is tried
+// in all.
+const authAttempts = 4
+
+// IsAuthError reports whether err is a 401 from the API: a token that was
+// revoked or expired in flight, which a fresh token fixes.
+func IsAuthError(err error) bool {
+	var e *googleapi.Error
+	return errors.As(err, &e) && e.Code == http.StatusUnauthorized
+}
+
+// OnAuthError calls fn, calling it again after a pause if the API rejects
+// its token with a 401, until ctx is done. List loops wrap each page fetch
+// in it so that a token problem hours into a run resumes from the page
+// that failed instead of ending the run. The clients package auth builds
+// drop a token the API rejects, so the next attempt fetches a new one.
+//
+// Nothing else is retried here, so no failure is retried at two levels:
+// Transport retries rate limits and server errors, and package auth's
+// token source retries the token endpoint's transient failures.
+func OnAuthError(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < authAttempts; attempt++ {
+		if attempt > 0 {
+			wait := time.Duration(1<<uint(attempt)) * time.Second
+			log.Printf("Authentication failed, retrying in %s: %v", wait, err)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return err
+			}
+		}
+		if err = fn(); !IsAuthError(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+// Package roster reads HR roster exports and compares them with the
+// directory, producing the joiner/leaver/mover actions that keep the two in
+// step.
+package roster
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/admin/directory/v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jburnham/google_apps_tools/pkg/provision"
+)
+
+// Mapping describes an HR system's csv export: which column holds what, and
+// how its values translate into directory terms.
+type Mapping struct {
+	EmailColumn      string `yaml:"email_column"`
+	GivenNameColumn  string `yaml:"given_name_column"`
+	FamilyNameColumn string `yaml:"family_name_column"`
+	OrgUnitColumn    string `yaml:"org_unit_column"`
+	StatusColumn     string `yaml:"status_column"`
+	// ActiveStatuses are the StatusColumn values of current employees.
+	// Without a status column everyone in the file is active.
+	ActiveStatuses []string `yaml:"active_statuses"`
+	// OrgUnits translates OrgUnitColumn values (e.g. departments) into OU
+	// paths. Values not listed are used as paths as-is.
+	OrgUnits map[string]string `yaml:"org_units"`
+	// IgnoreOrgUnits are OUs holding accounts HR doesn't know about
+	// (service accounts, shared mailboxes); they are never leavers or
+	// movers.
+	IgnoreOrgUnits []string `yaml:"ignore_org_units"`
+	// TerminateOrgUnits are the OUs, and those below them, whose accounts
+	// can be leavers. If empty, only accounts in an OU that holds someone
+	// in the roster can be, so accounts in OUs HR has nothing to do with
+	// are left alone.
+	TerminateOrgUnits []string `yaml:"terminate_org_units"`
+	// ExcludeUsers are addresses that are never leavers, such as
+	// break-glass admins and shared mailboxes outside IgnoreOrgUnits.
+	ExcludeUsers []string `yaml:"exclude_users"`
+	// TerminateAdmins lets admins and delegated admins be leavers. By
+	// default they aren't, since suspending one can lock out the domain.
+	TerminateAdmins bool `yaml:"terminate_admins"`
+}
+
+// DefaultMapping reads a file whose only required column is "email".
+func DefaultMapping() *Mapping {
+	return &Mapping{EmailColumn: "email"}
+}
+
+// LoadMapping reads a YAML mapping file.
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if m.EmailColumn == "" {
+		return nil, fmt.Errorf("%s: email_column is required", path)
+	}
+	return m, nil
+}
+
+// Person is one row of the roster.
+type Person struct {
+	Email       string
+	GivenName   string
+	FamilyName  string
+	OrgUnitPath string
+	Active      bool
+}
+
+// Read loads the roster csv at path.
+func Read(path string, m *Mapping) ([]*Person, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	cols := map[string]int{}
+	for i, name := range records[0] {
+		cols[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{m.EmailColumn, m.GivenNameColumn, m.FamilyNameColumn, m.OrgUnitColumn, m.StatusColumn} {
+		if _, ok := cols[name]; name != "" && !ok {
+			return nil, fmt.Errorf("%s has no %q column", path, name)
+		}
+	}
+	get := func(r []string, name string) string {
+		if i, ok := cols[name]; ok && name != "" && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	active := map[string]bool{}
+	for _, s := range m.ActiveStatuses {
+		active[strings.ToLower(s)] = true
+	}
+
+	people := []*Person{}
+	for _, r := range records[1:] {
+		p := &Person{
+			Email:      strings.ToLower(get(r, m.EmailColumn)),
+			GivenName:  get(r, m.GivenNameColumn),
+			FamilyName: get(r, m.FamilyNameColumn),
+			Active:     m.StatusColumn == "" || active[strings.ToLower(get(r, m.StatusColumn))],
+		}
+		if p.Email == "" {
+			continue
+		}
+		ou := get(r, m.OrgUnitColumn)
+		if mapped, ok := m.OrgUnits[ou]; ok {
+			ou = mapped
+		}
+		p.OrgUnitPath = ou
+		people = append(people, p)
+	}
+	return people, nil
+}
+
+// Emails returns the addresses of the active people in the roster.
+func Emails(people []*Person) map[string]bool {
+	emails := map[string]bool{}
+	for _, p := range people {
+		if p.Active {
+			emails[p.Email] = true
+		}
+	}
+	return emails
+}
+
+// Compare returns the actions that bring the directory in line with the
+// roster: hires for active people with no account, terminations for active
+// accounts with no active roster entry, and transfers for people whose OU
+// differs. A user's aliases count as matches for the roster address.
+// Accounts missing from the roster are only terminated if terminable says
+// so; kept is how many were left alone.
+func Compare(people []*Person, users []*admin.User, m *Mapping) (actions []*provision.Action, kept int) {
+	byEmail := map[string]*admin.User{}
+	for _, u := range users {
+		byEmail[strings.ToLower(u.PrimaryEmail)] = u
+		for _, alias := range u.Aliases {
+			byEmail[strings.ToLower(alias)] = u
+		}
+	}
+
+	actions = []*provision.Action{}
+	accounted := map[*admin.User]bool{}
+	// The OUs the roster's people are in, in the directory or the roster.
+	rosterOUs := map[string]bool{}
+	for _, p := range people {
+		u, ok := byEmail[p.Email]
+		if ok {
+			accounted[u] = accounted[u] || p.Active
+			rosterOUs[u.OrgUnitPath] = true
+		}
+		if p.OrgUnitPath != "" {
+			rosterOUs[p.OrgUnitPath] = true
+		}
+		if !p.Active {
+			continue
+		}
+		if !ok {
+			actions = append(actions, &provision.Action{
+				Kind:        provision.Hire,
+				Email:       p.Email,
+				GivenName:   p.GivenName,
+				FamilyName:  p.FamilyName,
+				OrgUnitPath: p.OrgUnitPath,
+			})
+			continue
+		}
+		if p.OrgUnitPath != "" && p.OrgUnitPath != u.OrgUnitPath && !ignored(u.OrgUnitPath, m) {
+			actions = append(actions, &provision.Action{
+				Kind:        provision.Transfer,
+				Email:       strings.ToLower(u.PrimaryEmail),
+				OrgUnitPath: p.OrgUnitPath,
+			})
+		}
+	}
+	for _, u := range users {
+		if u.Suspended || accounted[u] || ignored(u.OrgUnitPath, m) {
+			continue
+		}
+		if !terminable(u, m, rosterOUs) {
+			kept++
+			continue
+		}
+		actions = append(actions, &provision.Action{Kind: provision.Terminate, Email: strings.ToLower(u.PrimaryEmail)})
+	}
+	sort.Sort(byKindEmail(actions))
+	return actions, kept
+}
+
+// terminable reports whether u, an active account missing from the roster,
+// can be a leaver: it isn't excluded or an admin, and is in
+// TerminateOrgUnits or, without those, in one of rosterOUs.
+func terminable(u *admin.User, m *Mapping, rosterOUs map[string]bool) bool {
+	for _, e := range m.ExcludeUsers {
+		if strings.EqualFold(e, u.PrimaryEmail) {
+			return false
+		}
+	}
+	if (u.IsAdmin || u.IsDelegatedAdmin) && !m.TerminateAdmins {
+		return false
+	}
+	if len(m.TerminateOrgUnits) > 0 {
+		return within(u.OrgUnitPath, m.TerminateOrgUnits)
+	}
+	return rosterOUs[u.OrgUnitPath]
+}
+
+func ignored(ou string, m *Mapping) bool {
+	return within(ou, m.IgnoreOrgUnits)
+}
+
+// within reports whether ou is one of ous or below one.
+func within(ou string, ous []string) bool {
+	for _, o := range ous {
+		o = strings.TrimSuffix(o, "/")
+		if ou == o || strings.HasPrefix(ou, o+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type byKindEmail []*provision.Action
+
+func (a byKindEmail) Len() int      { return len(a) }
+func (a byKindEmail) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byKindEmail) Less(i, j int) bool {
+	if a[i].Kind != a[j].Kind {
+		return a[i].Kind < a[j].Kind
+	}
+	return a[i].Email < a[j].Email
+}
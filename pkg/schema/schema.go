@@ -0,0 +1,233 @@
+// Package schema versions the reports the tools write. Every report ends
+// with a schema_version column holding "<report>/<version>", so a
+// downstream parser can tell which layout it has been given, and
+// Migrate brings a file written by an older release up to date.
+//
+// Changing a report's columns means bumping its version here and adding
+// the step that converts the previous version.
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Column is the name of the column holding the schema stamp. It is always
+// last.
+const Column = "schema_version"
+
+// Table is a report without its stamp column.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Index returns the position of the named column, or -1.
+func (t *Table) Index(name string) int {
+	for i, h := range t.Header {
+		if h == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddColumn inserts a column after the column named after (or first if
+// after is ""), filling existing rows with value.
+func (t *Table) AddColumn(name, after, value string) {
+	at := 0
+	if after != "" {
+		at = t.Index(after) + 1
+	}
+	t.Header = insert(t.Header, at, name)
+	for i, row := range t.Rows {
+		t.Rows[i] = insert(row, at, value)
+	}
+}
+
+// RenameColumn renames a column if it is present.
+func (t *Table) RenameColumn(from, to string) {
+	if i := t.Index(from); i >= 0 {
+		t.Header[i] = to
+	}
+}
+
+func insert(s []string, at int, v string) []string {
+	if at > len(s) {
+		at = len(s)
+	}
+	out := make([]string, 0, len(s)+1)
+	out = append(out, s[:at]...)
+	out = append(out, v)
+	return append(out, s[at:]...)
+}
+
+// Step converts a table from version From to From+1.
+type Step struct {
+	From  int
+	Apply func(t *Table)
+}
+
+// report is the current version of one report and how to get there.
+type report struct {
+	version int
+	steps   []Step
+}
+
+// reports lists every report the tools write. Version 1 is the layout
+// each had when stamping was introduced, so unstamped files are version 1.
+var reports = map[string]*report{
+	"abuse_report_dashboard_export":               {version: 1},
+	"access_level_report":                         {version: 1},
+	"activity_report":                             {version: 1},
+	"admin_alert_subscription_manager":            {version: 1},
+	"admin_console_takeover_prep":                 {version: 1},
+	"admin_sdk_watchdog":                          {version: 1},
+	"audit_2sv_exceptions":                        {version: 1},
+	"calendar_delegation_report":                  {version: 1},
+	"chat_spaces_report":                          {version: 1},
+	"chat_spaces_report_members":                  {version: 1},
+	"chrome_devices_report":                       {version: 1},
+	"contact_delegation_report":                   {version: 1},
+	"deleted_users_report":                        {version: 1},
+	"domain_users_photo_report":                   {version: 1},
+	"domain_users_photo_report_by_ou":             {version: 1},
+	"domain_wide_delegation_inventory":            {version: 1},
+	"drive_external_sharing_report":               {version: 1},
+	"drive_labels_report":                         {version: 1},
+	"drive_labels_report_taxonomy":                {version: 1},
+	"duplicate_account_detector":                  {version: 1},
+	"email_settings_imap_pop_report":              {version: 1},
+	"email_settings_imap_pop_report_by_ou":        {version: 1},
+	"endpoint_verification_report":                {version: 1},
+	"external_domain_relationship_report":         {version: 1},
+	"external_domain_relationship_report_details": {version: 1},
+	"gat_group_history":                           {version: 1},
+	"gat_memberof":                                {version: 1},
+	"gat_whatif":                                  {version: 1},
+	"gat_whohas":                                  {version: 1},
+	"gcp_iam_google_group_usage_report":           {version: 1},
+	"gmail_settings_report":                       {version: 1},
+	"group_description_backfill":                  {version: 1},
+	"group_members_report":                        {version: 3, steps: groupMembersSteps},
+	"group_members_report_diff":                   {version: 1},
+	"group_membership_expiring_access_report":     {version: 1},
+	"group_settings_report":                       {version: 1},
+	"group_spam_moderation_stats":                 {version: 1},
+	"group_welcome_message_manager":               {version: 1},
+	"inbound_sso_profile_report":                  {version: 1},
+	"inbound_sso_profile_report_assignments":      {version: 1},
+	"license_report":                              {version: 1},
+	"license_report_by_sku":                       {version: 1},
+	"mobile_devices_report":                       {version: 1},
+	"orgunits_report":                             {version: 1},
+	"per_ou_group_report":                         {version: 1},
+	"shared_drive_report":                         {version: 1},
+	"shared_drive_report_permissions":             {version: 1},
+	"storage_quota_alerts":                        {version: 1},
+	"takeover_unmanaged_accounts":                 {version: 1},
+	"tasks_and_keep_usage_report":                 {version: 1},
+	"tasks_and_keep_usage_report_by_ou":           {version: 1},
+	"user_creation_date_report":                   {version: 1},
+	"users_report":                                {version: 1},
+}
+
+var groupMembersSteps = []Step{
+	// 2 added the domain each group was listed from, which for an older
+	// file is the group's own.
+	{From: 1, Apply: func(t *Table) {
+		t.AddColumn("domain", "", "")
+		if group := t.Index("group"); group >= 0 {
+			for _, row := range t.Rows {
+				row[0] = domainOf(row[group])
+			}
+		}
+	}},
+	// 3 added each membership's role, type, status and delivery settings,
+	// which an older file doesn't know.
+	{From: 2, Apply: func(t *Table) {
+		t.AddColumn("role", "email", "")
+		t.AddColumn("type", "role", "")
+		t.AddColumn("status", "type", "")
+		t.AddColumn("delivery_settings", "status", "")
+	}},
+}
+
+// domainOf returns the part of an address after the @.
+func domainOf(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+// Names returns the known report names, sorted.
+func Names() []string {
+	names := []string{}
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unregistered is the version Stamp gives a report missing from reports.
+const unregistered = "unregistered"
+
+// Stamp returns the current stamp for the named report. A report that
+// isn't registered, which is a bug in the tool asking, gets the stamp
+// "<report>/unregistered", which Check refuses, so the tool reports it
+// before writing anything.
+func Stamp(name string) string {
+	r, ok := reports[name]
+	if !ok {
+		return name + "/" + unregistered
+	}
+	return fmt.Sprintf("%s/%d", name, r.version)
+}
+
+// Check returns an error if stamp is one Stamp gave an unregistered
+// report.
+func Check(stamp string) error {
+	if name := strings.TrimSuffix(stamp, "/"+unregistered); name != stamp {
+		return fmt.Errorf("report %s has no schema version: it must be added to package schema", name)
+	}
+	return nil
+}
+
+// Parse splits a stamp into report name and version.
+func Parse(stamp string) (string, int, error) {
+	i := strings.LastIndex(stamp, "/")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid schema stamp %q", stamp)
+	}
+	version, err := strconv.Atoi(stamp[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid schema stamp %q", stamp)
+	}
+	return stamp[:i], version, nil
+}
+
+// Migrate converts t, a version from table of the named report, to the
+// current version, and returns the current stamp.
+func Migrate(name string, from int, t *Table) (string, error) {
+	r, ok := reports[name]
+	if !ok {
+		return "", fmt.Errorf("unknown report %q: the reports are %s", name, strings.Join(Names(), ", "))
+	}
+	if from > r.version {
+		return "", fmt.Errorf("%s version %d is newer than this release understands (%d)", name, from, r.version)
+	}
+	for v := from; v < r.version; v++ {
+		found := false
+		for _, s := range r.steps {
+			if s.From == v {
+				s.Apply(t)
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("no migration for %s from version %d", name, v)
+		}
+	}
+	return Stamp(name), nil
+}
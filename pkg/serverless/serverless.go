@@ -0,0 +1,370 @@
+// Package serverless runs the report tools behind an HTTP endpoint, for
+// deploying them as a Cloud Run service or Cloud Function. Configuration
+// comes from the environment, the service account key from Secret Manager,
+// and reports are written to Cloud Storage.
+//
+// A request names the report in its path and may pass the report's
+// filter flags as query parameters:
+//
+//	POST /group_members_report?added-dates=true
+//
+// It must carry a Google ID token, "Authorization: Bearer TOKEN", for one
+// of the accounts in GAT_INVOKERS, such as the Cloud Scheduler job's
+// service account.
+//
+// The tool binaries must be installed alongside the service (on $PATH or
+// in GAT_TOOLS_DIR).
+package serverless
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/gcs"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+// report describes how to run one tool: whether it takes -domain, the
+// flags naming the files it writes, with the file names to give them, and
+// the flags a request may set.
+type report struct {
+	domain  bool
+	outputs map[string]string
+	// params only narrow or reshape what is reported. Flags naming files,
+	// credentials, state or anything that writes or sends are never
+	// listed.
+	params []string
+}
+
+// outputParams are the report output flags a request may set.
+var outputParams = []string{"fields", "filter"}
+
+// reports are the tools that may be run this way. Only read-only reports
+// are listed; tools that change the directory need a person at the wheel.
+var reports = map[string]*report{
+	"admin_console_takeover_prep": {false, map[string]string{"output-file": "takeover_checklist.csv"},
+		append([]string{"admin"}, outputParams...)},
+	"contact_delegation_report": {true, map[string]string{"output-file": "contact_delegation.csv"},
+		outputParams},
+	"deleted_users_report": {true, map[string]string{"output-file": "deleted_users.csv"},
+		append([]string{"days"}, outputParams...)},
+	"domain_users_photo_report": {true, map[string]string{"output-file": "users_without_photos.csv", "summary-file": "photos_by_ou.csv"},
+		append([]string{"include-suspended"}, outputParams...)},
+	"duplicate_account_detector": {true, map[string]string{"output-file": "duplicate_accounts.csv"},
+		outputParams},
+	"group_members_report": {true, map[string]string{"output-file": "group_members.csv"},
+		append([]string{"group", "group-filter", "group-regex", "expand", "expand-nested", "max-depth", "paths",
+			"member-status", "concurrency", "added-dates", "dedupe", "resolve-aliases", "strip-plus"}, outputParams...)},
+	"group_membership_graph_export": {true, map[string]string{"output-file": "memberships.graph"},
+		[]string{"format"}},
+	"storage_quota_alerts": {false, map[string]string{"output-file": "storage_alerts.csv"},
+		append([]string{"thresholds", "date"}, outputParams...)},
+}
+
+// authParams choose the account a tool runs as, so a request can never
+// set them, whatever a report's params list.
+var authParams = map[string]bool{
+	"credentials-file": true, "credentials-secret": true, "credentials-env": true,
+	"auth-mode": true, "service-account": true, "impersonated-email": true, "config": true,
+}
+
+func (rep *report) allows(param string) bool {
+	if authParams[param] {
+		return false
+	}
+	for _, p := range rep.params {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the service's configuration.
+type Config struct {
+	// Bucket and Prefix say where reports go: each run is written under
+	// gs://Bucket/Prefix/<tool>/<UTC time>/.
+	Bucket            string
+	Prefix            string
+	CredentialsJSON   []byte
+	ImpersonatedEmail string
+	Domain            string
+	// ToolsDir holds the tool binaries; if empty they are found on $PATH.
+	ToolsDir string
+	// Storage is the client used to upload reports.
+	Storage *http.Client
+	// Invokers are the accounts whose ID tokens may run reports.
+	Invokers map[string]bool
+	// Audience, if set, is the audience the ID tokens must be for, such as
+	// the service's URL.
+	Audience string
+}
+
+// ConfigFromEnv builds a Config from these environment variables:
+//
+//	GAT_OUTPUT              gs://bucket/prefix for reports (required)
+//	GAT_CREDENTIALS_SECRET  Secret Manager secret holding the key, or
+//	GAT_CREDENTIALS_FILE    a key file mounted into the container
+//	GAT_IMPERSONATED_EMAIL  the admin to impersonate (required)
+//	GAT_DOMAIN              the domain, for tools that take -domain
+//	GAT_TOOLS_DIR           where the tool binaries are installed
+//	GAT_INVOKERS            comma separated accounts that may run reports
+//	                        (required)
+//	GAT_AUDIENCE            the audience their ID tokens must have
+//
+// Every missing or invalid variable is reported, not just the first.
+func ConfigFromEnv(ctx context.Context) (*Config, error) {
+	problems := []string{}
+	c := &Config{
+		ImpersonatedEmail: os.Getenv("GAT_IMPERSONATED_EMAIL"),
+		Domain:            os.Getenv("GAT_DOMAIN"),
+		ToolsDir:          os.Getenv("GAT_TOOLS_DIR"),
+		Invokers:          map[string]bool{},
+		Audience:          os.Getenv("GAT_AUDIENCE"),
+	}
+	if c.ImpersonatedEmail == "" {
+		problems = append(problems, "GAT_IMPERSONATED_EMAIL is not set")
+	}
+	for _, invoker := range strings.Split(os.Getenv("GAT_INVOKERS"), ",") {
+		if invoker = strings.ToLower(strings.TrimSpace(invoker)); invoker != "" {
+			c.Invokers[invoker] = true
+		}
+	}
+	if len(c.Invokers) == 0 {
+		problems = append(problems, "GAT_INVOKERS is not set: name the accounts that may run reports")
+	}
+	var err error
+	c.Bucket, c.Prefix, err = gcs.ParseURL(os.Getenv("GAT_OUTPUT"))
+	if err != nil {
+		problems = append(problems, "GAT_OUTPUT: "+err.Error())
+	}
+	c.Storage, err = auth.DefaultClient(ctx)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("no default credentials: %v", err))
+	}
+
+	secret, file := os.Getenv("GAT_CREDENTIALS_SECRET"), os.Getenv("GAT_CREDENTIALS_FILE")
+	switch {
+	case secret != "" && c.Storage != nil:
+		if c.CredentialsJSON, err = auth.AccessSecret(ctx, c.Storage, secret); err != nil {
+			problems = append(problems, "GAT_CREDENTIALS_SECRET: "+err.Error())
+		}
+	case file != "":
+		if c.CredentialsJSON, err = ioutil.ReadFile(file); err != nil {
+			problems = append(problems, "GAT_CREDENTIALS_FILE: "+err.Error())
+		}
+	case secret == "":
+		problems = append(problems, "one of GAT_CREDENTIALS_SECRET or GAT_CREDENTIALS_FILE must be set")
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return c, nil
+}
+
+// keyEnv is the variable the service account key is passed to a tool in.
+const keyEnv = "GAT_SERVERLESS_KEY"
+
+// result is the JSON response to a run.
+type result struct {
+	Report  string   `json:"report"`
+	Objects []string `json:"objects,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Log     string   `json:"log"`
+}
+
+// ServeHTTP runs the report named by the request path.
+func (c *Config) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	invoker, err := c.authenticate(r)
+	if err != nil {
+		log.Printf("Refused request: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !c.Invokers[invoker] {
+		log.Printf("Refused request from %s: not in GAT_INVOKERS", invoker)
+		http.Error(w, invoker+" may not run reports", http.StatusForbidden)
+		return
+	}
+	name := strings.Trim(r.URL.Path, "/")
+	rep, ok := reports[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown report %q", name), http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := []string{}
+	for k := range r.Form {
+		if !rep.allows(k) {
+			http.Error(w, fmt.Sprintf("-%s can't be set by a request; %s takes -%s", k, name, strings.Join(rep.params, ", -")), http.StatusBadRequest)
+			return
+		}
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	extra := []string{}
+	for _, k := range params {
+		for _, v := range r.Form[k] {
+			extra = append(extra, "-"+k+"="+v)
+		}
+	}
+
+	log.Printf("%s requested %s", invoker, name)
+	res := c.run(name, rep, extra)
+	status := http.StatusOK
+	if res.Error != "" {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// tokenInfoURL verifies Google ID tokens.
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// authenticate verifies the request's ID token and returns the account it
+// was issued to.
+func (c *Config) authenticate(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header || token == "" {
+		return "", fmt.Errorf("no ID token: send Authorization: Bearer TOKEN")
+	}
+	info := &struct {
+		Audience      string `json:"aud"`
+		Email         string `json:"email"`
+		EmailVerified string `json:"email_verified"`
+	}{}
+	u := rest.URL(tokenInfoURL, "", url.Values{"id_token": {token}})
+	if err := rest.Get(context.Background(), http.DefaultClient, u, info); err != nil {
+		// The error may quote the URL, and so the token; it isn't logged.
+		return "", fmt.Errorf("the ID token is invalid or couldn't be verified")
+	}
+	if info.Email == "" || info.EmailVerified != "true" {
+		return "", fmt.Errorf("the ID token has no verified email")
+	}
+	if c.Audience != "" && info.Audience != c.Audience {
+		return "", fmt.Errorf("the ID token is for %q, not %q", info.Audience, c.Audience)
+	}
+	return strings.ToLower(info.Email), nil
+}
+
+func (c *Config) run(name string, rep *report, extra []string) *result {
+	res := &result{Report: name}
+	fail := func(err error) *result {
+		log.Printf("Error running %s: %v", name, err)
+		res.Error = err.Error()
+		return res
+	}
+	dir, err := ioutil.TempDir("", name)
+	if err != nil {
+		return fail(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The key reaches the tool through its environment rather than a file,
+	// so it is never written to disk.
+	args := []string{"-credentials-env", keyEnv, "-impersonated-email", c.ImpersonatedEmail}
+	if rep.domain {
+		args = append(args, "-domain", c.Domain)
+	}
+	for flag, file := range rep.outputs {
+		args = append(args, "-"+flag, filepath.Join(dir, file))
+	}
+	args = append(args, extra...)
+	bin := name
+	if c.ToolsDir != "" {
+		bin = filepath.Join(c.ToolsDir, name)
+	}
+	log.Printf("Running %s %s", name, strings.Join(extra, " "))
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), keyEnv+"="+string(c.CredentialsJSON))
+	out, runErr := cmd.CombinedOutput()
+	res.Log = string(out)
+
+	// Upload whatever was written even if the run failed: tools write
+	// partial reports when they abort.
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	files := []string{}
+	for _, file := range rep.outputs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(dir, file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fail(err)
+		}
+		object := path.Join(c.Prefix, name, stamp, file)
+		err = gcs.Upload(context.Background(), c.Storage, c.Bucket, object, contentType(file), f)
+		f.Close()
+		if err != nil {
+			return fail(err)
+		}
+		res.Objects = append(res.Objects, "gs://"+c.Bucket+"/"+object)
+	}
+	if runErr != nil {
+		return fail(fmt.Errorf("%s failed: %v", name, runErr))
+	}
+	log.Printf("Wrote %s", strings.Join(res.Objects, ", "))
+	return res
+}
+
+func contentType(file string) string {
+	switch filepath.Ext(file) {
+	case ".csv":
+		return "text/csv"
+	case ".json":
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+var (
+	once      sync.Once
+	config    *Config
+	configErr error
+)
+
+// Handler is the Cloud Functions entry point. The configuration is read
+// from the environment on the first call and reused after that.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	once.Do(func() {
+		config, configErr = ConfigFromEnv(context.Background())
+	})
+	if configErr != nil {
+		log.Print(configErr)
+		http.Error(w, configErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	config.ServeHTTP(w, r)
+}
@@ -0,0 +1,41 @@
+// Package shutdown stops a long run cleanly when it is interrupted. The
+// first SIGINT or SIGTERM cancels the run's context, so API calls in flight
+// return and the tool can write out what it has; a second one exits at
+// once.
+package shutdown
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/net/context"
+)
+
+// ExitCode is the status a tool exits with after being stopped by a
+// signal, so a wrapper script can tell an interrupted run from a failed
+// one (1) or a complete one.
+const ExitCode = 130
+
+// Context returns a copy of parent that is canceled on the first SIGINT or
+// SIGTERM.
+func Context(parent context.Context) context.Context {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, stopping; send it again to exit at once", sig)
+		cancel()
+		<-signals
+		os.Exit(ExitCode)
+	}()
+	return ctx
+}
+
+// Interrupted reports whether ctx, from Context, has been canceled by a
+// signal.
+func Interrupted(ctx context.Context) bool {
+	return ctx.Err() == context.Canceled
+}
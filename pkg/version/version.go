@@ -0,0 +1,8 @@
+// Package version holds the version the tools print for -version. Each
+// binary's main sets Git from its own gitVersion, which the build stamps:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+package version
+
+// Git is the commit the running binary was built from, or "" if the build
+// wasn't stamped.
+var Git string
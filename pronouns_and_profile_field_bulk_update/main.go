@@ -0,0 +1,24 @@
+// Command pronouns_and_profile_field_bulk_update runs the
+// pronouns_and_profile_field_bulk_update tool; see package
+// pronounsandprofilefieldbulkupdate in
+// tools/pronouns_and_profile_field_bulk_update.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/pronouns_and_profile_field_bulk_update"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := pronounsandprofilefieldbulkupdate.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command shared_contacts_sync runs the shared_contacts_sync tool; see
+// package sharedcontactssync in tools/shared_contacts_sync.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/shared_contacts_sync"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := sharedcontactssync.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
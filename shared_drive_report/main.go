@@ -0,0 +1,22 @@
+// Command shared_drive_report runs the shared_drive_report tool; see
+// package shareddrivereport in tools/shared_drive_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/shared_drive_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := shareddrivereport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
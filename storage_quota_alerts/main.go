@@ -0,0 +1,22 @@
+// Command storage_quota_alerts runs the storage_quota_alerts tool; see
+// package storagequotaalerts in tools/storage_quota_alerts.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/storage_quota_alerts"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := storagequotaalerts.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
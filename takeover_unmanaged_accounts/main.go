@@ -0,0 +1,23 @@
+// Command takeover_unmanaged_accounts runs the takeover_unmanaged_accounts
+// tool; see package takeoverunmanagedaccounts in
+// tools/takeover_unmanaged_accounts.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/takeover_unmanaged_accounts"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := takeoverunmanagedaccounts.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Command tasks_and_keep_usage_report runs the tasks_and_keep_usage_report
+// tool; see package tasksandkeepusagereport in
+// tools/tasks_and_keep_usage_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/tasks_and_keep_usage_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := tasksandkeepusagereport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
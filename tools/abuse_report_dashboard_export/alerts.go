@@ -0,0 +1,63 @@
+package abusereportdashboardexport
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const alertsScope = "https://www.googleapis.com/auth/apps.alerts"
+
+// alert is an Alert Center alert. Data is only decoded for the Gmail alert
+// types, which share a layout.
+type alert struct {
+	AlertID    string `json:"alertId"`
+	CreateTime string `json:"createTime"`
+	Type       string `json:"type"`
+	Source     string `json:"source"`
+	Data       struct {
+		MaliciousEntity struct {
+			FromHeader  string `json:"fromHeader"`
+			DisplayName string `json:"displayName"`
+		} `json:"maliciousEntity"`
+		Messages []struct {
+			MessageID   string `json:"messageId"`
+			Recipient   string `json:"recipient"`
+			SubjectText string `json:"subjectText"`
+			Date        string `json:"date"`
+		} `json:"messages"`
+		SystemActionType string `json:"systemActionType"`
+	} `json:"data"`
+}
+
+// listAlerts returns the alerts created between start and end. The Alert
+// Center keeps alerts for about 30 days after they end.
+func listAlerts(ctx context.Context, client *http.Client, start, end time.Time) ([]*alert, error) {
+	alerts := []*alert{}
+	pageToken := ""
+	filter := `createTime >= "` + start.UTC().Format(time.RFC3339) + `" AND createTime < "` + end.UTC().Format(time.RFC3339) + `"`
+	for {
+		r := &struct {
+			Alerts        []*alert `json:"alerts"`
+			NextPageToken string   `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://alertcenter.googleapis.com/v1beta1/", "alerts", url.Values{
+			"filter":    {filter},
+			"orderBy":   {"createTime asc"},
+			"pageSize":  {"100"},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, r.Alerts...)
+		if r.NextPageToken == "" {
+			return alerts, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
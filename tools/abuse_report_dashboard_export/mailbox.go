@@ -0,0 +1,55 @@
+package abusereportdashboardexport
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const gmailReadonlyScope = "https://www.googleapis.com/auth/gmail.readonly"
+
+// mailboxState returns where the message with the given Message-ID header
+// is in the impersonated user's mailbox: inbox, spam, trash, archived, or
+// gone if they no longer have it.
+func mailboxState(ctx context.Context, client *http.Client, messageID string) (string, error) {
+	list := &struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}{}
+	u := rest.URL("https://www.googleapis.com/gmail/v1/users/", "me/messages", url.Values{
+		"q":                {"rfc822msgid:" + messageID},
+		"includeSpamTrash": {"true"},
+	})
+	if err := rest.Get(ctx, client, u, list); err != nil {
+		return "", err
+	}
+	if len(list.Messages) == 0 {
+		return "gone", nil
+	}
+	m := &struct {
+		LabelIDs []string `json:"labelIds"`
+	}{}
+	u = rest.URL("https://www.googleapis.com/gmail/v1/users/", "me/messages/"+list.Messages[0].ID, url.Values{
+		"format": {"minimal"},
+	})
+	if err := rest.Get(ctx, client, u, m); err != nil {
+		return "", err
+	}
+	labels := map[string]bool{}
+	for _, l := range m.LabelIDs {
+		labels[l] = true
+	}
+	switch {
+	case labels["TRASH"]:
+		return "trash", nil
+	case labels["SPAM"]:
+		return "spam", nil
+	case labels["INBOX"]:
+		return "inbox", nil
+	}
+	return "archived", nil
+}
@@ -0,0 +1,228 @@
+// Package abusereportdashboardexport is the abuse_report_dashboard_export
+// tool: a weekly digest of user-reported phishing and spam from the Alert
+// Center, by sender.
+package abusereportdashboardexport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of abuse_report_dashboard_export, which Run
+// parses.
+var commandLine = flag.NewFlagSet("abuse_report_dashboard_export", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	dateRange             = reports.RegisterDateFlags(commandLine, "1w")
+	typesFlag             = commandLine.String("types", "User reported phishing,User reported spam spike", "Comma separated Alert Center alert types to export.")
+	checkMailboxesFlag    = commandLine.Bool("check-mailboxes", false, "Look up each reported message in its recipient's mailbox and count those still in the inbox. Calls Gmail as every recipient.")
+	outputFile            = commandLine.String("output-file", "abuse_reports.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "abuse_report_dashboard_export", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// digest is one week's reports of one alert type about one sender.
+type digest struct {
+	week       string
+	alertType  string
+	sender     string
+	subjects   map[string]bool
+	recipients map[string]bool
+	alerts     []string
+	messages   int
+	inInbox    int
+	first      time.Time
+	last       time.Time
+}
+
+type byWeek []*digest
+
+func (d byWeek) Len() int      { return len(d) }
+func (d byWeek) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d byWeek) Less(i, j int) bool {
+	if d[i].week != d[j].week {
+		return d[i].week < d[j].week
+	}
+	if d[i].messages != d[j].messages {
+		return d[i].messages > d[j].messages
+	}
+	return d[i].sender < d[j].sender
+}
+
+// Run runs abuse_report_dashboard_export with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("abuse_report_dashboard_export", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(dateRange.Check())
+	types := map[string]bool{}
+	for _, t := range listfile.Split(*typesFlag) {
+		types[t] = true
+	}
+	if len(types) == 0 {
+		check.Problemf("-types names no alert types")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, alertsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// A message can only be found in a mailbox by its owner, so each
+	// recipient's is searched as them.
+	var impersonator *auth.Impersonator
+	if *checkMailboxesFlag {
+		if impersonator, err = auth.NewImpersonator(*credentialsFileFlag, gmailReadonlyScope); err != nil {
+			log.Fatal(err)
+		}
+	}
+	loc, err := dateRange.Location()
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := dateRange.Bounds()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Fetching alerts from %s", dateRange)
+	alerts, err := listAlerts(ctx, client, start, end)
+	if err != nil {
+		log.Fatalf("Error fetching alerts: %v", err)
+	}
+
+	digests := map[string]*digest{}
+	clients := map[string]*http.Client{}
+	reported, checked, unchecked := 0, 0, 0
+	for _, a := range alerts {
+		if !types[a.Type] {
+			continue
+		}
+		reported++
+		created, err := time.Parse(time.RFC3339, a.CreateTime)
+		if err != nil {
+			log.Printf("Skipping alert %s: bad createTime %q", a.AlertID, a.CreateTime)
+			continue
+		}
+		created = created.In(loc)
+		week := weekOf(created).Format(reports.DateLayout)
+		sender := strings.ToLower(a.Data.MaliciousEntity.FromHeader)
+		key := week + "\x00" + a.Type + "\x00" + sender
+		d, ok := digests[key]
+		if !ok {
+			d = &digest{week: week, alertType: a.Type, sender: sender, subjects: map[string]bool{},
+				recipients: map[string]bool{}, first: created, last: created}
+			digests[key] = d
+		}
+		d.alerts = append(d.alerts, a.AlertID)
+		if created.Before(d.first) {
+			d.first = created
+		}
+		if created.After(d.last) {
+			d.last = created
+		}
+		for _, m := range a.Data.Messages {
+			d.messages++
+			d.subjects[m.SubjectText] = true
+			recipient := strings.ToLower(m.Recipient)
+			d.recipients[recipient] = true
+			if impersonator == nil || recipient == "" || m.MessageID == "" {
+				continue
+			}
+			userClient, ok := clients[recipient]
+			if !ok {
+				if userClient, err = impersonator.Client(ctx, recipient); err != nil {
+					log.Printf("Can't act as %s: %v", recipient, err)
+				}
+				clients[recipient] = userClient
+			}
+			if userClient == nil {
+				unchecked++
+				continue
+			}
+			state, err := mailboxState(ctx, userClient, m.MessageID)
+			if err != nil {
+				log.Printf("Can't check %s's mailbox for %s: %v", recipient, m.MessageID, err)
+				unchecked++
+				continue
+			}
+			checked++
+			if state == "inbox" {
+				d.inInbox++
+			}
+		}
+	}
+
+	sorted := []*digest{}
+	for _, d := range digests {
+		sorted = append(sorted, d)
+	}
+	sort.Sort(byWeek(sorted))
+	rows := [][]string{
+		{"week", "alert_type", "sender", "alerts", "messages", "recipients", "in_inbox", "subjects", "first_reported", "last_reported", "alert_ids"},
+	}
+	for _, d := range sorted {
+		inInbox := ""
+		if impersonator != nil {
+			inInbox = strconv.Itoa(d.inInbox)
+		}
+		rows = append(rows, []string{d.week, d.alertType, d.sender, strconv.Itoa(len(d.alerts)),
+			strconv.Itoa(d.messages), strconv.Itoa(len(d.recipients)), inInbox, strings.Join(keys(d.subjects), ";"),
+			d.first.Format(time.RFC3339), d.last.Format(time.RFC3339), strings.Join(d.alerts, ";")})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if impersonator != nil {
+		log.Printf("Checked %d reported messages in their mailboxes, %d couldn't be checked", checked, unchecked)
+	}
+	log.Printf("%d senders reported across %d alerts", len(sorted), reported)
+	log.Println("Complete")
+	return nil
+}
+
+// weekOf returns midnight on the Monday starting t's week, in t's location.
+func weekOf(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -days).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// keys returns the set's non-empty members, sorted.
+func keys(set map[string]bool) []string {
+	list := []string{}
+	for k := range set {
+		if k != "" {
+			list = append(list, k)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
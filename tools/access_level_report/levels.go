@@ -0,0 +1,317 @@
+package accesslevelreport
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/devices"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const acmBasePath = "https://accesscontextmanager.googleapis.com/v1/"
+
+// accessLevel is an Access Context Manager level. Only basic levels can be
+// evaluated here; custom levels are CEL expressions.
+type accessLevel struct {
+	// Name is "accessPolicies/<policy>/accessLevels/<level>".
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Basic *struct {
+		Conditions        []*condition `json:"conditions"`
+		CombiningFunction string       `json:"combiningFunction"`
+	} `json:"basic"`
+}
+
+type condition struct {
+	IPSubnetworks        []string      `json:"ipSubnetworks"`
+	Regions              []string      `json:"regions"`
+	Members              []string      `json:"members"`
+	RequiredAccessLevels []string      `json:"requiredAccessLevels"`
+	Negate               bool          `json:"negate"`
+	DevicePolicy         *devicePolicy `json:"devicePolicy"`
+}
+
+type devicePolicy struct {
+	RequireScreenlock             bool     `json:"requireScreenlock"`
+	AllowedEncryptionStatuses     []string `json:"allowedEncryptionStatuses"`
+	AllowedDeviceManagementLevels []string `json:"allowedDeviceManagementLevels"`
+	OSConstraints                 []struct {
+		OSType         string `json:"osType"`
+		MinimumVersion string `json:"minimumVersion"`
+	} `json:"osConstraints"`
+	RequireAdminApproval bool `json:"requireAdminApproval"`
+	RequireCorpOwned     bool `json:"requireCorpOwned"`
+}
+
+// listLevels returns the access levels of the organization's access
+// policies.
+func listLevels(ctx context.Context, client *http.Client, organization string) ([]*accessLevel, error) {
+	policies := &struct {
+		AccessPolicies []struct {
+			Name string `json:"name"`
+		} `json:"accessPolicies"`
+	}{}
+	u := rest.URL(acmBasePath, "accessPolicies", url.Values{"parent": {"organizations/" + organization}})
+	if err := rest.Get(ctx, client, u, policies); err != nil {
+		return nil, err
+	}
+	levels := []*accessLevel{}
+	for _, p := range policies.AccessPolicies {
+		pageToken := ""
+		for {
+			r := &struct {
+				AccessLevels  []*accessLevel `json:"accessLevels"`
+				NextPageToken string         `json:"nextPageToken"`
+			}{}
+			u := rest.URL(acmBasePath, p.Name+"/accessLevels", url.Values{
+				"accessLevelFormat": {"AS_DEFINED"},
+				"pageToken":         {pageToken},
+			})
+			if err := rest.Get(ctx, client, u, r); err != nil {
+				return nil, err
+			}
+			levels = append(levels, r.AccessLevels...)
+			if r.NextPageToken == "" {
+				break
+			}
+			pageToken = r.NextPageToken
+		}
+	}
+	return levels, nil
+}
+
+// outcome is whether a device and user satisfy a level. Some attributes
+// (where a request comes from, custom expressions) can only be judged at
+// request time, so the answer can be undetermined.
+type outcome int
+
+const (
+	satisfied outcome = iota
+	undetermined
+	unsatisfied
+)
+
+func (o outcome) String() string {
+	return [...]string{"satisfied", "undetermined", "not satisfied"}[o]
+}
+
+// result is an outcome with the reasons for anything short of satisfied.
+type result struct {
+	outcome outcome
+	reasons []string
+}
+
+func (r *result) fail(reason string) {
+	r.outcome = unsatisfied
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *result) unknown(reason string) {
+	if r.outcome == satisfied {
+		r.outcome = undetermined
+	}
+	r.reasons = append(r.reasons, reason)
+}
+
+// evaluator checks devices against levels, resolving the levels that
+// conditions require by name.
+type evaluator struct {
+	levels map[string]*accessLevel
+}
+
+func (e *evaluator) level(l *accessLevel, d *devices.Device, u *devices.User, depth int) *result {
+	r := &result{}
+	switch {
+	case depth > 10:
+		r.unknown("required levels nest too deeply")
+		return r
+	case l.Basic == nil:
+		r.unknown("custom level, not evaluated")
+		return r
+	}
+	results := []*result{}
+	for _, c := range l.Basic.Conditions {
+		results = append(results, e.condition(c, d, u, depth))
+	}
+	if l.Basic.CombiningFunction == "OR" {
+		// One passing condition is enough; otherwise the best outcome is
+		// reported with every condition's reasons.
+		best := &result{outcome: unsatisfied}
+		for _, c := range results {
+			if c.outcome < best.outcome {
+				best.outcome = c.outcome
+			}
+		}
+		if best.outcome == satisfied {
+			return best
+		}
+		for _, c := range results {
+			best.reasons = append(best.reasons, c.reasons...)
+		}
+		return best
+	}
+	for _, c := range results {
+		if c.outcome > r.outcome {
+			r.outcome = c.outcome
+		}
+		r.reasons = append(r.reasons, c.reasons...)
+	}
+	return r
+}
+
+func (e *evaluator) condition(c *condition, d *devices.Device, u *devices.User, depth int) *result {
+	r := &result{}
+	if len(c.IPSubnetworks) > 0 {
+		r.unknown("depends on the request's IP address")
+	}
+	if len(c.Regions) > 0 {
+		r.unknown("depends on the request's region")
+	}
+	if len(c.Members) > 0 {
+		found := false
+		for _, m := range c.Members {
+			if strings.EqualFold(strings.TrimPrefix(m, "user:"), u.UserEmail) {
+				found = true
+			}
+		}
+		if !found {
+			r.fail("user is not a listed member")
+		}
+	}
+	for _, name := range c.RequiredAccessLevels {
+		required, ok := e.levels[name]
+		if !ok {
+			r.unknown("requires unknown level " + name)
+			continue
+		}
+		sub := e.level(required, d, u, depth+1)
+		switch sub.outcome {
+		case unsatisfied:
+			r.fail("fails required level " + required.Title)
+		case undetermined:
+			r.unknown("required level " + required.Title + " is undetermined")
+		}
+	}
+	if c.DevicePolicy != nil {
+		checkDevice(r, c.DevicePolicy, d, u)
+	}
+	if !c.Negate {
+		return r
+	}
+	switch r.outcome {
+	case satisfied:
+		return &result{outcome: unsatisfied, reasons: []string{"matches a negated condition"}}
+	case unsatisfied:
+		return &result{}
+	}
+	return r
+}
+
+// encryptionStatus maps a device's encryption state to the names device
+// policies use.
+var encryptionStatus = map[string]string{
+	"ENCRYPTED":              "ENCRYPTED",
+	"NOT_ENCRYPTED":          "UNENCRYPTED",
+	"ENCRYPTION_UNSUPPORTED": "ENCRYPTION_UNSUPPORTED",
+}
+
+// osType maps a device type to the names device policies use.
+var osType = map[string]string{
+	"MAC_OS":    "DESKTOP_MAC",
+	"WINDOWS":   "DESKTOP_WINDOWS",
+	"LINUX":     "DESKTOP_LINUX",
+	"CHROME_OS": "DESKTOP_CHROME_OS",
+	"ANDROID":   "ANDROID",
+	"IOS":       "IOS",
+}
+
+func checkDevice(r *result, p *devicePolicy, d *devices.Device, u *devices.User) {
+	if p.RequireScreenlock {
+		switch u.PasswordState {
+		case "PASSWORD_SET":
+		case "PASSWORD_NOT_SET":
+			r.fail("no screen lock")
+		default:
+			r.unknown("screen lock not reported")
+		}
+	}
+	if len(p.AllowedEncryptionStatuses) > 0 {
+		status, ok := encryptionStatus[d.EncryptionState]
+		switch {
+		case !ok:
+			r.unknown("encryption not reported")
+		case !contains(p.AllowedEncryptionStatuses, status):
+			r.fail("encryption is " + status)
+		}
+	}
+	if len(p.OSConstraints) > 0 {
+		t := osType[d.DeviceType]
+		matched := false
+		for _, oc := range p.OSConstraints {
+			if oc.OSType != t {
+				continue
+			}
+			if oc.MinimumVersion == "" || versionAtLeast(d.OSVersion, oc.MinimumVersion) {
+				matched = true
+			}
+		}
+		if !matched {
+			r.fail("OS " + strings.TrimSpace(d.DeviceType+" "+d.OSVersion) + " not allowed")
+		}
+	}
+	if p.RequireCorpOwned && d.OwnerType != "COMPANY" {
+		r.fail("not company owned")
+	}
+	if p.RequireAdminApproval && u.ManagementState != "APPROVED" {
+		r.fail("not approved by an admin")
+	}
+	if len(p.AllowedDeviceManagementLevels) > 0 {
+		r.unknown("management level not reported")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// versionAtLeast reports whether the first dotted version number in have
+// (OS versions read like "Windows 10.0.19045") is at least want.
+func versionAtLeast(have, want string) bool {
+	h, w := versionParts(have), versionParts(want)
+	for i := range w {
+		if i >= len(h) {
+			return false
+		}
+		if h[i] != w[i] {
+			return h[i] > w[i]
+		}
+	}
+	return true
+}
+
+func versionParts(s string) []int {
+	for _, word := range strings.Fields(s) {
+		parts := []int{}
+		for _, p := range strings.Split(word, ".") {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				parts = nil
+				break
+			}
+			parts = append(parts, n)
+		}
+		if len(parts) > 0 {
+			return parts
+		}
+	}
+	return nil
+}
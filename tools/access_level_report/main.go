@@ -0,0 +1,123 @@
+// Package accesslevelreport is the access_level_report tool: which users
+// and devices satisfy each context-aware access level.
+package accesslevelreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/devices"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of access_level_report, which Run parses.
+var commandLine = flag.NewFlagSet("access_level_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	organizationFlag      = commandLine.String("organization", "", "The numeric Google Cloud organization ID whose access levels are checked. The credentials' own service account needs accesscontextmanager.accessLevels.list on it.")
+	levelsFlag            = commandLine.String("levels", "", "Comma separated access level titles or names to check. Defaults to all of them.")
+	outputFile            = commandLine.String("output-file", "access_levels.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "access_level_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs access_level_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("access_level_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "organization")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	// Access Context Manager is called as the service account itself,
+	// the Devices API as the impersonated admin.
+	gcpClient, err := auth.ClientFromFile(ctx, *credentialsFileFlag, "", auth.CloudPlatformScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, devices.ReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching access levels")
+	levels, err := listLevels(ctx, gcpClient, *organizationFlag)
+	if err != nil {
+		log.Fatalf("Error fetching access levels: %v", err)
+	}
+	e := &evaluator{levels: map[string]*accessLevel{}}
+	for _, l := range levels {
+		e.levels[l.Name] = l
+	}
+	if wanted := listfile.Split(*levelsFlag); len(wanted) > 0 {
+		selected := []*accessLevel{}
+		for _, w := range wanted {
+			found := false
+			for _, l := range levels {
+				if l.Title == w || l.Name == w || strings.HasSuffix(l.Name, "/"+w) {
+					selected = append(selected, l)
+					found = true
+				}
+			}
+			if !found {
+				log.Fatalf("No access level %s", w)
+			}
+		}
+		levels = selected
+	}
+
+	log.Println("Fetching devices")
+	all, err := devices.List(ctx, client)
+	if err != nil {
+		log.Fatalf("Error fetching devices: %v", err)
+	}
+	users, err := devices.ListUsers(ctx, client)
+	if err != nil {
+		log.Fatalf("Error fetching device users: %v", err)
+	}
+
+	rows := [][]string{
+		{"level", "level_name", "email", "device_id", "device_type", "result", "reasons"},
+	}
+	counts := map[string]map[outcome]int{}
+	for _, l := range levels {
+		counts[l.Title] = map[outcome]int{}
+		for _, d := range all {
+			// Access is granted per user and device, so devices nobody is
+			// signed in to can't satisfy anything.
+			for _, u := range users[d.Name] {
+				r := e.level(l, d, u, 0)
+				counts[l.Title][r.outcome]++
+				rows = append(rows, []string{l.Title, l.Name, u.UserEmail, d.ID(), d.DeviceType, r.outcome.String(), strings.Join(r.reasons, ";")})
+			}
+		}
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	for _, l := range levels {
+		c := counts[l.Title]
+		log.Printf("%s: %d satisfied, %d not satisfied, %d undetermined", l.Title, c[satisfied], c[unsatisfied], c[undetermined])
+	}
+	log.Println("Complete")
+	return nil
+}
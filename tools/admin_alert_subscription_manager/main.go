@@ -0,0 +1,160 @@
+// Package adminalertsubscriptionmanager is the
+// admin_alert_subscription_manager tool: lists Alert Center's Pub/Sub
+// notifications and sets them from a YAML file, to repeat one tenant's
+// setup in another.
+package adminalertsubscriptionmanager
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of admin_alert_subscription_manager, which
+// Run parses.
+var commandLine = flag.NewFlagSet("admin_alert_subscription_manager", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	customerIDFlag        = commandLine.String("customer-id", "", "The customer whose settings to manage. Defaults to the impersonated admin's.")
+	settingsFileFlag      = commandLine.String("settings-file", "", "A YAML file of the Pub/Sub notifications the tenant should have. Without it the current settings are only listed.")
+	exportFileFlag        = commandLine.String("export-file", "", "Write the current settings to this YAML file, for use as another tenant's -settings-file.")
+	keepMissingFlag       = commandLine.Bool("keep-missing", false, "Don't remove notifications that aren't in -settings-file.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the change without making it.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "admin_alert_subscription_manager")
+	outputFile            = commandLine.String("output-file", "alert_subscriptions.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "admin_alert_subscription_manager", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs admin_alert_subscription_manager with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("admin_alert_subscription_manager", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	var desired *Settings
+	if *settingsFileFlag != "" {
+		var err error
+		desired, err = loadSettings(*settingsFileFlag)
+		check.Check(err)
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, alertsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching Alert Center settings")
+	current, err := getSettings(ctx, client, *customerIDFlag)
+	if err != nil {
+		log.Fatalf("Error fetching settings: %v", err)
+	}
+	if *exportFileFlag != "" {
+		if err := writeSettings(*exportFileFlag, current); err != nil {
+			log.Fatalf("Error writing settings: %v", err)
+		}
+		log.Printf("Wrote %d notifications to %s", len(current.Notifications), *exportFileFlag)
+	}
+
+	have := current.topics()
+	changes := map[string]string{}
+	if desired != nil {
+		want := desired.topics()
+		// The API replaces the settings whole, so notifications kept with
+		// -keep-missing are sent back unchanged.
+		next := &Settings{Notifications: []*Notification{}}
+		for _, n := range desired.Notifications {
+			t := n.CloudPubsubTopic
+			switch old, ok := have[t.TopicName]; {
+			case !ok:
+				changes[t.TopicName] = "add"
+			case old.PayloadFormat != t.PayloadFormat:
+				changes[t.TopicName] = "update"
+			}
+			next.Notifications = append(next.Notifications, n)
+		}
+		for _, n := range current.Notifications {
+			t := n.CloudPubsubTopic
+			if t == nil || want[t.TopicName] != nil {
+				continue
+			}
+			if *keepMissingFlag {
+				next.Notifications = append(next.Notifications, n)
+				continue
+			}
+			changes[t.TopicName] = "remove"
+		}
+		log.Printf("%d notifications to change", len(changes))
+		if len(changes) > 0 {
+			if _, err := reconcile.Apply([]*reconcile.Change{updateChange(ctx, client, *customerIDFlag, next, changes)},
+				reconcile.Options{DryRun: *dryRunFlag, Limits: limits, Existing: len(have), Plan: plan}); err != nil {
+				log.Fatal(err)
+			}
+		}
+		for _, n := range next.Notifications {
+			have[n.CloudPubsubTopic.TopicName] = n.CloudPubsubTopic
+		}
+	}
+
+	topics := []string{}
+	for topic := range have {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	rows := [][]string{{"topic", "payload_format", "change"}}
+	for _, topic := range topics {
+		rows = append(rows, []string{topic, have[topic].PayloadFormat, changes[topic]})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// updateChange replaces the customer's settings with next, described by
+// the topics changing.
+func updateChange(ctx context.Context, client *http.Client, customerID string, next *Settings, changes map[string]string) *reconcile.Change {
+	target := "Alert Center settings"
+	if customerID != "" {
+		target += " of " + customerID
+	}
+	subjects := []string{}
+	for topic, change := range changes {
+		subjects = append(subjects, change+" "+topic)
+	}
+	sort.Strings(subjects)
+	return &reconcile.Change{
+		Action:  "update",
+		Target:  target,
+		Subject: "(" + strings.Join(subjects, ", ") + ")",
+		Apply: func() error {
+			return updateSettings(ctx, client, customerID, next)
+		},
+	}
+}
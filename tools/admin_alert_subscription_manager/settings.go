@@ -0,0 +1,128 @@
+package adminalertsubscriptionmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const alertsScope = "https://www.googleapis.com/auth/apps.alerts"
+
+// payloadFormats are the encodings Alert Center can publish alerts in.
+var payloadFormats = []string{"JSON"}
+
+// Settings are a customer's Alert Center settings. The API only exposes
+// Pub/Sub notifications: the email recipients of each alert are set on its
+// rule in the Admin console.
+type Settings struct {
+	Notifications []*Notification `json:"notifications" yaml:"notifications"`
+}
+
+// Notification publishes every new alert to a Cloud Pub/Sub topic.
+type Notification struct {
+	CloudPubsubTopic *PubsubTopic `json:"cloudPubsubTopic,omitempty" yaml:"cloud_pubsub_topic"`
+}
+
+// PubsubTopic is "projects/PROJECT/topics/TOPIC" and the format alerts are
+// published in.
+type PubsubTopic struct {
+	TopicName     string `json:"topicName" yaml:"topic"`
+	PayloadFormat string `json:"payloadFormat,omitempty" yaml:"payload_format"`
+}
+
+// topics returns the settings' notifications keyed by topic name.
+func (s *Settings) topics() map[string]*PubsubTopic {
+	topics := map[string]*PubsubTopic{}
+	for _, n := range s.Notifications {
+		if n.CloudPubsubTopic != nil {
+			topics[n.CloudPubsubTopic.TopicName] = n.CloudPubsubTopic
+		}
+	}
+	return topics
+}
+
+// loadSettings reads the YAML file of the settings a tenant should have,
+// e.g.
+//
+//	notifications:
+//	  - cloud_pubsub_topic:
+//	      topic: projects/security-tools/topics/workspace-alerts
+//	      payload_format: JSON
+func loadSettings(path string) (*Settings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Settings{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	seen := map[string]bool{}
+	for i, n := range s.Notifications {
+		t := n.CloudPubsubTopic
+		if t == nil || t.TopicName == "" {
+			return nil, fmt.Errorf("%s: notification %d needs a cloud_pubsub_topic with a topic", path, i+1)
+		}
+		parts := strings.Split(t.TopicName, "/")
+		if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+			return nil, fmt.Errorf("%s: notification %d: %q is not projects/PROJECT/topics/TOPIC", path, i+1, t.TopicName)
+		}
+		if t.PayloadFormat == "" {
+			t.PayloadFormat = payloadFormats[0]
+		}
+		if !contains(payloadFormats, t.PayloadFormat) {
+			return nil, fmt.Errorf("%s: notification %d: unknown payload_format %q: use %s", path, i+1,
+				t.PayloadFormat, strings.Join(payloadFormats, ", "))
+		}
+		if seen[t.TopicName] {
+			return nil, fmt.Errorf("%s: topic %s is listed twice", path, t.TopicName)
+		}
+		seen[t.TopicName] = true
+	}
+	return s, nil
+}
+
+// writeSettings writes s as a file loadSettings reads, to copy one
+// tenant's setup to another.
+func writeSettings(path string, s *Settings) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func settingsURL(customerID string) string {
+	return rest.URL("https://alertcenter.googleapis.com/v1beta1/", "settings", url.Values{"customerId": {customerID}})
+}
+
+// getSettings returns the customer's settings. An empty customerID is the
+// impersonated admin's own customer.
+func getSettings(ctx context.Context, client *http.Client, customerID string) (*Settings, error) {
+	s := &Settings{}
+	if err := rest.Get(ctx, client, settingsURL(customerID), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// updateSettings replaces the customer's settings with s.
+func updateSettings(ctx context.Context, client *http.Client, customerID string, s *Settings) error {
+	return rest.Do(ctx, client, "PATCH", settingsURL(customerID), s, nil)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package adminconsoletakeoverprep
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/apierr"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	calendarResourceScope = "https://www.googleapis.com/auth/admin.directory.resource.calendar.readonly"
+	calendarACLScope      = "https://www.googleapis.com/auth/calendar.acls.readonly"
+	driveMetadataScope    = "https://www.googleapis.com/auth/drive.metadata.readonly"
+	gmailSettingsScope    = "https://www.googleapis.com/auth/gmail.settings.basic"
+)
+
+// adminRoles lists the admin roles assigned to email and their scope.
+func adminRoles(service *admin.Service, email string) ([]*item, error) {
+	names := map[int64]*admin.Role{}
+	pageToken := ""
+	for {
+		req := service.Roles.List("my_customer")
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := req.Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range r.Items {
+			names[role.RoleId] = role
+		}
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+
+	items := []*item{}
+	pageToken = ""
+	for {
+		req := service.RoleAssignments.List("my_customer").UserKey(email)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := req.Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range r.Items {
+			name := fmt.Sprint(a.RoleId)
+			handover := "Assign the role to the successor"
+			if role, ok := names[a.RoleId]; ok {
+				name = role.RoleName
+				if role.IsSuperAdminRole {
+					handover = "Make sure at least two other super admins exist before removing"
+				}
+			}
+			scope := a.ScopeType
+			if a.OrgUnitId != "" {
+				scope += " " + a.OrgUnitId
+			}
+			items = append(items, &item{category: "admin_role", name: name, detail: scope, handover: handover})
+		}
+		if r.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ownedGroups lists the groups email directly owns or manages.
+func ownedGroups(service *admin.Service, email string) ([]*item, error) {
+	items := []*item{}
+	pageToken := ""
+	for {
+		req := service.Groups.List().UserKey(email)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := req.Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range r.Groups {
+			m, err := service.Members.Get(g.Id, email).Do()
+			if err != nil {
+				return nil, fmt.Errorf("fetching role in %s: %v", g.Email, err)
+			}
+			if m.Role == "OWNER" || m.Role == "MANAGER" {
+				items = append(items, &item{
+					category: "group_" + strings.ToLower(m.Role),
+					name:     g.Email,
+					detail:   fmt.Sprintf("%d direct members", g.DirectMembersCount),
+					handover: "Add a new " + strings.ToLower(m.Role),
+				})
+			}
+		}
+		if r.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// mailDelegates lists who can read the admin's mailbox, via the Gmail API.
+func mailDelegates(ctx context.Context, client *http.Client) ([]*item, error) {
+	r := &struct {
+		Delegates []struct {
+			DelegateEmail      string `json:"delegateEmail"`
+			VerificationStatus string `json:"verificationStatus"`
+		} `json:"delegates"`
+	}{}
+	if err := rest.Get(ctx, client, "https://gmail.googleapis.com/gmail/v1/users/me/settings/delegates", r); err != nil {
+		return nil, err
+	}
+	items := []*item{}
+	for _, d := range r.Delegates {
+		items = append(items, &item{
+			category: "mail_delegate",
+			name:     d.DelegateEmail,
+			detail:   d.VerificationStatus,
+			handover: "Decide whether the delegate keeps access after departure",
+		})
+	}
+	return items, nil
+}
+
+// calendarResources lists the resource calendars (rooms, equipment) the
+// departing admin owns. Resources are listed with the admin client; each
+// ACL is read as the departing admin, which only succeeds where they have
+// owner access.
+func calendarResources(ctx context.Context, client, departingClient *http.Client) ([]*item, error) {
+	items := []*item{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Items []struct {
+				ResourceName  string `json:"resourceName"`
+				ResourceEmail string `json:"resourceEmail"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://admin.googleapis.com/admin/directory/v1/", "customer/my_customer/resources/calendars",
+			url.Values{"pageToken": {pageToken}})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, res := range r.Items {
+			owner, err := ownsCalendar(ctx, departingClient, res.ResourceEmail)
+			if err != nil {
+				return nil, fmt.Errorf("reading ACL of %s: %v", res.ResourceEmail, err)
+			}
+			if owner {
+				items = append(items, &item{
+					category: "calendar_resource",
+					name:     res.ResourceEmail,
+					detail:   res.ResourceName,
+					handover: "Grant the successor owner access to the resource calendar",
+				})
+			}
+		}
+		if r.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// ownsCalendar reports whether the client's user can read the calendar's
+// ACL, which the Calendar API only allows owners to do.
+func ownsCalendar(ctx context.Context, client *http.Client, calendar string) (bool, error) {
+	u := rest.URL("https://www.googleapis.com/calendar/v3/calendars/", url.QueryEscape(calendar)+"/acl",
+		url.Values{"maxResults": {"1"}})
+	err := rest.Get(ctx, client, u, &struct{}{})
+	if errors.Is(err, apierr.ErrForbidden) || errors.Is(err, apierr.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ownedFiles lists the Drive files the client's user owns. Ownership has to
+// be transferred before the account is deleted or the files go with it.
+func ownedFiles(ctx context.Context, client *http.Client) ([]*item, error) {
+	items := []*item{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Files []struct {
+				ID       string `json:"id"`
+				Name     string `json:"name"`
+				MimeType string `json:"mimeType"`
+				Shared   bool   `json:"shared"`
+			} `json:"files"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/drive/v3/", "files", url.Values{
+			"q":         {"'me' in owners and trashed = false"},
+			"fields":    {"files(id,name,mimeType,shared),nextPageToken"},
+			"pageSize":  {"1000"},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, f := range r.Files {
+			detail := f.MimeType
+			if f.Shared {
+				detail += ", shared"
+			}
+			items = append(items, &item{
+				category: "drive_file",
+				name:     f.Name + " (" + f.ID + ")",
+				detail:   detail,
+				handover: "Transfer ownership (Admin console data transfer)",
+			})
+		}
+		if r.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// configReferences finds lines mentioning email in the files under paths,
+// which is where automation impersonating the admin is configured (e.g.
+// -impersonated-email in crontabs and deploy scripts).
+func configReferences(paths []string, email string) ([]*item, error) {
+	items := []*item{}
+	for _, root := range paths {
+		root = strings.TrimSpace(root)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != root && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			found, err := grepFile(path, email)
+			if err != nil {
+				return err
+			}
+			for _, line := range found {
+				items = append(items, &item{
+					category: "config_reference",
+					name:     path,
+					detail:   line,
+					handover: "Switch the automation to a service admin account",
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// grepFile returns "line N" for each line of path containing needle,
+// ignoring case.
+func grepFile(path, needle string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	found := []string{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.Contains(strings.ToLower(scanner.Text()), needle) {
+			found = append(found, fmt.Sprintf("line %d", n))
+		}
+	}
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, err
+	}
+	return found, nil
+}
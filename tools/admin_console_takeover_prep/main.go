@@ -0,0 +1,123 @@
+// Package adminconsoletakeoverprep is the admin_console_takeover_prep tool:
+// handover checklist for a departing super admin.
+package adminconsoletakeoverprep
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of admin_console_takeover_prep, which Run
+// parses.
+var commandLine = flag.NewFlagSet("admin_console_takeover_prep", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = commandLine.String("impersonated-email", "", "The admin user email to impersonate for access. Use someone other than the departing admin.")
+	adminFlag             = commandLine.String("admin", "", "The departing super admin whose responsibilities are being handed over.")
+	configPathsFlag       = commandLine.String("config-paths", "", "Comma separated files or directories (crontabs, scripts, tool configs) to search for the admin's address.")
+	outputFile            = commandLine.String("output-file", "takeover_checklist.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "admin_console_takeover_prep", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// item is one thing bound to the departing admin that needs a new owner.
+type item struct {
+	category string
+	name     string
+	detail   string
+	handover string
+}
+
+// check gathers one category of items. Checks are independent, so one
+// failing (an API not enabled, a missing scope) doesn't stop the rest.
+type check struct {
+	name string
+	run  func() ([]*item, error)
+}
+
+// Run runs admin_console_takeover_prep with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	flagCheck := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("admin_console_takeover_prep", version.Git)
+		os.Exit(0)
+	}
+
+	flagCheck.Required("credentials-file", "impersonated-email", "admin")
+	flagCheck.Check(outputOptions.CheckFormat())
+	flagCheck.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	departing := strings.ToLower(*adminFlag)
+
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryRolemanagementReadonlyScope, calendarResourceScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Mail delegates, files and calendar ACLs are read as the departing
+	// admin, since only they can see all of their own.
+	departingClient, err := auth.ClientFromFile(ctx, *credentialsFileFlag, departing,
+		gmailSettingsScope, driveMetadataScope, calendarACLScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checks := []*check{
+		{"admin roles", func() ([]*item, error) { return adminRoles(service, departing) }},
+		{"owned groups", func() ([]*item, error) { return ownedGroups(service, departing) }},
+		{"mail delegates", func() ([]*item, error) { return mailDelegates(ctx, departingClient) }},
+		{"calendar resources", func() ([]*item, error) { return calendarResources(ctx, client, departingClient) }},
+		{"owned files", func() ([]*item, error) { return ownedFiles(ctx, departingClient) }},
+	}
+	if *configPathsFlag != "" {
+		checks = append(checks, &check{"config references", func() ([]*item, error) {
+			return configReferences(strings.Split(*configPathsFlag, ","), departing)
+		}})
+	}
+
+	rows := [][]string{
+		{"category", "item", "detail", "handover"},
+	}
+	failed := 0
+	for _, c := range checks {
+		log.Printf("Checking %s", c.name)
+		items, err := c.run()
+		if err != nil {
+			log.Printf("Error checking %s: %v", c.name, err)
+			rows = append(rows, []string{"error", c.name, err.Error(), "Check by hand"})
+			failed++
+		}
+		for _, it := range items {
+			rows = append(rows, []string{it.category, it.name, it.detail, it.handover})
+		}
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but %d checks failed; see the error rows", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
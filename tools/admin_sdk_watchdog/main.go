@@ -0,0 +1,215 @@
+// Package adminsdkwatchdog is the admin_sdk_watchdog tool: samples
+// Directory API latency and errors over a window and alerts when Google
+// looks throttled or degraded.
+package adminsdkwatchdog
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of admin_sdk_watchdog, which Run parses.
+var commandLine = flag.NewFlagSet("admin_sdk_watchdog", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain the users and groups probes list.")
+	windowFlag            = commandLine.Duration("window", 5*time.Minute, "How long to sample for.")
+	intervalFlag          = commandLine.Duration("interval", 15*time.Second, "The time between rounds of calls.")
+	timeoutFlag           = commandLine.Duration("timeout", 30*time.Second, "How long one call may take before it counts as timed out.")
+	maxThrottledFlag      = commandLine.Float64("max-throttled-rate", 0.05, "Alert when more than this fraction of calls hit a rate limit or quota.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.1, "Alert when more than this fraction of calls fail on Google's side (5xx, backend errors, timeouts).")
+	maxLatencyFlag        = commandLine.Duration("max-p95-latency", 3*time.Second, "Alert when the 95th percentile latency of any probe is above this.")
+	webhookURLFlag        = commandLine.String("webhook-url", "", "If set, POST a JSON alert here when the API looks throttled or degraded.")
+	outputFile            = commandLine.String("output-file", "admin_sdk_samples.csv", "The csv file of every call made to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "admin_sdk_watchdog", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// alertExitCode is the exit status when an alert is raised, so a scheduler
+// can tell an unhealthy API (3) from the watchdog failing to run (1).
+const alertExitCode = 3
+
+// Run runs admin_sdk_watchdog with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("admin_sdk_watchdog", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	if *intervalFlag <= 0 || *windowFlag < *intervalFlag {
+		check.Problemf("-interval must be positive and no longer than -window")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	// Retrying would hide the very rate limits and server errors being
+	// measured, so every call is made once.
+	retry.Default.MaxRetries = 0
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope,
+		admin.AdminDirectoryOrgunitReadonlyScope, admin.AdminDirectoryDomainReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writer, err := outputOptions.Create(*outputFile, []string{"time", "probe", "latency_ms", "result", "error"})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	log.Printf("Sampling the Admin SDK every %s for %s", *intervalFlag, *windowFlag)
+	samples := []*sample{}
+	ticker := time.NewTicker(*intervalFlag)
+	defer ticker.Stop()
+	deadline := time.Now().Add(*windowFlag)
+sampling:
+	for {
+		for _, p := range probes {
+			s := p.run(ctx, client, *domainFlag, *timeoutFlag)
+			if shutdown.Interrupted(ctx) {
+				break sampling
+			}
+			samples = append(samples, s)
+			errText := ""
+			if s.err != nil {
+				errText = s.err.Error()
+			}
+			if err := writer.Write([]string{
+				s.time.UTC().Format(time.RFC3339),
+				s.probe,
+				strconv.FormatInt(int64(s.latency/time.Millisecond), 10),
+				s.result,
+				errText,
+			}); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+		if !time.Now().Add(*intervalFlag).Before(deadline) {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			break sampling
+		}
+	}
+	interrupted := shutdown.Interrupted(ctx)
+	if interrupted {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if interrupted {
+		log.Printf("Interrupted: wrote %d samples, not checked", len(samples))
+		os.Exit(shutdown.ExitCode)
+	}
+
+	all := summarize("all", samples, func(*sample) bool { return true })
+	perProbe := []*stats{}
+	for _, p := range probes {
+		name := p.name
+		st := summarize(name, samples, func(s *sample) bool { return s.probe == name })
+		perProbe = append(perProbe, st)
+		log.Printf("%s: %d calls, %s, p50 %s, p95 %s", st.name, st.calls, describeResults(st), st.p50, st.p95)
+	}
+
+	reasons := []string{}
+	if r := all.rate(resultThrottled); r > *maxThrottledFlag {
+		reasons = append(reasons, fmt.Sprintf("%.0f%% of calls were throttled", r*100))
+	}
+	if r := all.rate(resultTransient, resultTimeout); r > *maxErrorRateFlag {
+		reasons = append(reasons, fmt.Sprintf("%.0f%% of calls failed on Google's side", r*100))
+	}
+	for _, st := range perProbe {
+		if st.p95 > *maxLatencyFlag {
+			reasons = append(reasons, fmt.Sprintf("%s p95 latency is %s", st.name, st.p95))
+		}
+	}
+	// Access errors are a problem with our credentials or delegation, not
+	// with the API; they are reported but don't raise an alert.
+	if n := all.results[resultForbidden] + all.results[resultFailed]; n > 0 {
+		log.Printf("Warning: %d calls failed for reasons on our side (access, bad requests); see %s", n, *outputFile)
+	}
+	if len(reasons) == 0 {
+		log.Printf("Healthy: %d calls, %s", all.calls, describeResults(all))
+		log.Println("Complete")
+		return nil
+	}
+	log.Printf("Alert: the Admin SDK looks throttled or degraded: %s", strings.Join(reasons, "; "))
+	if *webhookURLFlag != "" {
+		if err := sendAlert(ctx, *webhookURLFlag, reasons, all, perProbe); err != nil {
+			log.Fatalf("Error sending alert: %v", err)
+		}
+		log.Println("Sent alert")
+	}
+	os.Exit(alertExitCode)
+	return nil
+}
+
+// describeResults lists how many calls had each result other than ok.
+func describeResults(st *stats) string {
+	parts := []string{fmt.Sprintf("%d ok", st.results[resultOK])}
+	for _, r := range []string{resultThrottled, resultTransient, resultTimeout, resultForbidden, resultFailed} {
+		if n := st.results[r]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, r))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sendAlert POSTs the alert as JSON. The text field makes it readable as
+// is by Slack and Chat incoming webhooks.
+func sendAlert(ctx context.Context, webhookURL string, reasons []string, all *stats, perProbe []*stats) error {
+	type probeStats struct {
+		Name    string         `json:"name"`
+		Calls   int            `json:"calls"`
+		Results map[string]int `json:"results"`
+		P50MS   int64          `json:"p50_ms"`
+		P95MS   int64          `json:"p95_ms"`
+	}
+	probeJSON := []probeStats{}
+	for _, st := range append(perProbe, all) {
+		probeJSON = append(probeJSON, probeStats{
+			Name:    st.name,
+			Calls:   st.calls,
+			Results: st.results,
+			P50MS:   int64(st.p50 / time.Millisecond),
+			P95MS:   int64(st.p95 / time.Millisecond),
+		})
+	}
+	body := map[string]interface{}{
+		"text":    fmt.Sprintf("Admin SDK for %s looks throttled or degraded: %s", *domainFlag, strings.Join(reasons, "; ")),
+		"domain":  *domainFlag,
+		"window":  windowFlag.String(),
+		"reasons": reasons,
+		"probes":  probeJSON,
+	}
+	// The webhook isn't a Google API, so it gets a plain client.
+	return rest.Do(ctx, http.DefaultClient, "POST", webhookURL, body, nil)
+}
@@ -0,0 +1,145 @@
+package adminsdkwatchdog
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/apierr"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const directoryBasePath = "https://www.googleapis.com/admin/directory/v1/"
+
+// probe is one cheap read call the watchdog times.
+type probe struct {
+	name string
+	path string
+	// domain adds the -domain parameter.
+	domain bool
+}
+
+// probes are the calls made each round: the smallest useful read from
+// each of the Directory API resources the tools use most.
+var probes = []*probe{
+	{name: "users.list", path: "users", domain: true},
+	{name: "groups.list", path: "groups", domain: true},
+	{name: "orgunits.list", path: "customer/my_customer/orgunits"},
+	{name: "domains.list", path: "customer/my_customer/domains"},
+}
+
+// The results a call can have. throttled and transient are Google's side;
+// forbidden and failed are usually ours.
+const (
+	resultOK        = "ok"
+	resultThrottled = "throttled"
+	resultTransient = "transient"
+	resultTimeout   = "timeout"
+	resultForbidden = "forbidden"
+	resultFailed    = "failed"
+)
+
+// sample is the outcome of one call.
+type sample struct {
+	time    time.Time
+	probe   string
+	latency time.Duration
+	result  string
+	err     error
+}
+
+// run makes the call once, giving up after timeout.
+func (p *probe) run(ctx context.Context, client *http.Client, domain string, timeout time.Duration) *sample {
+	params := url.Values{"maxResults": {"1"}}
+	if p.domain {
+		params.Set("domain", domain)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	s := &sample{time: time.Now(), probe: p.name}
+	s.err = rest.Get(ctx, client, rest.URL(directoryBasePath, p.path, params), &struct{}{})
+	s.latency = time.Since(s.time)
+	s.result = classify(ctx, s.err)
+	return s
+}
+
+func classify(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return resultOK
+	case errors.Is(err, apierr.ErrQuotaExceeded):
+		return resultThrottled
+	case errors.Is(err, apierr.ErrTransient):
+		return resultTransient
+	case errors.Is(err, apierr.ErrForbidden):
+		return resultForbidden
+	case ctx.Err() == context.DeadlineExceeded:
+		return resultTimeout
+	}
+	return resultFailed
+}
+
+// stats summarize a probe's samples, or all of them.
+type stats struct {
+	name    string
+	calls   int
+	results map[string]int
+	p50     time.Duration
+	p95     time.Duration
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+
+// summarize computes the stats of the samples for which keep is true.
+// Latencies are of the calls that got an answer; a timeout is counted
+// among the results instead.
+func summarize(name string, samples []*sample, keep func(*sample) bool) *stats {
+	st := &stats{name: name, results: map[string]int{}}
+	latencies := byDuration{}
+	for _, s := range samples {
+		if !keep(s) {
+			continue
+		}
+		st.calls++
+		st.results[s.result]++
+		if s.result != resultTimeout {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	sort.Sort(latencies)
+	st.p50, st.p95 = percentile(latencies, 50), percentile(latencies, 95)
+	return st
+}
+
+// rate returns the fraction of calls with any of the results.
+func (st *stats) rate(results ...string) float64 {
+	if st.calls == 0 {
+		return 0
+	}
+	n := 0
+	for _, r := range results {
+		n += st.results[r]
+	}
+	return float64(n) / float64(st.calls)
+}
+
+// percentile returns the pth percentile of sorted latencies, or 0 if there
+// are none.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return sorted[i]
+}
@@ -0,0 +1,190 @@
+// Package audit2svexceptions is the audit_2sv_exceptions tool: users not
+// enrolled in 2-Step Verification, with why each is exempt.
+package audit2svexceptions
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of audit_2sv_exceptions, which Run parses.
+var commandLine = flag.NewFlagSet("audit_2sv_exceptions", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	includeSuspendedFlag  = commandLine.Bool("include-suspended", false, "Include suspended users.")
+	outputFile            = commandLine.String("output-file", "2sv_exceptions.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "audit_2sv_exceptions", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// groupPolicy is a group-based enforcement policy with its group's address.
+type groupPolicy struct {
+	*enforcement
+	email string
+}
+
+// Run runs audit_2sv_exceptions with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("audit_2sv_exceptions", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryOrgunitReadonlyScope,
+		admin.AdminDirectoryGroupReadonlyScope, policiesScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching 2SV enforcement policies")
+	policies, err := fetchEnforcement(ctx, client)
+	if err != nil {
+		log.Fatalf("Error fetching policies: %v", err)
+	}
+	ous, err := directory.ListOrgUnits(ctx, service)
+	if err != nil {
+		log.Fatalf("Error fetching org units: %v", err)
+	}
+	ouPaths := map[string]string{}
+	for _, ou := range ous {
+		ouPaths[strings.TrimPrefix(ou.OrgUnitId, "id:")] = ou.OrgUnitPath
+		if ou.ParentOrgUnitPath == "/" {
+			ouPaths[strings.TrimPrefix(ou.ParentOrgUnitId, "id:")] = "/"
+		}
+	}
+
+	byOU := map[string]*enforcement{}
+	// memberPolicies holds the group policies that apply to each user,
+	// keyed by lowercased address.
+	memberPolicies := map[string][]*groupPolicy{}
+	for _, p := range policies {
+		if p.groupID == "" {
+			path, ok := ouPaths[p.orgUnitID]
+			if !ok {
+				log.Printf("Warning: policy for unknown OU %s, ignoring", p.orgUnitID)
+				continue
+			}
+			byOU[path] = p
+			continue
+		}
+		group, err := service.Groups.Get(p.groupID).Do()
+		if err != nil {
+			log.Fatalf("Error fetching exception group %s: %v", p.groupID, err)
+		}
+		members, err := directory.ListMembers(ctx, service, group.Id)
+		if err != nil {
+			log.Fatalf("Error fetching members of %s: %v", group.Email, err)
+		}
+		for _, m := range members {
+			if m.Type == "USER" {
+				email := strings.ToLower(m.Email)
+				memberPolicies[email] = append(memberPolicies[email], &groupPolicy{p, group.Email})
+			}
+		}
+	}
+
+	log.Println("Fetching users")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	status, err := directory.TwoStepStatus(ctx, client, *domainFlag)
+	if err != nil {
+		log.Fatalf("Error fetching 2SV status: %v", err)
+	}
+
+	now := time.Now()
+	rows := [][]string{
+		{"email", "org_unit", "enforced", "policy", "reason"},
+	}
+	for _, u := range users {
+		email := strings.ToLower(u.PrimaryEmail)
+		st := status[email]
+		if st.Enrolled || (u.Suspended && !*includeSuspendedFlag) {
+			continue
+		}
+		policy, source := applicablePolicy(u.OrgUnitPath, byOU, memberPolicies[email])
+		var reason string
+		switch {
+		case st.Enforced:
+			reason = "Enforced but not enrolled: can sign in with a password until the new user enrollment period ends"
+		case policy == nil:
+			reason = "No 2SV enforcement policy applies"
+		case policy.from.IsZero() && strings.HasPrefix(source, "group "):
+			reason = "Member of exception " + source + ", which doesn't enforce 2SV"
+		case policy.from.IsZero():
+			reason = source + " doesn't enforce 2SV"
+		case policy.from.After(now):
+			reason = "Enforcement by " + source + " starts " + policy.from.Format("2006-01-02")
+		default:
+			// Policy changes take a while to reach every user.
+			reason = "Enforced by " + source + ", but not yet applied to the user"
+		}
+		rows = append(rows, []string{u.PrimaryEmail, u.OrgUnitPath, fmt.Sprint(st.Enforced), source, reason})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d users can sign in without a second factor", len(rows)-1)
+	log.Println("Complete")
+	return nil
+}
+
+// applicablePolicy returns the policy in effect for a user and a description
+// of where it comes from. Group policies win over OU policies, the highest
+// sort order winning among groups; otherwise the nearest OU policy up the
+// tree applies.
+func applicablePolicy(ouPath string, byOU map[string]*enforcement, groups []*groupPolicy) (*enforcement, string) {
+	var best *groupPolicy
+	for _, g := range groups {
+		if best == nil || g.sortOrder > best.sortOrder {
+			best = g
+		}
+	}
+	if best != nil {
+		return best.enforcement, "group " + best.email
+	}
+	for path := ouPath; ; {
+		if p, ok := byOU[path]; ok {
+			return p, "OU " + path
+		}
+		if path == "/" || path == "" {
+			return nil, ""
+		}
+		if i := strings.LastIndex(path, "/"); i > 0 {
+			path = path[:i]
+		} else {
+			path = "/"
+		}
+	}
+}
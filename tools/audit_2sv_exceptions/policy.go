@@ -0,0 +1,76 @@
+package audit2svexceptions
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const policiesScope = "https://www.googleapis.com/auth/cloud-identity.policies.readonly"
+
+// enforcement is one 2SV enforcement policy, applied either to an OU (and
+// its children) or to the members of a group. Group policies override OU
+// policies.
+type enforcement struct {
+	orgUnitID string
+	groupID   string
+	// from is when enforcement starts; zero means 2SV is not enforced.
+	from      time.Time
+	sortOrder float64
+}
+
+// fetchEnforcement lists the 2SV enforcement policies through the Cloud
+// Identity Policy API.
+func fetchEnforcement(ctx context.Context, client *http.Client) ([]*enforcement, error) {
+	policies := []*enforcement{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Policies []struct {
+				PolicyQuery struct {
+					OrgUnit   string  `json:"orgUnit"`
+					Group     string  `json:"group"`
+					SortOrder float64 `json:"sortOrder"`
+				} `json:"policyQuery"`
+				Setting struct {
+					Type  string `json:"type"`
+					Value struct {
+						EnforcedFrom string `json:"enforcedFrom"`
+					} `json:"value"`
+				} `json:"setting"`
+			} `json:"policies"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://cloudidentity.googleapis.com/v1/", "policies", url.Values{
+			"filter":    {`setting.type.matches("security.two_step_verification_enforcement")`},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, p := range r.Policies {
+			e := &enforcement{
+				orgUnitID: strings.TrimPrefix(p.PolicyQuery.OrgUnit, "orgUnits/"),
+				groupID:   strings.TrimPrefix(p.PolicyQuery.Group, "groups/"),
+				sortOrder: p.PolicyQuery.SortOrder,
+			}
+			if p.Setting.Value.EnforcedFrom != "" {
+				from, err := time.Parse(time.RFC3339Nano, p.Setting.Value.EnforcedFrom)
+				if err != nil {
+					return nil, err
+				}
+				e.from = from
+			}
+			policies = append(policies, e)
+		}
+		if r.NextPageToken == "" {
+			return policies, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
@@ -0,0 +1,184 @@
+// Package calendardelegationreport is the calendar_delegation_report tool:
+// users whose primary calendars grant writer or owner access to others.
+package calendardelegationreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of calendar_delegation_report, which Run
+// parses.
+var commandLine = flag.NewFlagSet("calendar_delegation_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	rolesFlag             = commandLine.String("roles", "writer,owner", "The comma-separated calendar ACL roles to report.")
+	outputFile            = commandLine.String("output-file", "calendar_delegation.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "calendar_delegation_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+const calendarACLScope = "https://www.googleapis.com/auth/calendar.acls.readonly"
+
+// aclRoles are the roles a calendar ACL rule can grant, least first.
+var aclRoles = []string{"none", "freeBusyReader", "reader", "writer", "owner"}
+
+// Run runs calendar_delegation_report with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("calendar_delegation_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	roles := map[string]bool{}
+	for _, r := range strings.Split(*rolesFlag, ",") {
+		r = strings.TrimSpace(r)
+		if !contains(aclRoles, r) {
+			check.Problemf("unknown role %q in -roles: use %s", r, strings.Join(aclRoles, ", "))
+		}
+		roles[r] = true
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Only a calendar's owners can read its ACL, so each user's is read as
+	// them.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, calendarACLScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Starting report generation")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	suspended := map[string]bool{}
+	for _, u := range users {
+		suspended[strings.ToLower(u.PrimaryEmail)] = u.Suspended
+	}
+
+	rows := [][]string{
+		{"email", "org_unit", "delegate", "delegate_type", "delegate_status", "role", "error"},
+	}
+	for _, u := range users {
+		// Suspended users can't be impersonated. Access they still hold
+		// to other calendars shows as a suspended delegate_status.
+		if u.Suspended {
+			continue
+		}
+		userClient, err := impersonator.Client(ctx, u.PrimaryEmail)
+		var rules []*aclRule
+		if err == nil {
+			rules, err = listACL(ctx, userClient)
+		}
+		if err != nil {
+			rows = append(rows, []string{u.PrimaryEmail, u.OrgUnitPath, "", "", "", "", err.Error()})
+			continue
+		}
+		for _, r := range rules {
+			// Every calendar lists its own user as an owner.
+			if !roles[r.Role] || strings.EqualFold(r.Scope.Value, u.PrimaryEmail) {
+				continue
+			}
+			rows = append(rows, []string{u.PrimaryEmail, u.OrgUnitPath, r.Scope.Value, r.Scope.Type,
+				delegateStatus(r, suspended), r.Role, ""})
+		}
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+type aclRule struct {
+	Role  string `json:"role"`
+	Scope struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"scope"`
+}
+
+// listACL returns the rules on the impersonated user's primary calendar.
+func listACL(ctx context.Context, client *http.Client) ([]*aclRule, error) {
+	rules := []*aclRule{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Items         []*aclRule `json:"items"`
+			NextPageToken string     `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/calendar/v3/calendars/", "primary/acl", url.Values{
+			"maxResults": {"250"},
+			"pageToken":  {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r.Items...)
+		if r.NextPageToken == "" {
+			return rules, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// delegateStatus describes the user a rule grants access to: active or
+// suspended for users of -domain, external for anyone else. Groups,
+// domains and the public have no status.
+func delegateStatus(r *aclRule, suspended map[string]bool) string {
+	if r.Scope.Type != "user" {
+		return ""
+	}
+	s, ok := suspended[strings.ToLower(r.Scope.Value)]
+	switch {
+	case !ok:
+		return "external"
+	case s:
+		return "suspended"
+	}
+	return "active"
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package chatspacesync
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	chatMembershipsScope = "https://www.googleapis.com/auth/chat.admin.memberships"
+	chatBasePath         = "https://chat.googleapis.com/v1/"
+)
+
+// membership is a user, app or group in a space. Name is the membership's
+// resource name, spaces/{space}/members/{member}; the member itself is
+// named users/{id}.
+type membership struct {
+	Name string `json:"name"`
+	// Role is ROLE_MEMBER or ROLE_MANAGER, State JOINED or INVITED.
+	Role   string `json:"role"`
+	State  string `json:"state"`
+	Member *struct {
+		Name string `json:"name"`
+		// Type is HUMAN or BOT.
+		Type string `json:"type"`
+	} `json:"member"`
+}
+
+// userID returns the directory ID of a human member, or "" for an app or
+// group.
+func (m *membership) userID() string {
+	if m.Member == nil || m.Member.Type != "HUMAN" {
+		return ""
+	}
+	return strings.TrimPrefix(m.Member.Name, "users/")
+}
+
+// spaceName accepts a space as its resource name or bare ID and returns the
+// resource name.
+func spaceName(space string) string {
+	if strings.HasPrefix(space, "spaces/") {
+		return space
+	}
+	return "spaces/" + space
+}
+
+// listMembers returns the joined and invited members of a space.
+func listMembers(ctx context.Context, client *http.Client, space string) ([]*membership, error) {
+	all := []*membership{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Memberships   []*membership `json:"memberships"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(chatBasePath, space+"/members", url.Values{
+			"useAdminAccess": {"true"},
+			"showInvited":    {"true"},
+			"pageSize":       {"1000"},
+			"pageToken":      {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Memberships...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// addMember adds a user to a space as an ordinary member. Chat takes the
+// user's address in place of their ID.
+func addMember(ctx context.Context, client *http.Client, space, email string) error {
+	m := map[string]interface{}{
+		"member": map[string]string{"name": "users/" + email, "type": "HUMAN"},
+	}
+	u := rest.URL(chatBasePath, space+"/members", url.Values{"useAdminAccess": {"true"}})
+	return rest.Do(ctx, client, "POST", u, m, nil)
+}
+
+// removeMember deletes a membership by its resource name.
+func removeMember(ctx context.Context, client *http.Client, name string) error {
+	u := rest.URL(chatBasePath, name, url.Values{"useAdminAccess": {"true"}})
+	return rest.Do(ctx, client, "DELETE", u, nil, nil)
+}
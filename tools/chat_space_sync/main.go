@@ -0,0 +1,177 @@
+// Package chatspacesync is the chat_space_sync tool: mirrors a group's
+// members into a Chat space, and optionally the space's members back into
+// the group.
+package chatspacesync
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of chat_space_sync, which Run parses.
+var commandLine = flag.NewFlagSet("chat_space_sync", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	groupFlag             = commandLine.String("group", "", "The group whose members the space should have.")
+	spaceFlag             = commandLine.String("space", "", "The Chat space to sync, as spaces/ID or just the ID.")
+	keepExtraFlag         = commandLine.Bool("keep-extra", false, "Don't remove space members who aren't in the group.")
+	twoWayFlag            = commandLine.Bool("two-way", false, "Also add space members missing from the group to it. Nobody is removed from either.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the membership changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "chat_space_sync")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs chat_space_sync with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("chat_space_sync", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "group", "space")
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	groupScope := admin.AdminDirectoryGroupMemberReadonlyScope
+	if *twoWayFlag {
+		groupScope = admin.AdminDirectoryGroupMemberScope
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, groupScope, chatMembershipsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	space := spaceName(*spaceFlag)
+
+	// Nested groups aren't expanded: the space gets the group's direct
+	// members, as matching_rules manages them.
+	log.Printf("Fetching members of %s", *groupFlag)
+	groupMembers, err := directory.ListMembers(ctx, service, *groupFlag)
+	if err != nil {
+		log.Fatalf("Error fetching members of %s: %v", *groupFlag, err)
+	}
+	inGroup := map[string]string{}
+	for _, m := range groupMembers {
+		if m.Type == "USER" {
+			inGroup[m.Id] = strings.ToLower(m.Email)
+		}
+	}
+	log.Printf("Fetching members of %s", space)
+	spaceMembers, err := listMembers(ctx, client, space)
+	if err != nil {
+		log.Fatalf("Error fetching members of %s: %v", space, err)
+	}
+	inSpace := map[string]*membership{}
+	for _, m := range spaceMembers {
+		if id := m.userID(); id != "" {
+			inSpace[id] = m
+		}
+	}
+
+	missing := []string{}
+	for id, email := range inGroup {
+		if inSpace[id] == nil {
+			missing = append(missing, email)
+		}
+	}
+	sort.Strings(missing)
+	changes := []*reconcile.Change{}
+	for _, email := range missing {
+		email := email
+		changes = append(changes, &reconcile.Change{
+			Action:  "add",
+			Target:  space,
+			Subject: email,
+			Apply:   func() error { return addMember(ctx, client, space, email) },
+		})
+	}
+	extra := []string{}
+	for id := range inSpace {
+		if _, ok := inGroup[id]; !ok && (*twoWayFlag || !*keepExtraFlag) {
+			extra = append(extra, id)
+		}
+	}
+	sort.Strings(extra)
+	for _, id := range extra {
+		m := inSpace[id]
+		// Chat only gives the member's ID; users outside the directory,
+		// such as external guests, keep it.
+		email := "users/" + id
+		u, err := service.Users.Get(id).Fields("primaryEmail").Do()
+		if err == nil {
+			email = strings.ToLower(u.PrimaryEmail)
+		}
+		switch {
+		case *twoWayFlag:
+			if err != nil {
+				log.Printf("Not adding %s to %s: not a user in the directory", email, *groupFlag)
+				continue
+			}
+			changes = append(changes, &reconcile.Change{
+				Action:  "add",
+				Target:  *groupFlag,
+				Subject: email,
+				Apply: func() error {
+					_, err := service.Members.Insert(*groupFlag, &admin.Member{Email: email, Role: "MEMBER"}).Do()
+					return err
+				},
+			})
+		case m.Role == "ROLE_MANAGER":
+			// A space's managers run it whether or not they're in the
+			// group.
+			log.Printf("Keeping %s in %s: a space manager", email, space)
+		default:
+			name := m.Name
+			changes = append(changes, &reconcile.Change{
+				Action:  "remove",
+				Target:  space,
+				Subject: email,
+				Apply:   func() error { return removeMember(ctx, client, name) },
+			})
+		}
+	}
+
+	log.Printf("%d membership changes to make", len(changes))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: len(inSpace),
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
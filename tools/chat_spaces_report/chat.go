@@ -0,0 +1,121 @@
+package chatspacesreport
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	chatSpacesScope      = "https://www.googleapis.com/auth/chat.admin.spaces.readonly"
+	chatMembershipsScope = "https://www.googleapis.com/auth/chat.admin.memberships.readonly"
+	chatBasePath         = "https://chat.googleapis.com/v1/"
+)
+
+// space is a Chat space as an admin sees it. Name is its resource name,
+// spaces/{id}.
+type space struct {
+	Name                string `json:"name"`
+	DisplayName         string `json:"displayName"`
+	CreateTime          string `json:"createTime"`
+	LastActiveTime      string `json:"lastActiveTime"`
+	ExternalUserAllowed bool   `json:"externalUserAllowed"`
+	// SpaceHistoryState is HISTORY_ON or HISTORY_OFF.
+	SpaceHistoryState string `json:"spaceHistoryState"`
+	AccessSettings    struct {
+		// AccessState is PRIVATE or DISCOVERABLE.
+		AccessState string `json:"accessState"`
+	} `json:"accessSettings"`
+}
+
+// membership is a user, app or group in a space. The API names members by
+// resource name (users/{id} or groups/{id}), not address.
+type membership struct {
+	// Role is ROLE_MEMBER or ROLE_MANAGER, State JOINED or INVITED.
+	Role       string `json:"role"`
+	State      string `json:"state"`
+	CreateTime string `json:"createTime"`
+	Member     *struct {
+		Name string `json:"name"`
+		// Type is HUMAN or BOT.
+		Type string `json:"type"`
+	} `json:"member"`
+	GroupMember *struct {
+		Name string `json:"name"`
+	} `json:"groupMember"`
+}
+
+// resource returns the member's resource name and type: HUMAN, BOT or
+// GROUP.
+func (m *membership) resource() (string, string) {
+	if m.GroupMember != nil {
+		return m.GroupMember.Name, "GROUP"
+	}
+	if m.Member != nil {
+		return m.Member.Name, m.Member.Type
+	}
+	return "", ""
+}
+
+// searchSpaces returns the organization's named spaces matching query
+// (the Chat API's admin search syntax, ANDed with the rest; "" for all).
+// Direct messages and group chats aren't spaces and are left out.
+func searchSpaces(ctx context.Context, client *http.Client, query string) ([]*space, error) {
+	q := `customer = "customers/my_customer" AND spaceType = "SPACE"`
+	if query != "" {
+		q += " AND " + query
+	}
+	all := []*space{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Spaces        []*space `json:"spaces"`
+			NextPageToken string   `json:"nextPageToken"`
+		}{}
+		u := rest.URL(chatBasePath, "spaces:search", url.Values{
+			"useAdminAccess": {"true"},
+			"query":          {q},
+			"pageSize":       {"1000"},
+			"pageToken":      {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Spaces...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// listMembers returns the joined and invited members of a space, groups
+// included.
+func listMembers(ctx context.Context, client *http.Client, spaceName string) ([]*membership, error) {
+	all := []*membership{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Memberships   []*membership `json:"memberships"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(chatBasePath, spaceName+"/members", url.Values{
+			"useAdminAccess": {"true"},
+			"showGroups":     {"true"},
+			"showInvited":    {"true"},
+			"pageSize":       {"1000"},
+			"pageToken":      {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Memberships...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
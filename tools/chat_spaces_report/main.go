@@ -0,0 +1,199 @@
+// Package chatspacesreport is the chat_spaces_report tool: every Chat space
+// with its settings and managers, and a second report of each space's
+// members.
+package chatspacesreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of chat_spaces_report, which Run parses.
+var commandLine = flag.NewFlagSet("chat_spaces_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users and groups members are looked up in, or several separated by commas.")
+	queryFlag             = commandLine.String("query", "", "Only report spaces matching this Chat admin search, e.g. 'externalUserAllowed = true'.")
+	outputFile            = commandLine.String("output-file", "chat_spaces.csv", "The csv file of spaces to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "chat_spaces_report", "csv")
+	membersFile           = commandLine.String("members-file", "chat_space_members.csv", "The csv file of every space's members to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs chat_spaces_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("chat_spaces_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope,
+		chatSpacesScope, chatMembershipsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Chat names members by ID, so the directory is read up front to put
+	// addresses to them. Anyone not found, such as an external user, is
+	// reported by resource name.
+	log.Println("Fetching users and groups")
+	addresses := map[string]string{}
+	for _, domain := range listfile.Split(*domainFlag) {
+		users, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			log.Fatalf("Error fetching users of %s: %v", domain, err)
+		}
+		for _, u := range users {
+			addresses["users/"+u.Id] = u.PrimaryEmail
+		}
+		groups, err := directory.ListGroups(ctx, service, domain)
+		if err != nil {
+			log.Fatalf("Error fetching groups of %s: %v", domain, err)
+		}
+		for _, g := range groups {
+			addresses["groups/"+g.Id] = g.Email
+		}
+	}
+
+	log.Println("Fetching spaces")
+	spaces, err := searchSpaces(ctx, client, *queryFlag)
+	if err != nil {
+		log.Fatalf("Error fetching spaces: %v", err)
+	}
+	log.Printf("%d spaces", len(spaces))
+
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"space", "display_name", "created", "last_active", "access", "history", "external_users_allowed",
+		"managers", "members", "groups", "unknown_members", "error",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	// -fields and -filter describe the space report; the members are
+	// always written whole.
+	membersOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("chat_spaces_report_members")}
+	membersWriter, err := membersOptions.Create(*membersFile, []string{
+		"space", "display_name", "member", "type", "role", "state", "joined", "known",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+
+	failed, done := 0, 0
+	for _, s := range spaces {
+		// A space whose members can't be read still gets a row, so the
+		// inventory is complete.
+		members, err := listMembers(ctx, client, s.Name)
+		if shutdown.Interrupted(ctx) {
+			break
+		}
+		done++
+		errText := ""
+		if err != nil {
+			log.Printf("Error fetching members of %s (%s): %v", s.DisplayName, s.Name, err)
+			failed++
+			errText = err.Error()
+		}
+		managers := []string{}
+		humans, groups, unknown := 0, 0, 0
+		for _, m := range members {
+			name, memberType := m.resource()
+			address, known := addresses[name]
+			if !known {
+				address = name
+				unknown++
+			}
+			switch memberType {
+			case "GROUP":
+				groups++
+			case "HUMAN":
+				humans++
+			}
+			if m.Role == "ROLE_MANAGER" {
+				managers = append(managers, address)
+			}
+			if err := membersWriter.Write([]string{
+				s.Name,
+				s.DisplayName,
+				address,
+				memberType,
+				m.Role,
+				m.State,
+				m.CreateTime,
+				strconv.FormatBool(known),
+			}); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+		membersCount, groupsCount, unknownCount := "", "", ""
+		if err == nil {
+			membersCount, groupsCount, unknownCount = strconv.Itoa(humans), strconv.Itoa(groups), strconv.Itoa(unknown)
+		}
+		if err := writer.Write([]string{
+			s.Name,
+			s.DisplayName,
+			s.CreateTime,
+			s.LastActiveTime,
+			s.AccessSettings.AccessState,
+			s.SpaceHistoryState,
+			strconv.FormatBool(s.ExternalUserAllowed),
+			strings.Join(managers, ";"),
+			membersCount,
+			groupsCount,
+			unknownCount,
+			errText,
+		}); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	interrupted := shutdown.Interrupted(ctx)
+	for _, w := range []output.RowWriter{writer, membersWriter} {
+		if interrupted {
+			if err := output.MarkIncomplete(w, "interrupted"); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if interrupted {
+		log.Printf("Interrupted: wrote partial report of %d of %d spaces", done, len(spaces))
+		os.Exit(shutdown.ExitCode)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but the members of %d spaces could not be fetched", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
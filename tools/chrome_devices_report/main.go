@@ -0,0 +1,118 @@
+// Package chromedevicesreport is the chrome_devices_report tool: inventory
+// of Chrome OS devices with their model, OS, status, OU, users and
+// enrollment.
+package chromedevicesreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of chrome_devices_report, which Run parses.
+var commandLine = flag.NewFlagSet("chrome_devices_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	orgUnitFlag           = commandLine.String("org-unit", "", "Only report devices in this OU and those below it, e.g. /Classrooms.")
+	statusFlag            = commandLine.String("status", "", "Only report devices with one of these comma separated statuses, e.g. ACTIVE,DISABLED.")
+	queryFlag             = commandLine.String("query", "", "Only report devices matching this admin console device search, e.g. 'sync:..2024-01-01'.")
+	outputFile            = commandLine.String("output-file", "chrome_devices.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "chrome_devices_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs chrome_devices_report with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("chrome_devices_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryDeviceChromeosReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching Chrome OS devices")
+	devices, err := directory.ListChromeOSDevices(ctx, service, *queryFlag)
+	if err != nil {
+		log.Fatalf("Error fetching Chrome OS devices: %v", err)
+	}
+
+	statuses := map[string]bool{}
+	for _, s := range listfile.Split(*statusFlag) {
+		statuses[strings.ToUpper(s)] = true
+	}
+	ou := strings.TrimSuffix(strings.ToLower(*orgUnitFlag), "/")
+	rows := [][]string{
+		{"device_id", "serial_number", "model", "os_version", "platform_version", "firmware_version", "status",
+			"org_unit", "annotated_user", "recent_user", "asset_id", "location", "last_sync", "last_enrollment",
+			"support_end_date", "boot_mode", "mac_address"},
+	}
+	for _, d := range devices {
+		if len(statuses) > 0 && !statuses[d.Status] {
+			continue
+		}
+		path := strings.ToLower(d.OrgUnitPath)
+		if ou != "" && path != ou && !strings.HasPrefix(path, ou+"/") {
+			continue
+		}
+		// The most recent user comes first.
+		recent := ""
+		if len(d.RecentUsers) > 0 {
+			recent = d.RecentUsers[0].Email
+		}
+		rows = append(rows, []string{
+			d.DeviceId,
+			d.SerialNumber,
+			d.Model,
+			d.OsVersion,
+			d.PlatformVersion,
+			d.FirmwareVersion,
+			d.Status,
+			d.OrgUnitPath,
+			d.AnnotatedUser,
+			recent,
+			d.AnnotatedAssetId,
+			d.AnnotatedLocation,
+			d.LastSync,
+			d.LastEnrollmentTime,
+			d.SupportEndDate,
+			d.BootMode,
+			d.MacAddress,
+		})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d of %d devices", len(rows)-1, len(devices))
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package cloudrunserver is the cloud_run_server tool: runs the read-only
+// reports over HTTP for Cloud Run, writing them to Cloud Storage.
+package cloudrunserver
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/serverless"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of cloud_run_server, which Run parses.
+var commandLine = flag.NewFlagSet("cloud_run_server", flag.ExitOnError)
+
+var versionFlag = commandLine.Bool("version", false, "Show version information.")
+
+// Run runs cloud_run_server with args, the arguments that follow its name.
+func Run(args []string) error {
+	commandLine.Parse(args)
+
+	if *versionFlag {
+		fmt.Println("cloud_run_server", version.Git)
+		os.Exit(0)
+	}
+
+	// Cloud Run tells the container which port to listen on.
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	config, err := serverless.ConfigFromEnv(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/", config)
+	log.Printf("Listening on :%s", port)
+	return http.ListenAndServe(":"+port, nil)
+}
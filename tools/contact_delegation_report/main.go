@@ -0,0 +1,166 @@
+// Package contactdelegationreport is the contact_delegation_report tool:
+// per-user Other contacts counts and contact delegates.
+package contactdelegationreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of contact_delegation_report, which Run parses.
+var commandLine = flag.NewFlagSet("contact_delegation_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	outputFile            = commandLine.String("output-file", "contact_delegation.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "contact_delegation_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+const (
+	otherContactsScope     = "https://www.googleapis.com/auth/contacts.other.readonly"
+	contactDelegationScope = "https://www.googleapis.com/auth/admin.contact.delegation.readonly"
+)
+
+// Run runs contact_delegation_report with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("contact_delegation_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, contactDelegationScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, otherContactsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Starting report generation")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+
+	rows := [][]string{
+		{"email", "org_unit", "other_contacts", "delegates", "error"},
+	}
+	for _, u := range users {
+		if u.Suspended {
+			continue
+		}
+		// Either half can fail for a user (no mailbox, API disabled for
+		// their OU); report what is accessible and note the rest.
+		errs := []string{}
+		delegates, err := listDelegates(ctx, client, u.PrimaryEmail)
+		if err != nil {
+			errs = append(errs, "delegates: "+err.Error())
+		}
+		count := ""
+		userClient, err := impersonator.Client(ctx, u.PrimaryEmail)
+		if err == nil {
+			var n int
+			n, err = countOtherContacts(ctx, userClient)
+			count = strconv.Itoa(n)
+		}
+		if err != nil {
+			errs = append(errs, "other contacts: "+err.Error())
+			count = ""
+		}
+		rows = append(rows, []string{u.PrimaryEmail, u.OrgUnitPath, count, strings.Join(delegates, ";"), strings.Join(errs, "; ")})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// listDelegates returns the addresses the user has delegated their contacts
+// to, via the Contact Delegation API.
+func listDelegates(ctx context.Context, client *http.Client, email string) ([]string, error) {
+	delegates := []string{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Delegates []struct {
+				Email string `json:"email"`
+			} `json:"delegates"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://admin.googleapis.com/admin/contacts/v1/users/", url.QueryEscape(email)+"/delegates",
+			url.Values{"pageToken": {pageToken}})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, d := range r.Delegates {
+			delegates = append(delegates, d.Email)
+		}
+		if r.NextPageToken == "" {
+			return delegates, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// countOtherContacts returns how many "Other contacts" (addresses Gmail
+// saved automatically) the impersonated user has, via the People API.
+func countOtherContacts(ctx context.Context, client *http.Client) (int, error) {
+	count := 0
+	pageToken := ""
+	for {
+		r := &struct {
+			OtherContacts []struct {
+				ResourceName string `json:"resourceName"`
+			} `json:"otherContacts"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://people.googleapis.com/v1/", "otherContacts", url.Values{
+			"readMask":  {"emailAddresses"},
+			"pageSize":  {"1000"},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return 0, err
+		}
+		count += len(r.OtherContacts)
+		if r.NextPageToken == "" {
+			return count, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
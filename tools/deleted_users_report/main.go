@@ -0,0 +1,212 @@
+// Package deletedusersreport is the deleted_users_report tool: recently
+// deleted users, which can be restored in bulk.
+package deletedusersreport
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of deleted_users_report, which Run parses.
+var commandLine = flag.NewFlagSet("deleted_users_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for deleted users.")
+	daysFlag              = commandLine.Int("days", 20, "Only include users deleted within this many days.")
+	outputFile            = commandLine.String("output-file", "deleted_users.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "deleted_users_report", "csv")
+	usersFlag             = commandLine.String("users", "", "restore: comma separated addresses or IDs of deleted users to restore.")
+	usersFileFlag         = commandLine.String("users-file", "", "restore: a csv with an id or email column (such as this tool's report) of users to restore.")
+	orgUnitFlag           = commandLine.String("org-unit", "/", "restore: the OU to restore users into.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "restore: log the restores without making them.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "deleted_users_report")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags]          report recently deleted users\n", commandLine.Name())
+	fmt.Fprintf(os.Stderr, "       %s restore [flags]  restore deleted users\n\n", commandLine.Name())
+	commandLine.PrintDefaults()
+}
+
+// Run runs deleted_users_report with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	commandLine.Usage = usage
+	restore := len(args) > 0 && args[0] == "restore"
+	if restore {
+		args = args[1:]
+	}
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("deleted_users_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	if restore {
+		check.RequireOne("users", "users-file")
+	}
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	scope := admin.AdminDirectoryUserReadonlyScope
+	if restore {
+		scope = admin.AdminDirectoryUserScope
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	deleted, err := directory.ListDeletedUsers(ctx, service, *domainFlag)
+	if err != nil {
+		log.Fatalf("Error fetching deleted users: %v", err)
+	}
+	cutoff := time.Now().AddDate(0, 0, -*daysFlag)
+	recent := []*admin.User{}
+	for _, u := range deleted {
+		t, err := time.Parse(time.RFC3339, u.DeletionTime)
+		if err == nil && t.Before(cutoff) {
+			continue
+		}
+		recent = append(recent, u)
+	}
+
+	if restore {
+		restoreUsers(service, recent)
+		return nil
+	}
+
+	rows := [][]string{
+		{"email", "id", "deletion_time", "org_unit"},
+	}
+	for _, u := range recent {
+		rows = append(rows, []string{u.PrimaryEmail, u.Id, u.DeletionTime, u.OrgUnitPath})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d users deleted in the last %d days", len(recent), *daysFlag)
+	log.Println("Complete")
+	return nil
+}
+
+// restoreUsers undeletes the selected users. Deleted users can only be
+// restored by ID, and an address may match several deleted accounts, so
+// ambiguous addresses are refused.
+func restoreUsers(service *admin.Service, deleted []*admin.User) {
+	selected := []string{}
+	if *usersFlag != "" {
+		selected = append(selected, strings.Split(*usersFlag, ",")...)
+	}
+	if *usersFileFlag != "" {
+		fromFile, err := readUsersFile(*usersFileFlag)
+		if err != nil {
+			log.Fatalf("Could not read users file: %v", err)
+		}
+		selected = append(selected, fromFile...)
+	}
+
+	byID := map[string]*admin.User{}
+	byEmail := map[string][]*admin.User{}
+	for _, u := range deleted {
+		byID[u.Id] = u
+		email := strings.ToLower(u.PrimaryEmail)
+		byEmail[email] = append(byEmail[email], u)
+	}
+
+	changes := []*reconcile.Change{}
+	for _, key := range selected {
+		key = strings.TrimSpace(key)
+		u, ok := byID[key]
+		if !ok {
+			matches := byEmail[strings.ToLower(key)]
+			switch len(matches) {
+			case 0:
+				log.Printf("No recently deleted user %s, skipping", key)
+				continue
+			case 1:
+				u = matches[0]
+			default:
+				log.Printf("%s matches %d deleted users, restore by ID instead, skipping", key, len(matches))
+				continue
+			}
+		}
+		changes = append(changes, &reconcile.Change{
+			Action:  "restore",
+			Target:  *orgUnitFlag,
+			Subject: fmt.Sprintf("%s (%s)", u.PrimaryEmail, u.Id),
+			Apply: func() error {
+				return service.Users.Undelete(u.Id, &admin.UserUndelete{OrgUnitPath: *orgUnitFlag}).Do()
+			},
+		})
+	}
+	if _, err := reconcile.Apply(changes, reconcile.Options{DryRun: *dryRunFlag, Limits: limits, Plan: plan}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+}
+
+// readUsersFile returns the id column of a csv file, or the email column if
+// there is no id column.
+func readUsersFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	col := -1
+	for i, name := range records[0] {
+		if name == "id" {
+			col = i
+			break
+		}
+		if name == "email" {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("%s has no id or email column", path)
+	}
+	users := []string{}
+	for _, r := range records[1:] {
+		if col < len(r) && r[col] != "" {
+			users = append(users, r[col])
+		}
+	}
+	return users, nil
+}
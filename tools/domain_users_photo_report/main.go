@@ -0,0 +1,128 @@
+// Package domainusersphotoreport is the domain_users_photo_report tool:
+// users with no profile photo, with per-OU totals.
+package domainusersphotoreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of domain_users_photo_report, which Run parses.
+var commandLine = flag.NewFlagSet("domain_users_photo_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	includeSuspendedFlag  = commandLine.Bool("include-suspended", false, "Include suspended users.")
+	outputFile            = commandLine.String("output-file", "users_without_photos.csv", "The csv file of users without a photo to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "domain_users_photo_report", "csv")
+	summaryFile           = commandLine.String("summary-file", "photos_by_ou.csv", "The csv file of per-OU totals to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+type ouStats struct {
+	users, withoutPhoto int
+}
+
+// Run runs domain_users_photo_report with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("domain_users_photo_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Starting report generation")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+
+	rows := [][]string{
+		{"email", "name", "org_unit"},
+	}
+	stats := map[string]*ouStats{}
+	for _, u := range users {
+		if u.Suspended && !*includeSuspendedFlag {
+			continue
+		}
+		s, ok := stats[u.OrgUnitPath]
+		if !ok {
+			s = &ouStats{}
+			stats[u.OrgUnitPath] = s
+		}
+		s.users++
+		// The API only returns a thumbnail URL once a photo has been
+		// uploaded; users on the default silhouette have none.
+		if u.ThumbnailPhotoUrl != "" {
+			continue
+		}
+		s.withoutPhoto++
+		name := ""
+		if u.Name != nil {
+			name = u.Name.FullName
+		}
+		rows = append(rows, []string{u.PrimaryEmail, name, u.OrgUnitPath})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+
+	ous := []string{}
+	for ou := range stats {
+		ous = append(ous, ou)
+	}
+	sort.Strings(ous)
+	summary := [][]string{
+		{"org_unit", "users", "without_photo", "percent_without_photo"},
+	}
+	for _, ou := range ous {
+		s := stats[ou]
+		summary = append(summary, []string{
+			ou,
+			strconv.Itoa(s.users),
+			strconv.Itoa(s.withoutPhoto),
+			strconv.FormatFloat(float64(s.withoutPhoto)*100/float64(s.users), 'f', 1, 64),
+		})
+	}
+	// -fields and -filter describe the main report; the summary only
+	// follows -format.
+	summaryOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("domain_users_photo_report_by_ou")}
+	if err := summaryOptions.WriteFile(*summaryFile, summary); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d users without a photo", len(rows)-1)
+	log.Println("Complete")
+	return nil
+}
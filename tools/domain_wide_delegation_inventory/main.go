@@ -0,0 +1,238 @@
+// Package domainwidedelegationinventory is the
+// domain_wide_delegation_inventory tool: service accounts with domain-wide
+// delegation, their scopes and recent use.
+package domainwidedelegationinventory
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of domain_wide_delegation_inventory, which
+// Run parses.
+var commandLine = flag.NewFlagSet("domain_wide_delegation_inventory", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	projectsFlag          = commandLine.String("projects", "", "Comma separated GCP project IDs whose service accounts are inventoried. The credentials' own service account needs iam.serviceAccounts.list in each.")
+	includeUnusedFlag     = commandLine.Bool("include-unused", false, "Also list service accounts with no sign of delegation.")
+	outputFile            = commandLine.String("output-file", "domain_wide_delegation.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "domain_wide_delegation_inventory", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// serviceAccount is a service account from the IAM API.
+type serviceAccount struct {
+	project  string
+	Email    string `json:"email"`
+	ClientID string `json:"oauth2ClientId"`
+	Disabled bool   `json:"disabled"`
+}
+
+// grant is the latest delegation change for one client ID.
+type grant struct {
+	time       string
+	authorized bool
+	scopes     string
+	admin      string
+}
+
+// usage summarizes a client's token grants.
+type usage struct {
+	lastUsed string
+	users    map[string]bool
+	scopes   map[string]bool
+}
+
+// Run runs domain_wide_delegation_inventory with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("domain_wide_delegation_inventory", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "projects")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	// IAM is called as the service account itself, the audit log as the
+	// impersonated admin.
+	gcpClient, err := auth.ClientFromFile(ctx, *credentialsFileFlag, "", auth.CloudPlatformScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, reports.AuditReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	accounts := []*serviceAccount{}
+	for _, project := range listfile.Split(*projectsFlag) {
+		log.Printf("Listing service accounts in %s", project)
+		found, err := listServiceAccounts(ctx, gcpClient, project)
+		if err != nil {
+			log.Fatalf("Error listing service accounts in %s: %v", project, err)
+		}
+		accounts = append(accounts, found...)
+	}
+
+	// There is no API listing the delegation grants themselves, so they are
+	// reconstructed from the admin audit log (about six months of history)
+	// and corroborated by recent token grants.
+	log.Println("Fetching delegation changes from the audit log")
+	grants, err := fetchGrants(ctx, client)
+	if err != nil {
+		log.Fatalf("Error fetching audit log: %v", err)
+	}
+
+	rows := [][]string{
+		{"project", "service_account", "client_id", "disabled", "delegation", "scopes", "granted_by", "granted", "last_used", "impersonated_users", "used_scopes"},
+	}
+	for _, sa := range accounts {
+		g := grants[sa.ClientID]
+		u, err := fetchUsage(ctx, client, sa.ClientID)
+		if err != nil {
+			log.Fatalf("Error fetching token activity for %s: %v", sa.Email, err)
+		}
+		delegation := "none seen"
+		switch {
+		case g != nil && g.authorized:
+			delegation = "authorized"
+		case g != nil:
+			delegation = "removed"
+		case len(u.users) > 0:
+			// Granted before the audit log's retention, but still in use.
+			delegation = "in use"
+		}
+		if delegation == "none seen" && !*includeUnusedFlag {
+			continue
+		}
+		row := []string{sa.project, sa.Email, sa.ClientID, strconv.FormatBool(sa.Disabled), delegation, "", "", "", u.lastUsed, strconv.Itoa(len(u.users)), strings.Join(sortedKeys(u.scopes), ";")}
+		if g != nil {
+			row[5], row[6], row[7] = g.scopes, g.admin, g.time
+		}
+		rows = append(rows, row)
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d service accounts with domain-wide delegation", len(rows)-1)
+	log.Println("Complete")
+	return nil
+}
+
+func listServiceAccounts(ctx context.Context, client *http.Client, project string) ([]*serviceAccount, error) {
+	accounts := []*serviceAccount{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Accounts      []*serviceAccount `json:"accounts"`
+			NextPageToken string            `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://iam.googleapis.com/v1/", "projects/"+url.QueryEscape(project)+"/serviceAccounts",
+			url.Values{"pageSize": {"100"}, "pageToken": {pageToken}})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, sa := range r.Accounts {
+			sa.project = project
+			accounts = append(accounts, sa)
+		}
+		if r.NextPageToken == "" {
+			return accounts, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// fetchGrants returns the latest authorize or remove event for each client
+// ID from the admin audit log.
+func fetchGrants(ctx context.Context, client *http.Client) (map[string]*grant, error) {
+	grants := map[string]*grant{}
+	for _, name := range []string{"AUTHORIZE_API_CLIENT_ACCESS", "REMOVE_API_CLIENT_ACCESS"} {
+		activities, err := reports.Activities(ctx, client, reports.ActivityQuery{Application: "admin", EventName: name})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range activities {
+			for _, e := range a.Events {
+				if e.Name != name {
+					continue
+				}
+				id := e.Param("API_CLIENT_NAME")
+				// RFC 3339 times in UTC compare correctly as strings.
+				if g, ok := grants[id]; ok && g.time >= a.ID.Time {
+					continue
+				}
+				grants[id] = &grant{
+					time:       a.ID.Time,
+					authorized: name == "AUTHORIZE_API_CLIENT_ACCESS",
+					scopes:     e.Param("API_SCOPES"),
+					admin:      a.Actor.Email,
+				}
+			}
+		}
+	}
+	return grants, nil
+}
+
+// fetchUsage summarizes the token grants to clientID in the token audit
+// log: who it acted as, with which scopes, and when it last did.
+func fetchUsage(ctx context.Context, client *http.Client, clientID string) (*usage, error) {
+	u := &usage{users: map[string]bool{}, scopes: map[string]bool{}}
+	activities, err := reports.Activities(ctx, client, reports.ActivityQuery{
+		Application: "token",
+		EventName:   "authorize",
+		Filters:     "client_id==" + clientID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range activities {
+		if u.lastUsed == "" {
+			u.lastUsed = a.ID.Time
+		}
+		u.users[strings.ToLower(a.Actor.Email)] = true
+		for _, e := range a.Events {
+			for _, scope := range strings.Split(e.Param("scope"), ";") {
+				if scope != "" {
+					u.scopes[scope] = true
+				}
+			}
+		}
+	}
+	return u, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package driveexternalsharingreport
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const driveMetadataScope = "https://www.googleapis.com/auth/drive.metadata.readonly"
+
+// file is a Drive file with who it is shared with.
+type file struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	MimeType    string        `json:"mimeType"`
+	WebViewLink string        `json:"webViewLink"`
+	Permissions []*permission `json:"permissions"`
+}
+
+// permission is one grant of access to a file.
+type permission struct {
+	// Type is user, group, domain or anyone.
+	Type         string `json:"type"`
+	EmailAddress string `json:"emailAddress"`
+	Domain       string `json:"domain"`
+	Role         string `json:"role"`
+	// AllowFileDiscovery is set on domain and anyone permissions that
+	// make the file show up in search rather than needing the link.
+	AllowFileDiscovery bool `json:"allowFileDiscovery"`
+}
+
+// external returns who outside the internal domains the permission
+// shares the file with: an address, a domain, or "anyone". It is "" for
+// an internal grant.
+func (p *permission) external(internal map[string]bool) string {
+	switch p.Type {
+	case "anyone":
+		return "anyone"
+	case "domain":
+		if !internal[strings.ToLower(p.Domain)] {
+			return p.Domain
+		}
+		return ""
+	}
+	email := strings.ToLower(p.EmailAddress)
+	if email == "" || internal[email[strings.LastIndex(email, "@")+1:]] {
+		return ""
+	}
+	return p.EmailAddress
+}
+
+// ownedFiles returns the files the client's user owns. Drive can't search
+// on who a file is shared with, so every owned file is listed with its
+// permissions and the caller picks out the external ones.
+func ownedFiles(ctx context.Context, client *http.Client) ([]*file, error) {
+	files := []*file{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Files         []*file `json:"files"`
+			NextPageToken string  `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/drive/v3/", "files", url.Values{
+			"q":         {"'me' in owners and trashed = false"},
+			"fields":    {"files(id,name,mimeType,webViewLink,permissions(type,emailAddress,domain,role,allowFileDiscovery)),nextPageToken"},
+			"pageSize":  {"1000"},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		files = append(files, r.Files...)
+		if r.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
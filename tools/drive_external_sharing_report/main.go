@@ -0,0 +1,236 @@
+// Package driveexternalsharingreport is the drive_external_sharing_report
+// tool: files users own that are shared outside the organization or with
+// anyone with the link.
+package driveexternalsharingreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of drive_external_sharing_report, which Run
+// parses.
+var commandLine = flag.NewFlagSet("drive_external_sharing_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users' files are checked, or several separated by commas.")
+	orgUnitFlag           = commandLine.String("org-unit", "", "Only check the users in this OU and those below it, e.g. /Finance.")
+	usersFlag             = commandLine.String("users", "", "Comma separated users whose files are checked, instead of every active user in -domain.")
+	internalDomainsFlag   = commandLine.String("internal-domains", "", "The organization's domains, separated by commas; sharing with any other is external. Defaults to every verified domain.")
+	concurrencyFlag       = commandLine.Int("concurrency", 5, "The number of users whose files are listed at once.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Stop early, writing a partial report, once more than this fraction of users' listings fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of users to attempt before -max-error-rate applies.")
+	outputFile            = commandLine.String("output-file", "external_sharing.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "drive_external_sharing_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs drive_external_sharing_report with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("drive_external_sharing_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	if *domainFlag == "" && *usersFlag == "" {
+		check.Problemf("one of -domain or -users is required")
+	}
+	if *orgUnitFlag != "" && *usersFlag != "" {
+		check.Problemf("-org-unit can't be used with -users")
+	}
+	if *concurrencyFlag < 1 {
+		check.Problemf("-concurrency must be at least 1")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	scopes := []string{admin.AdminDirectoryUserReadonlyScope}
+	internalDomains := listfile.Split(*internalDomainsFlag)
+	if len(internalDomains) == 0 {
+		scopes = append(scopes, admin.AdminDirectoryDomainReadonlyScope)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Drive only lists a user's own files to that user, so each owner is
+	// impersonated in turn.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, driveMetadataScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(internalDomains) == 0 {
+		if internalDomains, err = directory.ListDomains(ctx, service); err != nil {
+			log.Fatalf("Error fetching domains: %v", err)
+		}
+	}
+	internal := map[string]bool{}
+	for _, d := range internalDomains {
+		internal[strings.ToLower(d)] = true
+	}
+
+	owners := listfile.Split(*usersFlag)
+	if len(owners) == 0 {
+		log.Println("Fetching users")
+		ou := strings.TrimSuffix(strings.ToLower(*orgUnitFlag), "/")
+		for _, domain := range listfile.Split(*domainFlag) {
+			users, err := directory.ListUsers(ctx, service, domain, "")
+			if err != nil {
+				log.Fatalf("Error fetching users of %s: %v", domain, err)
+			}
+			for _, u := range users {
+				// Suspended users can't be impersonated.
+				if u.Suspended {
+					continue
+				}
+				path := strings.ToLower(u.OrgUnitPath)
+				if ou != "" && path != ou && !strings.HasPrefix(path, ou+"/") {
+					continue
+				}
+				owners = append(owners, u.PrimaryEmail)
+			}
+		}
+		log.Printf("%d users to check", len(owners))
+	}
+
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"owner", "file_id", "file", "mime_type", "link", "permission_type", "role", "shared_with", "discoverable",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	// Each owner's rows are written as one batch, in the order the users
+	// were listed.
+	ordered := output.NewOrderedWriter(writer)
+	cb := breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag)
+
+	var mu sync.Mutex
+	failed, shared := 0, 0
+	var aborted error
+	// Once the breaker trips or the run is interrupted no more users are
+	// handed out, and the report stops at the first user that wasn't
+	// written.
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrencyFlag; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				owner := owners[i]
+				rows, err := ownerRows(ctx, impersonator, owner, internal)
+				if err != nil && shutdown.Interrupted(ctx) {
+					continue
+				}
+				tripped := cb.Record(err)
+				mu.Lock()
+				if err != nil {
+					// Drive may be off for the user's OU; carry on with
+					// the rest.
+					log.Printf("Error listing files of %s, skipping: %v", owner, err)
+					failed++
+				}
+				shared += len(rows)
+				if tripped != nil && aborted == nil {
+					aborted = tripped
+				}
+				mu.Unlock()
+				if err := ordered.Write(i, rows); err != nil {
+					log.Fatalf("Error writing csv file: %v", err)
+				}
+			}
+		}()
+	}
+hand:
+	for i := range owners {
+		if cb.Tripped() {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break hand
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	interrupted := shutdown.Interrupted(ctx)
+	if interrupted {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if interrupted {
+		log.Println("Interrupted: wrote partial report")
+		os.Exit(shutdown.ExitCode)
+	}
+	if aborted != nil {
+		log.Fatalf("Wrote partial report: %v", aborted)
+	}
+	log.Printf("%d external shares", shared)
+	if failed > 0 {
+		log.Fatalf("Complete, but the files of %d users couldn't be listed", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// ownerRows lists owner's files as owner and returns a row for each
+// external permission on them.
+func ownerRows(ctx context.Context, impersonator *auth.Impersonator, owner string, internal map[string]bool) ([][]string, error) {
+	client, err := impersonator.Client(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ownedFiles(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	rows := [][]string{}
+	for _, f := range files {
+		for _, p := range f.Permissions {
+			with := p.external(internal)
+			if with == "" {
+				continue
+			}
+			discoverable := ""
+			if p.Type == "anyone" || p.Type == "domain" {
+				discoverable = strconv.FormatBool(p.AllowFileDiscovery)
+			}
+			rows = append(rows, []string{owner, f.ID, f.Name, f.MimeType, f.WebViewLink, p.Type, p.Role, with, discoverable})
+		}
+	}
+	return rows, nil
+}
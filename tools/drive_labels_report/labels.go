@@ -0,0 +1,225 @@
+package drivelabelsreport
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	labelsScope        = "https://www.googleapis.com/auth/drive.admin.labels.readonly"
+	driveMetadataScope = "https://www.googleapis.com/auth/drive.metadata.readonly"
+)
+
+// properties holds the display names the Labels API nests under
+// "properties".
+type properties struct {
+	Title       string `json:"title"`
+	DisplayName string `json:"displayName"`
+}
+
+type choice struct {
+	ID         string     `json:"id"`
+	Properties properties `json:"properties"`
+}
+
+type field struct {
+	ID               string     `json:"id"`
+	Properties       properties `json:"properties"`
+	SelectionOptions *struct {
+		Choices []*choice `json:"choices"`
+	} `json:"selectionOptions"`
+	TextOptions    *struct{} `json:"textOptions"`
+	IntegerOptions *struct{} `json:"integerOptions"`
+	DateOptions    *struct{} `json:"dateOptions"`
+	UserOptions    *struct{} `json:"userOptions"`
+}
+
+// kind returns the field's value type as the report names it.
+func (f *field) kind() string {
+	switch {
+	case f.SelectionOptions != nil:
+		return "selection"
+	case f.TextOptions != nil:
+		return "text"
+	case f.IntegerOptions != nil:
+		return "integer"
+	case f.DateOptions != nil:
+		return "date"
+	case f.UserOptions != nil:
+		return "user"
+	}
+	return ""
+}
+
+// choiceName returns the display name of the choice with the given ID, or
+// the ID if the choice has since been deleted.
+func (f *field) choiceName(id string) string {
+	if f.SelectionOptions != nil {
+		for _, c := range f.SelectionOptions.Choices {
+			if c.ID == id {
+				return c.Properties.DisplayName
+			}
+		}
+	}
+	return id
+}
+
+type label struct {
+	ID         string     `json:"id"`
+	LabelType  string     `json:"labelType"`
+	Properties properties `json:"properties"`
+	Lifecycle  struct {
+		State string `json:"state"`
+	} `json:"lifecycle"`
+	Fields []*field `json:"fields"`
+}
+
+func (l *label) field(id string) *field {
+	for _, f := range l.Fields {
+		if f.ID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// listLabels returns every label in the organization, published or not,
+// using admin access.
+func listLabels(ctx context.Context, client *http.Client) ([]*label, error) {
+	labels := []*label{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Labels        []*label `json:"labels"`
+			NextPageToken string   `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://drivelabels.googleapis.com/v2/", "labels", url.Values{
+			"useAdminAccess": {"true"},
+			"view":           {"LABEL_VIEW_FULL"},
+			"pageSize":       {"200"},
+			"pageToken":      {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		labels = append(labels, r.Labels...)
+		if r.NextPageToken == "" {
+			return labels, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// fieldValue is a label field's value on a file. Only the member for the
+// field's type is set.
+type fieldValue struct {
+	Selection  []string `json:"selection"`
+	Text       []string `json:"text"`
+	Integer    []string `json:"integer"`
+	DateString []string `json:"dateString"`
+	User       []struct {
+		EmailAddress string `json:"emailAddress"`
+	} `json:"user"`
+}
+
+// values returns the values as text, with selection choices resolved to
+// their display names through f when it is known.
+func (v *fieldValue) values(f *field) []string {
+	switch {
+	case v.Selection != nil:
+		values := []string{}
+		for _, id := range v.Selection {
+			if f != nil {
+				id = f.choiceName(id)
+			}
+			values = append(values, id)
+		}
+		return values
+	case v.User != nil:
+		values := []string{}
+		for _, u := range v.User {
+			values = append(values, u.EmailAddress)
+		}
+		return values
+	case v.Text != nil:
+		return v.Text
+	case v.Integer != nil:
+		return v.Integer
+	}
+	return v.DateString
+}
+
+type fileLabel struct {
+	ID     string                 `json:"id"`
+	Fields map[string]*fieldValue `json:"fields"`
+}
+
+// fieldIDs returns the IDs of the fields set on the file, sorted.
+func (l *fileLabel) fieldIDs() []string {
+	ids := []string{}
+	for id := range l.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+type file struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	MimeType  string `json:"mimeType"`
+	LabelInfo struct {
+		Labels []*fileLabel `json:"labels"`
+	} `json:"labelInfo"`
+}
+
+// labelsPerQuery bounds how many labels one files.list asks about, to keep
+// the query and includeLabels within the Drive API's limits.
+const labelsPerQuery = 10
+
+// labeledFiles returns the files the impersonated user owns that carry any
+// of the labels with the given IDs.
+func labeledFiles(ctx context.Context, client *http.Client, labelIDs []string) ([]*file, error) {
+	files := []*file{}
+	for start := 0; start < len(labelIDs); start += labelsPerQuery {
+		end := start + labelsPerQuery
+		if end > len(labelIDs) {
+			end = len(labelIDs)
+		}
+		ids := labelIDs[start:end]
+		terms := []string{}
+		for _, id := range ids {
+			terms = append(terms, "'labels/"+id+"' in labels")
+		}
+		q := "'me' in owners and trashed = false and (" + strings.Join(terms, " or ") + ")"
+		pageToken := ""
+		for {
+			r := &struct {
+				Files         []*file `json:"files"`
+				NextPageToken string  `json:"nextPageToken"`
+			}{}
+			u := rest.URL("https://www.googleapis.com/drive/v3/", "files", url.Values{
+				"q":             {q},
+				"includeLabels": {strings.Join(ids, ",")},
+				"fields":        {"files(id,name,mimeType,labelInfo),nextPageToken"},
+				"pageSize":      {"1000"},
+				"pageToken":     {pageToken},
+			})
+			if err := rest.Get(ctx, client, u, r); err != nil {
+				return nil, err
+			}
+			files = append(files, r.Files...)
+			if r.NextPageToken == "" {
+				break
+			}
+			pageToken = r.NextPageToken
+		}
+	}
+	return files, nil
+}
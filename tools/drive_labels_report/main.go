@@ -0,0 +1,189 @@
+// Package drivelabelsreport is the drive_labels_report tool: the Drive
+// Labels taxonomy and the files carrying each label.
+package drivelabelsreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of drive_labels_report, which Run parses.
+var commandLine = flag.NewFlagSet("drive_labels_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	usersFlag             = commandLine.String("users", "", "Comma separated users whose files are checked. Defaults to every active user in the domain.")
+	labelsFlag            = commandLine.String("labels", "", "Comma separated label IDs to report on. Defaults to every published label.")
+	outputFile            = commandLine.String("output-file", "labeled_files.csv", "The csv file of labeled files to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "drive_labels_report", "csv")
+	taxonomyFile          = commandLine.String("taxonomy-file", "drive_labels.csv", "The csv file of labels, fields and choices to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs drive_labels_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("drive_labels_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, labelsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Drive only shows a file's labels to someone who can see the file, so
+	// each owner is impersonated in turn.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, driveMetadataScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching labels")
+	labels, err := listLabels(ctx, client)
+	if err != nil {
+		log.Fatalf("Error fetching labels: %v", err)
+	}
+	byID := map[string]*label{}
+	wanted := map[string]bool{}
+	for _, id := range listfile.Split(*labelsFlag) {
+		wanted[id] = true
+	}
+	taxonomy := [][]string{
+		{"label_id", "label", "type", "state", "field_id", "field", "field_type", "choice_id", "choice"},
+	}
+	ids := []string{}
+	for _, l := range labels {
+		byID[l.ID] = l
+		if len(wanted) > 0 && !wanted[l.ID] {
+			continue
+		}
+		// Unpublished labels can't be applied, so there are no files to
+		// look for, but the taxonomy still lists them.
+		if l.Lifecycle.State == "PUBLISHED" {
+			ids = append(ids, l.ID)
+		}
+		row := []string{l.ID, l.Properties.Title, l.LabelType, l.Lifecycle.State}
+		if len(l.Fields) == 0 {
+			taxonomy = append(taxonomy, append(row, "", "", "", "", ""))
+		}
+		for _, f := range l.Fields {
+			fieldRow := append(row[:4:4], f.ID, f.Properties.DisplayName, f.kind())
+			if f.SelectionOptions == nil || len(f.SelectionOptions.Choices) == 0 {
+				taxonomy = append(taxonomy, append(fieldRow, "", ""))
+			}
+			if f.SelectionOptions != nil {
+				for _, c := range f.SelectionOptions.Choices {
+					taxonomy = append(taxonomy, append(fieldRow[:7:7], c.ID, c.Properties.DisplayName))
+				}
+			}
+		}
+	}
+	for id := range wanted {
+		if byID[id] == nil {
+			log.Fatalf("No label %s: see %s for the label IDs", id, *taxonomyFile)
+		}
+	}
+	// -fields and -filter describe the file report; the taxonomy is always
+	// written whole.
+	taxonomyOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("drive_labels_report_taxonomy")}
+	if err := taxonomyOptions.WriteFile(*taxonomyFile, taxonomy); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if len(ids) == 0 {
+		log.Println("No published labels to look for")
+		log.Println("Complete")
+		return nil
+	}
+
+	owners := listfile.Split(*usersFlag)
+	if len(owners) == 0 {
+		log.Println("Fetching users")
+		users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+		if err != nil {
+			log.Fatalf("Error fetching users: %v", err)
+		}
+		for _, u := range users {
+			if !u.Suspended {
+				owners = append(owners, u.PrimaryEmail)
+			}
+		}
+	}
+
+	rows := [][]string{
+		{"owner", "file_id", "file", "mime_type", "label_id", "label", "field", "value"},
+	}
+	failed := 0
+	for _, owner := range owners {
+		userClient, err := impersonator.Client(ctx, owner)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files, err := labeledFiles(ctx, userClient, ids)
+		if err != nil {
+			// Drive may be off for the user's OU; carry on with the rest.
+			log.Printf("Warning: can't list files of %s: %v", owner, err)
+			failed++
+			continue
+		}
+		for _, f := range files {
+			for _, fl := range f.LabelInfo.Labels {
+				row := []string{owner, f.ID, f.Name, f.MimeType, fl.ID, fl.ID}
+				l := byID[fl.ID]
+				if l != nil {
+					row[5] = l.Properties.Title
+				}
+				if len(fl.Fields) == 0 {
+					rows = append(rows, append(row, "", ""))
+				}
+				for _, fieldID := range fl.fieldIDs() {
+					var lf *field
+					name := fieldID
+					if l != nil {
+						if lf = l.field(fieldID); lf != nil {
+							name = lf.Properties.DisplayName
+						}
+					}
+					value := strings.Join(fl.Fields[fieldID].values(lf), ";")
+					rows = append(rows, append(row[:6:6], name, value))
+				}
+			}
+		}
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but the files of %d users couldn't be listed", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,194 @@
+package duplicateaccountdetector
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/address"
+)
+
+// Finding kinds.
+const (
+	findingSameName      = "same_name"
+	findingSimilarName   = "similar_name"
+	findingSimilarAddr   = "similar_address"
+	findingSameRecovery  = "same_recovery_email"
+	findingRecoveryInUse = "recovery_is_workspace_account"
+	findingNotInRoster   = "not_in_roster"
+)
+
+// finding is one row of the report: email is suspected to be related to
+// the addresses in related.
+type finding struct {
+	kind    string
+	email   string
+	related []string
+	detail  string
+}
+
+// account is the subset of a user the detector looks at.
+type account struct {
+	email    string
+	given    string
+	family   string
+	recovery string
+}
+
+func newAccount(u *admin.User, recovery string) *account {
+	a := &account{email: strings.ToLower(u.PrimaryEmail), recovery: strings.ToLower(recovery)}
+	if u.Name != nil {
+		a.given = normalizeName(u.Name.GivenName)
+		a.family = normalizeName(u.Name.FamilyName)
+	}
+	return a
+}
+
+// detectDuplicates groups accounts that look like the same person.
+func detectDuplicates(accounts []*account) []*finding {
+	findings := []*finding{}
+	byAddress := map[string]*account{}
+	for _, a := range accounts {
+		byAddress[a.email] = a
+	}
+
+	findings = append(findings, groupBy(accounts, findingSameName, func(a *account) string {
+		if a.given == "" || a.family == "" {
+			return ""
+		}
+		return a.given + " " + a.family
+	})...)
+	findings = append(findings, groupBy(accounts, findingSimilarAddr, func(a *account) string {
+		return canonicalAddress(a.email)
+	})...)
+	findings = append(findings, groupBy(accounts, findingSameRecovery, func(a *account) string {
+		return a.recovery
+	})...)
+
+	// Someone whose recovery address is another Workspace account most
+	// likely holds both.
+	for _, a := range accounts {
+		if other, ok := byAddress[a.recovery]; ok && other != a {
+			findings = append(findings, &finding{kind: findingRecoveryInUse, email: a.email, related: []string{other.email}})
+		}
+	}
+
+	// Similar names: same family name, given names one edit apart. Only
+	// compared within a family name to keep this from being quadratic in
+	// the size of the domain.
+	byFamily := map[string][]*account{}
+	families := []string{}
+	for _, a := range accounts {
+		if a.family == "" || a.given == "" {
+			continue
+		}
+		if _, ok := byFamily[a.family]; !ok {
+			families = append(families, a.family)
+		}
+		byFamily[a.family] = append(byFamily[a.family], a)
+	}
+	sort.Strings(families)
+	for _, family := range families {
+		group := byFamily[family]
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				x, y := group[i], group[j]
+				if x.given != y.given && editDistance(x.given, y.given) <= 1 {
+					findings = append(findings, &finding{
+						kind:    findingSimilarName,
+						email:   x.email,
+						related: []string{y.email},
+						detail:  x.given + " " + family + " / " + y.given + " " + family,
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// groupBy reports every set of two or more accounts sharing a non-empty key.
+func groupBy(accounts []*account, kind string, key func(*account) string) []*finding {
+	groups := map[string][]string{}
+	for _, a := range accounts {
+		if k := key(a); k != "" {
+			groups[k] = append(groups[k], a.email)
+		}
+	}
+	findings := []*finding{}
+	for _, k := range sortedKeys(groups) {
+		emails := groups[k]
+		if len(emails) < 2 {
+			continue
+		}
+		sort.Strings(emails)
+		findings = append(findings, &finding{kind: kind, email: emails[0], related: emails[1:], detail: k})
+	}
+	return findings
+}
+
+// canonicalAddress reduces an address the way Gmail does when routing:
+// dots in the local part and anything after a + are ignored.
+func canonicalAddress(email string) string {
+	email = address.StripPlus(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return strings.Replace(email[:at], ".", "", -1) + email[at:]
+}
+
+// normalizeName lowercases a name and drops everything but letters, so
+// "O'Brien" and "obrien" compare equal.
+func normalizeName(name string) string {
+	out := []rune{}
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) {
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
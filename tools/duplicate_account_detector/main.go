@@ -0,0 +1,116 @@
+// Package duplicateaccountdetector is the duplicate_account_detector tool:
+// likely duplicate accounts and, with a roster, active users HR doesn't
+// know.
+package duplicateaccountdetector
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/roster"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of duplicate_account_detector, which Run
+// parses.
+var commandLine = flag.NewFlagSet("duplicate_account_detector", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	rosterFileFlag        = commandLine.String("roster-file", "", "An HR roster csv; active users missing from it are reported.")
+	rosterColumnFlag      = commandLine.String("roster-email-column", "email", "The roster column holding each person's work address.")
+	outputFile            = commandLine.String("output-file", "duplicate_accounts.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "duplicate_account_detector", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs duplicate_account_detector with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("duplicate_account_detector", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	var inRoster map[string]bool
+	if *rosterFileFlag != "" {
+		people, err := roster.Read(*rosterFileFlag, &roster.Mapping{EmailColumn: *rosterColumnFlag})
+		if err != nil {
+			log.Fatalf("Could not read roster: %v", err)
+		}
+		inRoster = roster.Emails(people)
+	}
+
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Fetching users")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	recovery, err := directory.RecoveryEmails(ctx, client, *domainFlag)
+	if err != nil {
+		log.Fatalf("Error fetching recovery addresses: %v", err)
+	}
+
+	accounts := []*account{}
+	for _, u := range users {
+		accounts = append(accounts, newAccount(u, recovery[strings.ToLower(u.PrimaryEmail)]))
+	}
+	findings := detectDuplicates(accounts)
+	if inRoster != nil {
+		for _, u := range users {
+			if u.Suspended || inRoster[strings.ToLower(u.PrimaryEmail)] {
+				continue
+			}
+			// Aliases count: HR may have the person under an old address.
+			found := false
+			for _, alias := range u.Aliases {
+				found = found || inRoster[strings.ToLower(alias)]
+			}
+			if !found {
+				findings = append(findings, &finding{kind: findingNotInRoster, email: strings.ToLower(u.PrimaryEmail)})
+			}
+		}
+	}
+
+	rows := [][]string{
+		{"finding", "email", "related", "detail"},
+	}
+	for _, f := range findings {
+		rows = append(rows, []string{f.kind, f.email, strings.Join(f.related, ";"), f.detail})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d findings", len(findings))
+	log.Println("Complete")
+	return nil
+}
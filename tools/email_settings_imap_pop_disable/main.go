@@ -0,0 +1,230 @@
+// Package emailsettingsimappopdisable is the
+// email_settings_imap_pop_disable tool: turns off IMAP and/or POP for a
+// list of users, a domain or an OU, recording the old settings so they can
+// be restored.
+package emailsettingsimappopdisable
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of email_settings_imap_pop_disable, which Run
+// parses.
+var commandLine = flag.NewFlagSet("email_settings_imap_pop_disable", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	usersFileFlag         = commandLine.String("users-file", "", "A file with one user address per line (or a csv with an email column) to disable IMAP/POP for.")
+	domainFlag            = commandLine.String("domain", "", "Instead of -users-file, disable IMAP/POP for every active user in this domain.")
+	orgUnitFlag           = commandLine.String("org-unit", "", "With -domain, only the users in this OU and the OUs below it.")
+	excludeFileFlag       = commandLine.String("exclude-file", "", "A file of user addresses (or a csv with an email column) to leave alone, such as mail archivers that still need IMAP.")
+	protocolsFlag         = commandLine.String("protocols", "imap,pop", "The comma separated protocols to disable: imap, pop or both.")
+	restoreFileFlag       = commandLine.String("restore-file", "", "Instead of disabling anything, put back the settings recorded in this rollback file.")
+	rollbackFile          = commandLine.String("rollback-file", "imap_pop_rollback.csv", "Where each user's previous settings are recorded before they are changed. It mustn't exist yet, so an earlier run's is never overwritten.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "email_settings_imap_pop_disable")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs email_settings_imap_pop_disable with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("email_settings_imap_pop_disable", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.RequireOne("users-file", "domain", "restore-file")
+	switch {
+	case *restoreFileFlag != "" && (*usersFileFlag != "" || *domainFlag != ""):
+		check.Problemf("-restore-file can't be used with -users-file or -domain")
+	case *usersFileFlag != "" && *domainFlag != "":
+		check.Problemf("-users-file and -domain can't be used together")
+	}
+	if *orgUnitFlag != "" && *domainFlag == "" {
+		check.Problemf("-org-unit needs -domain")
+	}
+	protocols := map[string]bool{}
+	for _, p := range listfile.Split(*protocolsFlag) {
+		if p = strings.ToLower(p); settingsPaths[p] == "" {
+			check.Problemf("unknown protocol %q in -protocols: use imap, pop or both", p)
+		}
+		protocols[p] = true
+	}
+	if len(protocols) == 0 {
+		check.Problemf("-protocols names no protocols")
+	}
+	if _, err := os.Stat(*rollbackFile); err == nil && *restoreFileFlag == "" && !*dryRunFlag {
+		check.Problemf("-rollback-file %s already exists; move it aside or give another -rollback-file", *rollbackFile)
+	}
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	// Gmail settings can only be changed by their owner, so each user's
+	// are read and written as them.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, gmailSettingsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *restoreFileFlag != "" {
+		changes, err := restoreChanges(ctx, impersonator, *restoreFileFlag)
+		if err != nil {
+			log.Fatalf("Could not read rollback file: %v", err)
+		}
+		log.Printf("%d settings to restore", len(changes))
+		if _, err := reconcile.Apply(changes, reconcile.Options{
+			DryRun:  *dryRunFlag,
+			Canary:  *canaryFlag,
+			Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+			Limits:  limits,
+			Plan:    plan,
+		}); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Complete")
+		return nil
+	}
+
+	users, err := selectedUsers(ctx)
+	if err != nil {
+		log.Fatalf("Could not select users: %v", err)
+	}
+
+	rollback := [][]string{{"email", "setting", "value"}}
+	changes := []*reconcile.Change{}
+	failed := 0
+	for _, email := range users {
+		userClient, err := impersonator.Client(ctx, email)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, protocol := range []string{"imap", "pop"} {
+			if !protocols[protocol] {
+				continue
+			}
+			current, err := getSettings(ctx, userClient, protocol)
+			if err != nil {
+				log.Printf("Error fetching %s settings of %s, skipping: %v", protocol, email, err)
+				failed++
+				continue
+			}
+			if !enabled(protocol, current) {
+				continue
+			}
+			value, err := current.encode()
+			if err != nil {
+				log.Fatal(err)
+			}
+			rollback = append(rollback, []string{email, protocol, value})
+			changes = append(changes, disableChange(ctx, userClient, email, protocol, current))
+		}
+	}
+	if !*dryRunFlag {
+		if err := output.WriteNewCSV(*rollbackFile, rollback); err != nil {
+			log.Fatalf("Error writing rollback file: %v", err)
+		}
+	}
+	log.Printf("%d changes for %d users", len(changes), len(users))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:  *dryRunFlag,
+		Canary:  *canaryFlag,
+		Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:  limits,
+		Plan:    plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but %d settings could not be read and were left alone", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// selectedUsers returns the addresses -users-file or -domain and -org-unit
+// select, less those in -exclude-file. Suspended users are left out, since
+// they can't be impersonated and can't sign in anyway.
+func selectedUsers(ctx context.Context) ([]string, error) {
+	var users []string
+	if *usersFileFlag != "" {
+		var err error
+		if users, err = listfile.Read(*usersFileFlag, "email"); err != nil {
+			return nil, err
+		}
+	} else {
+		client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserReadonlyScope)
+		if err != nil {
+			return nil, err
+		}
+		service, err := admin.New(client)
+		if err != nil {
+			return nil, err
+		}
+		var found []*admin.User
+		if *orgUnitFlag != "" {
+			log.Printf("Fetching users in %s", *orgUnitFlag)
+			found, err = directory.ListUsersInOrgUnit(ctx, service, *domainFlag, *orgUnitFlag)
+		} else {
+			log.Printf("Fetching users in %s", *domainFlag)
+			found, err = directory.ListUsers(ctx, service, *domainFlag, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range found {
+			if !u.Suspended {
+				users = append(users, u.PrimaryEmail)
+			}
+		}
+	}
+	excluded := map[string]bool{}
+	if *excludeFileFlag != "" {
+		exclude, err := listfile.Read(*excludeFileFlag, "email")
+		if err != nil {
+			return nil, err
+		}
+		for _, email := range exclude {
+			excluded[strings.ToLower(email)] = true
+		}
+	}
+	selected := []string{}
+	for _, email := range users {
+		if !excluded[strings.ToLower(email)] {
+			selected = append(selected, email)
+		}
+	}
+	if len(excluded) > 0 {
+		log.Printf("%d of %d users excluded", len(users)-len(selected), len(users))
+	}
+	return selected, nil
+}
@@ -0,0 +1,119 @@
+package emailsettingsimappopdisable
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const gmailSettingsScope = "https://www.googleapis.com/auth/gmail.settings.basic"
+
+// settingsPaths are the Gmail settings resources of each protocol.
+var settingsPaths = map[string]string{
+	"imap": "https://gmail.googleapis.com/gmail/v1/users/me/settings/imap",
+	"pop":  "https://gmail.googleapis.com/gmail/v1/users/me/settings/pop",
+}
+
+// settings is an IMAP or POP settings resource. It is kept as the API
+// returned it, so a change only touches the field it means to and the
+// rollback file holds everything needed to put it back.
+type settings map[string]interface{}
+
+func (s settings) encode() (string, error) {
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+func getSettings(ctx context.Context, userClient *http.Client, protocol string) (settings, error) {
+	s := settings{}
+	if err := rest.Get(ctx, userClient, settingsPaths[protocol], &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func putSettings(ctx context.Context, userClient *http.Client, protocol string, s settings) error {
+	return rest.Do(ctx, userClient, "PUT", settingsPaths[protocol], s, nil)
+}
+
+// enabled reports whether s lets the protocol be used. POP is off when its
+// access window is "disabled".
+func enabled(protocol string, s settings) bool {
+	if protocol == "imap" {
+		on, _ := s["enabled"].(bool)
+		return on
+	}
+	window, _ := s["accessWindow"].(string)
+	return window != "" && window != "disabled"
+}
+
+// disableChange turns the protocol off, leaving its other settings as they
+// are.
+func disableChange(ctx context.Context, userClient *http.Client, email, protocol string, current settings) *reconcile.Change {
+	next := settings{}
+	for k, v := range current {
+		next[k] = v
+	}
+	if protocol == "imap" {
+		next["enabled"] = false
+	} else {
+		next["accessWindow"] = "disabled"
+	}
+	return &reconcile.Change{
+		Action:  "disable",
+		Target:  email,
+		Subject: protocol,
+		Apply:   func() error { return putSettings(ctx, userClient, protocol, next) },
+	}
+}
+
+// restoreChanges reads a rollback file and returns the changes that put
+// each recorded setting back.
+func restoreChanges(ctx context.Context, impersonator *auth.Impersonator, path string) ([]*reconcile.Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) < 3 || records[0][0] != "email" || records[0][1] != "setting" || records[0][2] != "value" {
+		return nil, fmt.Errorf("%s is not a rollback file: expected email, setting and value columns", path)
+	}
+	changes := []*reconcile.Change{}
+	for i, rec := range records[1:] {
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("%s line %d: expected email, setting and value", path, i+2)
+		}
+		email, protocol := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1])
+		if settingsPaths[protocol] == "" {
+			return nil, fmt.Errorf("%s line %d: unknown setting %q", path, i+2, protocol)
+		}
+		previous := settings{}
+		if err := json.Unmarshal([]byte(rec[2]), &previous); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, i+2, err)
+		}
+		userClient, err := impersonator.Client(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &reconcile.Change{
+			Action:  "restore",
+			Target:  email,
+			Subject: protocol,
+			Apply:   func() error { return putSettings(ctx, userClient, protocol, previous) },
+		})
+	}
+	return changes, nil
+}
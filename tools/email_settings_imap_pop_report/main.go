@@ -0,0 +1,181 @@
+// Package emailsettingsimappopreport is the email_settings_imap_pop_report
+// tool: which users have IMAP or POP enabled in Gmail, with per-OU totals.
+package emailsettingsimappopreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of email_settings_imap_pop_report, which Run
+// parses.
+var commandLine = flag.NewFlagSet("email_settings_imap_pop_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users.")
+	outputFile            = commandLine.String("output-file", "imap_pop.csv", "The csv file of each user's IMAP and POP settings to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "email_settings_imap_pop_report", "csv")
+	summaryFile           = commandLine.String("summary-file", "imap_pop_by_ou.csv", "The csv file of per-OU totals to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+const gmailSettingsScope = "https://www.googleapis.com/auth/gmail.settings.basic"
+
+type ouStats struct {
+	users, imap, pop, either, errors int
+}
+
+// Run runs email_settings_imap_pop_report with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("email_settings_imap_pop_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Gmail settings can only be read by their owner, so each user's are
+	// read as them.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, gmailSettingsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Starting report generation")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+
+	rows := [][]string{
+		{"email", "org_unit", "imap_enabled", "pop_enabled", "pop_access_window", "error"},
+	}
+	stats := map[string]*ouStats{}
+	for _, u := range users {
+		// Suspended users can't be impersonated, and can't sign in over
+		// IMAP or POP either.
+		if u.Suspended {
+			continue
+		}
+		s, ok := stats[u.OrgUnitPath]
+		if !ok {
+			s = &ouStats{}
+			stats[u.OrgUnitPath] = s
+		}
+		s.users++
+		userClient, err := impersonator.Client(ctx, u.PrimaryEmail)
+		var settings *mailSettings
+		if err == nil {
+			settings, err = getMailSettings(ctx, userClient)
+		}
+		if err != nil {
+			s.errors++
+			rows = append(rows, []string{u.PrimaryEmail, u.OrgUnitPath, "", "", "", err.Error()})
+			continue
+		}
+		pop := settings.pop.AccessWindow != "" && settings.pop.AccessWindow != "disabled"
+		if settings.imap.Enabled {
+			s.imap++
+		}
+		if pop {
+			s.pop++
+		}
+		if settings.imap.Enabled || pop {
+			s.either++
+		}
+		rows = append(rows, []string{u.PrimaryEmail, u.OrgUnitPath, strconv.FormatBool(settings.imap.Enabled),
+			strconv.FormatBool(pop), settings.pop.AccessWindow, ""})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+
+	ous := []string{}
+	for ou := range stats {
+		ous = append(ous, ou)
+	}
+	sort.Strings(ous)
+	summary := [][]string{
+		{"org_unit", "users", "imap_enabled", "pop_enabled", "either_enabled", "percent_either_enabled", "errors"},
+	}
+	for _, ou := range ous {
+		s := stats[ou]
+		percent := ""
+		if checked := s.users - s.errors; checked > 0 {
+			percent = strconv.FormatFloat(float64(s.either)*100/float64(checked), 'f', 1, 64)
+		}
+		summary = append(summary, []string{
+			ou,
+			strconv.Itoa(s.users),
+			strconv.Itoa(s.imap),
+			strconv.Itoa(s.pop),
+			strconv.Itoa(s.either),
+			percent,
+			strconv.Itoa(s.errors),
+		})
+	}
+	// -fields and -filter describe the main report; the summary only
+	// follows -format.
+	summaryOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("email_settings_imap_pop_report_by_ou")}
+	if err := summaryOptions.WriteFile(*summaryFile, summary); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+type mailSettings struct {
+	imap struct {
+		Enabled bool `json:"enabled"`
+	}
+	pop struct {
+		// AccessWindow is disabled, fromNowOn or allMail.
+		AccessWindow string `json:"accessWindow"`
+	}
+}
+
+// getMailSettings reads the impersonated user's IMAP and POP settings.
+func getMailSettings(ctx context.Context, client *http.Client) (*mailSettings, error) {
+	s := &mailSettings{}
+	const base = "https://gmail.googleapis.com/gmail/v1/users/me/settings/"
+	if err := rest.Get(ctx, client, base+"imap", &s.imap); err != nil {
+		return nil, err
+	}
+	if err := rest.Get(ctx, client, base+"pop", &s.pop); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
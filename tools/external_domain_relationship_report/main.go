@@ -0,0 +1,369 @@
+// Package externaldomainrelationshipreport is the
+// external_domain_relationship_report tool: external domains ranked by how
+// many group members, Drive shares and meeting guests tie us to them.
+package externaldomainrelationshipreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of external_domain_relationship_report, which
+// Run parses.
+var commandLine = flag.NewFlagSet("external_domain_relationship_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose groups and users are checked, or several separated by commas.")
+	internalDomainsFlag   = commandLine.String("internal-domains", "", "The organization's domains, separated by commas; any other is external. Defaults to every verified domain.")
+	sourcesFlag           = commandLine.String("sources", strings.Join(allSources, ","), "Where to look for external domains: groups, drive and calendar, separated by commas.")
+	calendarDaysFlag      = commandLine.Int("calendar-days", 90, "Count meetings from this many days ago to this many days ahead.")
+	concurrencyFlag       = commandLine.Int("concurrency", 5, "The number of users whose files and calendars are read at once.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Stop early, writing a partial report, once more than this fraction of users fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of users to attempt before -max-error-rate applies.")
+	outputFile            = commandLine.String("output-file", "external_domains.csv", "The csv file to write out.")
+	detailsFile           = commandLine.String("details-file", "", "If set, also write every group member, share and guest behind the counts to this csv file.")
+	outputOptions         = output.RegisterFlags(commandLine, "external_domain_relationship_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// relationship is everything tying us to one external domain.
+type relationship struct {
+	domain   string
+	counts   map[string]int
+	external map[string]bool
+	internal map[string]bool
+}
+
+func (r *relationship) exposure() int {
+	n := 0
+	for _, c := range r.counts {
+		n += c
+	}
+	return n
+}
+
+type byExposure []*relationship
+
+func (r byExposure) Len() int      { return len(r) }
+func (r byExposure) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byExposure) Less(i, j int) bool {
+	if a, b := r[i].exposure(), r[j].exposure(); a != b {
+		return a > b
+	}
+	return r[i].domain < r[j].domain
+}
+
+// tally collects exposures by domain. It is safe for concurrent use.
+type tally struct {
+	mu      sync.Mutex
+	domains map[string]*relationship
+	details [][]string
+}
+
+func (t *tally) add(exposures []*exposure) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range exposures {
+		r := t.domains[e.domain]
+		if r == nil {
+			r = &relationship{domain: e.domain, counts: map[string]int{}, external: map[string]bool{}, internal: map[string]bool{}}
+			t.domains[e.domain] = r
+		}
+		r.counts[e.source]++
+		r.external[strings.ToLower(e.external)] = true
+		r.internal[strings.ToLower(e.internal)] = true
+		if *detailsFile != "" {
+			t.details = append(t.details, []string{e.domain, e.source, e.internal, e.external, e.item})
+		}
+	}
+}
+
+// Run runs external_domain_relationship_report with args, the arguments
+// that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("external_domain_relationship_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	sources := map[string]bool{}
+	for _, s := range listfile.Split(strings.ToLower(*sourcesFlag)) {
+		switch s {
+		case sourceGroups, sourceDrive, sourceCalendar:
+			sources[s] = true
+		default:
+			check.Problemf("unknown source %q in -sources: use %s", s, strings.Join(allSources, ", "))
+		}
+	}
+	if len(sources) == 0 {
+		check.Problemf("-sources must name at least one source")
+	}
+	if *calendarDaysFlag < 1 {
+		check.Problemf("-calendar-days must be at least 1")
+	}
+	if *concurrencyFlag < 1 {
+		check.Problemf("-concurrency must be at least 1")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	scopes := []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope}
+	internalDomains := listfile.Split(*internalDomainsFlag)
+	if len(internalDomains) == 0 {
+		scopes = append(scopes, admin.AdminDirectoryDomainReadonlyScope)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(internalDomains) == 0 {
+		if internalDomains, err = directory.ListDomains(ctx, service); err != nil {
+			log.Fatalf("Error fetching domains: %v", err)
+		}
+	}
+	internal := map[string]bool{}
+	for _, d := range internalDomains {
+		internal[strings.ToLower(d)] = true
+	}
+
+	t := &tally{domains: map[string]*relationship{}}
+	failed := 0
+	if sources[sourceGroups] {
+		failed += tallyGroups(ctx, service, internal, t)
+	}
+
+	// Drive and Calendar only show a user's own files and meetings to that
+	// user, so each user is impersonated in turn.
+	userScopes := []string{}
+	if sources[sourceDrive] {
+		userScopes = append(userScopes, driveMetadataScope)
+	}
+	if sources[sourceCalendar] {
+		userScopes = append(userScopes, calendarEventsScope)
+	}
+	var aborted error
+	if len(userScopes) > 0 && !shutdown.Interrupted(ctx) {
+		impersonator, err := auth.NewImpersonator(*credentialsFileFlag, userScopes...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		users, err := activeUsers(ctx, service)
+		if err != nil {
+			log.Fatalf("Error fetching users: %v", err)
+		}
+		log.Printf("%d users to check", len(users))
+		var n int
+		n, aborted = tallyUsers(ctx, impersonator, users, sources, internal, t)
+		failed += n
+	}
+
+	relationships := byExposure{}
+	for _, r := range t.domains {
+		relationships = append(relationships, r)
+	}
+	sort.Sort(relationships)
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"domain", "exposure", "group_members", "drive_shares", "calendar_invites", "external_addresses", "internal_parties",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	for _, r := range relationships {
+		if err := writer.Write([]string{
+			r.domain,
+			strconv.Itoa(r.exposure()),
+			strconv.Itoa(r.counts[sourceGroups]),
+			strconv.Itoa(r.counts[sourceDrive]),
+			strconv.Itoa(r.counts[sourceCalendar]),
+			strconv.Itoa(len(r.external)),
+			strconv.Itoa(len(r.internal)),
+		}); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	interrupted := shutdown.Interrupted(ctx)
+	if interrupted {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if *detailsFile != "" {
+		details := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("external_domain_relationship_report_details")}
+		rows := append([][]string{{"domain", "source", "internal", "external", "item"}}, t.details...)
+		if err := details.WriteFile(*detailsFile, rows); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if interrupted {
+		log.Println("Interrupted: wrote partial report")
+		os.Exit(shutdown.ExitCode)
+	}
+	if aborted != nil {
+		log.Fatalf("Wrote partial report: %v", aborted)
+	}
+	log.Printf("%d external domains", len(relationships))
+	if failed > 0 {
+		log.Fatalf("Complete, but %d groups or users couldn't be read", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// tallyGroups adds the external members of every group in -domain, and
+// returns how many groups' members couldn't be listed.
+func tallyGroups(ctx context.Context, service *admin.Service, internal map[string]bool, t *tally) int {
+	failed := 0
+	for _, domain := range listfile.Split(*domainFlag) {
+		log.Printf("Fetching groups of %s", domain)
+		groups, err := directory.ListGroups(ctx, service, domain)
+		if err != nil {
+			log.Fatalf("Error fetching groups of %s: %v", domain, err)
+		}
+		for _, g := range groups {
+			if shutdown.Interrupted(ctx) {
+				return failed
+			}
+			members, err := directory.ListMembers(ctx, service, g.Email)
+			if err != nil {
+				log.Printf("Error fetching members of %s, skipping: %v", g.Email, err)
+				failed++
+				continue
+			}
+			exposures := []*exposure{}
+			for _, m := range members {
+				d := domainOf(m.Email)
+				if d == "" || internal[d] {
+					continue
+				}
+				exposures = append(exposures, &exposure{source: sourceGroups, internal: g.Email, external: m.Email, domain: d})
+			}
+			t.add(exposures)
+		}
+	}
+	return failed
+}
+
+// activeUsers returns the users of -domain who can be impersonated.
+func activeUsers(ctx context.Context, service *admin.Service) ([]string, error) {
+	emails := []string{}
+	for _, domain := range listfile.Split(*domainFlag) {
+		users, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			if !u.Suspended {
+				emails = append(emails, u.PrimaryEmail)
+			}
+		}
+	}
+	return emails, nil
+}
+
+// tallyUsers adds the Drive shares and meeting guests of each user, and
+// returns how many users failed and, if the breaker tripped, why.
+func tallyUsers(ctx context.Context, impersonator *auth.Impersonator, users []string, sources, internal map[string]bool, t *tally) (int, error) {
+	now := time.Now()
+	from, to := now.AddDate(0, 0, -*calendarDaysFlag), now.AddDate(0, 0, *calendarDaysFlag)
+	cb := breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag)
+	var mu sync.Mutex
+	failed := 0
+	var aborted error
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrencyFlag; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range jobs {
+				exposures, err := userExposures(ctx, impersonator, user, sources, from, to, internal)
+				if err != nil && shutdown.Interrupted(ctx) {
+					continue
+				}
+				tripped := cb.Record(err)
+				mu.Lock()
+				if err != nil {
+					// Drive or Calendar may be off for the user's OU;
+					// carry on with the rest.
+					log.Printf("Error reading %s, skipping: %v", user, err)
+					failed++
+				}
+				if tripped != nil && aborted == nil {
+					aborted = tripped
+				}
+				mu.Unlock()
+				t.add(exposures)
+			}
+		}()
+	}
+hand:
+	for _, user := range users {
+		if cb.Tripped() {
+			break
+		}
+		select {
+		case jobs <- user:
+		case <-ctx.Done():
+			break hand
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return failed, aborted
+}
+
+func userExposures(ctx context.Context, impersonator *auth.Impersonator, user string, sources map[string]bool, from, to time.Time, internal map[string]bool) ([]*exposure, error) {
+	client, err := impersonator.Client(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	exposures := []*exposure{}
+	if sources[sourceDrive] {
+		e, err := driveExposures(ctx, client, user, internal)
+		if err != nil {
+			return nil, fmt.Errorf("listing files: %v", err)
+		}
+		exposures = append(exposures, e...)
+	}
+	if sources[sourceCalendar] {
+		e, err := calendarExposures(ctx, client, user, from, to, internal)
+		if err != nil {
+			return nil, fmt.Errorf("listing meetings: %v", err)
+		}
+		exposures = append(exposures, e...)
+	}
+	return exposures, nil
+}
@@ -0,0 +1,161 @@
+package externaldomainrelationshipreport
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	driveMetadataScope  = "https://www.googleapis.com/auth/drive.metadata.readonly"
+	calendarEventsScope = "https://www.googleapis.com/auth/calendar.events.readonly"
+)
+
+// The places a relationship with an external domain can show up.
+const (
+	sourceGroups   = "groups"
+	sourceDrive    = "drive"
+	sourceCalendar = "calendar"
+)
+
+var allSources = []string{sourceGroups, sourceDrive, sourceCalendar}
+
+// exposure is one tie to an external domain: an external member of a
+// group, a file shared outside, or an external guest at a meeting.
+type exposure struct {
+	source string
+	// internal is the group, file owner or meeting organizer on our side.
+	internal string
+	// external is the outside address, or the domain for a Drive share
+	// with a whole domain.
+	external string
+	domain   string
+	// item names the file or meeting; it is empty for groups.
+	item string
+}
+
+// domainOf returns the lowercased domain of an address, or "" if it has
+// none.
+func domainOf(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 || i == len(email)-1 {
+		return ""
+	}
+	return strings.ToLower(email[i+1:])
+}
+
+// driveFile is a Drive file with who it is shared with.
+type driveFile struct {
+	Name        string `json:"name"`
+	Permissions []struct {
+		// Type is user, group, domain or anyone.
+		Type         string `json:"type"`
+		EmailAddress string `json:"emailAddress"`
+		Domain       string `json:"domain"`
+	} `json:"permissions"`
+}
+
+// driveExposures lists the files owner owns, as owner, and returns their
+// shares with external users, groups and domains. Link sharing with
+// anyone names no domain and isn't counted.
+func driveExposures(ctx context.Context, client *http.Client, owner string, internal map[string]bool) ([]*exposure, error) {
+	exposures := []*exposure{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Files         []*driveFile `json:"files"`
+			NextPageToken string       `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/drive/v3/", "files", url.Values{
+			"q":         {"'me' in owners and trashed = false"},
+			"fields":    {"files(name,permissions(type,emailAddress,domain)),nextPageToken"},
+			"pageSize":  {"1000"},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, f := range r.Files {
+			for _, p := range f.Permissions {
+				e := &exposure{source: sourceDrive, internal: owner, item: f.Name}
+				switch p.Type {
+				case "anyone":
+					continue
+				case "domain":
+					e.external, e.domain = p.Domain, strings.ToLower(p.Domain)
+				default:
+					e.external, e.domain = p.EmailAddress, domainOf(p.EmailAddress)
+				}
+				if e.domain != "" && !internal[e.domain] {
+					exposures = append(exposures, e)
+				}
+			}
+		}
+		if r.NextPageToken == "" {
+			return exposures, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// calendarExposures returns the external guests of the meetings organizer
+// organized on their primary calendar between from and to. Only the
+// organizer's copy of a meeting is counted, so one invite sent to several
+// of our users counts once, and a recurring meeting counts once rather
+// than for each occurrence.
+func calendarExposures(ctx context.Context, client *http.Client, organizer string, from, to time.Time, internal map[string]bool) ([]*exposure, error) {
+	exposures := []*exposure{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Items []struct {
+				Summary   string `json:"summary"`
+				Organizer struct {
+					Self bool `json:"self"`
+				} `json:"organizer"`
+				Attendees []struct {
+					Email    string `json:"email"`
+					Resource bool   `json:"resource"`
+				} `json:"attendees"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		u := rest.URL("https://www.googleapis.com/calendar/v3/", "calendars/primary/events", url.Values{
+			"timeMin":    {from.Format(time.RFC3339)},
+			"timeMax":    {to.Format(time.RFC3339)},
+			"fields":     {"items(summary,organizer/self,attendees(email,resource)),nextPageToken"},
+			"maxResults": {"2500"},
+			"pageToken":  {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		for _, ev := range r.Items {
+			if !ev.Organizer.Self {
+				continue
+			}
+			for _, a := range ev.Attendees {
+				domain := domainOf(a.Email)
+				if a.Resource || domain == "" || internal[domain] {
+					continue
+				}
+				exposures = append(exposures, &exposure{
+					source:   sourceCalendar,
+					internal: organizer,
+					external: a.Email,
+					domain:   domain,
+					item:     ev.Summary,
+				})
+			}
+		}
+		if r.NextPageToken == "" {
+			return exposures, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
@@ -0,0 +1,114 @@
+package gat
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+)
+
+var convertCommand = &command{
+	name:    "convert",
+	usage:   "[-report name] [-output-file file] report.csv",
+	summary: "Migrate a report written by an older release to the current schema.",
+}
+
+func init() {
+	convertCommand.run = runConvert
+}
+
+func runConvert(args []string) error {
+	fs := newFlagSet(convertCommand)
+	report := fs.String("report", "", "The report the file holds, for files written before reports were stamped: one of "+strings.Join(schema.Names(), ", ")+".")
+	outputFile := fs.String("output-file", "", "The file to write. Defaults to replacing the input.")
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	path := positional[0]
+	if *outputFile == "" {
+		*outputFile = path
+	}
+
+	format := "csv"
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		format = "tsv"
+	}
+	t, stamp, err := readReport(path, format)
+	if err != nil {
+		return err
+	}
+
+	name, version := *report, 1
+	if stamp != "" {
+		if name, version, err = schema.Parse(stamp); err != nil {
+			return err
+		}
+		if *report != "" && *report != name {
+			return fmt.Errorf("%s is a %s report, not %s", path, name, *report)
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("%s has no schema stamp: say which report it is with -report", path)
+	}
+	current, err := schema.Migrate(name, version, t)
+	if err != nil {
+		return err
+	}
+
+	opts := &output.Options{Format: format, Schema: current}
+	if err := opts.WriteFile(*outputFile, append([][]string{t.Header}, t.Rows...)); err != nil {
+		return err
+	}
+	log.Printf("Converted %s from %s/%d to %s in %s", path, name, version, current, *outputFile)
+	return nil
+}
+
+// readReport reads a csv or tsv report, returning it without its stamp
+// column and the stamp it carried, if any.
+func readReport(path, format string) (*schema.Table, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	if format == "tsv" {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't read %s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, "", fmt.Errorf("%s is empty", path)
+	}
+	t := &schema.Table{Header: records[0], Rows: records[1:]}
+	i := t.Index(schema.Column)
+	if i < 0 {
+		return t, "", nil
+	}
+	// Every row carries the same stamp, so a file with no rows has none.
+	stamp := ""
+	if len(t.Rows) > 0 && i < len(t.Rows[0]) {
+		stamp = t.Rows[0][i]
+	}
+	t.Header = remove(t.Header, i)
+	for n, row := range t.Rows {
+		t.Rows[n] = remove(row, i)
+	}
+	return t, stamp, nil
+}
+
+func remove(s []string, i int) []string {
+	if i >= len(s) {
+		return s
+	}
+	return append(s[:i:i], s[i+1:]...)
+}
@@ -0,0 +1,288 @@
+package gat
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jburnham/google_apps_tools/pkg/catalog"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+)
+
+var generateCommand = &command{
+	name:    "generate",
+	usage:   "k8s-cronjob -image image -impersonated-email admin@ [-set flag=value]... [flags] tool",
+	summary: "Write the Kubernetes manifests that run a report on a schedule.",
+}
+
+func init() {
+	generateCommand.run = runGenerate
+}
+
+// Where the generated CronJob mounts the service account key and the
+// -pvc volume.
+const (
+	keyDir     = "/var/run/secrets/google-apps-tools"
+	keyFile    = keyDir + "/credentials.json"
+	reportsDir = "/reports"
+)
+
+// reservedSettings are the tool flags the manifest sets itself.
+var reservedSettings = map[string]bool{"credentials-file": true, "auth-mode": true, "credentials-secret": true, "credentials-env": true, "config": true}
+
+// settingsValue collects repeated -set flag=value arguments.
+type settingsValue map[string]string
+
+func (v settingsValue) String() string {
+	pairs := []string{}
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+func (v settingsValue) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("%q is not flag=value", s)
+	}
+	v[strings.TrimLeft(s[:i], "-")] = s[i+1:]
+	return nil
+}
+
+// cronJob is what the manifest template is given.
+type cronJob struct {
+	Tool, Name, Namespace string
+	Schedule, Image       string
+	Secret                string
+	// ServiceAccount is the Kubernetes service account of a keyless job,
+	// bound to a Google one through Workload Identity.
+	ServiceAccount string
+	PVC            string
+	// Settings are the tool's flags, kept in the ConfigMap so they can be
+	// changed without touching the CronJob.
+	Settings []setting
+}
+
+type setting struct {
+	Flag, Value string
+}
+
+// Env is the variable the CronJob reads the setting into.
+func (s setting) Env() string {
+	return "GAT_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(s.Flag))
+}
+
+func runGenerate(args []string) error {
+	fs := newFlagSet(generateCommand)
+	image := fs.String("image", "", "The container image holding the tool binaries on its PATH.")
+	schedule := fs.String("schedule", "0 6 * * *", "When to run, in cron syntax (the cluster's time zone, usually UTC).")
+	name := fs.String("name", "", "The name of the CronJob and its ConfigMap. Defaults to the tool's, with - for _.")
+	namespace := fs.String("namespace", "default", "The namespace to deploy into.")
+	impersonatedEmail := fs.String("impersonated-email", "", "The admin user email the tool impersonates.")
+	secret := fs.String("secret", "google-apps-tools-key", "The Secret holding the service account key as credentials.json.")
+	keyless := fs.Bool("keyless", false, "Authenticate as the pod's own Google service account through Workload Identity (-auth-mode adc) instead of a key.")
+	serviceAccount := fs.String("k8s-service-account", "", "With -keyless, the Kubernetes service account bound to the Google one.")
+	outputPrefix := fs.String("output-prefix", "", "Where the reports go, e.g. gs://bucket/workspace/: each of the tool's output files is written under it.")
+	pvc := fs.String("pvc", "", "A PersistentVolumeClaim to mount at "+reportsDir+" and run in, so the reports are written to it.")
+	outputFile := fs.String("output-file", "-", "The file to write the manifests to, - for standard output.")
+	settings := settingsValue{}
+	fs.Var(settings, "set", "A flag to run the tool with, as flag=value, e.g. -set domain=example.com. Repeatable.")
+	if len(args) == 0 || args[0] != "k8s-cronjob" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	check := config.Parse(fs, args[1:])
+	var tool *catalog.Tool
+	switch fs.NArg() {
+	case 1:
+		tool = catalog.Lookup(fs.Arg(0))
+		switch {
+		case tool == nil:
+			check.Problemf("no tool %q", fs.Arg(0))
+		case tool.Name == "gat":
+			check.Problemf("gat itself can't be scheduled")
+		case tool.Kind != catalog.KindReport:
+			// Tools that change the directory need a person at the wheel.
+			check.Problemf("%s is a %s tool; only reports can be scheduled", tool.Name, tool.Kind)
+		}
+	default:
+		check.Problemf("name one tool to schedule")
+	}
+	if *image == "" {
+		check.Problemf("-image is required")
+	}
+	if *impersonatedEmail == "" {
+		check.Problemf("-impersonated-email is required")
+	}
+	if *serviceAccount != "" && !*keyless {
+		check.Problemf("-k8s-service-account needs -keyless")
+	}
+	if *outputPrefix == "" && *pvc == "" {
+		check.Problemf("one of -output-prefix or -pvc is required, or the reports are lost with the pod")
+	}
+	for flag := range settings {
+		if reservedSettings[flag] {
+			check.Problemf("-set %s can't be used: the manifest sets it", flag)
+		}
+	}
+	check.Done()
+
+	if _, ok := settings["impersonated-email"]; !ok {
+		settings["impersonated-email"] = *impersonatedEmail
+	}
+	if *outputPrefix != "" {
+		prefix := *outputPrefix
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		for _, o := range tool.Outputs {
+			if _, ok := settings[o.Flag]; !ok && o.Flag != "" && o.Default != "" {
+				settings[o.Flag] = prefix + o.Default
+			}
+		}
+	}
+	job := &cronJob{
+		Tool:      tool.Name,
+		Name:      *name,
+		Namespace: *namespace,
+		Schedule:  *schedule,
+		Image:     *image,
+		PVC:       *pvc,
+	}
+	if job.Name == "" {
+		job.Name = strings.Replace(tool.Name, "_", "-", -1)
+	}
+	if *keyless {
+		job.ServiceAccount = *serviceAccount
+	} else {
+		job.Secret = *secret
+	}
+	flags := []string{}
+	for flag := range settings {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		job.Settings = append(job.Settings, setting{flag, settings[flag]})
+	}
+
+	w := io.Writer(os.Stdout)
+	if *outputFile != "-" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return cronJobTemplate.Execute(w, job)
+}
+
+// cronJobTemplate is the ConfigMap and CronJob. Every value is quoted,
+// which also keeps YAML from reading e.g. "true" or "0700" as non-strings.
+var cronJobTemplate = template.Must(template.New("cronjob").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(`# Generated by gat generate k8s-cronjob for {{.Tool}}.
+{{- if .Secret}}
+# The service account key must be in a Secret first:
+#   kubectl -n {{.Namespace}} create secret generic {{.Secret}} --from-file=credentials.json=key.json
+{{- end}}
+# Change the tool's flags by editing the ConfigMap.
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.Name}}-config
+  namespace: {{.Namespace}}
+  labels:
+    app.kubernetes.io/name: {{.Name}}
+    app.kubernetes.io/part-of: google-apps-tools
+data:
+{{- range .Settings}}
+  {{.Flag}}: {{quote .Value}}
+{{- end}}
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+  labels:
+    app.kubernetes.io/name: {{.Name}}
+    app.kubernetes.io/part-of: google-apps-tools
+spec:
+  schedule: {{quote .Schedule}}
+  # A run still going when the next is due is left to finish.
+  concurrencyPolicy: Forbid
+  successfulJobsHistoryLimit: 3
+  failedJobsHistoryLimit: 3
+  jobTemplate:
+    spec:
+      # The tools retry API calls themselves; a failed run waits for the next.
+      backoffLimit: 0
+      template:
+        metadata:
+          labels:
+            app.kubernetes.io/name: {{.Name}}
+            app.kubernetes.io/part-of: google-apps-tools
+        spec:
+          restartPolicy: Never
+{{- if .ServiceAccount}}
+          serviceAccountName: {{.ServiceAccount}}
+{{- end}}
+          containers:
+            - name: {{.Name}}
+              image: {{quote .Image}}
+              command: [{{quote .Tool}}]
+              args:
+{{- if .Secret}}
+                - "-credentials-file=` + keyFile + `"
+{{- else}}
+                - "-auth-mode=adc"
+{{- end}}
+{{- range .Settings}}
+                - "-{{.Flag}}=$({{.Env}})"
+{{- end}}
+{{- if .PVC}}
+              workingDir: ` + reportsDir + `
+{{- end}}
+              env:
+{{- if .Secret}}
+                # gs:// and other uploads use Application Default Credentials.
+                - name: GOOGLE_APPLICATION_CREDENTIALS
+                  value: ` + keyFile + `
+{{- end}}
+{{- range .Settings}}
+                - name: {{.Env}}
+                  valueFrom:
+                    configMapKeyRef:
+                      name: {{$.Name}}-config
+                      key: {{.Flag}}
+{{- end}}
+{{- if or .Secret .PVC}}
+              volumeMounts:
+{{- if .Secret}}
+                - name: google-key
+                  mountPath: ` + keyDir + `
+                  readOnly: true
+{{- end}}
+{{- if .PVC}}
+                - name: reports
+                  mountPath: ` + reportsDir + `
+{{- end}}
+          volumes:
+{{- if .Secret}}
+            - name: google-key
+              secret:
+                secretName: {{.Secret}}
+{{- end}}
+{{- if .PVC}}
+            - name: reports
+              persistentVolumeClaim:
+                claimName: {{.PVC}}
+{{- end}}
+{{- end}}
+`))
@@ -0,0 +1,136 @@
+package gat
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/graph"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+)
+
+const defaultSnapshot = "membership_snapshot.json"
+
+var snapshotCommand = &command{
+	name:    "snapshot",
+	usage:   "-credentials-file key.json -impersonated-email admin@example.com -domain example.com",
+	summary: "Fetch the group membership graph and cache it for whohas and memberof.",
+}
+
+var whohasCommand = &command{
+	name:    "whohas",
+	usage:   "[-snapshot file] group@example.com",
+	summary: "List everyone who is effectively a member of a group, including through nested groups.",
+}
+
+var memberofCommand = &command{
+	name:    "memberof",
+	usage:   "[-snapshot file] [-effective] user@example.com",
+	summary: "List the groups a user or group belongs to.",
+}
+
+// The run functions refer back to their command for usage, so they are
+// attached here rather than in the literals to avoid an initialization loop.
+func init() {
+	snapshotCommand.run = runSnapshot
+	whohasCommand.run = runWhohas
+	memberofCommand.run = runMemberof
+}
+
+func runSnapshot(args []string) error {
+	fs := newFlagSet(snapshotCommand)
+	credentialsFile := fs.String("credentials-file", "", "The json file from Google that contains the service account private material.")
+	impersonatedEmail := fs.String("impersonated-email", "", "The admin user email to impersonate for access.")
+	domain := fs.String("domain", "", "The domain to query for groups.")
+	snapshot := fs.String("snapshot", defaultSnapshot, "The snapshot file to write.")
+	check := config.Parse(fs, args)
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFile, *impersonatedEmail,
+		admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryGroupMemberReadonlyScope)
+	if err != nil {
+		return err
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		return err
+	}
+	log.Println("Fetching membership graph")
+	g, err := graph.Fetch(ctx, service, *domain)
+	if err != nil {
+		return err
+	}
+	if err := graph.Save(*snapshot, g); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d nodes and %d memberships to %s", len(g.Nodes), len(g.Edges), *snapshot)
+	return nil
+}
+
+func runWhohas(args []string) error {
+	fs := newFlagSet(whohasCommand)
+	snapshot := fs.String("snapshot", defaultSnapshot, "The snapshot file written by gat snapshot.")
+	opts := output.RegisterFlags(fs, "gat_whohas", "tsv")
+	pos := parseInterspersed(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	g, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+	return printResults(opts, "member", g.EffectiveMembers(pos[0]))
+}
+
+func runMemberof(args []string) error {
+	fs := newFlagSet(memberofCommand)
+	snapshot := fs.String("snapshot", defaultSnapshot, "The snapshot file written by gat snapshot.")
+	effective := fs.Bool("effective", false, "Include groups the member belongs to through nested groups.")
+	opts := output.RegisterFlags(fs, "gat_memberof", "tsv")
+	pos := parseInterspersed(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	g, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+	return printResults(opts, "group", g.MemberOf(pos[0], *effective))
+}
+
+func loadSnapshot(path string) (*graph.Graph, error) {
+	g, err := graph.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("%v (run gat snapshot first)", err)
+	}
+	if age := time.Since(g.Fetched); age > 24*time.Hour {
+		log.Printf("Warning: snapshot is %s old", age/time.Hour*time.Hour)
+	}
+	return g, nil
+}
+
+// printResults writes one row per result to stdout; via lists the
+// intermediate groups, nearest the queried node first.
+func printResults(opts *output.Options, column string, results []graph.Result) error {
+	w, err := opts.NewWriter(os.Stdout, []string{column, "via"})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := w.Write([]string{r.Key, r.Path.String()}); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
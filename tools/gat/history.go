@@ -0,0 +1,148 @@
+package gat
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+)
+
+var groupCommand = &command{
+	name:    "group",
+	usage:   "history -credentials-file key.json -impersonated-email admin@example.com [-member user@] group@example.com",
+	summary: "Show a group's audit history: creation, setting changes and membership changes, oldest first.",
+}
+
+func init() {
+	groupCommand.run = runGroup
+}
+
+// historySources are the audit logs that record changes to a group: the
+// admin log (the Admin console and Directory API) filtered on its
+// GROUP_EMAIL parameter, and the groups log (the Groups web UI) on its
+// group_email one.
+var historySources = []struct {
+	application, groupParam string
+}{
+	{"admin", "GROUP_EMAIL"},
+	{"groups", "group_email"},
+}
+
+// The parameters that hold an event's member, role, setting and values,
+// which differ between the two logs.
+var (
+	memberParams  = []string{"USER_EMAIL", "user_email", "member_email"}
+	roleParams    = []string{"MEMBER_ROLE", "member_role"}
+	settingParams = []string{"SETTING_NAME", "acl_permission", "info_setting"}
+	oldParams     = []string{"OLD_VALUE", "old_value", "old_value_repeated"}
+	newParams     = []string{"NEW_VALUE", "new_value", "value", "value_repeated"}
+)
+
+// historyEvent is one row of the timeline.
+type historyEvent struct {
+	time string
+	row  []string
+}
+
+type byTime []*historyEvent
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTime) Less(i, j int) bool { return s[i].time < s[j].time }
+
+func runGroup(args []string) error {
+	fs := newFlagSet(groupCommand)
+	credentialsFile := fs.String("credentials-file", "", "The json file from Google that contains the service account private material.")
+	impersonatedEmail := fs.String("impersonated-email", "", "The admin user email to impersonate for access.")
+	member := fs.String("member", "", "Only show events about this member, e.g. to find who added them.")
+	dateRange := reports.RegisterDateFlags(fs, "180d")
+	opts := output.RegisterFlags(fs, "gat_group_history", "tsv")
+	if len(args) == 0 || args[0] != "history" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	check := config.Parse(fs, args[1:])
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(dateRange.Check())
+	check.Check(opts.CheckFormat())
+	if fs.NArg() != 1 {
+		check.Problemf("name one group")
+	}
+	check.Done()
+	group := fs.Arg(0)
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFile, *impersonatedEmail, reports.AuditReadonlyScope)
+	if err != nil {
+		return err
+	}
+	events := []*historyEvent{}
+	for _, source := range historySources {
+		q := reports.ActivityQuery{Application: source.application, Filters: source.groupParam + "==" + group}
+		if err := dateRange.Apply(&q); err != nil {
+			return err
+		}
+		activities, err := reports.Activities(ctx, client, q)
+		if err != nil {
+			return fmt.Errorf("fetching the %s audit log: %v", source.application, err)
+		}
+		for _, a := range activities {
+			for i := range a.Events {
+				e := &a.Events[i]
+				if !strings.EqualFold(e.Param(source.groupParam), group) {
+					continue
+				}
+				m := firstParam(e, memberParams)
+				if *member != "" && !strings.EqualFold(m, *member) {
+					continue
+				}
+				events = append(events, &historyEvent{time: a.ID.Time, row: []string{
+					a.ID.Time,
+					a.Actor.Email,
+					source.application,
+					e.Name,
+					m,
+					firstParam(e, roleParams),
+					firstParam(e, settingParams),
+					firstParam(e, oldParams),
+					firstParam(e, newParams),
+				}})
+			}
+		}
+	}
+	// Both logs come newest first; a timeline reads the other way.
+	sort.Stable(byTime(events))
+	log.Printf("%d events for %s from %s", len(events), group, dateRange)
+
+	w, err := opts.NewWriter(os.Stdout, []string{"time", "actor", "log", "event", "member", "role", "setting", "old_value", "new_value"})
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := w.Write(e.row); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// firstParam returns the first of the named parameters e has.
+func firstParam(e *reports.Event, names []string) string {
+	for _, name := range names {
+		for _, p := range e.Parameters {
+			if p.Name == name {
+				return e.Param(name)
+			}
+		}
+	}
+	return ""
+}
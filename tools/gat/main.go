@@ -0,0 +1,100 @@
+// Package gat answers questions about a Google Apps domain from cached
+// snapshots, and hosts the smaller utilities that don't warrant a tool of
+// their own.
+package gat
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// command is a gat subcommand. run receives the arguments following the
+// command name.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+// commandLine holds the flags of gat, which Run parses.
+var commandLine = flag.NewFlagSet("gat", flag.ExitOnError)
+
+var commands = []*command{
+	snapshotCommand,
+	whohasCommand,
+	memberofCommand,
+	whatifCommand,
+	convertCommand,
+	reportsCommand,
+	generateCommand,
+	groupCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gat <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `Run "gat <command> -h" for a command's flags.`)
+}
+
+// Run runs gat with args, the arguments that follow its name.
+func Run(args []string) error {
+	log.SetFlags(0)
+	if len(args) < 1 {
+		usage()
+		os.Exit(1)
+	}
+	name := args[0]
+	switch name {
+	case "-version", "--version", "version":
+		fmt.Println("gat", version.Git)
+		return nil
+	case "-h", "-help", "--help", "help":
+		usage()
+		return nil
+	}
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args[1:]); err != nil {
+				return fmt.Errorf("gat %s: %v", name, err)
+			}
+			return nil
+		}
+	}
+	usage()
+	return fmt.Errorf("gat: unknown command %q", name)
+}
+
+// newFlagSet returns a flag set for c that prints c's usage line on error.
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet("gat "+c.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: gat %s %s\n\n%s\n\n", c.name, c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseInterspersed parses args allowing flags after positional arguments,
+// so "gat memberof user@example.com -effective" works as expected.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
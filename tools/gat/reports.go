@@ -0,0 +1,75 @@
+package gat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jburnham/google_apps_tools/pkg/catalog"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+)
+
+var reportsCommand = &command{
+	name:    "reports",
+	usage:   "list [-format json|text] [-kind report|sync|service] [tool...]",
+	summary: "Describe the tools: their scopes, typical runtime and output columns.",
+}
+
+func init() {
+	reportsCommand.run = runReports
+}
+
+func runReports(args []string) error {
+	fs := newFlagSet(reportsCommand)
+	format := fs.String("format", "json", "The output format: json or text.")
+	kind := fs.String("kind", "", "Only list tools of this kind: "+strings.Join([]string{catalog.KindReport, catalog.KindSync, catalog.KindService}, ", ")+".")
+	if len(args) == 0 || args[0] != "list" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	check := config.Parse(fs, args[1:])
+	if *format != "json" && *format != "text" {
+		check.Problemf("unknown format %q: use json or text", *format)
+	}
+	switch *kind {
+	case "", catalog.KindReport, catalog.KindSync, catalog.KindService:
+	default:
+		check.Problemf("unknown kind %q", *kind)
+	}
+	tools := []*catalog.Tool{}
+	for _, name := range fs.Args() {
+		t := catalog.Lookup(name)
+		if t == nil {
+			check.Problemf("no tool %q", name)
+			continue
+		}
+		tools = append(tools, t)
+	}
+	check.Done()
+	if len(tools) == 0 {
+		tools = catalog.Tools
+	}
+	selected := []*catalog.Tool{}
+	for _, t := range tools {
+		if *kind == "" || t.Kind == *kind {
+			selected = append(selected, t)
+		}
+	}
+
+	if *format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TOOL\tKIND\tRUNTIME\tSUMMARY")
+		for _, t := range selected {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, t.Kind, t.Runtime, t.Summary)
+		}
+		return w.Flush()
+	}
+	data, err := json.MarshalIndent(selected, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
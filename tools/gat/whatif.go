@@ -0,0 +1,121 @@
+package gat
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/graph"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+)
+
+var whatifCommand = &command{
+	name:    "whatif",
+	usage:   "[-snapshot file] changes.csv",
+	summary: "Show the access each user would gain or lose from a set of membership changes, before applying them.",
+}
+
+func init() {
+	whatifCommand.run = runWhatif
+}
+
+func runWhatif(args []string) error {
+	fs := newFlagSet(whatifCommand)
+	snapshot := fs.String("snapshot", defaultSnapshot, "The snapshot file written by gat snapshot.")
+	opts := output.RegisterFlags(fs, "gat_whatif", "tsv")
+	pos := parseInterspersed(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	changes, err := readChanges(pos[0])
+	if err != nil {
+		return err
+	}
+	g, err := loadSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+	diffs, err := g.Simulate(changes)
+	if err != nil {
+		return err
+	}
+
+	w, err := opts.NewWriter(os.Stdout, []string{"member", "group", "change", "via"})
+	if err != nil {
+		return err
+	}
+	gained, lost := map[string]bool{}, map[string]bool{}
+	for _, d := range diffs {
+		change := "lost"
+		if d.Gained {
+			change = "gained"
+			gained[d.Member] = true
+		} else {
+			lost[d.Member] = true
+		}
+		if err := w.Write([]string{d.Member, d.Group, change, d.Path.String()}); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	log.Printf("%d changes: %d members gain access, %d lose it", len(changes), len(gained), len(lost))
+	return nil
+}
+
+// readChanges reads a change set: a csv with action (add or remove), group
+// and member columns, and optionally type (USER or GROUP) for members the
+// snapshot doesn't know yet.
+func readChanges(path string) ([]*graph.Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	cols := map[string]int{}
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"action", "group", "member"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("%s has no %q column", path, name)
+		}
+	}
+	get := func(r []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	changes := []*graph.Change{}
+	for n, r := range records[1:] {
+		c := &graph.Change{
+			Group:  get(r, "group"),
+			Member: get(r, "member"),
+			Type:   strings.ToUpper(get(r, "type")),
+		}
+		switch action := strings.ToLower(get(r, "action")); action {
+		case "add":
+		case "remove":
+			c.Remove = true
+		default:
+			return nil, fmt.Errorf("%s line %d: unknown action %q: use add or remove", path, n+2, action)
+		}
+		if c.Group == "" || c.Member == "" {
+			return nil, fmt.Errorf("%s line %d: group and member are required", path, n+2)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
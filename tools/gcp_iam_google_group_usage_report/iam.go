@@ -0,0 +1,58 @@
+package gcpiamgooglegroupusagereport
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	cloudPlatformReadonlyScope = "https://www.googleapis.com/auth/cloud-platform.read-only"
+	resourceManagerBase        = "https://cloudresourcemanager.googleapis.com/v3/"
+)
+
+// policy is an IAM policy as getIamPolicy returns it.
+type policy struct {
+	Bindings []struct {
+		Role      string   `json:"role"`
+		Members   []string `json:"members"`
+		Condition *struct {
+			Title      string `json:"title"`
+			Expression string `json:"expression"`
+		} `json:"condition"`
+	} `json:"bindings"`
+}
+
+// getPolicy fetches the IAM policy on resource, such as
+// "organizations/123" or "projects/my-project". Version 3 is asked for so
+// conditional bindings come back with their conditions.
+func getPolicy(ctx context.Context, client *http.Client, resource string) (*policy, error) {
+	in := map[string]interface{}{"options": map[string]int{"requestedPolicyVersion": 3}}
+	p := &policy{}
+	if err := rest.Do(ctx, client, "POST", resourceManagerBase+resource+":getIamPolicy", in, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// groupMember returns the group address an IAM member names, and whether
+// the group has since been deleted. ok is false for any other kind of
+// member.
+func groupMember(member string) (group string, deleted, ok bool) {
+	if strings.HasPrefix(member, "deleted:") {
+		member = strings.TrimPrefix(member, "deleted:")
+		deleted = true
+	}
+	if !strings.HasPrefix(member, "group:") {
+		return "", false, false
+	}
+	group = strings.TrimPrefix(member, "group:")
+	// Deleted principals carry the ID they had, as ?uid=.
+	if i := strings.Index(group, "?"); i >= 0 {
+		group = group[:i]
+	}
+	return strings.ToLower(group), deleted, true
+}
@@ -0,0 +1,164 @@
+// Package gcpiamgooglegroupusagereport is the
+// gcp_iam_google_group_usage_report tool: iAM bindings on GCP
+// organizations, folders and projects that name Google Groups, with each
+// group's members.
+package gcpiamgooglegroupusagereport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of gcp_iam_google_group_usage_report, which
+// Run parses.
+var commandLine = flag.NewFlagSet("gcp_iam_google_group_usage_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	organizationsFlag     = commandLine.String("organizations", "", "Comma separated GCP organization IDs whose IAM policies are scanned.")
+	foldersFlag           = commandLine.String("folders", "", "Comma separated GCP folder IDs whose IAM policies are scanned.")
+	projectsFlag          = commandLine.String("projects", "", "Comma separated GCP project IDs whose IAM policies are scanned. The credentials' own service account needs getIamPolicy on each resource.")
+	listMembersFlag       = commandLine.Bool("list-members", false, "Add a members column listing each group's direct members.")
+	outputFile            = commandLine.String("output-file", "gcp_iam_groups.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "gcp_iam_google_group_usage_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// membership is what the directory says about one group.
+type membership struct {
+	members []string
+	err     error
+}
+
+// Run runs gcp_iam_google_group_usage_report with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("gcp_iam_google_group_usage_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.RequireOne("organizations", "folders", "projects")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	// IAM policies are read as the service account itself, memberships as
+	// the impersonated admin.
+	gcpClient, err := auth.ClientFromFile(ctx, *credentialsFileFlag, "", cloudPlatformReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryGroupMemberReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resources := []string{}
+	for _, r := range []struct{ kind, list string }{
+		{"organizations", *organizationsFlag},
+		{"folders", *foldersFlag},
+		{"projects", *projectsFlag},
+	} {
+		for _, id := range listfile.Split(r.list) {
+			resources = append(resources, r.kind+"/"+id)
+		}
+	}
+
+	header := []string{"resource", "role", "group", "condition", "member_count"}
+	if *listMembersFlag {
+		header = append(header, "members")
+	}
+	rows := [][]string{append(header, "error")}
+	groups := map[string]*membership{}
+	for _, resource := range resources {
+		log.Printf("Fetching the IAM policy of %s", resource)
+		p, err := getPolicy(ctx, gcpClient, resource)
+		if err != nil {
+			log.Fatalf("Error fetching the IAM policy of %s: %v", resource, err)
+		}
+		for _, b := range p.Bindings {
+			condition := ""
+			if b.Condition != nil {
+				condition = b.Condition.Title
+				if condition == "" {
+					condition = b.Condition.Expression
+				}
+			}
+			for _, member := range b.Members {
+				group, deleted, ok := groupMember(member)
+				if !ok {
+					continue
+				}
+				m := &membership{err: fmt.Errorf("group deleted")}
+				if !deleted {
+					if groups[group] == nil {
+						log.Printf("Fetching members of %s", group)
+						groups[group] = fetchMembership(ctx, service, group)
+					}
+					m = groups[group]
+				}
+				row := []string{resource, b.Role, group, condition, ""}
+				if m.err == nil {
+					row[4] = strconv.Itoa(len(m.members))
+				}
+				if *listMembersFlag {
+					row = append(row, strings.Join(m.members, ";"))
+				}
+				errText := ""
+				if m.err != nil {
+					errText = m.err.Error()
+				}
+				rows = append(rows, append(row, errText))
+			}
+		}
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d group bindings across %d resources", len(rows)-1, len(resources))
+	log.Println("Complete")
+	return nil
+}
+
+// fetchMembership lists a group's direct members. Groups outside the
+// customer can't be listed, which is recorded rather than fatal: their
+// bindings are often the ones most worth a look.
+func fetchMembership(ctx context.Context, service *admin.Service, group string) *membership {
+	list, err := directory.ListMembers(ctx, service, group)
+	if err != nil {
+		return &membership{err: err}
+	}
+	members := []string{}
+	for _, m := range list {
+		members = append(members, strings.ToLower(m.Email))
+	}
+	sort.Strings(members)
+	return &membership{members: members}
+}
@@ -0,0 +1,105 @@
+package gmailsettingsreport
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	gmailSettingsScope = "https://www.googleapis.com/auth/gmail.settings.basic"
+	gmailSettingsPath  = "https://gmail.googleapis.com/gmail/v1/users/me/settings/"
+)
+
+// delegate is someone who can read and send as the user.
+type delegate struct {
+	DelegateEmail string `json:"delegateEmail"`
+	// VerificationStatus is accepted, pending, rejected or expired.
+	VerificationStatus string `json:"verificationStatus"`
+}
+
+type autoForwarding struct {
+	Enabled      bool   `json:"enabled"`
+	EmailAddress string `json:"emailAddress"`
+	// Disposition is what happens to the user's copy: leaveInInbox,
+	// archive, trash or markRead.
+	Disposition string `json:"disposition"`
+}
+
+type filter struct {
+	ID       string `json:"id"`
+	Criteria struct {
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Query   string `json:"query"`
+	} `json:"criteria"`
+	Action struct {
+		Forward string `json:"forward"`
+	} `json:"action"`
+}
+
+// criteria describes what mail the filter matches, in Gmail's search
+// syntax.
+func (f *filter) criteria() string {
+	c := f.Criteria
+	parts := []string{}
+	for _, p := range []struct{ op, value string }{{"from:", c.From}, {"to:", c.To}, {"subject:", c.Subject}, {"", c.Query}} {
+		if p.value != "" {
+			parts = append(parts, p.op+p.value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// sendAs is an address the user can send mail as. SmtpMsa is set when the
+// mail goes out through another provider's server rather than Gmail's.
+type sendAs struct {
+	SendAsEmail        string `json:"sendAsEmail"`
+	IsPrimary          bool   `json:"isPrimary"`
+	VerificationStatus string `json:"verificationStatus"`
+	SmtpMsa            *struct {
+		Host string `json:"host"`
+	} `json:"smtpMsa"`
+}
+
+// mailSettings are the settings of a user's mailbox that can send their
+// mail to someone else.
+type mailSettings struct {
+	delegates      []*delegate
+	autoForwarding *autoForwarding
+	filters        []*filter
+	sendAs         []*sendAs
+}
+
+// getMailSettings reads the impersonated user's delegation, forwarding,
+// filter and send-as settings.
+func getMailSettings(ctx context.Context, client *http.Client) (*mailSettings, error) {
+	s := &mailSettings{autoForwarding: &autoForwarding{}}
+	delegates := &struct {
+		Delegates []*delegate `json:"delegates"`
+	}{}
+	if err := rest.Get(ctx, client, gmailSettingsPath+"delegates", delegates); err != nil {
+		return nil, err
+	}
+	if err := rest.Get(ctx, client, gmailSettingsPath+"autoForwarding", s.autoForwarding); err != nil {
+		return nil, err
+	}
+	filters := &struct {
+		Filter []*filter `json:"filter"`
+	}{}
+	if err := rest.Get(ctx, client, gmailSettingsPath+"filters", filters); err != nil {
+		return nil, err
+	}
+	sendAs := &struct {
+		SendAs []*sendAs `json:"sendAs"`
+	}{}
+	if err := rest.Get(ctx, client, gmailSettingsPath+"sendAs", sendAs); err != nil {
+		return nil, err
+	}
+	s.delegates, s.filters, s.sendAs = delegates.Delegates, filters.Filter, sendAs.SendAs
+	return s, nil
+}
@@ -0,0 +1,255 @@
+// Package gmailsettingsreport is the gmail_settings_report tool: each
+// user's mail delegates, auto-forwarding, forwarding filters and send-as
+// aliases, flagging those outside the organization.
+package gmailsettingsreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of gmail_settings_report, which Run parses.
+var commandLine = flag.NewFlagSet("gmail_settings_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users' mailboxes are checked, or several separated by commas.")
+	usersFlag             = commandLine.String("users", "", "Comma separated users whose mailboxes are checked, instead of every active user in -domain.")
+	internalDomainsFlag   = commandLine.String("internal-domains", "", "The organization's domains, separated by commas; mail sent to any other is external. Defaults to every verified domain.")
+	externalOnlyFlag      = commandLine.Bool("external-only", false, "Only write the settings that send mail outside -internal-domains, and errors.")
+	concurrencyFlag       = commandLine.Int("concurrency", 5, "The number of users whose settings are read at once.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Stop early, writing a partial report, once more than this fraction of users' settings can't be read (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of users to attempt before -max-error-rate applies.")
+	outputFile            = commandLine.String("output-file", "gmail_settings.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "gmail_settings_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs gmail_settings_report with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("gmail_settings_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	if *domainFlag == "" && *usersFlag == "" {
+		check.Problemf("one of -domain or -users is required")
+	}
+	if *concurrencyFlag < 1 {
+		check.Problemf("-concurrency must be at least 1")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	scopes := []string{admin.AdminDirectoryUserReadonlyScope}
+	internalDomains := listfile.Split(*internalDomainsFlag)
+	if len(internalDomains) == 0 {
+		scopes = append(scopes, admin.AdminDirectoryDomainReadonlyScope)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Gmail settings can only be read by their owner, so each user's are
+	// read as them.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, gmailSettingsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(internalDomains) == 0 {
+		if internalDomains, err = directory.ListDomains(ctx, service); err != nil {
+			log.Fatalf("Error fetching domains: %v", err)
+		}
+	}
+	internal := map[string]bool{}
+	for _, d := range internalDomains {
+		internal[strings.ToLower(d)] = true
+	}
+
+	// orgUnits is only filled in for users listed from -domain.
+	users := listfile.Split(*usersFlag)
+	orgUnits := map[string]string{}
+	if len(users) == 0 {
+		log.Println("Fetching users")
+		for _, domain := range listfile.Split(*domainFlag) {
+			all, err := directory.ListUsers(ctx, service, domain, "")
+			if err != nil {
+				log.Fatalf("Error fetching users of %s: %v", domain, err)
+			}
+			for _, u := range all {
+				// Suspended users can't be impersonated.
+				if u.Suspended {
+					continue
+				}
+				users = append(users, u.PrimaryEmail)
+				orgUnits[u.PrimaryEmail] = u.OrgUnitPath
+			}
+		}
+		log.Printf("%d users to check", len(users))
+	}
+
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"email", "org_unit", "setting", "address", "detail", "external", "error",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	// Each user's rows are written as one batch, in the order the users
+	// were listed.
+	ordered := output.NewOrderedWriter(writer)
+	cb := breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag)
+
+	var mu sync.Mutex
+	failed, external := 0, 0
+	var aborted error
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrencyFlag; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				email := users[i]
+				rows, n, err := userRows(ctx, impersonator, email, orgUnits[email], internal)
+				if err != nil && shutdown.Interrupted(ctx) {
+					continue
+				}
+				tripped := cb.Record(err)
+				mu.Lock()
+				if err != nil {
+					// Gmail may be off for the user's OU; the error is
+					// written as a row and the rest carry on.
+					log.Printf("Error reading the mail settings of %s: %v", email, err)
+					failed++
+					rows = [][]string{{email, orgUnits[email], "", "", "", "", err.Error()}}
+				}
+				external += n
+				if tripped != nil && aborted == nil {
+					aborted = tripped
+				}
+				mu.Unlock()
+				if err := ordered.Write(i, rows); err != nil {
+					log.Fatalf("Error writing csv file: %v", err)
+				}
+			}
+		}()
+	}
+hand:
+	for i := range users {
+		if cb.Tripped() {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break hand
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	interrupted := shutdown.Interrupted(ctx)
+	if interrupted {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if interrupted {
+		log.Println("Interrupted: wrote partial report")
+		os.Exit(shutdown.ExitCode)
+	}
+	if aborted != nil {
+		log.Fatalf("Wrote partial report: %v", aborted)
+	}
+	log.Printf("%d settings send mail outside the organization", external)
+	if failed > 0 {
+		log.Fatalf("Complete, but the settings of %d users couldn't be read", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// userRows reads email's mail settings as them and returns a row for each
+// delegate, forwarding address, forwarding filter and send-as alias, and
+// how many of those are external.
+func userRows(ctx context.Context, impersonator *auth.Impersonator, email, orgUnit string, internal map[string]bool) ([][]string, int, error) {
+	client, err := impersonator.Client(ctx, email)
+	if err != nil {
+		return nil, 0, err
+	}
+	s, err := getMailSettings(ctx, client)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows := [][]string{}
+	n := 0
+	add := func(setting, address, detail string) {
+		ext := isExternal(address, internal)
+		if ext {
+			n++
+		} else if *externalOnlyFlag {
+			return
+		}
+		rows = append(rows, []string{email, orgUnit, setting, address, detail, strconv.FormatBool(ext), ""})
+	}
+	for _, d := range s.delegates {
+		add("delegate", d.DelegateEmail, d.VerificationStatus)
+	}
+	if s.autoForwarding.Enabled {
+		add("auto_forwarding", s.autoForwarding.EmailAddress, s.autoForwarding.Disposition)
+	}
+	for _, f := range s.filters {
+		if f.Action.Forward != "" {
+			add("filter_forward", f.Action.Forward, f.criteria())
+		}
+	}
+	for _, a := range s.sendAs {
+		// The primary address is the user's own.
+		if a.IsPrimary {
+			continue
+		}
+		detail := a.VerificationStatus
+		if a.SmtpMsa != nil && a.SmtpMsa.Host != "" {
+			detail += " via " + a.SmtpMsa.Host
+		}
+		add("send_as", a.SendAsEmail, strings.TrimSpace(detail))
+	}
+	return rows, n, nil
+}
+
+// isExternal reports whether an address is outside the organization's
+// domains.
+func isExternal(address string, internal map[string]bool) bool {
+	address = strings.ToLower(address)
+	return !internal[address[strings.LastIndex(address, "@")+1:]]
+}
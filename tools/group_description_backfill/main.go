@@ -0,0 +1,254 @@
+// Package groupdescriptionbackfill is the group_description_backfill tool:
+// reports groups with empty descriptions, then fills them in.
+package groupdescriptionbackfill
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// maxDescription is the longest description the Directory API accepts.
+const maxDescription = 4096
+
+// commandLine holds the flags of group_description_backfill, which Run
+// parses.
+var commandLine = flag.NewFlagSet("group_description_backfill", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for groups.")
+	descriptionsFileFlag  = commandLine.String("descriptions-file", "", "A csv with an email column and either a description column or columns for -template.")
+	templateFlag          = commandLine.String("template", "", "A text/template for descriptions using .Email, .Name, .Description, .Owners and (.Field \"column\") from -descriptions-file.")
+	overwriteFlag         = commandLine.Bool("overwrite", false, "Also replace descriptions that are already set.")
+	reportFile            = commandLine.String("report-file", "groups_without_descriptions.csv", "The csv of groups with empty descriptions, written before any update.")
+	outputOptions         = output.RegisterFlags(commandLine, "group_description_backfill", "csv")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the updates without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "group_description_backfill")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// templateData is what -template is executed against for each group.
+type templateData struct {
+	Email       string
+	Name        string
+	Description string
+	// Owners is a comma separated list of the group's owners.
+	Owners string
+	fields map[string]string
+}
+
+// Field returns the named column of the group's -descriptions-file row.
+func (d *templateData) Field(name string) string {
+	return d.fields[name]
+}
+
+// Run runs group_description_backfill with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_description_backfill", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	var tmpl *template.Template
+	if *templateFlag != "" {
+		var err error
+		tmpl, err = template.New("description").Parse(*templateFlag)
+		if err != nil {
+			log.Fatalf("Could not parse template: %v", err)
+		}
+	}
+	var rows map[string]map[string]string
+	if *descriptionsFileFlag != "" {
+		var err error
+		rows, err = readDescriptions(*descriptionsFileFlag, tmpl == nil)
+		if err != nil {
+			log.Fatalf("Could not read descriptions: %v", err)
+		}
+	}
+
+	scope := admin.AdminDirectoryGroupReadonlyScope
+	if tmpl != nil || rows != nil {
+		scope = admin.AdminDirectoryGroupScope
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Fetching groups")
+	groups, err := directory.ListGroups(ctx, service, *domainFlag)
+	if err != nil {
+		log.Fatalf("Error fetching groups: %v", err)
+	}
+
+	report := [][]string{{"email", "name", "members"}}
+	for _, g := range groups {
+		if strings.TrimSpace(g.Description) == "" {
+			report = append(report, []string{g.Email, g.Name, fmt.Sprint(g.DirectMembersCount)})
+		}
+	}
+	if err := outputOptions.WriteFile(*reportFile, report); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d of %d groups have no description", len(report)-1, len(groups))
+	if tmpl == nil && rows == nil {
+		log.Println("Complete")
+		return nil
+	}
+
+	changes := []*reconcile.Change{}
+	for _, g := range groups {
+		if strings.TrimSpace(g.Description) != "" && !*overwriteFlag {
+			continue
+		}
+		fields, listed := rows[strings.ToLower(g.Email)]
+		if rows != nil && !listed {
+			continue
+		}
+		description := fields["description"]
+		if tmpl != nil {
+			description, err = render(ctx, service, tmpl, g, fields)
+			if err != nil {
+				log.Fatalf("Error rendering description for %s: %v", g.Email, err)
+			}
+		}
+		description = strings.TrimSpace(description)
+		if description == "" || description == g.Description {
+			continue
+		}
+		if len(description) > maxDescription {
+			log.Fatalf("Description for %s is %d characters; the limit is %d", g.Email, len(description), maxDescription)
+		}
+		changes = append(changes, describe(service, g.Email, description))
+	}
+	log.Printf("%d descriptions to update", len(changes))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:  *dryRunFlag,
+		Canary:  *canaryFlag,
+		Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:  limits,
+		Plan:    plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+func describe(service *admin.Service, email, description string) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "describe",
+		Target:  email,
+		Subject: fmt.Sprintf("%q", description),
+		Apply: func() error {
+			_, err := service.Groups.Patch(email, &admin.Group{Description: description}).Do()
+			return err
+		},
+	}
+}
+
+// render executes tmpl for g. Owners are only fetched when the template
+// refers to them.
+func render(ctx context.Context, service *admin.Service, tmpl *template.Template, g *admin.Group, fields map[string]string) (string, error) {
+	data := &templateData{Email: g.Email, Name: g.Name, Description: g.Description, fields: fields}
+	if strings.Contains(*templateFlag, ".Owners") {
+		members, err := directory.ListMembers(ctx, service, g.Id)
+		if err != nil {
+			return "", fmt.Errorf("fetching owners: %v", err)
+		}
+		owners := []string{}
+		for _, m := range members {
+			if m.Role == "OWNER" {
+				owners = append(owners, m.Email)
+			}
+		}
+		data.Owners = strings.Join(owners, ", ")
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// readDescriptions returns the rows of the csv at path keyed by lowercased
+// group address, each row keyed by column name.
+func readDescriptions(path string, needDescription bool) (map[string]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	header := records[0]
+	required := []string{"email"}
+	if needDescription {
+		required = append(required, "description")
+	}
+	for _, name := range required {
+		found := false
+		for _, h := range header {
+			found = found || h == name
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no %q column", path, name)
+		}
+	}
+	rows := map[string]map[string]string{}
+	for _, r := range records[1:] {
+		fields := map[string]string{}
+		for i, h := range header {
+			if i < len(r) {
+				fields[h] = strings.TrimSpace(r[i])
+			}
+		}
+		if fields["email"] != "" {
+			rows[strings.ToLower(fields["email"])] = fields
+		}
+	}
+	return rows, nil
+}
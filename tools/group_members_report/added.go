@@ -0,0 +1,47 @@
+package groupmembersreport
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+)
+
+// auditRetention is roughly how far back the admin audit log goes.
+const auditRetention = 180 * 24 * time.Hour
+
+// fetchAddedDates reconstructs when each member was added to each group from
+// the admin audit log's ADD_GROUP_MEMBER events. Keys are made with
+// addedKey. Memberships older than the log's retention are absent.
+func fetchAddedDates(ctx context.Context, client *http.Client) (map[string]string, error) {
+	activities, err := reports.Activities(ctx, client, reports.ActivityQuery{
+		Application: "admin",
+		EventName:   "ADD_GROUP_MEMBER",
+		StartTime:   time.Now().Add(-auditRetention).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	added := map[string]string{}
+	// Activities come newest first, so the first event seen for a
+	// membership is its most recent addition.
+	for _, a := range activities {
+		for _, e := range a.Events {
+			if e.Name != "ADD_GROUP_MEMBER" {
+				continue
+			}
+			key := addedKey(e.Param("GROUP_EMAIL"), e.Param("USER_EMAIL"))
+			if _, ok := added[key]; !ok {
+				added[key] = a.ID.Time
+			}
+		}
+	}
+	return added, nil
+}
+
+func addedKey(group, member string) string {
+	return strings.ToLower(group) + "\x00" + strings.ToLower(member)
+}
@@ -0,0 +1,51 @@
+package groupmembersreport
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/address"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+)
+
+// newNormalizer returns the normalizer for -dedupe. With -resolve-aliases
+// it knows the aliases of every user in domains and of groups, so a member
+// added under an alias is reported under their primary address.
+func newNormalizer(ctx context.Context, service *admin.Service, domains []string, groups []*directory.DomainGroup) (*address.Normalizer, error) {
+	n := &address.Normalizer{StripPlus: *stripPlusFlag}
+	if !*resolveAliasesFlag {
+		return n, nil
+	}
+	for _, domain := range domains {
+		users, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			n.AddAliases(u.PrimaryEmail, u.Aliases...)
+			n.AddAliases(u.PrimaryEmail, u.NonEditableAliases...)
+		}
+	}
+	for _, g := range groups {
+		n.AddAliases(g.Email, g.Aliases...)
+		n.AddAliases(g.Email, g.NonEditableAliases...)
+	}
+	return n, nil
+}
+
+// dedupe normalizes each member's address and drops repeats, returning the
+// surviving members with their original addresses alongside. The first
+// spelling seen wins.
+func dedupe(n *address.Normalizer, members []*directory.Member) (kept []*directory.Member, normalized []string) {
+	seen := map[string]bool{}
+	for _, m := range members {
+		email := n.Normalize(m.Email)
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		kept = append(kept, m)
+		normalized = append(normalized, email)
+	}
+	return kept, normalized
+}
@@ -0,0 +1,134 @@
+package groupmembersreport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+)
+
+// membership is one group member in a report, for comparing two runs.
+type membership struct {
+	group string
+	email string
+	role  string
+}
+
+func (m *membership) key() string {
+	return strings.ToLower(m.group) + "\x00" + strings.ToLower(m.email)
+}
+
+// differ compares this run's memberships with those of a previous report.
+type differ struct {
+	mu       sync.Mutex
+	previous map[string]*membership
+	current  map[string]*membership
+	// reported are the groups whose members were fetched this run,
+	// lowercased.
+	reported map[string]bool
+}
+
+// newDiffer reads the previous report at path, a csv or tsv written by
+// this or an older release, which needs at least the group and email
+// columns.
+func newDiffer(path string) (*differ, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't read %s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	t := &schema.Table{Header: records[0], Rows: records[1:]}
+	if i := t.Index(schema.Column); i >= 0 && len(t.Rows) > 0 && i < len(t.Rows[0]) {
+		name, version, err := schema.Parse(t.Rows[0][i])
+		if err != nil {
+			return nil, err
+		}
+		if name != "group_members_report" {
+			return nil, fmt.Errorf("%s is a %s report, not group_members_report", path, name)
+		}
+		// The stamp column stays last, where the added columns don't
+		// reach, and is ignored below.
+		if _, err := schema.Migrate(name, version, t); err != nil {
+			return nil, err
+		}
+	}
+	group, email, role := t.Index("group"), t.Index("email"), t.Index("role")
+	if group < 0 || email < 0 {
+		return nil, fmt.Errorf("%s has no group and email columns", path)
+	}
+	d := &differ{previous: map[string]*membership{}, current: map[string]*membership{}, reported: map[string]bool{}}
+	for _, row := range t.Rows {
+		if group >= len(row) || email >= len(row) {
+			continue
+		}
+		m := &membership{group: row[group], email: row[email]}
+		if role >= 0 && role < len(row) {
+			m.role = row[role]
+		}
+		d.previous[m.key()] = m
+	}
+	return d, nil
+}
+
+// add records a group's rows from this run, in the report's layout.
+func (d *differ) add(group string, rows [][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reported[strings.ToLower(group)] = true
+	for _, row := range rows {
+		m := &membership{group: row[1], email: row[2], role: row[3]}
+		d.current[m.key()] = m
+	}
+}
+
+// changes returns the memberships added and removed since the previous
+// report, as rows of group, email, change and role, sorted. A previous
+// membership missing from this run only counts as removed if removable
+// says this run would have found it, rather than it being out of scope or
+// in a group whose members couldn't be fetched.
+func (d *differ) changes(removable func(m *membership) bool) [][]string {
+	rows := [][]string{}
+	for key, m := range d.current {
+		if d.previous[key] == nil {
+			rows = append(rows, []string{m.group, m.email, "added", m.role})
+		}
+	}
+	for key, m := range d.previous {
+		if d.current[key] != nil {
+			continue
+		}
+		if !removable(m) {
+			continue
+		}
+		rows = append(rows, []string{m.group, m.email, "removed", m.role})
+	}
+	sort.Sort(byGroupAndEmail(rows))
+	return rows
+}
+
+type byGroupAndEmail [][]string
+
+func (s byGroupAndEmail) Len() int      { return len(s) }
+func (s byGroupAndEmail) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byGroupAndEmail) Less(i, j int) bool {
+	if a, b := strings.ToLower(s[i][0]), strings.ToLower(s[j][0]); a != b {
+		return a < b
+	}
+	return strings.ToLower(s[i][1]) < strings.ToLower(s[j][1])
+}
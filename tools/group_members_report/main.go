@@ -0,0 +1,638 @@
+// Package groupmembersreport is the group_members_report tool: every group
+// in one or more domains and its members.
+package groupmembersreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/address"
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/preflight"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_members_report, which Run parses.
+var commandLine = flag.NewFlagSet("group_members_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for groups, or several separated by commas.")
+	allDomainsFlag        = commandLine.Bool("all-domains", false, "Query every verified domain of the customer instead of -domain.")
+	groupFlag             = commandLine.String("group", "", "Report only this group, skipping the listing of every group in -domain.")
+	groupFilterFlag       = commandLine.String("group-filter", "", "Only report groups matching this Directory API query, e.g. \"email:eng-*\" or \"name:'Engineering*'\".")
+	groupRegexFlag        = commandLine.String("group-regex", "", "Only report groups whose address matches this regular expression, e.g. \"^eng-\".")
+	membersFileFlag       = commandLine.String("members-file", "", "Report only the memberships of the addresses in this file (one per line, or the email column of a .csv), looking up each one's groups instead of listing every group.")
+	expandFlag            = commandLine.Bool("expand", false, "Same as -expand-nested.")
+	expandNestedFlag      = commandLine.Bool("expand-nested", false, "List the effective members, replacing each nested group with its members, recursively.")
+	maxDepthFlag          = commandLine.Int("max-depth", 10, "With -expand-nested, how many levels of nested groups to expand; deeper groups are listed as members.")
+	pathsFlag             = commandLine.Bool("paths", false, "With -expand-nested, add a via column with the nested groups through which each member belongs.")
+	memberStatusFlag      = commandLine.String("member-status", "", "Only report members with these membership statuses, separated by commas: ACTIVE, PENDING, SUSPENDED or UNDEFINED. Members with no status count as ACTIVE.")
+	concurrencyFlag       = commandLine.Int("concurrency", 1, "The number of groups whose members are fetched at once.")
+	outputFile            = commandLine.String("output-file", "report.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "group_members_report", "csv")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Stop early, writing a partial report, once more than this fraction of member fetches fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of member fetches to attempt before -max-error-rate applies.")
+	addedDatesFlag        = commandLine.Bool("added-dates", false, "Add an added column with when each member was added, from the audit log (about six months of history).")
+	dedupeFlag            = commandLine.Bool("dedupe", false, "Lowercase member addresses and list each member once per group.")
+	resolveAliasesFlag    = commandLine.Bool("resolve-aliases", false, "Report members added under an alias by their primary address. Implies -dedupe.")
+	stripPlusFlag         = commandLine.Bool("strip-plus", false, "Treat user+tag@ as user@. Implies -dedupe.")
+	diffAgainstFlag       = commandLine.String("diff-against", "", "A previous report to compare with, writing the memberships added and removed since to -diff-file.")
+	diffFileFlag          = commandLine.String("diff-file", "membership_changes.csv", "With -diff-against, the csv of added and removed memberships to write out.")
+	exitCodeFlag          = commandLine.Bool("exit-code", false, "With -diff-against, exit with status 3 if any membership changed.")
+	stateFileFlag         = commandLine.String("state-file", "group_members_report.state", "Where to save the run's progress, so a run that fails or is interrupted can be picked up with -resume. Removed when the run completes; \"\" disables it.")
+	resumeFlag            = commandLine.Bool("resume", false, "Carry on from -state-file instead of starting over: groups already listed and fetched aren't fetched again.")
+	preflightFlag         = commandLine.String("preflight", "warn", "Check API health before starting: off, warn, or wait (back off until healthy).")
+	preflightMaxWaitFlag  = commandLine.Duration("preflight-max-wait", 30*time.Minute, "How long -preflight=wait waits for the service to recover.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs group_members_report with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_members_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	domains := listfile.Split(*domainFlag)
+	switch {
+	case *allDomainsFlag && len(domains) > 0:
+		check.Problemf("-all-domains and -domain can't be used together")
+	case *groupFlag != "" || *membersFileFlag != "":
+		if *groupFlag != "" && *membersFileFlag != "" {
+			check.Problemf("-group and -members-file can't be used together")
+		}
+		if *resolveAliasesFlag && len(domains) == 0 && !*allDomainsFlag {
+			check.Problemf("-resolve-aliases needs -domain or -all-domains to find the aliases in")
+		}
+	case len(domains) == 0 && !*allDomainsFlag:
+		check.Problemf("one of -domain, -all-domains, -group or -members-file is required")
+	}
+	if *groupFilterFlag != "" && (*groupFlag != "" || *membersFileFlag != "") {
+		check.Problemf("-group-filter can't be used with -group or -members-file")
+	}
+	var groupRegex *regexp.Regexp
+	if *groupRegexFlag != "" {
+		var err error
+		groupRegex, err = regexp.Compile(*groupRegexFlag)
+		if err != nil {
+			check.Problemf("invalid -group-regex: %v", err)
+		}
+		if *groupFlag != "" {
+			check.Problemf("-group-regex can't be used with -group")
+		}
+	}
+	var members []string
+	if *membersFileFlag != "" {
+		var err error
+		members, err = listfile.Read(*membersFileFlag, "email")
+		check.Check(err)
+		if err == nil && len(members) == 0 {
+			check.Problemf("-members-file %s lists no addresses", *membersFileFlag)
+		}
+	}
+	expand := *expandFlag || *expandNestedFlag
+	if expand && *membersFileFlag != "" {
+		check.Problemf("-expand-nested can't be used with -members-file, which only finds direct memberships")
+	}
+	if expand && *maxDepthFlag < 1 {
+		check.Problemf("-max-depth must be at least 1")
+	}
+	if *pathsFlag && !expand {
+		check.Problemf("-paths needs -expand-nested")
+	}
+	statuses := map[string]bool{}
+	for _, st := range listfile.Split(strings.ToUpper(*memberStatusFlag)) {
+		if !memberStatuses[st] {
+			check.Problemf("unknown -member-status %q: use ACTIVE, PENDING, SUSPENDED or UNDEFINED", st)
+		}
+		statuses[st] = true
+	}
+	var diff *differ
+	if *diffAgainstFlag != "" {
+		var err error
+		diff, err = newDiffer(*diffAgainstFlag)
+		check.Check(err)
+	} else if *exitCodeFlag {
+		check.Problemf("-exit-code needs -diff-against")
+	}
+	if *resumeFlag {
+		switch {
+		case *stateFileFlag == "":
+			check.Problemf("-resume needs -state-file")
+		case *groupFlag != "" || *membersFileFlag != "" || *groupFilterFlag != "":
+			check.Problemf("-resume can't be used with -group, -members-file or -group-filter, which aren't checkpointed")
+		}
+	}
+	if *concurrencyFlag < 1 {
+		check.Problemf("-concurrency must be at least 1")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	credentials, err := auth.ReadCredentials(*credentialsFileFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scopes := []string{admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope}
+	if *allDomainsFlag {
+		// Only asked for when needed, so existing grants keep working.
+		scopes = append(scopes, admin.AdminDirectoryDomainReadonlyScope)
+	}
+	// Members are listed through the REST API, which has the status and
+	// delivery settings the vendored client lacks; the rest goes through
+	// the service.
+	client, err := auth.ClientFromJSON(ctx, credentials, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatalf("Can't load Google credentials file: %v", err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	probe := func() error {
+		_, err := service.Groups.List().Domain(domains[0]).MaxResults(1).Do()
+		return err
+	}
+	switch {
+	case *groupFlag != "":
+		probe = func() error {
+			_, err := service.Groups.Get(*groupFlag).Do()
+			return err
+		}
+	case *allDomainsFlag:
+		probe = func() error {
+			_, err := service.Domains.List("my_customer").Do()
+			return err
+		}
+	case len(domains) == 0:
+		probe = func() error {
+			_, err := service.Groups.List().UserKey(members[0]).MaxResults(1).Do()
+			return err
+		}
+	}
+	if err := preflight.Run(ctx, probe, preflight.Options{Mode: *preflightFlag, MaxWait: *preflightMaxWaitFlag}); err != nil {
+		log.Fatal(err)
+	}
+	if *allDomainsFlag {
+		domains, err = directory.ListDomains(ctx, service)
+		if err != nil {
+			log.Fatalf("Error fetching domains: %v", err)
+		}
+	}
+	// Only the listing of every group is long enough to be worth
+	// checkpointing.
+	var state *checkpoint
+	if *stateFileFlag != "" && *groupFlag == "" && *membersFileFlag == "" && *groupFilterFlag == "" {
+		state, err = openCheckpoint(*stateFileFlag, runSettings(), *resumeFlag)
+		if err != nil {
+			log.Fatalf("Error opening state file: %v", err)
+		}
+		if *resumeFlag {
+			log.Printf("Resuming from %s: %d groups already fetched", *stateFileFlag, state.resumed())
+		}
+	}
+	log.Println("Starting report generation")
+	cb := breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag)
+	var groups []*directory.DomainGroup
+	var known map[string][]string
+	lookupsFailed := 0
+	switch {
+	case *membersFileFlag != "":
+		groups, known, lookupsFailed, err = memberGroups(ctx, service, members, domains, cb)
+	case *groupFlag != "":
+		// Listing every group takes minutes in a large domain; one
+		// group's report comes back in seconds.
+		var group *admin.Group
+		group, err = service.Groups.Get(*groupFlag).Do()
+		if err == nil {
+			groups = []*directory.DomainGroup{{Domain: domainOf(group.Email), Group: group}}
+		}
+	case *groupFilterFlag != "":
+		groups, err = directory.ListDomainGroupsQuery(ctx, client, domains, *groupFilterFlag, func(domain string, n int) {
+			log.Printf("%d groups in %s match %s", n, domain, *groupFilterFlag)
+		})
+	default:
+		groups, err = state.listGroups(ctx, service, domains, func(domain string, n int) {
+			log.Printf("%d groups in %s", n, domain)
+		})
+	}
+	if shutdown.Interrupted(ctx) {
+		log.Println("Interrupted before any members were fetched")
+		if state != nil {
+			log.Printf("Progress saved to %s; rerun with -resume to carry on", *stateFileFlag)
+		}
+		os.Exit(shutdown.ExitCode)
+	}
+	if err != nil {
+		log.Fatalf("Error fetching groups: %v", err)
+	}
+	if groupRegex != nil {
+		// Filtering before any members are fetched is what makes a run
+		// over a few groups of a large domain quick.
+		matched := []*directory.DomainGroup{}
+		for _, g := range groups {
+			if groupRegex.MatchString(g.Email) {
+				matched = append(matched, g)
+			}
+		}
+		log.Printf("%d of %d groups match -group-regex %s", len(matched), len(groups), *groupRegexFlag)
+		groups = matched
+	}
+
+	var normalizer *address.Normalizer
+	if *dedupeFlag || *resolveAliasesFlag || *stripPlusFlag {
+		normalizer, err = newNormalizer(ctx, service, domains, groups)
+		if err != nil {
+			log.Fatalf("Error fetching aliases: %v", err)
+		}
+	}
+
+	var added map[string]string
+	if *addedDatesFlag {
+		client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, reports.AuditReadonlyScope)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Fetching membership additions from the audit log")
+		added, err = fetchAddedDates(ctx, client)
+		if err != nil {
+			log.Fatalf("Error fetching audit log: %v", err)
+		}
+	}
+
+	// Each group's directMembersCount is what its rows should add up to,
+	// unless members are expanded, looked up one by one, deduplicated or
+	// filtered by status.
+	if !*expandFlag && !*expandNestedFlag && known == nil && normalizer == nil && len(statuses) == 0 {
+		for _, g := range groups {
+			outputOptions.ExpectRows("group", g.Email, int(g.DirectMembersCount))
+		}
+	}
+	writer, err := outputOptions.Create(*outputFile, header())
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	// Each group's rows are written as one batch, in the order Groups.List
+	// returned the groups, domain by domain.
+	ordered := output.NewOrderedWriter(writer)
+
+	var mu sync.Mutex
+	failed := 0
+	duplicates, excluded := 0, 0
+	byStatus := map[string]int{}
+	var aborted error
+	// Workers fetch groups in whatever order they finish; the ordered
+	// writer holds each group's rows until those before it are written.
+	// Once the breaker trips or the run is interrupted no more groups are
+	// handed out, and the report stops at the first group that wasn't
+	// written.
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrencyFlag; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				group := groups[i]
+				rows, dropped, left, err := groupRows(ctx, client, group, known[group.Id], normalizer, added, statuses)
+				if err != nil && shutdown.Interrupted(ctx) {
+					// Not the group's failure; it just isn't written.
+					continue
+				}
+				if err == nil {
+					if err := state.record(group.Id, rows); err != nil {
+						log.Fatal(err)
+					}
+				}
+				tripped := cb.Record(err)
+				mu.Lock()
+				if err != nil {
+					log.Printf("Error fetching members of %s, skipping: %v", group.Email, err)
+					failed++
+				}
+				duplicates += dropped
+				excluded += left
+				for _, row := range rows {
+					byStatus[memberStatus(row[5])]++
+				}
+				if err == nil && diff != nil {
+					diff.add(group.Email, rows)
+				}
+				if tripped != nil && aborted == nil {
+					aborted = tripped
+				}
+				mu.Unlock()
+				// The rows of the group that trips the breaker, and of
+				// those already being fetched, are written too.
+				if err := ordered.Write(i, rows); err != nil {
+					log.Fatalf("Error writing csv file: %v", err)
+				}
+			}
+		}()
+	}
+hand:
+	for i := range groups {
+		if cb.Tripped() {
+			break
+		}
+		if rows, ok := state.done(groups[i].Id); ok {
+			// Fetched by the run being resumed.
+			if diff != nil {
+				diff.add(groups[i].Email, rows)
+			}
+			if err := ordered.Write(i, rows); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+			continue
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break hand
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	interrupted := shutdown.Interrupted(ctx)
+	if interrupted {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	complete := !interrupted && aborted == nil && failed == 0
+	if err := state.finish(complete); err != nil {
+		log.Printf("Error closing state file: %v", err)
+	}
+	if state != nil && !complete {
+		log.Printf("Progress saved to %s; rerun with -resume to carry on", *stateFileFlag)
+	}
+	if interrupted {
+		log.Println("Interrupted: wrote partial report")
+		os.Exit(shutdown.ExitCode)
+	}
+	if duplicates > 0 {
+		log.Printf("Dropped %d duplicate memberships", duplicates)
+	}
+	if byStatus["PENDING"]+byStatus["SUSPENDED"]+byStatus["UNDEFINED"] > 0 {
+		log.Printf("Memberships by status: %d active, %d pending, %d suspended, %d undefined",
+			byStatus["ACTIVE"], byStatus["PENDING"], byStatus["SUSPENDED"], byStatus["UNDEFINED"])
+	}
+	if excluded > 0 {
+		log.Printf("Left out %d memberships not matching -member-status %s", excluded, *memberStatusFlag)
+	}
+	changed := false
+	if diff != nil && aborted == nil {
+		changed = writeDiff(diff, groups, domains, members, lookupsFailed, groupRegex)
+	}
+	if aborted != nil {
+		log.Fatalf("Wrote partial report: %v", aborted)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but members of %d groups could not be fetched", failed)
+	}
+	if lookupsFailed > 0 {
+		log.Fatalf("Complete, but the groups of %d members could not be fetched", lookupsFailed)
+	}
+	log.Println("Complete")
+	if changed && *exitCodeFlag {
+		os.Exit(3)
+	}
+	return nil
+}
+
+// runSettings describes the flags that decide which groups a run lists and
+// what its rows hold, which a resumed run must share.
+func runSettings() string {
+	return fmt.Sprintf("domain=%s all-domains=%t group-regex=%s expand-nested=%t max-depth=%d paths=%t added-dates=%t dedupe=%t resolve-aliases=%t strip-plus=%t member-status=%s",
+		*domainFlag, *allDomainsFlag, *groupRegexFlag, *expandFlag || *expandNestedFlag, *maxDepthFlag, *pathsFlag,
+		*addedDatesFlag, *dedupeFlag, *resolveAliasesFlag, *stripPlusFlag, strings.ToUpper(*memberStatusFlag))
+}
+
+// writeDiff writes the memberships added and removed since -diff-against
+// to -diff-file, and reports whether there were any. A previous membership
+// missing now counts as removed if its group's members were fetched, or,
+// for a group this run didn't list, if the run would have listed it: a
+// group deleted from one of the domains, or, with -members-file, a group
+// a listed address left. With -members-file only the listed addresses are
+// compared.
+func writeDiff(diff *differ, groups []*directory.DomainGroup, domains, members []string, lookupsFailed int, groupRegex *regexp.Regexp) bool {
+	listed := map[string]bool{}
+	for _, g := range groups {
+		listed[strings.ToLower(g.Email)] = true
+	}
+	inDomains := map[string]bool{}
+	for _, domain := range domains {
+		inDomains[strings.ToLower(domain)] = true
+	}
+	addresses := map[string]bool{}
+	for _, email := range members {
+		addresses[strings.ToLower(email)] = true
+	}
+	removable := func(m *membership) bool {
+		group := strings.ToLower(m.group)
+		switch {
+		case *membersFileFlag != "" && !addresses[strings.ToLower(m.email)]:
+			return false
+		case listed[group]:
+			return diff.reported[group]
+		case *groupFlag != "" || *groupFilterFlag != "":
+			// A group missing from a filtered listing may just not match.
+			return false
+		case groupRegex != nil && !groupRegex.MatchString(m.group):
+			return false
+		case *membersFileFlag != "":
+			return lookupsFailed == 0 && (len(inDomains) == 0 || inDomains[domainOf(m.group)])
+		}
+		return inDomains[domainOf(m.group)]
+	}
+	changes := diff.changes(removable)
+	diffOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("group_members_report_diff")}
+	if err := diffOptions.WriteFile(*diffFileFlag, append([][]string{{"group", "email", "change", "role"}}, changes...)); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	added, changedGroups := 0, map[string]bool{}
+	for _, row := range changes {
+		if row[2] == "added" {
+			added++
+		}
+		changedGroups[strings.ToLower(row[0])] = true
+	}
+	if len(changes) == 0 {
+		log.Printf("No membership changes since %s", *diffAgainstFlag)
+	} else {
+		log.Printf("Since %s: %d memberships added and %d removed in %d groups", *diffAgainstFlag, added, len(changes)-added, len(changedGroups))
+	}
+	return len(changes) > 0
+}
+
+// header returns the report's columns, which match the rows groupRows
+// returns.
+func header() []string {
+	columns := []string{"domain", "group", "email", "role", "type", "status", "delivery_settings"}
+	if *pathsFlag {
+		columns = append(columns, "via")
+	}
+	if *addedDatesFlag {
+		columns = append(columns, "added")
+	}
+	return columns
+}
+
+// memberStatuses are the membership statuses the Directory API reports.
+// PENDING is an invitation not yet accepted and SUSPENDED a suspended
+// user's membership; neither receives the group's mail.
+var memberStatuses = map[string]bool{"ACTIVE": true, "PENDING": true, "SUSPENDED": true, "UNDEFINED": true}
+
+// memberStatus returns a member's status, taking the customer and other
+// members the API gives no status as ACTIVE.
+func memberStatus(status string) string {
+	if status == "" {
+		return "ACTIVE"
+	}
+	return strings.ToUpper(status)
+}
+
+// groupRows fetches a group's members, or just the known ones if the
+// addresses of some are given, and returns its report rows, the number of
+// duplicate memberships dropped and the number left out because their
+// status isn't in statuses. With nested groups expanded, statuses applies
+// to the members reported, not to the groups they came through. A group
+// whose members can't be fetched has no rows.
+func groupRows(ctx context.Context, client *http.Client, group *directory.DomainGroup, known []string, normalizer *address.Normalizer, added map[string]string, statuses map[string]bool) ([][]string, int, int, error) {
+	var members []*directory.Member
+	var err error
+	if known == nil {
+		members, err = directory.ListMemberDetails(ctx, client, group.Id)
+	}
+	for _, email := range known {
+		var m *directory.Member
+		if m, err = directory.GetMember(ctx, client, group.Id, email); err != nil {
+			break
+		}
+		members = append(members, m)
+	}
+	var via map[*directory.Member]string
+	if err == nil && (*expandFlag || *expandNestedFlag) {
+		members, via, err = expandMembers(ctx, client, group.Group, members, *maxDepthFlag)
+	}
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	excluded := 0
+	if len(statuses) > 0 {
+		kept := []*directory.Member{}
+		for _, m := range members {
+			if statuses[memberStatus(m.Status)] {
+				kept = append(kept, m)
+			}
+		}
+		excluded = len(members) - len(kept)
+		members = kept
+	}
+	dropped := 0
+	emails := []string{}
+	if normalizer != nil {
+		before := len(members)
+		members, emails = dedupe(normalizer, members)
+		dropped = before - len(members)
+	} else {
+		for _, member := range members {
+			emails = append(emails, member.Email)
+		}
+	}
+	rows := [][]string{}
+	for j, member := range members {
+		row := []string{group.Domain, group.Email, emails[j], member.Role, member.Type, member.Status, member.DeliverySettings}
+		if *pathsFlag {
+			row = append(row, via[member])
+		}
+		if added != nil {
+			row = append(row, added[addedKey(group.Email, member.Email)])
+		}
+		rows = append(rows, row)
+	}
+	return rows, dropped, excluded, nil
+}
+
+func domainOf(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+// expandMembers replaces the groups among members with their own members,
+// recursively, so only users and other non-group members are left. Each
+// is listed once, however many groups lead to it, under the shortest path
+// to it; a group that contains itself, directly or through others, is only
+// expanded once. Groups more than maxDepth levels down are listed as they
+// are. via gives the nested groups, outermost first, through which each
+// indirect member belongs.
+func expandMembers(ctx context.Context, client *http.Client, group *admin.Group, members []*directory.Member, maxDepth int) ([]*directory.Member, map[*directory.Member]string, error) {
+	type queued struct {
+		*directory.Member
+		via   []string
+		depth int
+	}
+	queue := []queued{}
+	for _, m := range members {
+		queue = append(queue, queued{m, nil, 1})
+	}
+	expanded := map[string]bool{group.Id: true}
+	seen := map[string]bool{}
+	result := []*directory.Member{}
+	via := map[*directory.Member]string{}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		if m.Type != "GROUP" || m.depth > maxDepth {
+			if email := strings.ToLower(m.Email); !seen[email] {
+				seen[email] = true
+				result = append(result, m.Member)
+				via[m.Member] = strings.Join(m.via, " > ")
+			}
+			if m.Type == "GROUP" {
+				log.Printf("%s: not expanding %s, more than -max-depth %d groups down", group.Email, m.Email, maxDepth)
+			}
+			continue
+		}
+		if expanded[m.ID] {
+			continue
+		}
+		expanded[m.ID] = true
+		nested, err := directory.ListMemberDetails(ctx, client, m.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("expanding %s: %v", m.Email, err)
+		}
+		path := append(append([]string{}, m.via...), m.Email)
+		for _, n := range nested {
+			queue = append(queue, queued{n, path, m.depth + 1})
+		}
+	}
+	return result, via, nil
+}
@@ -0,0 +1,77 @@
+package groupmembersreport
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+)
+
+// memberGroups looks up the groups each of emails is a direct member of,
+// which for a short list such as a quarter's leavers is far quicker than
+// fetching the members of every group. It returns the groups, by domain in
+// the order of domains (keeping only groups in them, if any are given) and
+// then by address, and each group's members among emails, keyed by group
+// ID. Lookups that fail are logged and counted in failed; the error is
+// only set if cb trips.
+func memberGroups(ctx context.Context, service *admin.Service, emails, domains []string, cb *breaker.Breaker) (groups []*directory.DomainGroup, known map[string][]string, failed int, err error) {
+	order := map[string]int{}
+	for i, domain := range domains {
+		order[strings.ToLower(domain)] = i
+	}
+	byID := map[string]*directory.DomainGroup{}
+	known = map[string][]string{}
+	seen := map[string]bool{}
+	for _, email := range emails {
+		email = strings.ToLower(email)
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		found, err := directory.ListMemberGroups(ctx, service, email)
+		if tripped := cb.Record(err); tripped != nil {
+			return nil, nil, failed, tripped
+		}
+		if err != nil {
+			log.Printf("Error fetching groups of %s, skipping: %v", email, err)
+			failed++
+			continue
+		}
+		for _, g := range found {
+			domain := domainOf(g.Email)
+			if _, ok := order[domain]; len(order) > 0 && !ok {
+				continue
+			}
+			if byID[g.Id] == nil {
+				byID[g.Id] = &directory.DomainGroup{Domain: domain, Group: g}
+				groups = append(groups, byID[g.Id])
+			}
+			known[g.Id] = append(known[g.Id], email)
+		}
+	}
+	sort.Sort(byDomainOrder{groups, order})
+	log.Printf("%d members are in %d groups", len(seen), len(groups))
+	return groups, known, failed, nil
+}
+
+// byDomainOrder sorts groups by the position of their domain in order, then
+// by address.
+type byDomainOrder struct {
+	groups []*directory.DomainGroup
+	order  map[string]int
+}
+
+func (s byDomainOrder) Len() int      { return len(s.groups) }
+func (s byDomainOrder) Swap(i, j int) { s.groups[i], s.groups[j] = s.groups[j], s.groups[i] }
+func (s byDomainOrder) Less(i, j int) bool {
+	a, b := s.groups[i], s.groups[j]
+	if s.order[a.Domain] != s.order[b.Domain] {
+		return s.order[a.Domain] < s.order[b.Domain]
+	}
+	return strings.ToLower(a.Email) < strings.ToLower(b.Email)
+}
@@ -0,0 +1,233 @@
+package groupmembersreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+)
+
+// checkpoint records a run's progress in -state-file, one JSON object per
+// line, so a run that fails or is interrupted part way can be picked up
+// with -resume instead of started over: each page of groups as it is
+// listed, with the token of the next, and each group's rows once its
+// members are fetched. The file is removed when the run completes.
+type checkpoint struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+
+	// Restored by -resume.
+	listings map[string]*listing
+	rows     map[string][][]string
+}
+
+// listing is how far one domain's groups had been listed.
+type listing struct {
+	groups []*admin.Group
+	next   string
+	done   bool
+}
+
+// stateEntry is one line of the state file: the run's settings first, then
+// a page of a domain's groups or a group whose members were fetched.
+type stateEntry struct {
+	Run    string         `json:"run,omitempty"`
+	Domain string         `json:"domain,omitempty"`
+	Groups []*admin.Group `json:"groups,omitempty"`
+	Next   string         `json:"next,omitempty"`
+	Group  string         `json:"group,omitempty"`
+	Rows   [][]string     `json:"rows,omitempty"`
+}
+
+// openCheckpoint starts the state file at path for a run with the given
+// settings, or with resume reads the progress it holds and carries on
+// appending to it. A state file written with other settings can't be
+// resumed, as its rows wouldn't match.
+func openCheckpoint(path, run string, resume bool) (*checkpoint, error) {
+	c := &checkpoint{path: path, listings: map[string]*listing{}, rows: map[string][][]string{}}
+	if !resume {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		c.file, c.enc = file, json.NewEncoder(file)
+		if err := c.enc.Encode(&stateEntry{Run: run}); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+	good, err := c.restore(run)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Truncate(path, good); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+	c.file, c.enc = file, json.NewEncoder(file)
+	return c, nil
+}
+
+// restore reads the progress in the state file and returns the length of
+// its complete lines. A run killed mid-write leaves a torn last line, which
+// is cut off before appending; its page or group is fetched again.
+func (c *checkpoint) restore(run string) (int64, error) {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	var good int64
+	first := true
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("can't read %s: %v", c.path, err)
+		}
+		e := &stateEntry{}
+		if err := json.Unmarshal(line, e); err != nil {
+			return 0, fmt.Errorf("can't read %s: %v", c.path, err)
+		}
+		good += int64(len(line))
+		switch {
+		case first:
+			if e.Run != run {
+				return 0, fmt.Errorf("%s is from a run with other settings (%s); drop -resume to start over", c.path, e.Run)
+			}
+			first = false
+		case e.Domain != "":
+			l := c.listings[e.Domain]
+			if l == nil {
+				l = &listing{}
+				c.listings[e.Domain] = l
+			}
+			l.groups = append(l.groups, e.Groups...)
+			l.next, l.done = e.Next, e.Next == ""
+		case e.Group != "":
+			c.rows[e.Group] = e.Rows
+		}
+	}
+	if first {
+		return 0, fmt.Errorf("%s holds no progress to resume", c.path)
+	}
+	return good, nil
+}
+
+// resumed returns the number of groups restored by -resume.
+func (c *checkpoint) resumed() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.rows)
+}
+
+// listGroups is directory.ListDomainGroups, picking up each domain's
+// listing where the resumed run left it and recording every page.
+func (c *checkpoint) listGroups(ctx context.Context, service *admin.Service, domains []string, count func(domain string, groups int)) ([]*directory.DomainGroup, error) {
+	if c == nil {
+		return directory.ListDomainGroups(ctx, service, domains, count)
+	}
+	type result struct {
+		groups []*admin.Group
+		err    error
+	}
+	results := make([]chan result, len(domains))
+	for i, domain := range domains {
+		results[i] = make(chan result, 1)
+		l := c.listings[domain]
+		if l == nil {
+			l = &listing{}
+		}
+		if l.done {
+			results[i] <- result{groups: l.groups}
+			continue
+		}
+		go func(domain string, l *listing, out chan<- result) {
+			groups := l.groups
+			err := directory.ListGroupsPages(ctx, service, domain, l.next, func(page []*admin.Group, next string) error {
+				groups = append(groups, page...)
+				return c.write(&stateEntry{Domain: domain, Groups: page, Next: next})
+			})
+			out <- result{groups, err}
+		}(domain, l, results[i])
+	}
+	all := []*directory.DomainGroup{}
+	var first error
+	for i, domain := range domains {
+		r := <-results[i]
+		if r.err != nil {
+			if first == nil {
+				first = fmt.Errorf("%s: %w", domain, r.err)
+			}
+			continue
+		}
+		if count != nil {
+			count(domain, len(r.groups))
+		}
+		for _, g := range r.groups {
+			all = append(all, &directory.DomainGroup{Domain: domain, Group: g})
+		}
+	}
+	if first != nil {
+		return nil, first
+	}
+	return all, nil
+}
+
+// done returns the rows of a group whose members the resumed run fetched.
+func (c *checkpoint) done(id string) ([][]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	rows, ok := c.rows[id]
+	return rows, ok
+}
+
+// record notes that a group's members were fetched.
+func (c *checkpoint) record(id string, rows [][]string) error {
+	if c == nil {
+		return nil
+	}
+	return c.write(&stateEntry{Group: id, Rows: rows})
+}
+
+func (c *checkpoint) write(e *stateEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.enc.Encode(e); err != nil {
+		return fmt.Errorf("can't save progress to %s: %v", c.path, err)
+	}
+	return nil
+}
+
+// finish closes the state file, removing it if the run completed.
+func (c *checkpoint) finish(complete bool) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	if complete {
+		return os.Remove(c.path)
+	}
+	return nil
+}
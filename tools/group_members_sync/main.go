@@ -0,0 +1,223 @@
+// Package groupmemberssync is the group_members_sync tool: converges the
+// members and roles of groups to a csv or YAML manifest.
+package groupmemberssync
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/apierr"
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_members_sync, which Run parses.
+var commandLine = flag.NewFlagSet("group_members_sync", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	membersFileFlag       = commandLine.String("members-file", "", "The desired members: a csv with group, email and optional role columns, or a .yaml file of groups with owners, managers and members.")
+	keepExtraFlag         = commandLine.Bool("keep-extra", false, "Don't remove members who aren't in -members-file.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the membership changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "group_members_sync")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs group_members_sync with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_members_sync", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "members-file")
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	wanted, err := loadManifest(*membersFileFlag)
+	if err != nil {
+		log.Fatalf("Could not read members: %v", err)
+	}
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryGroupMemberScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	changes := []*reconcile.Change{}
+	existing := 0
+	for _, group := range wanted.groups() {
+		log.Printf("Fetching members of %s", group)
+		current, err := directory.ListMembers(ctx, service, group)
+		if err != nil {
+			log.Fatalf("Error fetching members of %s: %v", group, err)
+		}
+		existing += len(current)
+		matched, err := match(ctx, service, group, wanted[group], current)
+		if err != nil {
+			log.Fatalf("Error matching members of %s: %v", group, err)
+		}
+		changes = append(changes, diff(service, group, wanted[group], current, matched)...)
+	}
+
+	log.Printf("%d membership changes to make across %d groups", len(changes), len(wanted))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: existing,
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// match finds the current member each desired address names. Members.List
+// gives primary addresses, so an address that isn't one of them, such as
+// an alias, is looked up with Members.Get, which takes aliases too; one it
+// doesn't find isn't a member yet. Two addresses naming the same member
+// are an error, as the member can only have one role.
+func match(ctx context.Context, service *admin.Service, group string, want map[string]string, current []*admin.Member) (map[string]*admin.Member, error) {
+	emails, ids := map[string]*admin.Member{}, map[string]*admin.Member{}
+	for _, m := range current {
+		// The customer-wide member ("all users") has no address and
+		// can't be listed in the manifest.
+		if m.Email != "" {
+			emails[strings.ToLower(m.Email)] = m
+			ids[m.Id] = m
+		}
+	}
+	matched := map[string]*admin.Member{}
+	by := map[*admin.Member]string{}
+	for _, email := range sortedKeys(want) {
+		m := emails[email]
+		if m == nil {
+			found, err := service.Members.Get(group, email).Context(ctx).Do()
+			if err != nil && !errors.Is(apierr.Wrap(err), apierr.ErrNotFound) {
+				return nil, fmt.Errorf("looking up %s: %v", email, err)
+			}
+			if err == nil {
+				m = ids[found.Id]
+			}
+		}
+		if m == nil {
+			continue
+		}
+		if other, ok := by[m]; ok {
+			return nil, fmt.Errorf("%s and %s are the same member, %s", other, email, m.Email)
+		}
+		by[m] = email
+		matched[email] = m
+	}
+	return matched, nil
+}
+
+// diff compares a group's desired members with its current ones, as
+// matched by match: missing members are added, members with a different
+// role (where the manifest gives one) are updated, and unlisted members
+// are removed unless -keep-extra is set. Changes come adds first, then
+// updates, then removes, each sorted by address.
+func diff(service *admin.Service, group string, want map[string]string, current []*admin.Member, matched map[string]*admin.Member) []*reconcile.Change {
+	adds, updates, removes := []*reconcile.Change{}, []*reconcile.Change{}, []*reconcile.Change{}
+	kept := map[*admin.Member]bool{}
+	for _, email := range sortedKeys(want) {
+		email, role := email, want[email]
+		m, ok := matched[email]
+		switch {
+		case !ok:
+			if role == "" {
+				role = "MEMBER"
+			}
+			adds = append(adds, &reconcile.Change{
+				Action:  "add",
+				Target:  group,
+				Subject: email + " (" + role + ")",
+				Apply: func() error {
+					_, err := service.Members.Insert(group, &admin.Member{Email: email, Role: role}).Do()
+					return err
+				},
+			})
+			continue
+		case role != "" && role != m.Role:
+			subject := email + " (" + m.Role + " -> " + role + ")"
+			if !strings.EqualFold(email, m.Email) {
+				subject = email + " as " + m.Email + " (" + m.Role + " -> " + role + ")"
+			}
+			updates = append(updates, &reconcile.Change{
+				Action:  "update",
+				Target:  group,
+				Subject: subject,
+				Apply: func() error {
+					_, err := service.Members.Patch(group, m.Id, &admin.Member{Role: role}).Do()
+					return err
+				},
+			})
+		}
+		kept[m] = true
+	}
+	if !*keepExtraFlag {
+		extra := []*admin.Member{}
+		for _, m := range current {
+			if m.Email != "" && !kept[m] {
+				extra = append(extra, m)
+			}
+		}
+		sort.Sort(byEmail(extra))
+		for _, m := range extra {
+			m := m
+			removes = append(removes, &reconcile.Change{
+				Action:  "remove",
+				Target:  group,
+				Subject: strings.ToLower(m.Email),
+				Apply:   func() error { return service.Members.Delete(group, m.Id).Do() },
+			})
+		}
+	}
+	return append(append(adds, updates...), removes...)
+}
+
+type byEmail []*admin.Member
+
+func (s byEmail) Len() int      { return len(s) }
+func (s byEmail) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byEmail) Less(i, j int) bool {
+	return strings.ToLower(s[i].Email) < strings.ToLower(s[j].Email)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
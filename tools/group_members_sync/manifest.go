@@ -0,0 +1,146 @@
+package groupmemberssync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// manifest is the desired membership of each group it names, keyed by
+// lowercased group and member address. A member's role is OWNER, MANAGER
+// or MEMBER, or "" to add them as a MEMBER but leave the role of an
+// existing member alone.
+type manifest map[string]map[string]string
+
+// groups returns the groups in the manifest, sorted.
+func (m manifest) groups() []string {
+	groups := []string{}
+	for g := range m {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func (m manifest) add(path, group, email, role string) error {
+	group, email, role = strings.ToLower(group), strings.ToLower(email), strings.ToUpper(role)
+	switch role {
+	case "", "OWNER", "MANAGER", "MEMBER":
+	default:
+		return fmt.Errorf("%s: %s in %s has unknown role %q: use OWNER, MANAGER or MEMBER", path, email, group, role)
+	}
+	if m[group] == nil {
+		m[group] = map[string]string{}
+	}
+	if _, ok := m[group][email]; ok {
+		return fmt.Errorf("%s lists %s in %s more than once", path, email, group)
+	}
+	m[group][email] = role
+	return nil
+}
+
+// manifestFile is the YAML form of a manifest. Listing a group with no
+// members empties it.
+type manifestFile struct {
+	Groups []struct {
+		Group    string   `yaml:"group"`
+		Owners   []string `yaml:"owners"`
+		Managers []string `yaml:"managers"`
+		Members  []string `yaml:"members"`
+	} `yaml:"groups"`
+}
+
+// loadManifest reads a .yaml or .yml manifest, or otherwise a csv with
+// group and email columns and an optional role column.
+func loadManifest(path string) (manifest, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return loadYAML(path)
+	}
+	return loadCSV(path)
+}
+
+func loadYAML(path string) (manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mf := &manifestFile{}
+	// A misspelt key, such as "member:", would otherwise leave those
+	// members out and have them removed.
+	if err := yaml.UnmarshalStrict(data, mf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if len(mf.Groups) == 0 {
+		return nil, fmt.Errorf("%s has no groups", path)
+	}
+	m := manifest{}
+	for i, g := range mf.Groups {
+		if g.Group == "" {
+			return nil, fmt.Errorf("%s: group %d has no address", path, i+1)
+		}
+		group := strings.ToLower(g.Group)
+		if m[group] != nil {
+			return nil, fmt.Errorf("%s lists %s more than once", path, g.Group)
+		}
+		m[group] = map[string]string{}
+		for role, emails := range map[string][]string{"OWNER": g.Owners, "MANAGER": g.Managers, "MEMBER": g.Members} {
+			for _, email := range emails {
+				if err := m.add(path, group, email, role); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func loadCSV(path string) (manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	cols := map[string]int{}
+	for i, h := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, name := range []string{"group", "email"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("%s has no %q column", path, name)
+		}
+	}
+	field := func(r []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	m := manifest{}
+	for _, r := range records[1:] {
+		group, email := field(r, "group"), field(r, "email")
+		if group == "" || email == "" {
+			continue
+		}
+		if err := m.add(path, group, email, field(r, "role")); err != nil {
+			return nil, err
+		}
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("%s has no members", path)
+	}
+	return m, nil
+}
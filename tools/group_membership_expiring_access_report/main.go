@@ -0,0 +1,181 @@
+// Package groupmembershipexpiringaccessreport is the
+// group_membership_expiring_access_report tool: group memberships of users
+// with a contract end date that outlive it.
+package groupmembershipexpiringaccessreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_membership_expiring_access_report,
+// which Run parses.
+var commandLine = flag.NewFlagSet("group_membership_expiring_access_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users, or several separated by commas.")
+	endDateFieldFlag      = commandLine.String("end-date-field", "", "The custom schema field holding each user's contract end date, as Schema.Field. Users without one are skipped.")
+	dateLayoutFlag        = commandLine.String("date-layout", "2006-01-02", "The layout of -end-date-field's values, in Go's time format. The default is how DATE fields are returned.")
+	allMembershipsFlag    = commandLine.Bool("all-memberships", false, "Also list the memberships that expire by the user's end date.")
+	outputFile            = commandLine.String("output-file", "expiring_access.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "group_membership_expiring_access_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs group_membership_expiring_access_report with args, the arguments
+// that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_membership_expiring_access_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain", "end-date-field")
+	if *endDateFieldFlag != "" && !strings.Contains(*endDateFieldFlag, ".") {
+		check.Problemf("-end-date-field must be Schema.Field, not %q", *endDateFieldFlag)
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	// Memberships are listed through the Cloud Identity API, which has
+	// the expiry the Directory API's members lack.
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, groupsReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	type contractor struct {
+		user *admin.User
+		end  time.Time
+	}
+	contractors := []*contractor{}
+	invalid := 0
+	for _, domain := range listfile.Split(*domainFlag) {
+		// The full projection is what includes custom schemas.
+		users, err := directory.ListUsers(ctx, service, domain, "full")
+		if err != nil {
+			log.Fatalf("Error fetching users of %s: %v", domain, err)
+		}
+		n := 0
+		for _, u := range users {
+			value := directory.CustomField(u, *endDateFieldFlag)
+			if value == "" {
+				continue
+			}
+			end, err := time.Parse(*dateLayoutFlag, value)
+			if err != nil {
+				log.Printf("Skipping %s: %s %q isn't a date like %s", u.PrimaryEmail, *endDateFieldFlag, value, *dateLayoutFlag)
+				invalid++
+				continue
+			}
+			contractors = append(contractors, &contractor{user: u, end: end})
+			n++
+		}
+		log.Printf("%d of %d users in %s have an end date", n, len(users), domain)
+	}
+
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"email", "name", "org_unit", "suspended", "end_date", "group", "group_name", "role",
+		"membership_expires", "outlives_end_date", "days_past_end_date",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	log.Println("Starting report generation")
+	failed, flagged, done := 0, 0, 0
+	for _, c := range contractors {
+		memberships, err := listMemberships(ctx, client, c.user.PrimaryEmail)
+		if shutdown.Interrupted(ctx) {
+			break
+		}
+		done++
+		if err != nil {
+			log.Printf("Error fetching groups of %s, skipping: %v", c.user.PrimaryEmail, err)
+			failed++
+			continue
+		}
+		for _, m := range memberships {
+			// A membership outlives the contract unless it expires by the
+			// end of the end date.
+			expires := m.expires()
+			cutoff := c.end.AddDate(0, 0, 1)
+			outlives := expires.IsZero() || expires.After(cutoff)
+			if !outlives && !*allMembershipsFlag {
+				continue
+			}
+			expiresText, daysPast := "", ""
+			if !expires.IsZero() {
+				expiresText = expires.UTC().Format(time.RFC3339)
+				if outlives {
+					daysPast = strconv.Itoa(int(math.Ceil(expires.Sub(cutoff).Hours() / 24)))
+				}
+			}
+			if outlives {
+				flagged++
+			}
+			if err := writer.Write([]string{
+				c.user.PrimaryEmail,
+				c.user.Name.FullName,
+				c.user.OrgUnitPath,
+				strconv.FormatBool(c.user.Suspended),
+				c.end.Format("2006-01-02"),
+				m.GroupKey.ID,
+				m.DisplayName,
+				m.role(),
+				expiresText,
+				strconv.FormatBool(outlives),
+				daysPast,
+			}); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+	}
+	if shutdown.Interrupted(ctx) {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if shutdown.Interrupted(ctx) {
+		log.Printf("Interrupted: wrote partial report of %d of %d users", done, len(contractors))
+		os.Exit(shutdown.ExitCode)
+	}
+	log.Printf("%d memberships of %d users with an end date outlive it", flagged, len(contractors))
+	if failed > 0 {
+		log.Fatalf("Complete, but the groups of %d users could not be fetched", failed)
+	}
+	if invalid > 0 {
+		log.Printf("%d users' end dates couldn't be read", invalid)
+	}
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,84 @@
+package groupmembershipexpiringaccessreport
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	groupsReadonlyScope = "https://www.googleapis.com/auth/cloud-identity.groups.readonly"
+	groupsBasePath      = "https://cloudidentity.googleapis.com/v1/"
+)
+
+// membership is a MembershipRelation from the Cloud Identity API: one
+// group a user is a direct member of, with the roles they hold in it.
+// Unlike the Directory API it has each role's expiry.
+type membership struct {
+	GroupKey struct {
+		ID string `json:"id"`
+	} `json:"groupKey"`
+	DisplayName string `json:"displayName"`
+	Roles       []struct {
+		Name         string `json:"name"`
+		ExpiryDetail *struct {
+			ExpireTime string `json:"expireTime"`
+		} `json:"expiryDetail"`
+	} `json:"roles"`
+}
+
+// role returns the highest role held, OWNER, MANAGER or MEMBER.
+func (m *membership) role() string {
+	role := ""
+	for _, r := range m.Roles {
+		if role == "" || rank[r.Name] > rank[role] {
+			role = r.Name
+		}
+	}
+	return role
+}
+
+var rank = map[string]int{"MEMBER": 1, "MANAGER": 2, "OWNER": 3}
+
+// expires returns when the membership ends, the zero time if it doesn't.
+// Only the MEMBER role can expire, and with it the membership.
+func (m *membership) expires() time.Time {
+	for _, r := range m.Roles {
+		if r.ExpiryDetail == nil {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, r.ExpiryDetail.ExpireTime); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// listMemberships returns the groups email is a direct member of.
+func listMemberships(ctx context.Context, client *http.Client, email string) ([]*membership, error) {
+	all := []*membership{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Memberships   []*membership `json:"memberships"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(groupsBasePath, "groups/-/memberships:searchDirectGroups", url.Values{
+			"query":     {"member_key_id == '" + email + "'"},
+			"pageSize":  {"500"},
+			"pageToken": {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Memberships...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
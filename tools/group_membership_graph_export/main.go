@@ -0,0 +1,93 @@
+// Package groupmembershipgraphexport is the group_membership_graph_export
+// tool: the group membership graph as Graphviz DOT, GraphML or Neo4j
+// Cypher.
+package groupmembershipgraphexport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/graph"
+	"github.com/jburnham/google_apps_tools/pkg/preflight"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_membership_graph_export, which Run
+// parses.
+var commandLine = flag.NewFlagSet("group_membership_graph_export", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for groups.")
+	formatFlag            = commandLine.String("format", "dot", "The output format: dot (Graphviz), graphml or cypher (Neo4j).")
+	outputFile            = commandLine.String("output-file", "", "The file to write out. Defaults to memberships.<format>.")
+	preflightFlag         = commandLine.String("preflight", "warn", "Check API health before starting: off, warn, or wait (back off until healthy).")
+	preflightMaxWaitFlag  = commandLine.Duration("preflight-max-wait", 30*time.Minute, "How long -preflight=wait waits for the service to recover.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs group_membership_graph_export with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_membership_graph_export", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(graph.CheckFormat(*formatFlag))
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	if *outputFile == "" {
+		*outputFile = "memberships." + *formatFlag
+	}
+
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryGroupReadonlyScope, admin.AdminDirectoryGroupMemberReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	probe := func() error {
+		_, err := service.Groups.List().Domain(*domainFlag).MaxResults(1).Do()
+		return err
+	}
+	if err := preflight.Run(ctx, probe, preflight.Options{Mode: *preflightFlag, MaxWait: *preflightMaxWaitFlag}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Fetching membership graph")
+	g, err := graph.Fetch(ctx, service, *domainFlag)
+	if err != nil {
+		log.Fatalf("Error fetching memberships: %v", err)
+	}
+	log.Printf("%d nodes, %d memberships", len(g.Nodes), len(g.Edges))
+	file, err := os.Create(*outputFile)
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	if err := graph.Write(file, g, *formatFlag); err != nil {
+		log.Fatalf("Error writing graph: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("Error writing graph: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package grouppurge is the group_purge tool: deletes groups after
+// exporting each one's settings and members.
+package grouppurge
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/groupsettings"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_purge, which Run parses.
+var commandLine = flag.NewFlagSet("group_purge", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	groupsFlag            = commandLine.String("groups", "", "Comma separated addresses of groups to delete.")
+	groupsFileFlag        = commandLine.String("groups-file", "", "A file with one group address per line (or a csv with an email column) to delete.")
+	exportDirFlag         = commandLine.String("export-dir", "", "The directory the pre-deletion exports are written to.")
+	confirmFlag           = commandLine.Bool("confirm", false, "Actually delete the groups. Without it the exports are taken and the deletes only logged.")
+	limits                = registerLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "group_purge")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// registerLimitFlags defines -max-changes and -force on fs. Every group
+// named is meant to go, so there is nothing for -max-delete-fraction to
+// count the deletes against and it isn't offered.
+func registerLimitFlags(fs *flag.FlagSet) *reconcile.Limits {
+	l := &reconcile.Limits{}
+	fs.IntVar(&l.MaxChanges, "max-changes", 0, "Refuse to run if there are more than this many groups to delete (0 disables).")
+	fs.BoolVar(&l.Force, "force", false, "Delete the groups even if there are more than -max-changes.")
+	return l
+}
+
+// export is everything needed to recreate a group by hand.
+type export struct {
+	ExportedAt time.Time              `json:"exported_at"`
+	Group      *admin.Group           `json:"group"`
+	Settings   groupsettings.Settings `json:"settings"`
+	Members    []*admin.Member        `json:"members"`
+}
+
+// Run runs group_purge with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_purge", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "export-dir")
+	check.RequireOne("groups", "groups-file")
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	groups, err := selectedGroups()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(groups) == 0 {
+		log.Fatal("No groups given; use -groups or -groups-file")
+	}
+	if err := os.MkdirAll(*exportDirFlag, 0700); err != nil {
+		log.Fatalf("Could not create export directory: %v", err)
+	}
+
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryGroupScope, groupsettings.Scope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// A group is only queued for deletion once its export has been written
+	// and read back in this run. There is deliberately no way to skip this.
+	changes := []*reconcile.Change{}
+	for _, email := range groups {
+		group, path, err := exportGroup(ctx, service, client, email)
+		if err != nil {
+			log.Printf("Not deleting %s: export failed: %v", email, err)
+			continue
+		}
+		log.Printf("Exported %s to %s", email, path)
+		// The group is deleted by the ID that was exported, so an address
+		// reassigned since can't take another group with it.
+		id := group.Id
+		changes = append(changes, &reconcile.Change{
+			Action: "delete",
+			Target: email,
+			Apply: func() error {
+				return service.Groups.Delete(id).Do()
+			},
+		})
+	}
+	if !*confirmFlag {
+		log.Println("Running without -confirm; nothing will be deleted")
+	}
+	if _, err := reconcile.Apply(changes, reconcile.Options{DryRun: !*confirmFlag, Limits: limits, Plan: plan}); err != nil {
+		log.Fatal(err)
+	}
+	if len(changes) < len(groups) {
+		log.Fatalf("%d of %d groups were not deleted because their export failed", len(groups)-len(changes), len(groups))
+	}
+	log.Println("Complete")
+	return nil
+}
+
+func selectedGroups() ([]string, error) {
+	groups := listfile.Split(*groupsFlag)
+	if *groupsFileFlag != "" {
+		fromFile, err := listfile.Read(*groupsFileFlag, "email")
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, fromFile...)
+	}
+	return groups, nil
+}
+
+// exportGroup writes the group, its settings and its members to a JSON file
+// and verifies the file reads back intact. It returns the group and the
+// file's path.
+func exportGroup(ctx context.Context, service *admin.Service, client *http.Client, email string) (*admin.Group, string, error) {
+	group, err := service.Groups.Get(email).Do()
+	if err != nil {
+		return nil, "", err
+	}
+	members, err := directory.ListMembers(ctx, service, group.Id)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching members: %v", err)
+	}
+	settings, err := groupsettings.Get(ctx, client, group.Email)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching settings: %v", err)
+	}
+	ex := &export{ExportedAt: time.Now(), Group: group, Settings: settings, Members: members}
+	data, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	path := filepath.Join(*exportDirFlag, fmt.Sprintf("%s-%s.json", group.Email, ex.ExportedAt.Format("20060102T150405")))
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return nil, "", err
+	}
+
+	readBack, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	check := &export{}
+	if err := json.Unmarshal(readBack, check); err != nil {
+		return nil, "", fmt.Errorf("export did not read back: %v", err)
+	}
+	if check.Group == nil || check.Group.Id != group.Id || len(check.Members) != len(members) || len(check.Settings) == 0 {
+		return nil, "", fmt.Errorf("export at %s is incomplete", path)
+	}
+	return group, path, nil
+}
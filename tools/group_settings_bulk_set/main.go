@@ -0,0 +1,136 @@
+// Package groupsettingsbulkset is the group_settings_bulk_set tool: sets
+// Groups Settings attributes across a list of groups, recording the old
+// values.
+package groupsettingsbulkset
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/groupsettings"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_settings_bulk_set, which Run parses.
+var commandLine = flag.NewFlagSet("group_settings_bulk_set", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	setFlag               = commandLine.String("set", "", "The setting to apply, as attribute=value, e.g. whoCanPostMessage=ALL_MEMBERS_CAN_POST.")
+	groupsFlag            = commandLine.String("groups", "", "Comma separated addresses of groups to update.")
+	groupsFileFlag        = commandLine.String("groups-file", "", "A file with one group address per line (or a csv with an email column) to update.")
+	rollbackFile          = commandLine.String("rollback-file", "group_settings_rollback.csv", "Where each group's previous value is recorded before it is changed.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "group_settings_bulk_set")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs group_settings_bulk_set with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_settings_bulk_set", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "set")
+	check.RequireOne("groups", "groups-file")
+	parts := strings.SplitN(*setFlag, "=", 2)
+	if *setFlag != "" && (len(parts) != 2 || parts[0] == "") {
+		check.Problemf("invalid -set %q: expected attribute=value", *setFlag)
+	}
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	attribute, value := parts[0], parts[1]
+
+	groups := listfile.Split(*groupsFlag)
+	if *groupsFileFlag != "" {
+		fromFile, err := listfile.Read(*groupsFileFlag, "email")
+		if err != nil {
+			log.Fatalf("Could not read groups: %v", err)
+		}
+		groups = append(groups, fromFile...)
+	}
+	if len(groups) == 0 {
+		log.Fatal("No groups given; use -groups or -groups-file")
+	}
+
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, groupsettings.Scope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rollback := [][]string{{"email", "attribute", "value"}}
+	changes := []*reconcile.Change{}
+	for _, email := range groups {
+		current, err := groupsettings.Get(ctx, client, email)
+		if err != nil {
+			log.Fatalf("Error fetching settings of %s: %v", email, err)
+		}
+		// Every group returns every attribute, so a missing one is a typo
+		// rather than a setting this group lacks.
+		if _, ok := current[attribute]; !ok {
+			log.Fatalf("Groups have no setting %q; they have %s", attribute, strings.Join(current.Names(), ", "))
+		}
+		old := current.String(attribute)
+		if old == value {
+			continue
+		}
+		rollback = append(rollback, []string{email, attribute, old})
+		changes = append(changes, setChange(ctx, client, email, attribute, old, value))
+	}
+	if !*dryRunFlag {
+		if err := output.WriteCSV(*rollbackFile, rollback); err != nil {
+			log.Fatalf("Error writing rollback file: %v", err)
+		}
+	}
+	log.Printf("%d of %d groups to update", len(changes), len(groups))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:  *dryRunFlag,
+		Canary:  *canaryFlag,
+		Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:  limits,
+		Plan:    plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+func setChange(ctx context.Context, client *http.Client, email, attribute, old, value string) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "set",
+		Target:  email,
+		Subject: fmt.Sprintf("%s=%s (was %s)", attribute, value, old),
+		Apply: func() error {
+			_, err := groupsettings.Patch(ctx, client, email, groupsettings.Settings{attribute: value})
+			return err
+		},
+	}
+}
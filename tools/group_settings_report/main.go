@@ -0,0 +1,185 @@
+// Package groupsettingsreport is the group_settings_report tool: each
+// group's access settings from the Groups Settings API, for access reviews.
+package groupsettingsreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/groupsettings"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_settings_report, which Run parses.
+var commandLine = flag.NewFlagSet("group_settings_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for groups, or several separated by commas.")
+	allDomainsFlag        = commandLine.Bool("all-domains", false, "Query every verified domain of the customer instead of -domain.")
+	groupFlag             = commandLine.String("group", "", "Report only this group, skipping the listing of every group in -domain.")
+	outputFile            = commandLine.String("output-file", "group_settings.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "group_settings_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// attributes are the Groups Settings attributes reported, the ones access
+// reviews ask about: who can find, join, see and post to a group, and
+// whether outsiders can be in it. Each is a column named in snake_case.
+var attributes = []string{
+	"whoCanJoin",
+	"whoCanViewMembership",
+	"whoCanViewGroup",
+	"whoCanPostMessage",
+	"whoCanContactOwner",
+	"whoCanLeaveGroup",
+	"allowExternalMembers",
+	"allowWebPosting",
+	"includeInGlobalAddressList",
+	"messageModerationLevel",
+	"spamModerationLevel",
+	"isArchived",
+}
+
+// column returns the snake_case column name of a camelCase attribute.
+func column(attribute string) string {
+	var b strings.Builder
+	for _, r := range attribute {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Run runs group_settings_report with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_settings_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	domains := listfile.Split(*domainFlag)
+	switch {
+	case *allDomainsFlag && len(domains) > 0:
+		check.Problemf("-all-domains and -domain can't be used together")
+	case *groupFlag != "" && (len(domains) > 0 || *allDomainsFlag):
+		check.Problemf("-group can't be used with -domain or -all-domains")
+	case *groupFlag == "" && len(domains) == 0 && !*allDomainsFlag:
+		check.Problemf("one of -domain, -all-domains or -group is required")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	scopes := []string{admin.AdminDirectoryGroupReadonlyScope, groupsettings.Scope}
+	if *allDomainsFlag {
+		scopes = append(scopes, admin.AdminDirectoryDomainReadonlyScope)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *allDomainsFlag {
+		domains, err = directory.ListDomains(ctx, service)
+		if err != nil {
+			log.Fatalf("Error fetching domains: %v", err)
+		}
+	}
+
+	var groups []*directory.DomainGroup
+	if *groupFlag != "" {
+		var group *admin.Group
+		group, err = service.Groups.Get(*groupFlag).Do()
+		if err == nil {
+			domain := strings.ToLower(group.Email[strings.LastIndex(group.Email, "@")+1:])
+			groups = []*directory.DomainGroup{{Domain: domain, Group: group}}
+		}
+	} else {
+		groups, err = directory.ListDomainGroups(ctx, service, domains, func(domain string, n int) {
+			log.Printf("%d groups in %s", n, domain)
+		})
+	}
+	if shutdown.Interrupted(ctx) {
+		log.Println("Interrupted before any settings were fetched")
+		os.Exit(shutdown.ExitCode)
+	}
+	if err != nil {
+		log.Fatalf("Error fetching groups: %v", err)
+	}
+
+	header := []string{"domain", "group", "name"}
+	for _, a := range attributes {
+		header = append(header, column(a))
+	}
+	header = append(header, "error")
+	writer, err := outputOptions.Create(*outputFile, header)
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	failed, done := 0, 0
+	for _, group := range groups {
+		row := []string{group.Domain, group.Email, group.Name}
+		// A group whose settings can't be read still gets a row, so the
+		// review sees every group.
+		settings, err := groupsettings.Get(ctx, client, group.Email)
+		if shutdown.Interrupted(ctx) {
+			break
+		}
+		done++
+		if err != nil {
+			log.Printf("Error fetching settings of %s: %v", group.Email, err)
+			failed++
+			settings = groupsettings.Settings{}
+		}
+		for _, a := range attributes {
+			row = append(row, settings.String(a))
+		}
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		if err := writer.Write(append(row, errText)); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if shutdown.Interrupted(ctx) {
+		if err := output.MarkIncomplete(writer, "interrupted"); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if shutdown.Interrupted(ctx) {
+		log.Printf("Interrupted: wrote partial report of %d of %d groups", done, len(groups))
+		os.Exit(shutdown.ExitCode)
+	}
+	log.Printf("%d groups, %d whose settings couldn't be read", len(groups), failed)
+	log.Println("Complete")
+	return nil
+}
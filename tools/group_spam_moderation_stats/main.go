@@ -0,0 +1,147 @@
+// Package groupspammoderationstats is the group_spam_moderation_stats tool:
+// per-group counts of moderated, approved, rejected and spam messages.
+package groupspammoderationstats
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of group_spam_moderation_stats, which Run
+// parses.
+var commandLine = flag.NewFlagSet("group_spam_moderation_stats", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	dateRange             = reports.RegisterDateFlags(commandLine, "30d")
+	outputFile            = commandLine.String("output-file", "group_moderation_stats.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "group_spam_moderation_stats", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// stats are one group's moderation counts.
+type stats struct {
+	group    string
+	approved int
+	rejected int
+	spam     int
+	other    int
+	bans     int
+}
+
+func (s *stats) total() int { return s.approved + s.rejected + s.spam + s.other }
+
+type byTotal []*stats
+
+func (s byTotal) Len() int      { return len(s) }
+func (s byTotal) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byTotal) Less(i, j int) bool {
+	if s[i].total() != s[j].total() {
+		return s[i].total() > s[j].total()
+	}
+	return s[i].group < s[j].group
+}
+
+// Run runs group_spam_moderation_stats with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_spam_moderation_stats", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(dateRange.Check())
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, reports.AuditReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Fetching Groups activity from %s", dateRange)
+	// The groups audit log records what moderators (and the spam filter,
+	// acting as one) do with held messages.
+	q := reports.ActivityQuery{Application: "groups"}
+	if err := dateRange.Apply(&q); err != nil {
+		log.Fatal(err)
+	}
+	activities, err := reports.Activities(ctx, client, q)
+	if err != nil {
+		log.Fatalf("Error fetching audit log: %v", err)
+	}
+
+	groups := map[string]*stats{}
+	get := func(group string) *stats {
+		group = strings.ToLower(group)
+		s, ok := groups[group]
+		if !ok {
+			s = &stats{group: group}
+			groups[group] = s
+		}
+		return s
+	}
+	for _, a := range activities {
+		for _, e := range a.Events {
+			switch e.Name {
+			case "moderate_message":
+				s := get(e.Param("group_email"))
+				action := strings.ToLower(e.Param("message_moderation_action"))
+				switch {
+				case strings.Contains(action, "spam"):
+					s.spam++
+				case strings.Contains(action, "approve"):
+					s.approved++
+				case strings.Contains(action, "reject"), strings.Contains(action, "delete"):
+					s.rejected++
+				default:
+					s.other++
+				}
+			case "ban_user_with_moderation":
+				get(e.Param("group_email")).bans++
+			}
+		}
+	}
+
+	sorted := []*stats{}
+	for _, s := range groups {
+		sorted = append(sorted, s)
+	}
+	sort.Sort(byTotal(sorted))
+	rows := [][]string{
+		{"group", "moderated", "approved", "rejected", "spam", "other", "bans", "spam_percent"},
+	}
+	for _, s := range sorted {
+		percent := strconv.FormatFloat(100*float64(s.spam)/float64(s.total()), 'f', 1, 64)
+		if s.total() == 0 {
+			percent = ""
+		}
+		rows = append(rows, []string{s.group, strconv.Itoa(s.total()), strconv.Itoa(s.approved), strconv.Itoa(s.rejected),
+			strconv.Itoa(s.spam), strconv.Itoa(s.other), strconv.Itoa(s.bans), percent})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d groups with moderation activity", len(sorted))
+	log.Println("Complete")
+	return nil
+}
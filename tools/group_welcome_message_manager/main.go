@@ -0,0 +1,283 @@
+// Package groupwelcomemessagemanager is the group_welcome_message_manager
+// tool: audits or standardizes groups' message footers and rejection
+// replies from templates.
+package groupwelcomemessagemanager
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/groupsettings"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// The limits the Groups Settings API puts on the message texts.
+const (
+	maxFooter           = 1000
+	maxDenyNotification = 10000
+)
+
+// commandLine holds the flags of group_welcome_message_manager, which Run
+// parses.
+var commandLine = flag.NewFlagSet("group_welcome_message_manager", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose groups to manage.")
+	groupsFlag            = commandLine.String("groups", "", "Comma separated addresses of groups to manage instead of every group in -domain.")
+	groupsFileFlag        = commandLine.String("groups-file", "", "A file with one group address per line (or a csv with an email column) to manage instead of every group in -domain.")
+	footerFileFlag        = commandLine.String("footer-file", "", "A text/template file of the footer every message to the group gets, using .Email, .Name and .Description. Without it or -reply-file the messages are only audited.")
+	replyFileFlag         = commandLine.String("reply-file", "", "A text/template file of the automatic reply to senders whose message is rejected, using .Email, .Name and .Description.")
+	reportFile            = commandLine.String("report-file", "group_welcome_messages.csv", "The csv of each group's current messages, written before any update.")
+	outputOptions         = output.RegisterFlags(commandLine, "group_welcome_message_manager", "csv")
+	restoreFileFlag       = commandLine.String("restore-file", "", "Instead of auditing or setting any groups, put back the settings recorded in this rollback file.")
+	rollbackFile          = commandLine.String("rollback-file", "welcome_message_rollback.csv", "Where each group's previous settings are recorded before they are changed. It mustn't exist yet, so an earlier run's is never overwritten.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "group_welcome_message_manager")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// message is one standard text and the Groups Settings attributes that
+// hold and switch it on.
+type message struct {
+	name   string
+	text   string
+	enable string
+	max    int
+}
+
+// The Groups Settings API has no welcome message, so the footer, which
+// every message to the group carries, is where onboarding text goes.
+var messages = []*message{
+	{name: "footer", text: "customFooterText", enable: "includeCustomFooter", max: maxFooter},
+	{name: "reply", text: "defaultMessageDenyNotificationText", enable: "sendMessageDenyNotification", max: maxDenyNotification},
+}
+
+// templateData is what the message templates are executed against.
+type templateData struct {
+	Email       string
+	Name        string
+	Description string
+}
+
+// Run runs group_welcome_message_manager with args, the arguments that
+// follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("group_welcome_message_manager", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.RequireOne("domain", "groups", "groups-file", "restore-file")
+	if *restoreFileFlag != "" && (*domainFlag != "" || *groupsFlag != "" || *groupsFileFlag != "" || *footerFileFlag != "" || *replyFileFlag != "") {
+		check.Problemf("-restore-file can't be used with -domain, -groups, -groups-file, -footer-file or -reply-file")
+	}
+	if _, err := os.Stat(*rollbackFile); err == nil && (*footerFileFlag != "" || *replyFileFlag != "") && *restoreFileFlag == "" && !*dryRunFlag {
+		check.Problemf("-rollback-file %s already exists; move it aside or give another -rollback-file", *rollbackFile)
+	}
+	templates := map[*message]*template.Template{}
+	for m, path := range map[*message]string{messages[0]: *footerFileFlag, messages[1]: *replyFileFlag} {
+		if path == "" {
+			continue
+		}
+		tmpl, err := readTemplate(path)
+		check.Check(err)
+		templates[m] = tmpl
+	}
+	check.Check(outputOptions.CheckFormat())
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryGroupReadonlyScope, groupsettings.Scope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *restoreFileFlag != "" {
+		changes, err := restoreChanges(ctx, client, *restoreFileFlag)
+		if err != nil {
+			log.Fatalf("Could not read rollback file: %v", err)
+		}
+		log.Printf("%d groups to restore", len(changes))
+		if _, err := reconcile.Apply(changes, reconcile.Options{
+			DryRun:  *dryRunFlag,
+			Canary:  *canaryFlag,
+			Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+			Limits:  limits,
+			Plan:    plan,
+		}); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Complete")
+		return nil
+	}
+
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	groups, err := listGroups(ctx, service)
+	if err != nil {
+		log.Fatalf("Error fetching groups: %v", err)
+	}
+
+	report := [][]string{{"email", "name", "include_custom_footer", "custom_footer_text",
+		"send_message_deny_notification", "default_message_deny_notification_text", "standard"}}
+	rollback := [][]string{{"email", "attribute", "value"}}
+	changes := []*reconcile.Change{}
+	failed := 0
+	for _, g := range groups {
+		current, err := groupsettings.Get(ctx, client, g.Email)
+		if err != nil {
+			log.Printf("Error fetching settings of %s, skipping: %v", g.Email, err)
+			failed++
+			continue
+		}
+		// update holds only the attributes that differ from the standard.
+		update := groupsettings.Settings{}
+		data := &templateData{Email: g.Email, Name: g.Name, Description: g.Description}
+		for _, m := range messages {
+			tmpl := templates[m]
+			if tmpl == nil {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				log.Fatalf("Error rendering %s for %s: %v", m.name, g.Email, err)
+			}
+			text := strings.TrimSpace(buf.String())
+			if len(text) > m.max {
+				log.Fatalf("The %s for %s is %d characters; the limit is %d", m.name, g.Email, len(text), m.max)
+			}
+			if current.String(m.text) != text {
+				update[m.text] = text
+			}
+			if current.String(m.enable) != "true" {
+				update[m.enable] = "true"
+			}
+		}
+		row := []string{g.Email, g.Name}
+		for _, m := range messages {
+			row = append(row, current.String(m.enable), current.String(m.text))
+		}
+		standard := ""
+		if len(templates) > 0 {
+			standard = strconv.FormatBool(len(update) == 0)
+		}
+		report = append(report, append(row, standard))
+		if len(update) == 0 {
+			continue
+		}
+		for _, attribute := range update.Names() {
+			rollback = append(rollback, []string{g.Email, attribute, current.String(attribute)})
+		}
+		changes = append(changes, setChange(ctx, client, g.Email, update))
+	}
+	if err := outputOptions.WriteFile(*reportFile, report); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if len(templates) == 0 {
+		log.Printf("Audited %d groups", len(groups)-failed)
+		if failed > 0 {
+			log.Fatalf("Complete, but the settings of %d groups could not be read and are missing from the report", failed)
+		}
+		log.Println("Complete")
+		return nil
+	}
+	if !*dryRunFlag {
+		if err := output.WriteNewCSV(*rollbackFile, rollback); err != nil {
+			log.Fatalf("Error writing rollback file: %v", err)
+		}
+	}
+	log.Printf("%d of %d groups to update", len(changes), len(groups))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:  *dryRunFlag,
+		Canary:  *canaryFlag,
+		Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:  limits,
+		Plan:    plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but the settings of %d groups could not be read and were left alone", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// listGroups returns the groups named by -groups and -groups-file, or
+// every group in -domain.
+func listGroups(ctx context.Context, service *admin.Service) ([]*admin.Group, error) {
+	emails := listfile.Split(*groupsFlag)
+	if *groupsFileFlag != "" {
+		fromFile, err := listfile.Read(*groupsFileFlag, "email")
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, fromFile...)
+	}
+	if len(emails) == 0 {
+		log.Println("Fetching groups")
+		return directory.ListGroups(ctx, service, *domainFlag)
+	}
+	groups := []*admin.Group{}
+	for _, email := range emails {
+		g, err := service.Groups.Get(email).Do()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", email, err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+func readTemplate(path string) (*template.Template, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(path).Parse(string(data))
+}
+
+func setChange(ctx context.Context, client *http.Client, email string, update groupsettings.Settings) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "set",
+		Target:  email,
+		Subject: strings.Join(update.Names(), ", "),
+		Apply: func() error {
+			_, err := groupsettings.Patch(ctx, client, email, update)
+			return err
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package groupwelcomemessagemanager
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/groupsettings"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+)
+
+// restoreChanges reads a rollback file and returns the changes that put
+// each group's recorded attributes back.
+func restoreChanges(ctx context.Context, client *http.Client, path string) ([]*reconcile.Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) < 3 || records[0][0] != "email" || records[0][1] != "attribute" || records[0][2] != "value" {
+		return nil, fmt.Errorf("%s is not a rollback file: expected email, attribute and value columns", path)
+	}
+	known := map[string]bool{}
+	for _, m := range messages {
+		known[m.text], known[m.enable] = true, true
+	}
+	emails := []string{}
+	updates := map[string]groupsettings.Settings{}
+	for i, rec := range records[1:] {
+		email, attribute := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1])
+		if !known[attribute] {
+			return nil, fmt.Errorf("%s line %d: unknown attribute %q", path, i+2, attribute)
+		}
+		key := strings.ToLower(email)
+		if updates[key] == nil {
+			emails = append(emails, email)
+			updates[key] = groupsettings.Settings{}
+		}
+		updates[key][attribute] = rec[2]
+	}
+	changes := []*reconcile.Change{}
+	for _, email := range emails {
+		change := setChange(ctx, client, email, updates[strings.ToLower(email)])
+		change.Action = "restore"
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
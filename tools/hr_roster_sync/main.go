@@ -0,0 +1,106 @@
+// Package hrrostersync is the hr_roster_sync tool: compares an HR roster
+// with the directory and writes the actions for user_provision.
+package hrrostersync
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/provision"
+	"github.com/jburnham/google_apps_tools/pkg/roster"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of hr_roster_sync, which Run parses.
+var commandLine = flag.NewFlagSet("hr_roster_sync", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to compare against the roster.")
+	rosterFileFlag        = commandLine.String("roster-file", "", "The HR roster csv export.")
+	mappingFileFlag       = commandLine.String("mapping-file", "", "A YAML file describing the roster's columns; by default only an email column is read.")
+	excludeUsersFlag      = commandLine.String("exclude-users", "", "Comma separated addresses that are never leavers, added to the mapping's exclude_users.")
+	outputFile            = commandLine.String("output-file", "roster_actions.csv", "The actions csv to write out, for user_provision.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs hr_roster_sync with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("hr_roster_sync", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain", "roster-file")
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	mapping := roster.DefaultMapping()
+	if *mappingFileFlag != "" {
+		var err error
+		mapping, err = roster.LoadMapping(*mappingFileFlag)
+		if err != nil {
+			log.Fatalf("Could not load mapping: %v", err)
+		}
+	}
+	for _, e := range strings.Split(*excludeUsersFlag, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			mapping.ExcludeUsers = append(mapping.ExcludeUsers, e)
+		}
+	}
+	people, err := roster.Read(*rosterFileFlag, mapping)
+	if err != nil {
+		log.Fatalf("Could not read roster: %v", err)
+	}
+
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Fetching users")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+
+	actions, kept := roster.Compare(people, users, mapping)
+	counts := map[string]int{}
+	for _, a := range actions {
+		counts[a.Kind]++
+	}
+
+	file, err := os.Create(*outputFile)
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	if err := provision.WriteActions(file, actions); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d joiners, %d leavers, %d movers", counts[provision.Hire], counts[provision.Terminate], counts[provision.Transfer])
+	if kept > 0 {
+		log.Printf("%d active accounts missing from the roster weren't made leavers: excluded, admins or outside the OUs terminated in", kept)
+	}
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package hrwebhookreceiver is the hr_webhook_receiver tool: an HTTP server
+// turning HR webhooks into Directory user operations, optionally held for
+// approval.
+package hrwebhookreceiver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/provision"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of hr_webhook_receiver, which Run parses.
+var commandLine = flag.NewFlagSet("hr_webhook_receiver", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	mappingFileFlag       = commandLine.String("mapping-file", "", "The json file describing how HR payload fields map to directory operations.")
+	listenFlag            = commandLine.String("listen", ":8080", "The address to listen on for webhooks.")
+	sharedSecretFlag      = commandLine.String("shared-secret", "", "The value the HR system must send in the X-Webhook-Secret header with each webhook.")
+	approverSecretFlag    = commandLine.String("approver-secret", "", "The value operators must send in the X-Webhook-Secret header to list, approve or reject queued events; required with -require-approval.")
+	tlsCertFileFlag       = commandLine.String("tls-cert-file", "", "Serve HTTPS with this certificate; needs -tls-key-file.")
+	tlsKeyFileFlag        = commandLine.String("tls-key-file", "", "The private key for -tls-cert-file.")
+	requireApprovalFlag   = commandLine.Bool("require-approval", false, "Queue events for approval instead of applying them immediately.")
+	queueFileFlag         = commandLine.String("queue-file", "approval_queue.json", "Where pending events are stored when -require-approval is set.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+type server struct {
+	service *admin.Service
+	mapping *Mapping
+	queue   *Queue
+}
+
+// Run runs hr_webhook_receiver with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("hr_webhook_receiver", version.Git)
+		os.Exit(0)
+	}
+
+	// Requests create and suspend users, so none is accepted without the
+	// secret. Approvals take their own, so that whoever holds the HR
+	// system's can't approve the events it sent.
+	check.Required("credentials-file", "impersonated-email", "mapping-file", "shared-secret")
+	if *requireApprovalFlag {
+		check.Required("approver-secret")
+		if *approverSecretFlag != "" && *approverSecretFlag == *sharedSecretFlag {
+			check.Problemf("-approver-secret must differ from -shared-secret")
+		}
+	}
+	if (*tlsCertFileFlag == "") != (*tlsKeyFileFlag == "") {
+		check.Problemf("-tls-cert-file and -tls-key-file must be given together")
+	}
+	check.Done()
+
+	mapping, err := loadMapping(*mappingFileFlag)
+	if err != nil {
+		log.Fatalf("Could not load mapping: %v", err)
+	}
+	client, err := auth.ClientFromFile(context.Background(), *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{service: service, mapping: mapping}
+	if *requireApprovalFlag {
+		s.queue, err = openQueue(*queueFileFlag)
+		if err != nil {
+			log.Fatalf("Could not open approval queue: %v", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	mux.HandleFunc("/queue", s.handleQueue)
+	mux.HandleFunc("/queue/approve", s.handleApprove)
+	mux.HandleFunc("/queue/reject", s.handleReject)
+	// A client that sends or reads slowly can't hold a connection open:
+	// bodies are small, and the write timeout leaves room for the
+	// Directory API calls an applied event waits on.
+	srv := &http.Server{
+		Addr:              *listenFlag,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      2 * time.Minute,
+	}
+	if *tlsCertFileFlag != "" {
+		log.Printf("Listening on %s (HTTPS)", *listenFlag)
+		return srv.ListenAndServeTLS(*tlsCertFileFlag, *tlsKeyFileFlag)
+	}
+	log.Printf("Listening on %s; without -tls-cert-file, terminate TLS in front of it", *listenFlag)
+	return srv.ListenAndServe()
+}
+
+// maxBodyBytes bounds a request body. HR payloads and approvals are small.
+const maxBodyBytes = 1 << 20
+
+// authorized checks that the request sends secret, and limits the body it
+// may send.
+func (s *server) authorized(w http.ResponseWriter, r *http.Request, secret string) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	got := r.Header.Get("X-Webhook-Secret")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (s *server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(w, r, *sharedSecretFlag) {
+		return
+	}
+	payload := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("invalid json: %v", err), http.StatusBadRequest)
+		return
+	}
+	event, err := s.mapping.Translate(payload)
+	if err != nil {
+		log.Printf("Rejected webhook: %v", err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if s.queue != nil {
+		id, err := s.queue.Add(event)
+		if err != nil {
+			log.Printf("Error queueing %s for %s: %v", event.Kind, event.Email, err)
+			http.Error(w, "could not queue event", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Queued %s for %s as %s", event.Kind, event.Email, id)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued", "id": id})
+		return
+	}
+	if err := s.apply(event); err != nil {
+		log.Printf("Error applying %s for %s: %v", event.Kind, event.Email, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "applied"})
+}
+
+func (s *server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, *approverSecretFlag) {
+		return
+	}
+	if s.queue == nil {
+		http.Error(w, "approval queue is not enabled", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.queue.List())
+}
+
+func (s *server) handleApprove(w http.ResponseWriter, r *http.Request) {
+	qe, ok := s.dequeue(w, r)
+	if !ok {
+		return
+	}
+	if err := s.apply(qe.Event); err != nil {
+		log.Printf("Error applying approved %s for %s: %v", qe.Event.Kind, qe.Event.Email, err)
+		// Put it back so the operator can retry once the problem is fixed.
+		if _, qerr := s.queue.Add(qe.Event); qerr != nil {
+			log.Printf("Error requeueing %s: %v", qe.ID, qerr)
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "applied", "id": qe.ID})
+}
+
+func (s *server) handleReject(w http.ResponseWriter, r *http.Request) {
+	qe, ok := s.dequeue(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Rejected %s for %s (%s)", qe.Event.Kind, qe.Event.Email, qe.ID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "rejected", "id": qe.ID})
+}
+
+func (s *server) dequeue(w http.ResponseWriter, r *http.Request) (*QueuedEvent, bool) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if !s.authorized(w, r, *approverSecretFlag) {
+		return nil, false
+	}
+	if s.queue == nil {
+		http.Error(w, "approval queue is not enabled", http.StatusNotFound)
+		return nil, false
+	}
+	id := r.FormValue("id")
+	qe, err := s.queue.Remove(id)
+	if err != nil {
+		log.Printf("Error removing %s from the queue: %v", id, err)
+		http.Error(w, "could not update the queue", http.StatusInternalServerError)
+		return nil, false
+	}
+	if qe == nil {
+		http.Error(w, fmt.Sprintf("no queued event with id %q", id), http.StatusNotFound)
+		return nil, false
+	}
+	return qe, true
+}
+
+// apply performs the directory operation for a.
+func (s *server) apply(a *provision.Action) error {
+	c := provision.Change(s.service, a)
+	if err := c.Apply(); err != nil {
+		return err
+	}
+	log.Printf("Applied: %s", c)
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package hrwebhookreceiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jburnham/google_apps_tools/pkg/provision"
+)
+
+// Mapping describes how an HR system's webhook payload maps onto directory
+// operations. Field values are dotted paths into the JSON body, e.g.
+// "employee.workEmail".
+type Mapping struct {
+	EventTypeField  string            `json:"event_type_field"`
+	EventTypes      map[string]string `json:"event_types"`
+	EmailField      string            `json:"email_field"`
+	GivenNameField  string            `json:"given_name_field"`
+	FamilyNameField string            `json:"family_name_field"`
+	OrgUnitField    string            `json:"org_unit_field"`
+	OrgUnits        map[string]string `json:"org_units"`
+}
+
+func loadMapping(path string) (*Mapping, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if m.EventTypeField == "" || m.EmailField == "" {
+		return nil, fmt.Errorf("%s: event_type_field and email_field are required", path)
+	}
+	for hrType, action := range m.EventTypes {
+		switch action {
+		case provision.Hire, provision.Terminate, provision.Transfer:
+		default:
+			return nil, fmt.Errorf("%s: event type %q maps to unknown action %q", path, hrType, action)
+		}
+	}
+	return m, nil
+}
+
+// Translate converts a decoded webhook payload into a provisioning action.
+func (m *Mapping) Translate(payload map[string]interface{}) (*provision.Action, error) {
+	hrType := lookup(payload, m.EventTypeField)
+	kind, ok := m.EventTypes[hrType]
+	if !ok {
+		return nil, fmt.Errorf("unmapped event type %q", hrType)
+	}
+	a := &provision.Action{
+		Kind:       kind,
+		Email:      lookup(payload, m.EmailField),
+		GivenName:  lookup(payload, m.GivenNameField),
+		FamilyName: lookup(payload, m.FamilyNameField),
+	}
+	if a.Email == "" {
+		return nil, fmt.Errorf("payload has no value at %q", m.EmailField)
+	}
+	ou := lookup(payload, m.OrgUnitField)
+	if mapped, ok := m.OrgUnits[ou]; ok {
+		ou = mapped
+	}
+	a.OrgUnitPath = ou
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// lookup walks a dotted path through nested JSON objects and returns the
+// value found there as a string, or "" if there is none.
+func lookup(payload map[string]interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	var cur interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		cur = obj[key]
+	}
+	switch v := cur.(type) {
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package hrwebhookreceiver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jburnham/google_apps_tools/pkg/provision"
+)
+
+// QueuedEvent is an event waiting for an operator to approve or reject it.
+type QueuedEvent struct {
+	ID       string            `json:"id"`
+	Received time.Time         `json:"received"`
+	Event    *provision.Action `json:"event"`
+}
+
+// Queue holds events pending approval. Every change is written through to
+// path so pending events survive a restart.
+type Queue struct {
+	mu      sync.Mutex
+	path    string
+	pending []*QueuedEvent
+}
+
+func openQueue(path string) (*Queue, error) {
+	q := &Queue{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &q.pending); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return q, nil
+}
+
+// Add queues e and returns its ID.
+func (q *Queue) Add(e *provision.Action) (string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, &QueuedEvent{ID: id, Received: time.Now(), Event: e})
+	return id, q.save()
+}
+
+// List returns a copy of the pending events in arrival order.
+func (q *Queue) List() []*QueuedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]*QueuedEvent(nil), q.pending...)
+}
+
+// Remove takes the event with the given ID off the queue and returns it,
+// or nil if there is none. If the queue can't be saved without it, the
+// event stays queued and the error is returned.
+func (q *Queue) Remove(id string) (*QueuedEvent, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, qe := range q.pending {
+		if qe.ID != id {
+			continue
+		}
+		// Remove from a copy, so the old slice can be put back.
+		old := q.pending
+		q.pending = append(append([]*QueuedEvent(nil), old[:i]...), old[i+1:]...)
+		if err := q.save(); err != nil {
+			q.pending = old
+			return nil, err
+		}
+		return qe, nil
+	}
+	return nil, nil
+}
+
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(q.pending, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
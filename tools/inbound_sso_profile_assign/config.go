@@ -0,0 +1,108 @@
+package inboundssoprofileassign
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/jburnham/google_apps_tools/pkg/inboundsso"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+)
+
+// modeInherit removes a target's assignment, so it signs in like its
+// parent OU.
+const modeInherit = "inherit"
+
+// Config is the SSO assignments a -config-file asks for. Targets it
+// doesn't list are left alone, so a cutover can move a few OUs or pilot
+// groups at a time.
+type Config struct {
+	Assignments []*Entry `yaml:"assignments"`
+}
+
+// Entry is the assignment one OU or group should have.
+type Entry struct {
+	OrgUnit string `yaml:"org_unit,omitempty"`
+	Group   string `yaml:"group,omitempty"`
+	// Profile is a profile's display name or resource name. Its kind
+	// sets the mode.
+	Profile string `yaml:"profile,omitempty"`
+	// Mode, without a profile, is SSO_OFF, DOMAIN_WIDE_SAML_IF_ENABLED or
+	// inherit.
+	Mode string `yaml:"mode,omitempty"`
+	// Rank orders group assignments, 1 first. OUs have none.
+	Rank              int    `yaml:"rank,omitempty"`
+	RedirectCondition string `yaml:"redirect_condition,omitempty"`
+}
+
+func (e *Entry) target() string {
+	if e.Group != "" {
+		return "group " + e.Group
+	}
+	return "OU " + e.OrgUnit
+}
+
+// loadConfig reads and checks the YAML file at path, e.g.
+//
+//	assignments:
+//	  - org_unit: /Engineering
+//	    profile: Okta
+//	  - group: okta-pilot@example.com
+//	    profile: Okta
+//	    rank: 1
+//	  - org_unit: /Contractors
+//	    mode: SSO_OFF
+//	  - org_unit: /Engineering/Interns
+//	    mode: inherit
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	seen := map[string]bool{}
+	for i, e := range c.Assignments {
+		if (e.OrgUnit == "") == (e.Group == "") {
+			return nil, fmt.Errorf("%s: assignment %d needs one of org_unit or group", path, i+1)
+		}
+		switch {
+		case e.Profile != "" && e.Mode != "":
+			return nil, fmt.Errorf("%s: %s has both a profile and a mode; the profile sets the mode", path, e.target())
+		case e.Profile == "" && e.Mode != inboundsso.ModeOff && e.Mode != inboundsso.ModeDomainWide && e.Mode != modeInherit:
+			return nil, fmt.Errorf("%s: %s needs a profile, or a mode of %s, %s or %s", path, e.target(),
+				inboundsso.ModeOff, inboundsso.ModeDomainWide, modeInherit)
+		case e.Group != "" && e.Mode != modeInherit && e.Rank < 1:
+			return nil, fmt.Errorf("%s: group %s needs a rank of 1 or more", path, e.Group)
+		case e.OrgUnit != "" && e.Rank != 0:
+			return nil, fmt.Errorf("%s: OU %s can't have a rank; only group assignments are ranked", path, e.OrgUnit)
+		}
+		key := strings.ToLower(e.target())
+		if seen[key] {
+			return nil, fmt.Errorf("%s: %s is listed twice", path, e.target())
+		}
+		seen[key] = true
+	}
+	return c, nil
+}
+
+// writeConfig writes c as a file loadConfig reads, failing if path exists.
+func writeConfig(path string, c *Config) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	file, err := output.CreateNew(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
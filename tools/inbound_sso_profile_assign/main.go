@@ -0,0 +1,297 @@
+// Package inboundssoprofileassign is the inbound_sso_profile_assign tool:
+// assigns and unassigns inbound SSO profiles to OUs and groups from a YAML
+// file.
+package inboundssoprofileassign
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/inboundsso"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of inbound_sso_profile_assign, which Run
+// parses.
+var commandLine = flag.NewFlagSet("inbound_sso_profile_assign", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	customerIDFlag        = commandLine.String("customer-id", "", "The customer whose SSO assignments to change. Defaults to the impersonated admin's.")
+	configFileFlag        = commandLine.String("config-file", "", "A YAML file of the SSO assignments OUs and groups should have.")
+	rollbackFile          = commandLine.String("rollback-file", "sso_assignment_rollback.yaml", "Where the previous assignments of the targets changed are written, as a -config-file that restores them. It mustn't exist yet, so an earlier run's is never overwritten.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "inbound_sso_profile_assign")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs inbound_sso_profile_assign with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("inbound_sso_profile_assign", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "config-file")
+	var desired *Config
+	if *configFileFlag != "" {
+		var err error
+		desired, err = loadConfig(*configFileFlag)
+		check.Check(err)
+	}
+	if _, err := os.Stat(*rollbackFile); err == nil && !*dryRunFlag {
+		check.Problemf("-rollback-file %s already exists; move it aside or give another -rollback-file", *rollbackFile)
+	}
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, inboundsso.Scope,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryOrgunitReadonlyScope, admin.AdminDirectoryGroupReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	customerID := *customerIDFlag
+	if customerID == "" {
+		u, err := service.Users.Get(*impersonatedEmailFlag).Do()
+		if err != nil {
+			log.Fatalf("Error fetching the customer ID: %v", err)
+		}
+		customerID = u.CustomerId
+	}
+
+	log.Println("Fetching inbound SSO profiles and assignments")
+	profiles, err := inboundsso.ListProfiles(ctx, client, customerID)
+	if err != nil {
+		log.Fatalf("Error fetching SSO profiles: %v", err)
+	}
+	assignments, err := inboundsso.ListAssignments(ctx, client, customerID)
+	if err != nil {
+		log.Fatalf("Error fetching SSO assignments: %v", err)
+	}
+	current := map[string]*inboundsso.Assignment{}
+	for _, a := range assignments {
+		current[a.Target()] = a
+	}
+	targets, err := newResolver(ctx, service)
+	if err != nil {
+		log.Fatalf("Error fetching OUs: %v", err)
+	}
+
+	rollback := &Config{Assignments: []*Entry{}}
+	changes := []*reconcile.Change{}
+	for _, e := range desired.Assignments {
+		target, err := targets.resolve(e)
+		if err != nil {
+			log.Fatal(err)
+		}
+		want, err := assignment(e, profiles, customerID, target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		have := current[target]
+		switch {
+		case want == nil && have == nil:
+			continue
+		case want == nil:
+			changes = append(changes, removeChange(ctx, client, e.target(), have))
+		case have == nil:
+			changes = append(changes, assignChange(ctx, client, e.target(), want))
+		case !same(have, want):
+			want.Name = have.Name
+			changes = append(changes, updateChange(ctx, client, e.target(), have, want))
+		default:
+			continue
+		}
+		rollback.Assignments = append(rollback.Assignments, previous(e, have))
+	}
+	if !*dryRunFlag && len(changes) > 0 {
+		if err := writeConfig(*rollbackFile, rollback); err != nil {
+			log.Fatalf("Error writing rollback file: %v", err)
+		}
+	}
+	log.Printf("%d of %d assignments to change", len(changes), len(desired.Assignments))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: len(assignments),
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// resolver turns OU paths and group addresses into the API's target names.
+type resolver struct {
+	service *admin.Service
+	ous     map[string]string
+}
+
+func newResolver(ctx context.Context, service *admin.Service) (*resolver, error) {
+	ous, err := directory.ListOrgUnits(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	r := &resolver{service: service, ous: map[string]string{}}
+	for _, ou := range ous {
+		r.ous[strings.ToLower(ou.OrgUnitPath)] = strings.TrimPrefix(ou.OrgUnitId, "id:")
+		if ou.ParentOrgUnitPath == "/" {
+			r.ous["/"] = strings.TrimPrefix(ou.ParentOrgUnitId, "id:")
+		}
+	}
+	return r, nil
+}
+
+// resolve returns e's target as "orgUnits/<id>" or "groups/<id>".
+func (r *resolver) resolve(e *Entry) (string, error) {
+	if e.Group != "" {
+		g, err := r.service.Groups.Get(e.Group).Do()
+		if err != nil {
+			return "", fmt.Errorf("group %s: %v", e.Group, err)
+		}
+		return "groups/" + g.Id, nil
+	}
+	id, ok := r.ous[strings.ToLower(e.OrgUnit)]
+	if !ok {
+		return "", fmt.Errorf("no OU %s", e.OrgUnit)
+	}
+	return "orgUnits/" + id, nil
+}
+
+// assignment returns the assignment e asks for on target, or nil to
+// inherit.
+func assignment(e *Entry, profiles []*inboundsso.Profile, customerID, target string) (*inboundsso.Assignment, error) {
+	if e.Mode == modeInherit {
+		return nil, nil
+	}
+	a := &inboundsso.Assignment{Customer: "customers/" + customerID, SsoMode: e.Mode, Rank: float64(e.Rank)}
+	if strings.HasPrefix(target, "groups/") {
+		a.TargetGroup = target
+	} else {
+		a.TargetOrgUnit = target
+	}
+	if e.RedirectCondition != "" {
+		a.SignInBehavior = &inboundsso.SignInBehavior{RedirectCondition: e.RedirectCondition}
+	}
+	if e.Profile == "" {
+		return a, nil
+	}
+	var p *inboundsso.Profile
+	for _, candidate := range profiles {
+		if candidate.Name == e.Profile || strings.EqualFold(candidate.DisplayName, e.Profile) {
+			if p != nil {
+				return nil, fmt.Errorf("%s: more than one profile is called %q; use its resource name", e.target(), e.Profile)
+			}
+			p = candidate
+		}
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%s: no SSO profile %q", e.target(), e.Profile)
+	}
+	a.SsoMode = p.Mode()
+	if a.SsoMode == inboundsso.ModeOIDC {
+		a.OidcSsoInfo = &inboundsso.OIDCSSOInfo{InboundOidcSsoProfile: p.Name}
+	} else {
+		a.SamlSsoInfo = &inboundsso.SAMLSSOInfo{InboundSamlSsoProfile: p.Name}
+	}
+	return a, nil
+}
+
+// same reports whether have already is want.
+func same(have, want *inboundsso.Assignment) bool {
+	return have.SsoMode == want.SsoMode && have.ProfileName() == want.ProfileName() &&
+		have.Rank == want.Rank && have.RedirectCondition() == want.RedirectCondition()
+}
+
+// previous returns the entry restoring have on e's target, or making it
+// inherit again if it had no assignment.
+func previous(e *Entry, have *inboundsso.Assignment) *Entry {
+	p := &Entry{OrgUnit: e.OrgUnit, Group: e.Group}
+	if have == nil {
+		p.Mode = modeInherit
+		return p
+	}
+	p.Profile = have.ProfileName()
+	if p.Profile == "" {
+		p.Mode = have.SsoMode
+	}
+	p.Rank = int(have.Rank)
+	p.RedirectCondition = have.RedirectCondition()
+	return p
+}
+
+// describe summarizes an assignment for the change log.
+func describe(a *inboundsso.Assignment) string {
+	s := a.SsoMode
+	if name := a.ProfileName(); name != "" {
+		s += " " + name
+	}
+	if a.Rank != 0 {
+		s += fmt.Sprintf(" rank %g", a.Rank)
+	}
+	return s
+}
+
+func assignChange(ctx context.Context, client *http.Client, target string, want *inboundsso.Assignment) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "assign",
+		Target:  target,
+		Subject: describe(want),
+		Apply: func() error {
+			return inboundsso.CreateAssignment(ctx, client, want)
+		},
+	}
+}
+
+func updateChange(ctx context.Context, client *http.Client, target string, have, want *inboundsso.Assignment) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "update",
+		Target:  target,
+		Subject: fmt.Sprintf("%s (was %s)", describe(want), describe(have)),
+		Apply: func() error {
+			return inboundsso.UpdateAssignment(ctx, client, want)
+		},
+	}
+}
+
+func removeChange(ctx context.Context, client *http.Client, target string, have *inboundsso.Assignment) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "remove",
+		Target:  target,
+		Subject: describe(have),
+		Apply: func() error {
+			return inboundsso.DeleteAssignment(ctx, client, have.Name)
+		},
+	}
+}
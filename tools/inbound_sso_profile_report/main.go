@@ -0,0 +1,155 @@
+// Package inboundssoprofilereport is the inbound_sso_profile_report tool:
+// inbound SAML and OIDC SSO profiles and the OUs and groups assigned to
+// each.
+package inboundssoprofilereport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/inboundsso"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of inbound_sso_profile_report, which Run
+// parses.
+var commandLine = flag.NewFlagSet("inbound_sso_profile_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	customerIDFlag        = commandLine.String("customer-id", "", "The customer whose SSO settings to report. Defaults to the impersonated admin's.")
+	outputFile            = commandLine.String("output-file", "inbound_sso_profiles.csv", "The csv file of SSO profiles to write out.")
+	assignmentsFile       = commandLine.String("assignments-file", "inbound_sso_assignments.csv", "The csv file of which OUs and groups use which profile to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "inbound_sso_profile_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+type byRank []*inboundsso.Assignment
+
+func (s byRank) Len() int           { return len(s) }
+func (s byRank) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byRank) Less(i, j int) bool { return s[i].Rank < s[j].Rank }
+
+// Run runs inbound_sso_profile_report with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("inbound_sso_profile_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, inboundsso.ReadonlyScope,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryOrgunitReadonlyScope, admin.AdminDirectoryGroupReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	customerID := *customerIDFlag
+	if customerID == "" {
+		// The Cloud Identity API wants the customer's ID, which
+		// "my_customer" doesn't stand in for.
+		u, err := service.Users.Get(*impersonatedEmailFlag).Do()
+		if err != nil {
+			log.Fatalf("Error fetching the customer ID: %v", err)
+		}
+		customerID = u.CustomerId
+	}
+
+	log.Println("Fetching inbound SSO profiles")
+	profiles, err := inboundsso.ListProfiles(ctx, client, customerID)
+	if err != nil {
+		log.Fatalf("Error fetching SSO profiles: %v", err)
+	}
+	assignments, err := inboundsso.ListAssignments(ctx, client, customerID)
+	if err != nil {
+		log.Fatalf("Error fetching SSO assignments: %v", err)
+	}
+	sort.Stable(byRank(assignments))
+	log.Printf("%d profiles, %d assignments", len(profiles), len(assignments))
+
+	ous, err := directory.ListOrgUnits(ctx, service)
+	if err != nil {
+		log.Fatalf("Error fetching OUs: %v", err)
+	}
+	ouPaths := map[string]string{}
+	for _, ou := range ous {
+		ouPaths[strings.TrimPrefix(ou.OrgUnitId, "id:")] = ou.OrgUnitPath
+		if ou.ParentOrgUnitPath == "/" {
+			ouPaths[strings.TrimPrefix(ou.ParentOrgUnitId, "id:")] = "/"
+		}
+	}
+
+	byName := map[string]*inboundsso.Profile{}
+	uses := map[string]int{}
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+	rows := [][]string{{"target_type", "target", "rank", "sso_mode", "profile", "profile_name", "redirect_condition"}}
+	for _, a := range assignments {
+		targetType, target := "org_unit", strings.TrimPrefix(a.TargetOrgUnit, "orgUnits/")
+		if a.TargetGroup != "" {
+			targetType = "group"
+			id := strings.TrimPrefix(a.TargetGroup, "groups/")
+			target = id
+			if g, err := service.Groups.Get(id).Do(); err == nil {
+				target = g.Email
+			} else {
+				log.Printf("Error fetching group %s, reporting its ID: %v", id, err)
+			}
+		} else if path, ok := ouPaths[target]; ok {
+			target = path
+		}
+		name := a.ProfileName()
+		displayName := ""
+		if p := byName[name]; p != nil {
+			displayName = p.DisplayName
+			uses[name]++
+		}
+		rows = append(rows, []string{targetType, target, strconv.FormatFloat(a.Rank, 'f', -1, 64), a.SsoMode,
+			name, displayName, a.RedirectCondition()})
+	}
+
+	report := [][]string{{"profile", "type", "display_name", "idp_entity_id", "sso_url", "sign_out_url",
+		"change_password_url", "sp_entity_id", "acs_url", "oidc_issuer", "oidc_client_id", "assignments"}}
+	for _, p := range profiles {
+		report = append(report, []string{p.Name, p.Kind, p.DisplayName, p.IdpConfig.EntityID, p.IdpConfig.SingleSignOnServiceURI,
+			p.IdpConfig.LogoutRedirectURI, p.IdpConfig.ChangePasswordURI, p.SpConfig.EntityID, p.SpConfig.AssertionConsumerServiceURI,
+			p.IdpConfig.IssuerURI, p.RpConfig.ClientID, strconv.Itoa(uses[p.Name])})
+	}
+	if err := outputOptions.WriteFile(*outputFile, report); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	// -fields and -filter describe the profiles; the assignments only
+	// share the format.
+	assignmentOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("inbound_sso_profile_report_assignments")}
+	if err := assignmentOptions.WriteFile(*assignmentsFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
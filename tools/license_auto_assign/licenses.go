@@ -0,0 +1,64 @@
+package licenseautoassign
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	licensingScope    = "https://www.googleapis.com/auth/apps.licensing"
+	licensingBasePath = "https://licensing.googleapis.com/apps/licensing/v1/"
+)
+
+// assignment is a LicenseAssignment from the Enterprise License Manager
+// API.
+type assignment struct {
+	UserID    string `json:"userId"`
+	ProductID string `json:"productId"`
+	SKUID     string `json:"skuId"`
+}
+
+// listAssignments returns every assignment of any of product's SKUs in
+// customer (a domain or customer ID).
+func listAssignments(ctx context.Context, client *http.Client, product, customer string) ([]*assignment, error) {
+	all := []*assignment{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Items         []*assignment `json:"items"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(licensingBasePath, "product/"+url.QueryEscape(product)+"/users",
+			url.Values{"customerId": {customer}, "maxResults": {"1000"}, "pageToken": {pageToken}})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Items...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+func skuPath(product, sku string) string {
+	return licensingBasePath + "product/" + url.QueryEscape(product) + "/sku/" + url.QueryEscape(sku) + "/user"
+}
+
+func assignLicense(ctx context.Context, client *http.Client, product, sku, email string) error {
+	return rest.Do(ctx, client, "POST", skuPath(product, sku), map[string]string{"userId": email}, nil)
+}
+
+// reassignLicense moves the user from one SKU of the product to another in
+// a single call, so they are never left unlicensed in between.
+func reassignLicense(ctx context.Context, client *http.Client, product, from, to, email string) error {
+	return rest.Do(ctx, client, "PATCH", skuPath(product, from)+"/"+url.QueryEscape(email), map[string]string{"skuId": to}, nil)
+}
+
+func removeLicense(ctx context.Context, client *http.Client, product, sku, email string) error {
+	return rest.Do(ctx, client, "DELETE", skuPath(product, sku)+"/"+url.QueryEscape(email), nil, nil)
+}
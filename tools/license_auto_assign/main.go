@@ -0,0 +1,244 @@
+// Package licenseautoassign is the license_auto_assign tool: assigns,
+// changes and removes license SKUs from YAML rules on OU, group membership
+// and user attributes.
+package licenseautoassign
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of license_auto_assign, which Run parses.
+var commandLine = flag.NewFlagSet("license_auto_assign", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users the rules are evaluated against.")
+	rulesFileFlag         = commandLine.String("rules-file", "", "The YAML file of license rules.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the license changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "license_auto_assign")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs license_auto_assign with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("license_auto_assign", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain", "rules-file")
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	rules, err := loadRules(*rulesFileFlag)
+	if err != nil {
+		log.Fatalf("Could not load rules: %v", err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupMemberReadonlyScope, licensingScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching users")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "full")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	members := map[string]map[string]bool{}
+	for _, r := range rules.Rules {
+		for _, g := range r.Groups {
+			if _, ok := members[g]; ok {
+				continue
+			}
+			log.Printf("Fetching members of %s", g)
+			list, err := directory.ListMembers(ctx, service, g)
+			if err != nil {
+				log.Fatalf("Error fetching members of %s: %v", g, err)
+			}
+			members[g] = map[string]bool{}
+			for _, m := range list {
+				members[g][strings.ToLower(m.Email)] = true
+			}
+		}
+	}
+	wanted := evaluate(rules, users, members)
+
+	known := map[string]bool{}
+	for _, u := range users {
+		known[strings.ToLower(u.PrimaryEmail)] = true
+	}
+	changes := []*reconcile.Change{}
+	existing := 0
+	for _, product := range productsOf(rules) {
+		log.Printf("Fetching %s license assignments", product)
+		list, err := listAssignments(ctx, client, product, *domainFlag)
+		if err != nil {
+			log.Fatalf("Error fetching %s licenses: %v", product, err)
+		}
+		have := map[string]string{}
+		for _, a := range list {
+			// Users outside -domain are left to whoever manages them.
+			if email := strings.ToLower(a.UserID); known[email] {
+				have[email] = a.SKUID
+			}
+		}
+		existing += len(have)
+		changes = append(changes, diff(ctx, client, rules, product, wanted[product], have)...)
+	}
+
+	log.Printf("%d license changes for %d users", len(changes), len(users))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: existing,
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// evaluate returns the SKU each user should have of each product, keyed by
+// product and then lowercased address. members holds the direct members
+// of the groups the rules name.
+func evaluate(rules *RulesFile, users []*admin.User, members map[string]map[string]bool) map[string]map[string]string {
+	wanted := map[string]map[string]string{}
+	for _, r := range rules.Rules {
+		if wanted[r.Product] == nil {
+			wanted[r.Product] = map[string]string{}
+		}
+		for _, u := range users {
+			email := strings.ToLower(u.PrimaryEmail)
+			if _, decided := wanted[r.Product][email]; decided {
+				continue
+			}
+			if u.Suspended && !r.IncludeSuspended {
+				continue
+			}
+			if !inAnyGroup(email, r.Groups, members) || !r.Match.Matches(u) {
+				continue
+			}
+			wanted[r.Product][email] = r.SKU
+		}
+	}
+	return wanted
+}
+
+func inAnyGroup(email string, groups []string, members map[string]map[string]bool) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		if members[g][email] {
+			return true
+		}
+	}
+	return false
+}
+
+// diff compares the SKUs users should have of product with those they
+// have. A user holding a different SKU of the product is moved to the
+// wanted one; one holding a SKU nobody should is only unassigned if a rule
+// for that SKU has remove_unmatched.
+func diff(ctx context.Context, client *http.Client, rules *RulesFile, product string, want, have map[string]string) []*reconcile.Change {
+	removable := map[string]bool{}
+	for _, r := range rules.Rules {
+		if r.Product == product && r.RemoveUnmatched {
+			removable[r.SKU] = true
+		}
+	}
+	emails := map[string]bool{}
+	for email := range want {
+		emails[email] = true
+	}
+	for email := range have {
+		emails[email] = true
+	}
+	changes := []*reconcile.Change{}
+	for _, email := range sortedKeys(emails) {
+		email, to, from := email, want[email], have[email]
+		switch {
+		case to == from:
+		case from == "":
+			changes = append(changes, &reconcile.Change{
+				Action:  "assign",
+				Target:  email,
+				Subject: product + "/" + to,
+				Apply:   func() error { return assignLicense(ctx, client, product, to, email) },
+			})
+		case to != "":
+			changes = append(changes, &reconcile.Change{
+				Action:  "change",
+				Target:  email,
+				Subject: fmt.Sprintf("%s/%s (was %s)", product, to, from),
+				Apply:   func() error { return reassignLicense(ctx, client, product, from, to, email) },
+			})
+		case removable[from]:
+			changes = append(changes, &reconcile.Change{
+				Action:  "remove",
+				Target:  email,
+				Subject: product + "/" + from,
+				Apply:   func() error { return removeLicense(ctx, client, product, from, email) },
+			})
+		}
+	}
+	return changes
+}
+
+// productsOf returns the products the rules name, in the order first named.
+func productsOf(rules *RulesFile) []string {
+	seen := map[string]bool{}
+	products := []string{}
+	for _, r := range rules.Rules {
+		if !seen[r.Product] {
+			seen[r.Product] = true
+			products = append(products, r.Product)
+		}
+	}
+	return products
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package licenseautoassign
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/jburnham/google_apps_tools/pkg/match"
+)
+
+// RulesFile is the top level of the YAML rules file.
+type RulesFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule assigns SKU of Product to every user matching Match who, if Groups
+// is set, is also a direct member of one of the groups. Rules are evaluated
+// in order and the first rule for a product that a user matches decides
+// which of its SKUs they get, so tiered SKUs list the premium tier first.
+type Rule struct {
+	Product string   `yaml:"product"`
+	SKU     string   `yaml:"sku"`
+	Groups  []string `yaml:"groups"`
+	// RemoveUnmatched takes the SKU away from users that no rule assigns
+	// it to. Without it the rule only ever assigns.
+	RemoveUnmatched  bool            `yaml:"remove_unmatched"`
+	IncludeSuspended bool            `yaml:"include_suspended"`
+	Match            match.Condition `yaml:"match"`
+}
+
+func loadRules(path string) (*RulesFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rf := &RulesFile{}
+	if err := yaml.Unmarshal(data, rf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if len(rf.Rules) == 0 {
+		return nil, fmt.Errorf("%s has no rules", path)
+	}
+	for i, r := range rf.Rules {
+		if r.Product == "" || r.SKU == "" {
+			return nil, fmt.Errorf("%s: rule %d needs a product and a sku", path, i+1)
+		}
+		if r.Match.Empty() && len(r.Groups) == 0 {
+			return nil, fmt.Errorf("%s: rule %d (%s) has no groups and an empty match, which every user would satisfy; use match: {org_unit: /} to mean everyone", path, i+1, r.SKU)
+		}
+		for j, g := range r.Groups {
+			r.Groups[j] = strings.ToLower(g)
+		}
+		if err := r.Match.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d (%s): %v", path, i+1, r.SKU, err)
+		}
+	}
+	return rf, nil
+}
@@ -0,0 +1,48 @@
+package licensereport
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	licensingScope    = "https://www.googleapis.com/auth/apps.licensing"
+	licensingBasePath = "https://licensing.googleapis.com/apps/licensing/v1/"
+)
+
+// assignment is a LicenseAssignment from the Enterprise License Manager
+// API.
+type assignment struct {
+	UserID      string `json:"userId"`
+	ProductID   string `json:"productId"`
+	ProductName string `json:"productName"`
+	SKUID       string `json:"skuId"`
+	SKUName     string `json:"skuName"`
+}
+
+// listAssignments returns every assignment of any of product's SKUs in
+// customer (a domain or customer ID).
+func listAssignments(ctx context.Context, client *http.Client, product, customer string) ([]*assignment, error) {
+	all := []*assignment{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Items         []*assignment `json:"items"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(licensingBasePath, "product/"+url.QueryEscape(product)+"/users",
+			url.Values{"customerId": {customer}, "maxResults": {"1000"}, "pageToken": {pageToken}})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Items...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
@@ -0,0 +1,215 @@
+// Package licensereport is the license_report tool: license assignments per
+// SKU, flagging those held by suspended, never-signed-in or inactive users.
+package licensereport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of license_report, which Run parses.
+var commandLine = flag.NewFlagSet("license_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose licenses are reported, or several of the same customer separated by commas.")
+	productsFlag          = commandLine.String("products", "Google-Apps", "Comma separated license product IDs to report, e.g. Google-Apps,Google-Vault,101031.")
+	inactiveDaysFlag      = commandLine.Int("inactive-days", 90, "Flag licensed users who haven't signed in for this many days (0 disables).")
+	flaggedOnlyFlag       = commandLine.Bool("flagged-only", false, "Only write the assignments that are flagged.")
+	outputFile            = commandLine.String("output-file", "licenses.csv", "The csv file of license assignments to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "license_report", "csv")
+	summaryFile           = commandLine.String("summary-file", "licenses_by_sku.csv", "The csv file of per-SKU totals to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// neverLoggedIn is the lastLoginTime of a user who has never signed in.
+const neverLoggedIn = "1970-01-01T00:00:00.000Z"
+
+// skuStats are the totals of one SKU, by flag.
+type skuStats struct {
+	product, productName, sku, skuName string
+	assigned                           int
+	flagged                            map[string]int
+}
+
+type bySKU []*skuStats
+
+func (s bySKU) Len() int      { return len(s) }
+func (s bySKU) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySKU) Less(i, j int) bool {
+	if s[i].product != s[j].product {
+		return s[i].product < s[j].product
+	}
+	return s[i].sku < s[j].sku
+}
+
+// flags are the reasons a license may be wasted, as the summary's columns.
+var flags = []string{"suspended", "never_logged_in", "inactive", "not_in_directory"}
+
+// Run runs license_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("license_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain", "products")
+	if *inactiveDaysFlag < 0 {
+		check.Problemf("-inactive-days can't be negative")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, licensingScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching users")
+	domains := listfile.Split(*domainFlag)
+	users := map[string]*admin.User{}
+	for _, domain := range domains {
+		list, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			log.Fatalf("Error fetching users of %s: %v", domain, err)
+		}
+		for _, u := range list {
+			users[strings.ToLower(u.PrimaryEmail)] = u
+		}
+	}
+
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"email", "product", "product_name", "sku", "sku_name", "org_unit", "suspended", "created", "last_login", "flag",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	now := time.Now()
+	stats := map[string]*skuStats{}
+	for _, product := range listfile.Split(*productsFlag) {
+		// Every domain of a customer shares its licenses, so any of them
+		// names the customer.
+		log.Printf("Fetching %s license assignments", product)
+		list, err := listAssignments(ctx, client, product, domains[0])
+		if err != nil {
+			log.Fatalf("Error fetching %s licenses: %v", product, err)
+		}
+		sort.Sort(byAssignment(list))
+		for _, a := range list {
+			key := a.ProductID + "/" + a.SKUID
+			s, ok := stats[key]
+			if !ok {
+				s = &skuStats{product: a.ProductID, productName: a.ProductName, sku: a.SKUID, skuName: a.SKUName, flagged: map[string]int{}}
+				stats[key] = s
+			}
+			s.assigned++
+			u := users[strings.ToLower(a.UserID)]
+			reason := flagOf(u, now)
+			if reason != "" {
+				s.flagged[reason]++
+			} else if *flaggedOnlyFlag {
+				continue
+			}
+			orgUnit, suspended, created, lastLogin := "", "", "", ""
+			if u != nil {
+				orgUnit, suspended, created = u.OrgUnitPath, strconv.FormatBool(u.Suspended), u.CreationTime
+				if u.LastLoginTime != neverLoggedIn {
+					lastLogin = u.LastLoginTime
+				}
+			}
+			if err := writer.Write([]string{
+				a.UserID, a.ProductID, a.ProductName, a.SKUID, a.SKUName, orgUnit, suspended, created, lastLogin, reason,
+			}); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+
+	all := bySKU{}
+	for _, s := range stats {
+		all = append(all, s)
+	}
+	sort.Sort(all)
+	summary := [][]string{
+		append([]string{"product", "product_name", "sku", "sku_name", "assigned"}, append(flags, "flagged")...),
+	}
+	for _, s := range all {
+		row := []string{s.product, s.productName, s.sku, s.skuName, strconv.Itoa(s.assigned)}
+		flagged := 0
+		for _, f := range flags {
+			row = append(row, strconv.Itoa(s.flagged[f]))
+			flagged += s.flagged[f]
+		}
+		summary = append(summary, append(row, strconv.Itoa(flagged)))
+	}
+	// -fields and -filter describe the main report; the summary only
+	// follows -format.
+	summaryOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("license_report_by_sku")}
+	if err := summaryOptions.WriteFile(*summaryFile, summary); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// flagOf returns why a license assigned to u may be wasted, or "" if it
+// isn't. u is nil for an assignee outside -domain's users, such as a
+// deleted account.
+func flagOf(u *admin.User, now time.Time) string {
+	switch {
+	case u == nil:
+		return "not_in_directory"
+	case u.Suspended:
+		return "suspended"
+	case u.LastLoginTime == "" || u.LastLoginTime == neverLoggedIn:
+		return "never_logged_in"
+	}
+	if *inactiveDaysFlag > 0 {
+		last, err := time.Parse(time.RFC3339Nano, u.LastLoginTime)
+		if err == nil && now.Sub(last) > time.Duration(*inactiveDaysFlag)*24*time.Hour {
+			return "inactive"
+		}
+	}
+	return ""
+}
+
+type byAssignment []*assignment
+
+func (a byAssignment) Len() int      { return len(a) }
+func (a byAssignment) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAssignment) Less(i, j int) bool {
+	if a[i].SKUID != a[j].SKUID {
+		return a[i].SKUID < a[j].SKUID
+	}
+	return a[i].UserID < a[j].UserID
+}
@@ -0,0 +1,214 @@
+// Package matchingrules is the matching_rules tool: reconciles group
+// memberships against YAML rules on user attributes.
+package matchingrules
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of matching_rules, which Run parses.
+var commandLine = flag.NewFlagSet("matching_rules", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users the rules are evaluated against.")
+	rulesFileFlag         = commandLine.String("rules-file", "", "The YAML file of group matching rules.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the membership changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "matching_rules")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// groupTarget is the desired state of one group, merged from all the rules
+// that name it.
+type groupTarget struct {
+	email           string
+	removeUnmatched bool
+	members         map[string]bool
+}
+
+// Run runs matching_rules with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("matching_rules", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain", "rules-file")
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	rules, err := loadRules(*rulesFileFlag)
+	if err != nil {
+		log.Fatalf("Could not load rules: %v", err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupMemberScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching users")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "full")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	targets := evaluate(rules, users)
+
+	var changes []*reconcile.Change
+	existing := 0
+	for _, t := range targets {
+		current, err := directory.ListMembers(ctx, service, t.email)
+		if err != nil {
+			log.Fatalf("Error fetching members of %s: %v", t.email, err)
+		}
+		// Only groups that lose unmatched users count towards what can
+		// be removed, and of those only the USER members diff manages.
+		for _, m := range current {
+			if t.removeUnmatched && managed(m, *domainFlag) {
+				existing++
+			}
+		}
+		changes = append(changes, diff(service, t, current, *domainFlag)...)
+	}
+	log.Printf("%d membership changes to make across %d groups", len(changes), len(targets))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: existing,
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// evaluate runs every user through the rules and returns the desired
+// membership of each group, sorted by group address.
+func evaluate(rules *RulesFile, users []*admin.User) []*groupTarget {
+	byGroup := map[string]*groupTarget{}
+	for _, r := range rules.Rules {
+		email := strings.ToLower(r.Group)
+		t, ok := byGroup[email]
+		if !ok {
+			t = &groupTarget{email: email, members: map[string]bool{}}
+			byGroup[email] = t
+		}
+		t.removeUnmatched = t.removeUnmatched || r.RemoveUnmatched
+		for _, u := range users {
+			if u.Suspended && !r.IncludeSuspended {
+				continue
+			}
+			if r.Match.Matches(u) {
+				t.members[strings.ToLower(u.PrimaryEmail)] = true
+			}
+		}
+	}
+	emails := []string{}
+	for email := range byGroup {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	targets := []*groupTarget{}
+	for _, email := range emails {
+		targets = append(targets, byGroup[email])
+	}
+	return targets
+}
+
+// managed reports whether the rules decide m's membership: it is a user in
+// domain, the domain whose users the rules are evaluated against. Nested
+// groups and users in other domains are never removed.
+func managed(m *admin.Member, domain string) bool {
+	return m.Type == "USER" && strings.HasSuffix(strings.ToLower(m.Email), "@"+strings.ToLower(domain))
+}
+
+// diff compares a group's desired members with its current ones. Only the
+// members managed in domain are removed.
+func diff(service *admin.Service, t *groupTarget, current []*admin.Member, domain string) []*reconcile.Change {
+	have, removable := map[string]bool{}, map[string]bool{}
+	for _, m := range current {
+		if m.Type == "USER" {
+			have[strings.ToLower(m.Email)] = true
+		}
+		if managed(m, domain) {
+			removable[strings.ToLower(m.Email)] = true
+		}
+	}
+	changes := []*reconcile.Change{}
+	for _, email := range sortedKeys(t.members) {
+		if have[email] {
+			continue
+		}
+		email := email
+		changes = append(changes, &reconcile.Change{
+			Action:  "add",
+			Target:  t.email,
+			Subject: email,
+			Apply: func() error {
+				_, err := service.Members.Insert(t.email, &admin.Member{Email: email, Role: "MEMBER"}).Do()
+				return err
+			},
+		})
+	}
+	if !t.removeUnmatched {
+		return changes
+	}
+	for _, email := range sortedKeys(removable) {
+		if t.members[email] {
+			continue
+		}
+		email := email
+		changes = append(changes, &reconcile.Change{
+			Action:  "remove",
+			Target:  t.email,
+			Subject: email,
+			Apply: func() error {
+				return service.Members.Delete(t.email, email).Do()
+			},
+		})
+	}
+	return changes
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
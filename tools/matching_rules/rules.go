@@ -0,0 +1,50 @@
+package matchingrules
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/jburnham/google_apps_tools/pkg/match"
+)
+
+// RulesFile is the top level of the YAML rules file.
+type RulesFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule says that every user matching Match belongs in Group. Several rules
+// may name the same group; a user matching any of them is a member.
+type Rule struct {
+	Group string `yaml:"group"`
+	// RemoveUnmatched removes USER members of the group in -domain that no
+	// rule for the group matches. Members in other domains, which the rules
+	// never see, are left alone. Without it the rule only ever adds members.
+	RemoveUnmatched  bool            `yaml:"remove_unmatched"`
+	IncludeSuspended bool            `yaml:"include_suspended"`
+	Match            match.Condition `yaml:"match"`
+}
+
+func loadRules(path string) (*RulesFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rf := &RulesFile{}
+	if err := yaml.Unmarshal(data, rf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for i, r := range rf.Rules {
+		if r.Group == "" {
+			return nil, fmt.Errorf("%s: rule %d has no group", path, i+1)
+		}
+		if r.Match.Empty() {
+			return nil, fmt.Errorf("%s: rule %d (%s) has an empty match, which every user would satisfy; use org_unit: / to mean everyone", path, i+1, r.Group)
+		}
+		if err := r.Match.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d (%s): %v", path, i+1, r.Group, err)
+		}
+	}
+	return rf, nil
+}
@@ -0,0 +1,133 @@
+// Package mobiledevicesreport is the mobile_devices_report tool: inventory
+// of mobile devices with their model, OS, status, users and sync times.
+package mobiledevicesreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of mobile_devices_report, which Run parses.
+var commandLine = flag.NewFlagSet("mobile_devices_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users' OUs are looked up, or several separated by commas.")
+	orgUnitFlag           = commandLine.String("org-unit", "", "Only report devices of users in this OU and those below it, e.g. /Sales.")
+	statusFlag            = commandLine.String("status", "", "Only report devices with one of these comma separated statuses, e.g. APPROVED,BLOCKED.")
+	queryFlag             = commandLine.String("query", "", "Only report devices matching this admin console device search, e.g. 'os:android'.")
+	outputFile            = commandLine.String("output-file", "mobile_devices.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "mobile_devices_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs mobile_devices_report with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("mobile_devices_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryDeviceMobileReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Mobile devices have no OU of their own; they are in their users'.
+	log.Println("Fetching users")
+	orgUnits := map[string]string{}
+	for _, domain := range listfile.Split(*domainFlag) {
+		users, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			log.Fatalf("Error fetching users of %s: %v", domain, err)
+		}
+		for _, u := range users {
+			orgUnits[strings.ToLower(u.PrimaryEmail)] = u.OrgUnitPath
+		}
+	}
+
+	log.Println("Fetching mobile devices")
+	devices, err := directory.ListMobileDevices(ctx, service, *queryFlag)
+	if err != nil {
+		log.Fatalf("Error fetching mobile devices: %v", err)
+	}
+
+	statuses := map[string]bool{}
+	for _, s := range listfile.Split(*statusFlag) {
+		statuses[strings.ToUpper(s)] = true
+	}
+	ou := strings.TrimSuffix(strings.ToLower(*orgUnitFlag), "/")
+	rows := [][]string{
+		{"device_id", "type", "model", "os", "build_number", "serial_number", "imei", "status", "compromised",
+			"user", "name", "org_unit", "first_sync", "last_sync", "user_agent"},
+	}
+	for _, d := range devices {
+		if len(statuses) > 0 && !statuses[d.Status] {
+			continue
+		}
+		ous := []string{}
+		inOU := ou == ""
+		for _, email := range d.Email {
+			path := orgUnits[strings.ToLower(email)]
+			ous = append(ous, path)
+			lower := strings.ToLower(path)
+			if lower == ou || strings.HasPrefix(lower, ou+"/") {
+				inOU = true
+			}
+		}
+		if !inOU {
+			continue
+		}
+		rows = append(rows, []string{
+			d.ResourceId,
+			d.Type,
+			d.Model,
+			d.Os,
+			d.BuildNumber,
+			d.SerialNumber,
+			d.Imei,
+			d.Status,
+			d.DeviceCompromisedStatus,
+			strings.Join(d.Email, ";"),
+			strings.Join(d.Name, ";"),
+			strings.Join(ous, ";"),
+			d.FirstSync,
+			d.LastSync,
+			d.UserAgent,
+		})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d of %d devices", len(rows)-1, len(devices))
+	log.Println("Complete")
+	return nil
+}
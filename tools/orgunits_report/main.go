@@ -0,0 +1,152 @@
+// Package orgunitsreport is the orgunits_report tool: the OU tree,
+// optionally with the active and suspended users in each OU and below it.
+package orgunitsreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of orgunits_report, which Run parses.
+var commandLine = flag.NewFlagSet("orgunits_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	countUsersFlag        = commandLine.Bool("count-users", false, "Add the number of active and suspended users in each OU, listing the users of -domain.")
+	domainFlag            = commandLine.String("domain", "", "With -count-users, the domain to count users in, or several separated by commas.")
+	outputFile            = commandLine.String("output-file", "orgunits.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "orgunits_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// userCounts are the users directly in an OU.
+type userCounts struct {
+	active, suspended int
+}
+
+// Run runs orgunits_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("orgunits_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	if *countUsersFlag {
+		check.Required("domain")
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	scopes := []string{admin.AdminDirectoryOrgunitReadonlyScope}
+	if *countUsersFlag {
+		scopes = append(scopes, admin.AdminDirectoryUserReadonlyScope)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Starting report generation")
+	ous, err := directory.ListOrgUnits(ctx, service)
+	if err != nil {
+		log.Fatalf("Error fetching org units: %v", err)
+	}
+	// The root isn't listed, but users can live in it.
+	ous = append(ous, &admin.OrgUnit{OrgUnitPath: "/", Name: "/"})
+	sort.Sort(byPath(ous))
+
+	counts := map[string]*userCounts{}
+	if *countUsersFlag {
+		for _, ou := range ous {
+			counts[strings.ToLower(ou.OrgUnitPath)] = &userCounts{}
+		}
+		for _, domain := range listfile.Split(*domainFlag) {
+			users, err := directory.ListUsers(ctx, service, domain, "")
+			if err != nil {
+				log.Fatalf("Error fetching users of %s: %v", domain, err)
+			}
+			for _, u := range users {
+				c := counts[strings.ToLower(u.OrgUnitPath)]
+				if c == nil {
+					// An OU created since the listing.
+					c = &userCounts{}
+					counts[strings.ToLower(u.OrgUnitPath)] = c
+				}
+				if u.Suspended {
+					c.suspended++
+				} else {
+					c.active++
+				}
+			}
+		}
+	}
+
+	rows := [][]string{
+		{"path", "name", "description", "parent", "block_inheritance",
+			"active_users", "suspended_users", "subtree_active_users", "subtree_suspended_users"},
+	}
+	for _, ou := range ous {
+		row := []string{ou.OrgUnitPath, ou.Name, ou.Description, ou.ParentOrgUnitPath, strconv.FormatBool(ou.BlockInheritance)}
+		if *countUsersFlag {
+			c := counts[strings.ToLower(ou.OrgUnitPath)]
+			subtree := &userCounts{}
+			for path, pc := range counts {
+				if within(path, strings.ToLower(ou.OrgUnitPath)) {
+					subtree.active += pc.active
+					subtree.suspended += pc.suspended
+				}
+			}
+			row = append(row, strconv.Itoa(c.active), strconv.Itoa(c.suspended),
+				strconv.Itoa(subtree.active), strconv.Itoa(subtree.suspended))
+		} else {
+			row = append(row, "", "", "", "")
+		}
+		rows = append(rows, row)
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d org units", len(ous))
+	log.Println("Complete")
+	return nil
+}
+
+// within reports whether path is the OU parent or below it.
+func within(path, parent string) bool {
+	return parent == "/" || path == parent || strings.HasPrefix(path, parent+"/")
+}
+
+// byPath sorts OUs by path, so each follows its parent.
+type byPath []*admin.OrgUnit
+
+func (s byPath) Len() int      { return len(s) }
+func (s byPath) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPath) Less(i, j int) bool {
+	return strings.ToLower(s[i].OrgUnitPath) < strings.ToLower(s[j].OrgUnitPath)
+}
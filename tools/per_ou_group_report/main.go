@@ -0,0 +1,157 @@
+// Package perougroupreport is the per_ou_group_report tool: how many of
+// each group's members are in each OU.
+package perougroupreport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of per_ou_group_report, which Run parses.
+var commandLine = flag.NewFlagSet("per_ou_group_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for groups and users.")
+	groupsFlag            = commandLine.String("groups", "", "Comma separated addresses of groups to report, instead of every group in -domain.")
+	outputFile            = commandLine.String("output-file", "groups_by_ou.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "per_ou_group_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Stand-in OUs for members that aren't users of -domain.
+const (
+	externalOU    = "(external)"
+	nestedGroupOU = "(group)"
+	customerOU    = "(all users)"
+)
+
+// ouCount is how many of a group's members are in one OU.
+type ouCount struct {
+	orgUnit string
+	members int
+}
+
+type byMembers []*ouCount
+
+func (c byMembers) Len() int      { return len(c) }
+func (c byMembers) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byMembers) Less(i, j int) bool {
+	if c[i].members != c[j].members {
+		return c[i].members > c[j].members
+	}
+	return c[i].orgUnit < c[j].orgUnit
+}
+
+// Run runs per_ou_group_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("per_ou_group_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, admin.AdminDirectoryGroupReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching users")
+	users, err := directory.ListUsers(ctx, service, *domainFlag, "")
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	// Members are matched by ID, since a user may have been added under
+	// an alias.
+	orgUnits := map[string]string{}
+	for _, u := range users {
+		orgUnits[u.Id] = u.OrgUnitPath
+	}
+
+	groups := listfile.Split(*groupsFlag)
+	if len(groups) == 0 {
+		log.Println("Fetching groups")
+		list, err := directory.ListGroups(ctx, service, *domainFlag)
+		if err != nil {
+			log.Fatalf("Error fetching groups: %v", err)
+		}
+		for _, g := range list {
+			groups = append(groups, g.Email)
+		}
+	}
+
+	rows := [][]string{{"group", "org_unit", "members", "percent", "group_members"}}
+	failed := 0
+	for _, group := range groups {
+		members, err := directory.ListMembers(ctx, service, group)
+		if err != nil {
+			log.Printf("Error fetching members of %s, skipping: %v", group, err)
+			failed++
+			continue
+		}
+		counts := map[string]int{}
+		for _, m := range members {
+			counts[orgUnitOf(m, orgUnits)]++
+		}
+		sorted := []*ouCount{}
+		for ou, n := range counts {
+			sorted = append(sorted, &ouCount{ou, n})
+		}
+		sort.Sort(byMembers(sorted))
+		for _, c := range sorted {
+			percent := strconv.FormatFloat(100*float64(c.members)/float64(len(members)), 'f', 1, 64)
+			rows = append(rows, []string{strings.ToLower(group), c.orgUnit, strconv.Itoa(c.members), percent, strconv.Itoa(len(members))})
+		}
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but members of %d groups could not be fetched", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// orgUnitOf returns the OU of a member, or a stand-in for members that
+// aren't users of -domain.
+func orgUnitOf(m *admin.Member, orgUnits map[string]string) string {
+	switch m.Type {
+	case "GROUP":
+		return nestedGroupOU
+	case "CUSTOMER":
+		return customerOU
+	}
+	if ou, ok := orgUnits[m.Id]; ok {
+		return ou
+	}
+	return externalOU
+}
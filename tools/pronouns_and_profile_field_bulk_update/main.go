@@ -0,0 +1,170 @@
+// Package pronounsandprofilefieldbulkupdate is the
+// pronouns_and_profile_field_bulk_update tool: sets users' pronouns, job
+// title, department and location from an HR csv, recording the old values.
+package pronounsandprofilefieldbulkupdate
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of pronouns_and_profile_field_bulk_update,
+// which Run parses.
+var commandLine = flag.NewFlagSet("pronouns_and_profile_field_bulk_update", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	usersFileFlag         = commandLine.String("users-file", "", "The HR csv, with an email column and any of pronouns, title, department and location. Blank cells are left unchanged.")
+	columnsFlag           = commandLine.String("columns", "", "The csv's headers for the fields, where they differ from the field names, e.g. email=work_email,title=job_title.")
+	pronounsFieldFlag     = commandLine.String("pronouns-field", "", "The custom attribute that holds pronouns, as Schema.Field. The Directory API has no pronouns field of its own.")
+	restoreFileFlag       = commandLine.String("restore-file", "", "Instead of -users-file, put back the values recorded in this rollback file.")
+	rollbackFile          = commandLine.String("rollback-file", "profile_rollback.csv", "Where each user's previous value is recorded before it is changed. It mustn't exist yet, so an earlier run's is never overwritten.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "pronouns_and_profile_field_bulk_update")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs pronouns_and_profile_field_bulk_update with args, the arguments
+// that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("pronouns_and_profile_field_bulk_update", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.RequireOne("users-file", "restore-file")
+	if *usersFileFlag != "" && *restoreFileFlag != "" {
+		check.Problemf("-users-file and -restore-file can't be used together")
+	}
+	columns, err := parseColumns(*columnsFlag)
+	check.Check(err)
+	if parts := strings.SplitN(*pronounsFieldFlag, ".", 2); *pronounsFieldFlag != "" && (len(parts) != 2 || parts[0] == "" || parts[1] == "") {
+		check.Problemf("invalid -pronouns-field %q: use Schema.Field", *pronounsFieldFlag)
+	}
+	var profiles []*profile
+	if err == nil && *usersFileFlag != "" {
+		profiles, err = readProfiles(*usersFileFlag, columns)
+		check.Check(err)
+	}
+	for _, p := range profiles {
+		if p.fields["pronouns"] != "" && *pronounsFieldFlag == "" {
+			check.Problemf("%s has pronouns but no -pronouns-field to put them in", *usersFileFlag)
+			break
+		}
+	}
+	var restores []*restore
+	if *restoreFileFlag != "" {
+		restores, err = readRollback(*restoreFileFlag)
+		check.Check(err)
+	}
+	for _, r := range restores {
+		if _, ok := r.set["pronouns"]; ok && *pronounsFieldFlag == "" {
+			check.Problemf("%s has pronouns but no -pronouns-field to put them back in", *restoreFileFlag)
+			break
+		}
+	}
+	if _, err := os.Stat(*rollbackFile); err == nil && *restoreFileFlag == "" && !*dryRunFlag {
+		check.Problemf("-rollback-file %s already exists; move it aside or give another -rollback-file", *rollbackFile)
+	}
+	_, err = reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *restoreFileFlag != "" {
+		changes := []*reconcile.Change{}
+		for _, r := range restores {
+			change := profileChange(service, r.email, r.set, nil)
+			change.Action = "restore"
+			changes = append(changes, change)
+		}
+		log.Printf("%d users to restore", len(changes))
+		if _, err := reconcile.Apply(changes, reconcile.Options{
+			DryRun:  *dryRunFlag,
+			Canary:  *canaryFlag,
+			Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+			Limits:  limits,
+			Plan:    plan,
+		}); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Complete")
+		return nil
+	}
+
+	rollback := [][]string{rollbackHeader}
+	changes := []*reconcile.Change{}
+	failed := 0
+	for _, p := range profiles {
+		u, err := service.Users.Get(p.email).Projection("full").Do()
+		if err != nil {
+			log.Printf("Error fetching %s, skipping: %v", p.email, err)
+			failed++
+			continue
+		}
+		current := currentValues(u)
+		set := map[string]*string{}
+		for _, field := range fields {
+			value := p.fields[field]
+			if value != "" && (current[field] == nil || value != *current[field]) {
+				rollback = append(rollback, rollbackRow(p.email, field, current[field]))
+				set[field] = &value
+			}
+		}
+		if len(set) > 0 {
+			changes = append(changes, profileChange(service, p.email, set, current))
+		}
+	}
+	if !*dryRunFlag {
+		if err := output.WriteNewCSV(*rollbackFile, rollback); err != nil {
+			log.Fatalf("Error writing rollback file: %v", err)
+		}
+	}
+	log.Printf("%d of %d users to update", len(changes), len(profiles))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:  *dryRunFlag,
+		Canary:  *canaryFlag,
+		Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:  limits,
+		Plan:    plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but %d users could not be read and were left alone", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,236 @@
+package pronounsandprofilefieldbulkupdate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+)
+
+// fields are the profile fields the csv can set, in the order changes
+// describe them.
+var fields = []string{"pronouns", "title", "department", "location"}
+
+// profile is the fields one user should have. Blank fields are left alone.
+type profile struct {
+	email  string
+	fields map[string]string
+}
+
+// parseColumns parses -columns into each field's csv header. Fields not
+// given are read from a column of their own name.
+func parseColumns(spec string) (map[string]string, error) {
+	columns := map[string]string{"email": "email"}
+	for _, f := range fields {
+		columns[f] = f
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		field := strings.TrimSpace(parts[0])
+		if _, ok := columns[field]; !ok || len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid -columns entry %q: expected field=header, with field one of email, %s", pair, strings.Join(fields, ", "))
+		}
+		columns[field] = strings.TrimSpace(parts[1])
+	}
+	return columns, nil
+}
+
+// readProfiles reads the HR csv at path, finding each field under its
+// header in columns.
+func readProfiles(path string, columns map[string]string) ([]*profile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	cols := map[string]int{}
+	for i, h := range records[0] {
+		cols[strings.TrimSpace(h)] = i
+	}
+	if _, ok := cols[columns["email"]]; !ok {
+		return nil, fmt.Errorf("%s has no %q column", path, columns["email"])
+	}
+	found := 0
+	for _, f := range fields {
+		if _, ok := cols[columns[f]]; ok {
+			found++
+		}
+	}
+	if found == 0 {
+		return nil, fmt.Errorf("%s has none of the %s columns", path, strings.Join(fields, ", "))
+	}
+	get := func(r []string, header string) string {
+		if i, ok := cols[header]; ok && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	profiles := []*profile{}
+	for _, r := range records[1:] {
+		p := &profile{email: get(r, columns["email"]), fields: map[string]string{}}
+		if p.email == "" {
+			continue
+		}
+		for _, f := range fields {
+			p.fields[f] = get(r, columns[f])
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// currentValues returns u's values of the fields, leaving out those it has
+// no value for at all, so they can be told apart from blank ones. u must
+// have been fetched with the "full" projection.
+func currentValues(u *admin.User) map[string]*string {
+	values := map[string]*string{}
+	orgs := organizations(u.Organizations)
+	if i := primary(orgs); i >= 0 {
+		for _, f := range orgFields {
+			if v, ok := orgs[i][f].(string); ok {
+				values[f] = &v
+			}
+		}
+	}
+	if *pronounsFieldFlag != "" {
+		parts := strings.SplitN(*pronounsFieldFlag, ".", 2)
+		if props, ok := u.CustomSchemas[parts[0]].(map[string]interface{}); ok {
+			if _, ok := props[parts[1]]; ok {
+				v := directory.CustomField(u, *pronounsFieldFlag)
+				values["pronouns"] = &v
+			}
+		}
+	}
+	return values
+}
+
+// orgFields are the fields kept on the primary organization.
+var orgFields = []string{"title", "department", "location"}
+
+// organizations returns a user's untyped Organizations as maps, so fields
+// this tool doesn't know about survive the round trip.
+func organizations(orgs interface{}) []map[string]interface{} {
+	list := []map[string]interface{}{}
+	if orgs != nil {
+		if data, err := json.Marshal(orgs); err == nil {
+			json.Unmarshal(data, &list)
+		}
+	}
+	return list
+}
+
+// primary is the index of the organization marked primary, or of the
+// first, or -1 if there are none.
+func primary(orgs []map[string]interface{}) int {
+	for i, o := range orgs {
+		if p, _ := o["primary"].(bool); p {
+			return i
+		}
+	}
+	if len(orgs) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// show describes a field's value, which is nil if it isn't set.
+func show(value *string) string {
+	if value == nil {
+		return "(unset)"
+	}
+	return *value
+}
+
+// describe lists the fields in set, with their current values if current
+// isn't nil.
+func describe(set, current map[string]*string) string {
+	described := []string{}
+	for _, f := range fields {
+		value, ok := set[f]
+		switch {
+		case !ok:
+			continue
+		case current == nil:
+			described = append(described, fmt.Sprintf("%s=%s", f, show(value)))
+		default:
+			described = append(described, fmt.Sprintf("%s=%s (was %s)", f, show(value), show(current[f])))
+		}
+	}
+	return strings.Join(described, ", ")
+}
+
+// profileChange sets the fields in set, described against their current
+// values. The organization fields go to the primary organization, keeping
+// the rest of it, and pronouns to the -pronouns-field custom attribute. A
+// nil value removes the field.
+func profileChange(service *admin.Service, email string, set, current map[string]*string) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "set",
+		Target:  email,
+		Subject: describe(set, current),
+		Apply:   func() error { return updateProfile(service, email, set) },
+	}
+}
+
+func updateProfile(service *admin.Service, email string, set map[string]*string) error {
+	u := &admin.User{}
+	changesOrg := false
+	for _, f := range orgFields {
+		if _, ok := set[f]; ok {
+			changesOrg = true
+		}
+	}
+	if changesOrg {
+		// Patch replaces the whole organizations list, so it is read
+		// fresh rather than from before the run's other changes.
+		current, err := service.Users.Get(email).Fields("organizations").Do()
+		if err != nil {
+			return err
+		}
+		orgs := organizations(current.Organizations)
+		i := primary(orgs)
+		if i < 0 {
+			orgs, i = append(orgs, map[string]interface{}{"primary": true}), 0
+		}
+		for _, f := range orgFields {
+			value, ok := set[f]
+			switch {
+			case !ok:
+			case value == nil:
+				delete(orgs[i], f)
+			default:
+				orgs[i][f] = *value
+			}
+		}
+		u.Organizations = orgs
+	}
+	if pronouns, ok := set["pronouns"]; ok {
+		parts := strings.SplitN(*pronounsFieldFlag, ".", 2)
+		// A nil value is sent as null, which clears the attribute.
+		var value interface{}
+		if pronouns != nil {
+			value = *pronouns
+		}
+		u.CustomSchemas = map[string]admin.UserCustomProperties{
+			parts[0]: map[string]interface{}{parts[1]: value},
+		}
+	}
+	_, err := service.Users.Patch(email, u).Do()
+	return err
+}
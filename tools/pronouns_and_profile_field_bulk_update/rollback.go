@@ -0,0 +1,70 @@
+package pronounsandprofilefieldbulkupdate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// rollbackHeader is the rollback file's header. set is false where the
+// user had no value at all, so restoring it removes the field rather than
+// leaving it blank.
+var rollbackHeader = []string{"email", "field", "value", "set"}
+
+// rollbackRow records a field's value before it is changed.
+func rollbackRow(email, field string, value *string) []string {
+	if value == nil {
+		return []string{email, field, "", "false"}
+	}
+	return []string{email, field, *value, "true"}
+}
+
+// restore is the values a rollback file gives one user's fields.
+type restore struct {
+	email string
+	set   map[string]*string
+}
+
+// readRollback reads a rollback file, grouping its rows by user in the
+// order they first appear.
+func readRollback(path string) ([]*restore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(rollbackHeader, ",") {
+		return nil, fmt.Errorf("%s is not a rollback file: expected %s columns", path, strings.Join(rollbackHeader, ", "))
+	}
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f] = true
+	}
+	restores := []*restore{}
+	byEmail := map[string]*restore{}
+	for i, rec := range records[1:] {
+		email, field, value, set := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1]), rec[2], rec[3]
+		if !known[field] {
+			return nil, fmt.Errorf("%s line %d: unknown field %q", path, i+2, field)
+		}
+		if set != "true" && set != "false" {
+			return nil, fmt.Errorf("%s line %d: set is %q, not true or false", path, i+2, set)
+		}
+		r := byEmail[strings.ToLower(email)]
+		if r == nil {
+			r = &restore{email: email, set: map[string]*string{}}
+			byEmail[strings.ToLower(email)] = r
+			restores = append(restores, r)
+		}
+		r.set[field] = nil
+		if set == "true" {
+			r.set[field] = &value
+		}
+	}
+	return restores, nil
+}
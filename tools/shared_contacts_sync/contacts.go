@@ -0,0 +1,379 @@
+package sharedcontactssync
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/googleapi"
+
+	"github.com/jburnham/google_apps_tools/pkg/retry"
+)
+
+// Domain Shared Contacts are only available through the GData Contacts API,
+// which speaks Atom XML rather than JSON.
+const (
+	contactsScope = "https://www.google.com/m8/feeds"
+	gdNS          = "http://schemas.google.com/g/2005"
+	workRel       = gdNS + "#work"
+	kindScheme    = gdNS + "#kind"
+	contactKind   = "http://schemas.google.com/contact/2008#contact"
+)
+
+// managedProperty marks the contacts this tool created, so that only those
+// are deleted when they drop out of the csv. Contacts made by hand are
+// updated to match the csv but never marked.
+const managedProperty = "google_apps_tools.shared_contacts_sync"
+
+type link struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type category struct {
+	Scheme string `xml:"scheme,attr"`
+	Term   string `xml:"term,attr"`
+}
+
+// element is an element the sync doesn't model, kept as it was fetched so
+// that an update writes it back unchanged.
+type element struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// withoutDeclarations drops the namespace declarations the decoder reports
+// as attributes, which the encoder adds again itself.
+func withoutDeclarations(attrs []xml.Attr) []xml.Attr {
+	kept := []xml.Attr{}
+	for _, a := range attrs {
+		if a.Name.Space != "xmlns" && !(a.Name.Space == "" && a.Name.Local == "xmlns") {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+func tidyElements(elements []element) {
+	for i := range elements {
+		elements[i].Attrs = withoutDeclarations(elements[i].Attrs)
+	}
+}
+
+type name struct {
+	FullName   string    `xml:"http://schemas.google.com/g/2005 fullName,omitempty"`
+	GivenName  string    `xml:"http://schemas.google.com/g/2005 givenName,omitempty"`
+	FamilyName string    `xml:"http://schemas.google.com/g/2005 familyName,omitempty"`
+	Extra      []element `xml:",any"`
+}
+
+type email struct {
+	Rel     string     `xml:"rel,attr,omitempty"`
+	Primary bool       `xml:"primary,attr,omitempty"`
+	Address string     `xml:"address,attr"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+}
+
+type phone struct {
+	Rel     string     `xml:"rel,attr,omitempty"`
+	Primary bool       `xml:"primary,attr,omitempty"`
+	Number  string     `xml:",chardata"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+}
+
+type organization struct {
+	Rel     string     `xml:"rel,attr,omitempty"`
+	Primary bool       `xml:"primary,attr,omitempty"`
+	Name    string     `xml:"http://schemas.google.com/g/2005 orgName,omitempty"`
+	Title   string     `xml:"http://schemas.google.com/g/2005 orgTitle,omitempty"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Extra   []element  `xml:",any"`
+}
+
+type property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// entry is a shared contact. The fields the sync doesn't manage are kept in
+// Extra, and ETag is the version fetched, so an update changes only the
+// managed fields and fails rather than overwriting someone else's edit.
+type entry struct {
+	XMLName       xml.Name       `xml:"http://www.w3.org/2005/Atom entry"`
+	ETag          string         `xml:"http://schemas.google.com/g/2005 etag,attr,omitempty"`
+	ID            string         `xml:"http://www.w3.org/2005/Atom id,omitempty"`
+	Links         []link         `xml:"http://www.w3.org/2005/Atom link"`
+	Category      *category      `xml:"http://www.w3.org/2005/Atom category"`
+	Name          *name          `xml:"http://schemas.google.com/g/2005 name"`
+	Emails        []email        `xml:"http://schemas.google.com/g/2005 email"`
+	Phones        []phone        `xml:"http://schemas.google.com/g/2005 phoneNumber"`
+	Organizations []organization `xml:"http://schemas.google.com/g/2005 organization"`
+	Properties    []property     `xml:"http://schemas.google.com/g/2005 extendedProperty"`
+	Extra         []element      `xml:",any"`
+}
+
+// tidy prepares a fetched entry to be written back.
+func (e *entry) tidy() {
+	tidyElements(e.Extra)
+	if e.Name != nil {
+		tidyElements(e.Name.Extra)
+	}
+	for i := range e.Emails {
+		e.Emails[i].Attrs = withoutDeclarations(e.Emails[i].Attrs)
+	}
+	for i := range e.Phones {
+		e.Phones[i].Attrs = withoutDeclarations(e.Phones[i].Attrs)
+	}
+	for i := range e.Organizations {
+		e.Organizations[i].Attrs = withoutDeclarations(e.Organizations[i].Attrs)
+		tidyElements(e.Organizations[i].Extra)
+	}
+}
+
+func (e *entry) link(rel string) string {
+	for _, l := range e.Links {
+		if l.Rel == rel {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// address returns the contact's primary (or first) email address.
+func (e *entry) address() string {
+	if i := primaryEmail(e.Emails); i >= 0 {
+		return e.Emails[i].Address
+	}
+	return ""
+}
+
+// primaryEmail, primaryPhone and primaryOrganization return the index of
+// the entry marked primary, or of the first, or -1 if there are none. The
+// primary ones are those the sync manages; any others are left alone.
+func primaryEmail(emails []email) int {
+	for i, m := range emails {
+		if m.Primary {
+			return i
+		}
+	}
+	return first(len(emails))
+}
+
+func primaryPhone(phones []phone) int {
+	for i, p := range phones {
+		if p.Primary {
+			return i
+		}
+	}
+	return first(len(phones))
+}
+
+func primaryOrganization(orgs []organization) int {
+	for i, o := range orgs {
+		if o.Primary {
+			return i
+		}
+	}
+	return first(len(orgs))
+}
+
+// first is the index of the first of n entries, or -1 if there are none.
+func first(n int) int {
+	if n > 0 {
+		return 0
+	}
+	return -1
+}
+
+func (e *entry) managed() bool {
+	for _, p := range e.Properties {
+		if p.Name == managedProperty {
+			return true
+		}
+	}
+	return false
+}
+
+type feed struct {
+	Entries []*entry `xml:"http://www.w3.org/2005/Atom entry"`
+	Links   []link   `xml:"http://www.w3.org/2005/Atom link"`
+}
+
+func feedURL(domain string) string {
+	return "https://www.google.com/m8/feeds/contacts/" + url.QueryEscape(domain) + "/full"
+}
+
+// listContacts returns every shared contact in domain.
+func listContacts(ctx context.Context, client *http.Client, domain string) ([]*entry, error) {
+	entries := []*entry{}
+	next := feedURL(domain) + "?max-results=1000"
+	for next != "" {
+		f := &feed{}
+		if err := gdata(ctx, client, "GET", next, "", nil, f); err != nil {
+			return nil, err
+		}
+		for _, e := range f.Entries {
+			e.tidy()
+		}
+		entries = append(entries, f.Entries...)
+		next = ""
+		for _, l := range f.Links {
+			if l.Rel == "next" {
+				next = l.Href
+			}
+		}
+	}
+	return entries, nil
+}
+
+// createContact creates e, marked as managed by the sync.
+func createContact(ctx context.Context, client *http.Client, domain string, e *entry) error {
+	e.Properties = append(e.Properties, property{Name: managedProperty, Value: "true"})
+	return gdata(ctx, client, "POST", feedURL(domain), "", e, nil)
+}
+
+// updateContact writes e back, failing if the contact has changed since e
+// was fetched.
+func updateContact(ctx context.Context, client *http.Client, e *entry) error {
+	return gdata(ctx, client, "PUT", e.link("edit"), e.ETag, e, nil)
+}
+
+func deleteContact(ctx context.Context, client *http.Client, e *entry) error {
+	return gdata(ctx, client, "DELETE", e.link("edit"), e.ETag, nil, nil)
+}
+
+// gdata sends in (if non-nil) as an Atom entry and decodes the response
+// into out (if non-nil). A write to an existing contact sends etag as
+// If-Match, so it fails with 412 if someone else has changed the contact
+// since it was fetched rather than overwriting their change.
+func gdata(ctx context.Context, client *http.Client, method, u, etag string, in, out interface{}) error {
+	if (method == "PUT" || method == "DELETE") && etag == "" {
+		return fmt.Errorf("%s %s: the contact was fetched without an etag", method, u)
+	}
+	var data []byte
+	if in != nil {
+		var err error
+		if data, err = xml.Marshal(in); err != nil {
+			return err
+		}
+	}
+	return retry.OnAuthError(ctx, func() error {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest(method, u, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("GData-Version", "3.0")
+		if data != nil {
+			req.Header.Set("Content-Type", "application/atom+xml")
+		}
+		if etag != "" {
+			req.Header.Set("If-Match", etag)
+		}
+		res, err := ctxhttp.Do(ctx, client, req)
+		if err != nil {
+			return err
+		}
+		defer googleapi.CloseBody(res)
+		if err := googleapi.CheckResponse(res); err != nil {
+			return err
+		}
+		if out == nil {
+			return nil
+		}
+		return xml.NewDecoder(res.Body).Decode(out)
+	})
+}
+
+// contact is one row of the csv.
+type contact struct {
+	Email        string
+	Name         string
+	GivenName    string
+	FamilyName   string
+	Organization string
+	Title        string
+	Phone        string
+}
+
+// apply sets the managed fields of e from c: the name, and the primary
+// email address, phone number and organization. Everything else on the
+// contact is left as it was.
+func (c *contact) apply(e *entry) {
+	if e.Category == nil {
+		e.Category = &category{Scheme: kindScheme, Term: contactKind}
+	}
+	if e.Name == nil {
+		e.Name = &name{}
+	}
+	e.Name.FullName = c.Name
+	if e.Name.FullName == "" {
+		e.Name.FullName = strings.TrimSpace(c.GivenName + " " + c.FamilyName)
+	}
+	e.Name.GivenName, e.Name.FamilyName = c.GivenName, c.FamilyName
+
+	if i := primaryEmail(e.Emails); i >= 0 {
+		e.Emails[i].Address = c.Email
+	} else {
+		e.Emails = []email{{Rel: workRel, Primary: true, Address: c.Email}}
+	}
+
+	i := primaryPhone(e.Phones)
+	switch {
+	case c.Phone == "" && i >= 0:
+		e.Phones = append(e.Phones[:i], e.Phones[i+1:]...)
+	case c.Phone != "" && i >= 0:
+		e.Phones[i].Number = c.Phone
+	case c.Phone != "":
+		e.Phones = append(e.Phones, phone{Rel: workRel, Primary: true, Number: c.Phone})
+	}
+
+	i = primaryOrganization(e.Organizations)
+	switch {
+	case c.Organization == "" && c.Title == "" && i >= 0:
+		e.Organizations = append(e.Organizations[:i], e.Organizations[i+1:]...)
+	case i >= 0:
+		e.Organizations[i].Name, e.Organizations[i].Title = c.Organization, c.Title
+	case c.Organization != "" || c.Title != "":
+		e.Organizations = append(e.Organizations, organization{Rel: workRel, Primary: true, Name: c.Organization, Title: c.Title})
+	}
+}
+
+// differs returns the managed fields of e that don't match c.
+func (c *contact) differs(e *entry) []string {
+	want := &entry{}
+	c.apply(want)
+	fields := []string{}
+	if e.Name == nil || e.Name.FullName != want.Name.FullName ||
+		e.Name.GivenName != want.Name.GivenName || e.Name.FamilyName != want.Name.FamilyName {
+		fields = append(fields, "name")
+	}
+	if !strings.EqualFold(e.address(), c.Email) {
+		fields = append(fields, "email")
+	}
+	number := ""
+	if i := primaryPhone(e.Phones); i >= 0 {
+		number = e.Phones[i].Number
+	}
+	if number != c.Phone {
+		fields = append(fields, "phone")
+	}
+	org, title := "", ""
+	if i := primaryOrganization(e.Organizations); i >= 0 {
+		org, title = e.Organizations[i].Name, e.Organizations[i].Title
+	}
+	if org != c.Organization || title != c.Title {
+		fields = append(fields, "organization")
+	}
+	return fields
+}
@@ -0,0 +1,206 @@
+// Package sharedcontactssync is the shared_contacts_sync tool: syncs Domain
+// Shared Contacts from a csv.
+package sharedcontactssync
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of shared_contacts_sync, which Run parses.
+var commandLine = flag.NewFlagSet("shared_contacts_sync", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose shared contacts are synced.")
+	contactsFileFlag      = commandLine.String("contacts-file", "", "A csv with an email column and optional name, given_name, family_name, organization, title and phone columns.")
+	keepMissingFlag       = commandLine.Bool("keep-missing", false, "Don't delete contacts this tool created that are no longer in the csv.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "shared_contacts_sync")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs shared_contacts_sync with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("shared_contacts_sync", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain", "contacts-file")
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	wanted, err := readContacts(*contactsFileFlag)
+	if err != nil {
+		log.Fatalf("Could not read contacts: %v", err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, contactsScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching shared contacts")
+	existing, err := listContacts(ctx, client, *domainFlag)
+	if err != nil {
+		log.Fatalf("Error fetching shared contacts: %v", err)
+	}
+	byEmail := map[string]*entry{}
+	for _, e := range existing {
+		if addr := strings.ToLower(e.address()); addr != "" {
+			byEmail[addr] = e
+		}
+	}
+
+	changes := []*reconcile.Change{}
+	managed := 0
+	for _, c := range wanted {
+		e, ok := byEmail[strings.ToLower(c.Email)]
+		if !ok {
+			changes = append(changes, createChange(ctx, client, c))
+			continue
+		}
+		if fields := c.differs(e); len(fields) > 0 {
+			changes = append(changes, updateChange(ctx, client, c, e, fields))
+		}
+	}
+	if !*keepMissingFlag {
+		inFile := map[string]bool{}
+		for _, c := range wanted {
+			inFile[strings.ToLower(c.Email)] = true
+		}
+		// Contacts added by hand or by other tools aren't ours to delete.
+		for _, e := range existing {
+			if !e.managed() {
+				continue
+			}
+			managed++
+			if !inFile[strings.ToLower(e.address())] {
+				changes = append(changes, deleteChange(ctx, client, e))
+			}
+		}
+	}
+
+	log.Printf("%d changes for %d contacts", len(changes), len(wanted))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: managed,
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+func createChange(ctx context.Context, client *http.Client, c *contact) *reconcile.Change {
+	return &reconcile.Change{
+		Action: "add",
+		Target: c.Email,
+		Apply: func() error {
+			e := &entry{}
+			c.apply(e)
+			return createContact(ctx, client, *domainFlag, e)
+		},
+	}
+}
+
+func updateChange(ctx context.Context, client *http.Client, c *contact, e *entry, fields []string) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "update",
+		Target:  c.Email,
+		Subject: strings.Join(fields, ","),
+		Apply: func() error {
+			c.apply(e)
+			return updateContact(ctx, client, e)
+		},
+	}
+}
+
+func deleteChange(ctx context.Context, client *http.Client, e *entry) *reconcile.Change {
+	return &reconcile.Change{
+		Action: "delete",
+		Target: e.address(),
+		Apply:  func() error { return deleteContact(ctx, client, e) },
+	}
+}
+
+func readContacts(path string) ([]*contact, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	cols := map[string]int{}
+	for i, h := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["email"]; !ok {
+		return nil, fmt.Errorf("%s has no \"email\" column", path)
+	}
+	field := func(r []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	contacts := []*contact{}
+	seen := map[string]bool{}
+	for _, r := range records[1:] {
+		c := &contact{
+			Email:        field(r, "email"),
+			Name:         field(r, "name"),
+			GivenName:    field(r, "given_name"),
+			FamilyName:   field(r, "family_name"),
+			Organization: field(r, "organization"),
+			Title:        field(r, "title"),
+			Phone:        field(r, "phone"),
+		}
+		if c.Email == "" {
+			continue
+		}
+		key := strings.ToLower(c.Email)
+		if seen[key] {
+			return nil, fmt.Errorf("%s lists %s more than once", path, c.Email)
+		}
+		seen[key] = true
+		contacts = append(contacts, c)
+	}
+	return contacts, nil
+}
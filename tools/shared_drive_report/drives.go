@@ -0,0 +1,110 @@
+package shareddrivereport
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	driveReadonlyScope = "https://www.googleapis.com/auth/drive.readonly"
+	driveBasePath      = "https://www.googleapis.com/drive/v3/"
+)
+
+// drive is a Shared Drive as an admin sees it.
+type drive struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	CreatedTime  string `json:"createdTime"`
+	Hidden       bool   `json:"hidden"`
+	Restrictions struct {
+		AdminManagedRestrictions                  bool `json:"adminManagedRestrictions"`
+		CopyRequiresWriterPermission              bool `json:"copyRequiresWriterPermission"`
+		DomainUsersOnly                           bool `json:"domainUsersOnly"`
+		DriveMembersOnly                          bool `json:"driveMembersOnly"`
+		SharingFoldersRequiresOrganizerPermission bool `json:"sharingFoldersRequiresOrganizerPermission"`
+	} `json:"restrictions"`
+}
+
+// permission is a member of a Shared Drive: a user, group, domain or
+// anyone with the link.
+type permission struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	EmailAddress string `json:"emailAddress"`
+	Domain       string `json:"domain"`
+	Role         string `json:"role"`
+	DisplayName  string `json:"displayName"`
+	// Deleted is set on members whose account has been deleted.
+	Deleted bool `json:"deleted"`
+}
+
+// member returns who the permission is for: an address, a domain, or
+// "anyone".
+func (p *permission) member() string {
+	switch {
+	case p.EmailAddress != "":
+		return p.EmailAddress
+	case p.Domain != "":
+		return p.Domain
+	}
+	return p.Type
+}
+
+// listDrives returns every Shared Drive in the organization matching
+// query (Drive's search syntax for drives, "" for all).
+func listDrives(ctx context.Context, client *http.Client, query string) ([]*drive, error) {
+	all := []*drive{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Drives        []*drive `json:"drives"`
+			NextPageToken string   `json:"nextPageToken"`
+		}{}
+		u := rest.URL(driveBasePath, "drives", url.Values{
+			"useDomainAdminAccess": {"true"},
+			"q":                    {query},
+			"fields":               {"drives(id,name,createdTime,hidden,restrictions),nextPageToken"},
+			"pageSize":             {"100"},
+			"pageToken":            {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Drives...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// listPermissions returns the members of a Shared Drive.
+func listPermissions(ctx context.Context, client *http.Client, driveID string) ([]*permission, error) {
+	all := []*permission{}
+	pageToken := ""
+	for {
+		r := &struct {
+			Permissions   []*permission `json:"permissions"`
+			NextPageToken string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(driveBasePath, "files/"+url.PathEscape(driveID)+"/permissions", url.Values{
+			"useDomainAdminAccess": {"true"},
+			"supportsAllDrives":    {"true"},
+			"fields":               {"permissions(id,type,emailAddress,domain,role,displayName,deleted),nextPageToken"},
+			"pageSize":             {"100"},
+			"pageToken":            {pageToken},
+		})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.Permissions...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
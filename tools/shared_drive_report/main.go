@@ -0,0 +1,199 @@
+// Package shareddrivereport is the shared_drive_report tool: every Shared
+// Drive with its organizers, members and sharing restrictions.
+package shareddrivereport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of shared_drive_report, which Run parses.
+var commandLine = flag.NewFlagSet("shared_drive_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	internalDomainsFlag   = commandLine.String("internal-domains", "", "The organization's domains, separated by commas, for telling external members apart. Defaults to every verified domain.")
+	queryFlag             = commandLine.String("query", "", "Only report Shared Drives matching this Drive search, e.g. \"name contains 'Finance'\".")
+	outputFile            = commandLine.String("output-file", "shared_drives.csv", "The csv file of Shared Drives to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "shared_drive_report", "csv")
+	permissionsFile       = commandLine.String("permissions-file", "shared_drive_permissions.csv", "The csv file of every Shared Drive's members to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs shared_drive_report with args, the arguments that follow its name.
+func Run(args []string) error {
+	// -domain named the internal domains here but the domain to read
+	// elsewhere.
+	config.Alias(commandLine, "domain", "internal-domains", "2026-10")
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("shared_drive_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	scopes := []string{driveReadonlyScope}
+	domains := listfile.Split(*internalDomainsFlag)
+	if len(domains) == 0 {
+		scopes = append(scopes, admin.AdminDirectoryDomainReadonlyScope)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, scopes...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(domains) == 0 {
+		service, err := admin.New(client)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if domains, err = directory.ListDomains(ctx, service); err != nil {
+			log.Fatalf("Error fetching domains: %v", err)
+		}
+	}
+	internal := map[string]bool{}
+	for _, d := range domains {
+		internal[strings.ToLower(d)] = true
+	}
+
+	log.Println("Fetching Shared Drives")
+	drives, err := listDrives(ctx, client, *queryFlag)
+	if err != nil {
+		log.Fatalf("Error fetching Shared Drives: %v", err)
+	}
+	log.Printf("%d Shared Drives", len(drives))
+
+	writer, err := outputOptions.Create(*outputFile, []string{
+		"drive_id", "name", "created", "hidden", "organizers", "members", "external_members",
+		"admin_managed_restrictions", "copy_requires_writer_permission", "domain_users_only",
+		"drive_members_only", "sharing_folders_requires_organizer_permission", "error",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+	// -fields and -filter describe the drive report; the members are
+	// always written whole.
+	permissionsOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("shared_drive_report_permissions")}
+	permissionsWriter, err := permissionsOptions.Create(*permissionsFile, []string{
+		"drive_id", "drive", "member", "type", "role", "display_name", "external", "deleted",
+	})
+	if err != nil {
+		log.Fatalf("Could not open file for writing: %v", err)
+	}
+
+	failed, done := 0, 0
+	for _, d := range drives {
+		// A drive whose members can't be read still gets a row, so the
+		// inventory is complete.
+		perms, err := listPermissions(ctx, client, d.ID)
+		if shutdown.Interrupted(ctx) {
+			break
+		}
+		done++
+		errText := ""
+		if err != nil {
+			log.Printf("Error fetching members of %s (%s): %v", d.Name, d.ID, err)
+			failed++
+			errText = err.Error()
+		}
+		organizers := []string{}
+		external := 0
+		for _, p := range perms {
+			isExternal := isExternal(p, internal)
+			if isExternal {
+				external++
+			}
+			if p.Role == "organizer" {
+				organizers = append(organizers, p.member())
+			}
+			if err := permissionsWriter.Write([]string{
+				d.ID,
+				d.Name,
+				p.member(),
+				p.Type,
+				p.Role,
+				p.DisplayName,
+				strconv.FormatBool(isExternal),
+				strconv.FormatBool(p.Deleted),
+			}); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+		members := ""
+		if err == nil {
+			members = strconv.Itoa(len(perms))
+		}
+		r := d.Restrictions
+		if err := writer.Write([]string{
+			d.ID,
+			d.Name,
+			d.CreatedTime,
+			strconv.FormatBool(d.Hidden),
+			strings.Join(organizers, ";"),
+			members,
+			strconv.Itoa(external),
+			strconv.FormatBool(r.AdminManagedRestrictions),
+			strconv.FormatBool(r.CopyRequiresWriterPermission),
+			strconv.FormatBool(r.DomainUsersOnly),
+			strconv.FormatBool(r.DriveMembersOnly),
+			strconv.FormatBool(r.SharingFoldersRequiresOrganizerPermission),
+			errText,
+		}); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	interrupted := shutdown.Interrupted(ctx)
+	for _, w := range []output.RowWriter{writer, permissionsWriter} {
+		if interrupted {
+			if err := output.MarkIncomplete(w, "interrupted"); err != nil {
+				log.Fatalf("Error writing csv file: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			log.Fatalf("Error writing csv file: %v", err)
+		}
+	}
+	if interrupted {
+		log.Printf("Interrupted: wrote partial report of %d of %d Shared Drives", done, len(drives))
+		os.Exit(shutdown.ExitCode)
+	}
+	if failed > 0 {
+		log.Fatalf("Complete, but the members of %d Shared Drives could not be fetched", failed)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// isExternal reports whether a member is outside the organization's
+// domains. Anyone with the link counts as external.
+func isExternal(p *permission, internal map[string]bool) bool {
+	switch p.Type {
+	case "anyone":
+		return true
+	case "domain":
+		return !internal[strings.ToLower(p.Domain)]
+	}
+	email := strings.ToLower(p.EmailAddress)
+	return !internal[email[strings.LastIndex(email, "@")+1:]]
+}
@@ -0,0 +1,160 @@
+// Package storagequotaalerts is the storage_quota_alerts tool: users above
+// a percentage of their storage quota, optionally emailed a warning.
+package storagequotaalerts
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of storage_quota_alerts, which Run parses.
+var commandLine = flag.NewFlagSet("storage_quota_alerts", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	thresholdsFlag        = commandLine.String("thresholds", "90", "Comma separated percentages of quota at which to alert, e.g. 80,90,95.")
+	dateFlag              = commandLine.String("date", "", "The report date (YYYY-MM-DD, in UTC). Defaults to three days ago, since usage data lags.")
+	notifyFromFlag        = commandLine.String("notify-from", "", "If set, email each user over a threshold, sending as this address.")
+	outputFile            = commandLine.String("output-file", "storage_alerts.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "storage_quota_alerts", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+const (
+	usedParam  = "accounts:used_quota_in_mb"
+	totalParam = "accounts:total_quota_in_mb"
+)
+
+type alert struct {
+	email     string
+	usedMB    int64
+	totalMB   int64
+	percent   float64
+	threshold float64
+}
+
+type byPercent []*alert
+
+func (a byPercent) Len() int           { return len(a) }
+func (a byPercent) Less(i, j int) bool { return a[i].percent > a[j].percent }
+func (a byPercent) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// Run runs storage_quota_alerts with args, the arguments that follow its
+// name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("storage_quota_alerts", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(outputOptions.CheckFormat())
+	thresholds, err := parseThresholds(*thresholdsFlag)
+	check.Check(err)
+	// Usage is reported for a single day, so this takes -date rather
+	// than a range.
+	date := time.Now().UTC().AddDate(0, 0, -3).Format(reports.DateLayout)
+	if *dateFlag != "" {
+		d, err := reports.ParseDate(*dateFlag, time.UTC)
+		if err == nil && d.After(time.Now()) {
+			err = fmt.Errorf("%s is in the future", *dateFlag)
+		}
+		check.Check(err)
+		date = d.Format(reports.DateLayout)
+	}
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, reports.UsageReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Fetching storage usage for %s", date)
+	usage, err := reports.UserUsage(ctx, client, date, usedParam, totalParam)
+	if err != nil {
+		log.Fatalf("Error fetching usage reports: %v", err)
+	}
+
+	alerts := []*alert{}
+	for _, u := range usage {
+		used, ok1 := u.Int(usedParam)
+		total, ok2 := u.Int(totalParam)
+		// Unlimited storage is reported as a total of -1.
+		if !ok1 || !ok2 || total <= 0 {
+			continue
+		}
+		pct := float64(used) * 100 / float64(total)
+		crossed := 0.0
+		for _, t := range thresholds {
+			if pct >= t {
+				crossed = t
+			}
+		}
+		if crossed > 0 {
+			alerts = append(alerts, &alert{u.Entity.UserEmail, used, total, pct, crossed})
+		}
+	}
+	sort.Sort(byPercent(alerts))
+
+	rows := [][]string{
+		{"email", "used_mb", "quota_mb", "percent_used", "threshold"},
+	}
+	for _, a := range alerts {
+		rows = append(rows, []string{
+			a.email,
+			strconv.FormatInt(a.usedMB, 10),
+			strconv.FormatInt(a.totalMB, 10),
+			strconv.FormatFloat(a.percent, 'f', 1, 64),
+			strconv.FormatFloat(a.threshold, 'f', -1, 64),
+		})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d users over threshold", len(alerts))
+
+	if *notifyFromFlag != "" {
+		sender, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *notifyFromFlag, gmailSendScope)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, a := range alerts {
+			if err := notify(ctx, sender, *notifyFromFlag, a); err != nil {
+				log.Printf("Error notifying %s: %v", a.email, err)
+			}
+		}
+	}
+	log.Println("Complete")
+	return nil
+}
+
+func parseThresholds(s string) ([]float64, error) {
+	thresholds := []float64{}
+	for _, part := range strings.Split(s, ",") {
+		t, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || t <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q", part)
+		}
+		thresholds = append(thresholds, t)
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
@@ -0,0 +1,30 @@
+package storagequotaalerts
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const gmailSendScope = "https://www.googleapis.com/auth/gmail.send"
+
+// notify emails the user about their storage use through the Gmail API,
+// sending from the mailbox client is authorized for.
+func notify(ctx context.Context, client *http.Client, from string, a *alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", a.email)
+	fmt.Fprintf(&msg, "Subject: Your account is at %.0f%% of its storage limit\r\n", a.percent)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Your account is using %d MB of its %d MB storage limit (%.1f%%).\r\n\r\n", a.usedMB, a.totalMB, a.percent)
+	fmt.Fprintf(&msg, "Once the limit is reached you will no longer be able to send or receive mail, ")
+	fmt.Fprintf(&msg, "so please delete large attachments or old files in Drive.\r\n")
+
+	body := map[string]string{"raw": base64.URLEncoding.EncodeToString(msg.Bytes())}
+	return rest.Do(ctx, client, "POST", "https://www.googleapis.com/gmail/v1/users/me/messages/send", body, nil)
+}
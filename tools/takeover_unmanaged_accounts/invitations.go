@@ -0,0 +1,83 @@
+package takeoverunmanagedaccounts
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const (
+	invitationsScope         = "https://www.googleapis.com/auth/cloud-identity.userinvitations"
+	invitationsReadonlyScope = "https://www.googleapis.com/auth/cloud-identity.userinvitations.readonly"
+	invitationsBasePath      = "https://cloudidentity.googleapis.com/v1/"
+)
+
+// Invitation states. Google creates a NOT_YET_SENT invitation for every
+// unmanaged consumer account it finds using one of the customer's domains.
+const (
+	stateNotYetSent = "NOT_YET_SENT"
+	stateInvited    = "INVITED"
+	stateAccepted   = "ACCEPTED"
+	stateDeclined   = "DECLINED"
+)
+
+// invitation is a UserInvitation from the Cloud Identity API.
+type invitation struct {
+	// Name is "customers/<id>/userinvitations/<escaped email>".
+	Name           string `json:"name"`
+	State          string `json:"state"`
+	UpdateTime     string `json:"updateTime"`
+	MailsSentCount string `json:"mailsSentCount"`
+}
+
+// email returns the address of the unmanaged account.
+func (i *invitation) email() string {
+	escaped := i.Name[strings.LastIndex(i.Name, "/")+1:]
+	if e, err := url.QueryUnescape(escaped); err == nil {
+		return e
+	}
+	return escaped
+}
+
+// daysSinceUpdate returns how long ago the state last changed (when the
+// invitation was last sent, for INVITED), or -1 if that isn't known.
+func (i *invitation) daysSinceUpdate(now time.Time) int {
+	t, err := time.Parse(time.RFC3339Nano, i.UpdateTime)
+	if err != nil {
+		return -1
+	}
+	return int(now.Sub(t).Hours() / 24)
+}
+
+// listInvitations returns the customer's invitations.
+func listInvitations(ctx context.Context, client *http.Client, customerID string) ([]*invitation, error) {
+	all := []*invitation{}
+	pageToken := ""
+	for {
+		r := &struct {
+			UserInvitations []*invitation `json:"userInvitations"`
+			NextPageToken   string        `json:"nextPageToken"`
+		}{}
+		u := rest.URL(invitationsBasePath, "customers/"+url.QueryEscape(customerID)+"/userinvitations",
+			url.Values{"pageSize": {"200"}, "pageToken": {pageToken}})
+		if err := rest.Get(ctx, client, u, r); err != nil {
+			return nil, err
+		}
+		all = append(all, r.UserInvitations...)
+		if r.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// sendInvitation emails the account's owner an invitation to join the
+// organization's account. Sending again resends it.
+func sendInvitation(ctx context.Context, client *http.Client, i *invitation) error {
+	return rest.Do(ctx, client, "POST", invitationsBasePath+i.Name+":send", struct{}{}, nil)
+}
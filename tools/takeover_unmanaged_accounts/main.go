@@ -0,0 +1,161 @@
+// Package takeoverunmanagedaccounts is the takeover_unmanaged_accounts
+// tool: lists unmanaged consumer accounts using the domain and sends them
+// transfer invitations.
+package takeoverunmanagedaccounts
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of takeover_unmanaged_accounts, which Run
+// parses.
+var commandLine = flag.NewFlagSet("takeover_unmanaged_accounts", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	sendFlag              = commandLine.Bool("send", false, "Send invitations to the unmanaged accounts that haven't had one. Without it the accounts are only reported.")
+	resendAfterFlag       = commandLine.Int("resend-after-days", 0, "With -send, also resend invitations still pending after this many days (0 never resends).")
+	outputFile            = commandLine.String("output-file", "unmanaged_accounts.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "takeover_unmanaged_accounts", "csv")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the invitations without sending them.")
+	canaryFlag            = commandLine.String("canary", "", "Send only the first N invitations (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of sends fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of sends to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "takeover_unmanaged_accounts")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs takeover_unmanaged_accounts with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("takeover_unmanaged_accounts", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.Check(outputOptions.CheckFormat())
+	if *resendAfterFlag < 0 {
+		check.Problemf("-resend-after-days can't be negative")
+	}
+	if *resendAfterFlag > 0 && !*sendFlag {
+		check.Problemf("-resend-after-days needs -send")
+	}
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	scope := invitationsReadonlyScope
+	if *sendFlag {
+		scope = invitationsScope
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryCustomerReadonlyScope, scope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The invitations API wants the customer ID itself, not my_customer.
+	customer, err := service.Customers.Get("my_customer").Do()
+	if err != nil {
+		log.Fatalf("Error fetching customer: %v", err)
+	}
+
+	log.Println("Fetching unmanaged account invitations")
+	invitations, err := listInvitations(ctx, client, customer.Id)
+	if err != nil {
+		log.Fatalf("Error fetching invitations: %v", err)
+	}
+	sort.Sort(byEmail(invitations))
+
+	now := time.Now()
+	rows := [][]string{{"email", "state", "mails_sent", "last_updated", "days_since_update", "action"}}
+	changes := []*reconcile.Change{}
+	counts := map[string]int{}
+	for _, i := range invitations {
+		counts[i.State]++
+		days := i.daysSinceUpdate(now)
+		action := ""
+		switch {
+		case !*sendFlag:
+		case i.State == stateNotYetSent:
+			action = "send"
+		case i.State == stateInvited && *resendAfterFlag > 0 && days >= *resendAfterFlag:
+			action = "resend"
+		}
+		if action != "" {
+			changes = append(changes, sendChange(ctx, client, i, action))
+		}
+		daysValue := ""
+		if days >= 0 {
+			daysValue = strconv.Itoa(days)
+		}
+		mails := i.MailsSentCount
+		if mails == "" {
+			mails = "0"
+		}
+		rows = append(rows, []string{i.email(), i.State, mails, i.UpdateTime, daysValue, action})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d unmanaged accounts: %d not yet invited, %d invited, %d accepted, %d declined",
+		len(invitations), counts[stateNotYetSent], counts[stateInvited], counts[stateAccepted], counts[stateDeclined])
+
+	if *sendFlag {
+		log.Printf("%d invitations to send", len(changes))
+		if _, err := reconcile.Apply(changes, reconcile.Options{
+			DryRun:  *dryRunFlag,
+			Canary:  *canaryFlag,
+			Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+			Limits:  limits,
+			Plan:    plan,
+		}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Println("Complete")
+	return nil
+}
+
+func sendChange(ctx context.Context, client *http.Client, i *invitation, action string) *reconcile.Change {
+	return &reconcile.Change{
+		Action: action,
+		Target: i.email(),
+		Apply:  func() error { return sendInvitation(ctx, client, i) },
+	}
+}
+
+type byEmail []*invitation
+
+func (s byEmail) Len() int           { return len(s) }
+func (s byEmail) Less(i, j int) bool { return s[i].email() < s[j].email() }
+func (s byEmail) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
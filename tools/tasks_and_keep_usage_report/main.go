@@ -0,0 +1,183 @@
+// Package tasksandkeepusagereport is the tasks_and_keep_usage_report tool:
+// each user's use of Keep and Tasks from the audit logs, with the share of
+// each OU using them.
+package tasksandkeepusagereport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/schema"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of tasks_and_keep_usage_report, which Run
+// parses.
+var commandLine = flag.NewFlagSet("tasks_and_keep_usage_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain whose users are reported, or several separated by commas.")
+	dateRange             = reports.RegisterDateFlags(commandLine, "30d")
+	outputFile            = commandLine.String("output-file", "tasks_and_keep_usage.csv", "The csv file of each user's usage to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "tasks_and_keep_usage_report", "csv")
+	summaryFile           = commandLine.String("summary-file", "tasks_and_keep_usage_by_ou.csv", "The csv file of per-OU totals to write out.")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// applications are the Reports API activity logs the report reads, in
+// column order.
+var applications = []string{"keep", "tasks"}
+
+// usage is one user's use of one application.
+type usage struct {
+	events   int
+	lastUsed string
+}
+
+type ouStats struct {
+	users int
+	using map[string]int
+}
+
+// Run runs tasks_and_keep_usage_report with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("tasks_and_keep_usage_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(dateRange.Check())
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, reports.AuditReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Fetching users")
+	users := []*admin.User{}
+	for _, domain := range listfile.Split(*domainFlag) {
+		list, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			log.Fatalf("Error fetching users of %s: %v", domain, err)
+		}
+		for _, u := range list {
+			// Suspended users can't use anything, and would only dilute
+			// their OU's share.
+			if !u.Suspended {
+				users = append(users, u)
+			}
+		}
+	}
+
+	// used maps application and lowercased address to the user's usage.
+	used := map[string]map[string]*usage{}
+	for _, app := range applications {
+		log.Printf("Fetching %s activity from %s", app, dateRange)
+		q := reports.ActivityQuery{Application: app}
+		if err := dateRange.Apply(&q); err != nil {
+			log.Fatal(err)
+		}
+		activities, err := reports.Activities(ctx, client, q)
+		if err != nil {
+			log.Fatalf("Error fetching %s audit log: %v", app, err)
+		}
+		used[app] = map[string]*usage{}
+		// Activities come newest first, so the first one seen for a user
+		// is their last.
+		for _, a := range activities {
+			email := strings.ToLower(a.Actor.Email)
+			u, ok := used[app][email]
+			if !ok {
+				u = &usage{lastUsed: a.ID.Time}
+				used[app][email] = u
+			}
+			u.events += len(a.Events)
+		}
+	}
+
+	header := []string{"email", "org_unit"}
+	for _, app := range applications {
+		header = append(header, app+"_events", app+"_last_used")
+	}
+	rows := [][]string{header}
+	stats := map[string]*ouStats{}
+	for _, u := range users {
+		s, ok := stats[u.OrgUnitPath]
+		if !ok {
+			s = &ouStats{using: map[string]int{}}
+			stats[u.OrgUnitPath] = s
+		}
+		s.users++
+		row := []string{u.PrimaryEmail, u.OrgUnitPath}
+		for _, app := range applications {
+			a, ok := used[app][strings.ToLower(u.PrimaryEmail)]
+			if !ok {
+				row = append(row, "0", "")
+				continue
+			}
+			s.using[app]++
+			row = append(row, strconv.Itoa(a.events), a.lastUsed)
+		}
+		rows = append(rows, row)
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+
+	ous := []string{}
+	for ou := range stats {
+		ous = append(ous, ou)
+	}
+	sort.Strings(ous)
+	header = []string{"org_unit", "users"}
+	for _, app := range applications {
+		header = append(header, app+"_users", app+"_percent")
+	}
+	summary := [][]string{header}
+	for _, ou := range ous {
+		s := stats[ou]
+		row := []string{ou, strconv.Itoa(s.users)}
+		for _, app := range applications {
+			percent := strconv.FormatFloat(float64(s.using[app])*100/float64(s.users), 'f', 1, 64)
+			row = append(row, strconv.Itoa(s.using[app]), percent)
+		}
+		summary = append(summary, row)
+	}
+	// -fields and -filter describe the main report; the summary only
+	// follows -format.
+	summaryOptions := &output.Options{Format: outputOptions.Format, Schema: schema.Stamp("tasks_and_keep_usage_report_by_ou")}
+	if err := summaryOptions.WriteFile(*summaryFile, summary); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Println("Complete")
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package usercreationdatereport is the user_creation_date_report tool:
+// users created in a date range, with who created them from the admin audit
+// log.
+package usercreationdatereport
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/listfile"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reports"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of user_creation_date_report, which Run parses.
+var commandLine = flag.NewFlagSet("user_creation_date_report", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	domainFlag            = commandLine.String("domain", "", "The domain to query for users, or several separated by commas.")
+	dateRange             = reports.RegisterDateFlags(commandLine, "30d")
+	sortFlag              = commandLine.String("sort", "created", "The order of the report: created (newest first), email, org_unit or creator.")
+	outputFile            = commandLine.String("output-file", "user_creation_dates.csv", "The csv file to write out.")
+	outputOptions         = output.RegisterFlags(commandLine, "user_creation_date_report", "csv")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// created is one user created in the range.
+type created struct {
+	user    *admin.User
+	time    time.Time
+	creator string
+	// creatorType is the audit log's caller type: USER for an admin, or
+	// empty for a service account or another API client.
+	creatorType string
+}
+
+// orders are the -sort values, each a less function for created users.
+var orders = map[string]func(a, b *created) bool{
+	"created": func(a, b *created) bool { return a.time.After(b.time) },
+	"email": func(a, b *created) bool {
+		return strings.ToLower(a.user.PrimaryEmail) < strings.ToLower(b.user.PrimaryEmail)
+	},
+	"org_unit": func(a, b *created) bool {
+		return strings.ToLower(a.user.OrgUnitPath) < strings.ToLower(b.user.OrgUnitPath)
+	},
+	"creator": func(a, b *created) bool { return strings.ToLower(a.creator) < strings.ToLower(b.creator) },
+}
+
+type byOrder struct {
+	users []*created
+	less  func(a, b *created) bool
+}
+
+func (s byOrder) Len() int           { return len(s.users) }
+func (s byOrder) Swap(i, j int)      { s.users[i], s.users[j] = s.users[j], s.users[i] }
+func (s byOrder) Less(i, j int) bool { return s.less(s.users[i], s.users[j]) }
+
+// Run runs user_creation_date_report with args, the arguments that follow
+// its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("user_creation_date_report", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "domain")
+	check.Check(dateRange.Check())
+	if orders[*sortFlag] == nil {
+		check.Problemf("unknown -sort %q: use created, email, org_unit or creator", *sortFlag)
+	}
+	check.Check(outputOptions.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag,
+		admin.AdminDirectoryUserReadonlyScope, reports.AuditReadonlyScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := dateRange.Bounds()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Fetching users created from %s", dateRange)
+	users := []*created{}
+	for _, domain := range listfile.Split(*domainFlag) {
+		all, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			log.Fatalf("Error fetching users of %s: %v", domain, err)
+		}
+		for _, u := range all {
+			t, err := time.Parse(time.RFC3339, u.CreationTime)
+			if err != nil || t.Before(start) || !t.Before(end) {
+				continue
+			}
+			users = append(users, &created{user: u, time: t})
+		}
+	}
+
+	// The admin audit log says who created each account, for as long as
+	// it keeps events (about six months). An account renamed since has
+	// its old address in the log, and no creator here.
+	q := reports.ActivityQuery{Application: "admin", EventName: "CREATE_USER"}
+	if err := dateRange.Apply(&q); err != nil {
+		log.Fatal(err)
+	}
+	activities, err := reports.Activities(ctx, client, q)
+	if err != nil {
+		log.Fatalf("Error fetching audit log: %v", err)
+	}
+	creators := map[string]*reports.Activity{}
+	for _, a := range activities {
+		for _, e := range a.Events {
+			if e.Name == "CREATE_USER" {
+				creators[strings.ToLower(e.Param("USER_EMAIL"))] = a
+			}
+		}
+	}
+	for _, c := range users {
+		if a := creators[strings.ToLower(c.user.PrimaryEmail)]; a != nil {
+			c.creator = a.Actor.Email
+			c.creatorType = a.Actor.CallerType
+		}
+	}
+	sort.Stable(byOrder{users, orders[*sortFlag]})
+
+	rows := [][]string{
+		{"email", "name", "org_unit", "created", "creator", "creator_type", "suspended"},
+	}
+	for _, c := range users {
+		name := ""
+		if c.user.Name != nil {
+			name = c.user.Name.FullName
+		}
+		rows = append(rows, []string{c.user.PrimaryEmail, name, c.user.OrgUnitPath, c.user.CreationTime,
+			c.creator, c.creatorType, strconv.FormatBool(c.user.Suspended)})
+	}
+	if err := outputOptions.WriteFile(*outputFile, rows); err != nil {
+		log.Fatalf("Error writing csv file: %v", err)
+	}
+	log.Printf("%d users created", len(users))
+	log.Println("Complete")
+	return nil
+}
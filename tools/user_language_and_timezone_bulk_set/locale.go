@@ -0,0 +1,188 @@
+package userlanguageandtimezonebulkset
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/rest"
+)
+
+const calendarScope = "https://www.googleapis.com/auth/calendar"
+
+// target is the language and timezone one user should have. Empty fields
+// are left alone.
+type target struct {
+	email    string
+	language string
+	timezone string
+}
+
+var languageCode = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// checkLanguage rejects values that can't be language codes, such as a
+// display name ("French") put in the wrong column.
+func checkLanguage(code string) error {
+	if !languageCode.MatchString(code) {
+		return fmt.Errorf("invalid language %q: use a code such as en-GB or fr", code)
+	}
+	return nil
+}
+
+// checkTimezone rejects names that aren't IANA timezones.
+func checkTimezone(name string) error {
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return fmt.Errorf("invalid timezone %q: use an IANA name such as Europe/Paris", name)
+	}
+	return nil
+}
+
+// readTargets reads a csv with an email column and language and/or
+// timezone columns.
+func readTargets(path string) ([]*target, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	cols := map[string]int{}
+	for i, h := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["email"]; !ok {
+		return nil, fmt.Errorf("%s has no \"email\" column", path)
+	}
+	_, hasLanguage := cols["language"]
+	_, hasTimezone := cols["timezone"]
+	if !hasLanguage && !hasTimezone {
+		return nil, fmt.Errorf("%s has neither a \"language\" nor a \"timezone\" column", path)
+	}
+	field := func(r []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	targets := []*target{}
+	for n, r := range records[1:] {
+		t := &target{email: field(r, "email"), language: field(r, "language"), timezone: field(r, "timezone")}
+		if t.email == "" {
+			continue
+		}
+		// Line numbers count the header as line 1.
+		if t.language != "" {
+			if err := checkLanguage(t.language); err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", path, n+2, err)
+			}
+		}
+		if t.timezone != "" {
+			if err := checkTimezone(t.timezone); err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", path, n+2, err)
+			}
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+type language struct {
+	LanguageCode   string `json:"languageCode,omitempty"`
+	CustomLanguage string `json:"customLanguage,omitempty"`
+	Preference     string `json:"preference,omitempty"`
+}
+
+func userURL(email string) string {
+	return "https://www.googleapis.com/admin/directory/v1/users/" + url.QueryEscape(email)
+}
+
+// getLanguages returns the user's languages. The vendored Directory client
+// predates the languages field.
+func getLanguages(ctx context.Context, client *http.Client, email string) ([]language, error) {
+	r := &struct {
+		Languages []language `json:"languages"`
+	}{}
+	if err := rest.Get(ctx, client, userURL(email)+"?fields=languages", r); err != nil {
+		return nil, err
+	}
+	return r.Languages, nil
+}
+
+// preferred returns the index of the preferred language, or of the first
+// if none is marked preferred, or -1 if there are none.
+func preferred(languages []language) int {
+	for i, l := range languages {
+		if l.Preference == "preferred" {
+			return i
+		}
+	}
+	if len(languages) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// preferredCode returns the code of the user's preferred language, or ""
+// if none is set.
+func preferredCode(languages []language) string {
+	if i := preferred(languages); i >= 0 {
+		return languages[i].LanguageCode
+	}
+	return ""
+}
+
+// withPreferred returns languages with code as the preferred language in
+// place of the old one, keeping the others. An entry that already had code
+// is dropped rather than listed twice.
+func withPreferred(languages []language, code string) []language {
+	updated := []language{{LanguageCode: code, Preference: "preferred"}}
+	i := preferred(languages)
+	for j, l := range languages {
+		if j != i && l.LanguageCode != code {
+			updated = append(updated, l)
+		}
+	}
+	return updated
+}
+
+// setLanguages replaces the user's languages.
+func setLanguages(ctx context.Context, client *http.Client, email string, languages []language) error {
+	if languages == nil {
+		languages = []language{}
+	}
+	body := map[string]interface{}{"languages": languages}
+	return rest.Do(ctx, client, "PATCH", userURL(email), body, nil)
+}
+
+const primaryCalendarURL = "https://www.googleapis.com/calendar/v3/calendars/primary"
+
+// getTimezone returns the timezone of the impersonated user's primary
+// calendar, which is what Calendar shows as their timezone.
+func getTimezone(ctx context.Context, userClient *http.Client) (string, error) {
+	r := &struct {
+		TimeZone string `json:"timeZone"`
+	}{}
+	if err := rest.Get(ctx, userClient, primaryCalendarURL, r); err != nil {
+		return "", err
+	}
+	return r.TimeZone, nil
+}
+
+// setTimezone sets the impersonated user's primary calendar timezone. The
+// Calendar settings API is read-only, so this is the only way to change it.
+func setTimezone(ctx context.Context, userClient *http.Client, name string) error {
+	return rest.Do(ctx, userClient, "PATCH", primaryCalendarURL, map[string]string{"timeZone": name}, nil)
+}
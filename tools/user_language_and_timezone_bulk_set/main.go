@@ -0,0 +1,207 @@
+// Package userlanguageandtimezonebulkset is the
+// user_language_and_timezone_bulk_set tool: sets users' language and
+// Calendar timezone, recording the old values.
+package userlanguageandtimezonebulkset
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/output"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of user_language_and_timezone_bulk_set, which
+// Run parses.
+var commandLine = flag.NewFlagSet("user_language_and_timezone_bulk_set", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	usersFileFlag         = commandLine.String("users-file", "", "A csv with an email column and language and/or timezone columns. Blank cells are left unchanged.")
+	orgUnitFlag           = commandLine.String("org-unit", "", "Instead of -users-file, apply -language and -timezone to every active user in this OU and the OUs below it.")
+	domainFlag            = commandLine.String("domain", "", "With -org-unit, the domain to query for users.")
+	languageFlag          = commandLine.String("language", "", "With -org-unit, the language code to set, e.g. fr or en-GB.")
+	timezoneFlag          = commandLine.String("timezone", "", "With -org-unit, the Calendar timezone to set, e.g. Europe/Paris.")
+	restoreFileFlag       = commandLine.String("restore-file", "", "Instead of -users-file or -org-unit, put back the settings recorded in this rollback file.")
+	rollbackFile          = commandLine.String("rollback-file", "locale_rollback.csv", "Where each user's previous languages and timezone are recorded before they are changed. It mustn't exist yet, so an earlier run's is never overwritten.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "user_language_and_timezone_bulk_set")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs user_language_and_timezone_bulk_set with args, the arguments
+// that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("user_language_and_timezone_bulk_set", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email")
+	check.RequireOne("users-file", "org-unit", "restore-file")
+	switch {
+	case *restoreFileFlag != "" && (*usersFileFlag != "" || *orgUnitFlag != ""):
+		check.Problemf("-restore-file can't be used with -users-file or -org-unit")
+	case *usersFileFlag != "" && *orgUnitFlag != "":
+		check.Problemf("-users-file and -org-unit can't be used together")
+	}
+	if _, err := os.Stat(*rollbackFile); err == nil && *restoreFileFlag == "" && !*dryRunFlag {
+		check.Problemf("-rollback-file %s already exists; move it aside or give another -rollback-file", *rollbackFile)
+	}
+	if *orgUnitFlag != "" {
+		check.Required("domain")
+		check.RequireOne("language", "timezone")
+	}
+	if *languageFlag != "" {
+		check.Check(checkLanguage(*languageFlag))
+	}
+	if *timezoneFlag != "" {
+		check.Check(checkTimezone(*timezoneFlag))
+	}
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Timezones belong to each user's own calendar.
+	impersonator, err := auth.NewImpersonator(*credentialsFileFlag, calendarScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *restoreFileFlag != "" {
+		changes, err := restoreChanges(ctx, client, impersonator, *restoreFileFlag)
+		if err != nil {
+			log.Fatalf("Could not read rollback file: %v", err)
+		}
+		log.Printf("%d settings to restore", len(changes))
+		if _, err := reconcile.Apply(changes, reconcile.Options{
+			DryRun:  *dryRunFlag,
+			Canary:  *canaryFlag,
+			Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+			Limits:  limits,
+			Plan:    plan,
+		}); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Complete")
+		return nil
+	}
+
+	var targets []*target
+	if *usersFileFlag != "" {
+		targets, err = readTargets(*usersFileFlag)
+		if err != nil {
+			log.Fatalf("Could not read users: %v", err)
+		}
+	} else {
+		service, err := admin.New(client)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Fetching users in %s", *orgUnitFlag)
+		users, err := directory.ListUsersInOrgUnit(ctx, service, *domainFlag, *orgUnitFlag)
+		if err != nil {
+			log.Fatalf("Error fetching users: %v", err)
+		}
+		for _, u := range users {
+			if !u.Suspended {
+				targets = append(targets, &target{email: u.PrimaryEmail, language: *languageFlag, timezone: *timezoneFlag})
+			}
+		}
+	}
+
+	rollback := [][]string{rollbackHeader}
+	changes := []*reconcile.Change{}
+	for _, t := range targets {
+		if t.language != "" {
+			languages, err := getLanguages(ctx, client, t.email)
+			if err != nil {
+				log.Fatalf("Error fetching language of %s: %v", t.email, err)
+			}
+			if old := preferredCode(languages); old != t.language {
+				row, err := languagesRow(t.email, languages)
+				if err != nil {
+					log.Fatal(err)
+				}
+				rollback = append(rollback, row)
+				changes = append(changes, languageChange(ctx, client, t.email, old, t.language, withPreferred(languages, t.language)))
+			}
+		}
+		if t.timezone != "" {
+			userClient, err := impersonator.Client(ctx, t.email)
+			if err != nil {
+				log.Fatal(err)
+			}
+			old, err := getTimezone(ctx, userClient)
+			if err != nil {
+				log.Fatalf("Error fetching timezone of %s: %v", t.email, err)
+			}
+			if old != t.timezone {
+				rollback = append(rollback, []string{t.email, "timezone", old})
+				changes = append(changes, timezoneChange(ctx, userClient, t.email, old, t.timezone))
+			}
+		}
+	}
+	if !*dryRunFlag {
+		if err := output.WriteNewCSV(*rollbackFile, rollback); err != nil {
+			log.Fatalf("Error writing rollback file: %v", err)
+		}
+	}
+	log.Printf("%d changes for %d users", len(changes), len(targets))
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:  *dryRunFlag,
+		Canary:  *canaryFlag,
+		Breaker: breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:  limits,
+		Plan:    plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// languageChange makes code the user's preferred language, keeping their
+// other languages.
+func languageChange(ctx context.Context, client *http.Client, email, old, code string, languages []language) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "set",
+		Target:  email,
+		Subject: fmt.Sprintf("language=%s (was %s)", code, old),
+		Apply:   func() error { return setLanguages(ctx, client, email, languages) },
+	}
+}
+
+func timezoneChange(ctx context.Context, userClient *http.Client, email, old, name string) *reconcile.Change {
+	return &reconcile.Change{
+		Action:  "set",
+		Target:  email,
+		Subject: fmt.Sprintf("timezone=%s (was %s)", name, old),
+		Apply:   func() error { return setTimezone(ctx, userClient, name) },
+	}
+}
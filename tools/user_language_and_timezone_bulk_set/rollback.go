@@ -0,0 +1,79 @@
+package userlanguageandtimezonebulkset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+)
+
+// The rollback file has a row per setting changed. A language row's value
+// is the user's whole languages list before the run, as JSON, and a
+// timezone row's the timezone.
+var rollbackHeader = []string{"email", "setting", "value"}
+
+func languagesRow(email string, languages []language) ([]string, error) {
+	if languages == nil {
+		languages = []language{}
+	}
+	data, err := json.Marshal(languages)
+	if err != nil {
+		return nil, err
+	}
+	return []string{email, "languages", string(data)}, nil
+}
+
+// restoreChanges reads a rollback file and returns the changes that put
+// each recorded setting back.
+func restoreChanges(ctx context.Context, client *http.Client, impersonator *auth.Impersonator, path string) ([]*reconcile.Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(rollbackHeader, ",") {
+		return nil, fmt.Errorf("%s is not a rollback file: expected %s columns", path, strings.Join(rollbackHeader, ", "))
+	}
+	changes := []*reconcile.Change{}
+	for i, rec := range records[1:] {
+		email, setting, value := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1]), rec[2]
+		switch setting {
+		case "languages":
+			languages := []language{}
+			if err := json.Unmarshal([]byte(value), &languages); err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", path, i+2, err)
+			}
+			changes = append(changes, &reconcile.Change{
+				Action:  "restore",
+				Target:  email,
+				Subject: "languages=" + value,
+				Apply:   func() error { return setLanguages(ctx, client, email, languages) },
+			})
+		case "timezone":
+			userClient, err := impersonator.Client(ctx, email)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, &reconcile.Change{
+				Action:  "restore",
+				Target:  email,
+				Subject: "timezone=" + value,
+				Apply:   func() error { return setTimezone(ctx, userClient, value) },
+			})
+		default:
+			return nil, fmt.Errorf("%s line %d: unknown setting %q", path, i+2, setting)
+		}
+	}
+	return changes, nil
+}
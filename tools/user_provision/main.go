@@ -0,0 +1,120 @@
+// Package userprovision is the user_provision tool: applies an actions csv
+// of hires, terminations, transfers and sparse updates.
+package userprovision
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/admin/directory/v1"
+
+	"github.com/jburnham/google_apps_tools/pkg/auth"
+	"github.com/jburnham/google_apps_tools/pkg/breaker"
+	"github.com/jburnham/google_apps_tools/pkg/config"
+	"github.com/jburnham/google_apps_tools/pkg/directory"
+	"github.com/jburnham/google_apps_tools/pkg/provision"
+	"github.com/jburnham/google_apps_tools/pkg/reconcile"
+	"github.com/jburnham/google_apps_tools/pkg/shutdown"
+	"github.com/jburnham/google_apps_tools/pkg/version"
+)
+
+// commandLine holds the flags of user_provision, which Run parses.
+var commandLine = flag.NewFlagSet("user_provision", flag.ExitOnError)
+
+var (
+	credentialsFileFlag   = config.CredentialsFile(commandLine)
+	impersonatedEmailFlag = config.ImpersonatedEmail(commandLine)
+	actionsFileFlag       = commandLine.String("actions-file", "", "The actions csv to apply, as written by hr_roster_sync.")
+	skipTerminateFlag     = commandLine.Bool("skip-terminate", false, "Apply hires and transfers only, leaving terminations to the offboarding process.")
+	dryRunFlag            = commandLine.Bool("dry-run", false, "Log the changes without making them.")
+	canaryFlag            = commandLine.String("canary", "", "Apply only the first N changes (or N%) and stop for review.")
+	maxErrorRateFlag      = commandLine.Float64("max-error-rate", 0.5, "Abort once more than this fraction of changes fail (0 disables).")
+	breakerMinCallsFlag   = commandLine.Int("breaker-min-calls", 20, "The number of changes to attempt before -max-error-rate applies.")
+	limits                = reconcile.RegisterLimitFlags(commandLine)
+	plan                  = reconcile.RegisterPlanFlags(commandLine, "user_provision")
+	versionFlag           = commandLine.Bool("version", false, "Show version information.")
+)
+
+// Run runs user_provision with args, the arguments that follow its name.
+func Run(args []string) error {
+	check := config.Parse(commandLine, args)
+
+	if *versionFlag {
+		fmt.Println("user_provision", version.Git)
+		os.Exit(0)
+	}
+
+	check.Required("credentials-file", "impersonated-email", "actions-file")
+	_, err := reconcile.ParseCanary(*canaryFlag, 0)
+	check.Check(err)
+	check.Check(plan.CheckFormat())
+	check.Done()
+
+	ctx := shutdown.Context(context.Background())
+
+	actions, err := provision.ReadActions(*actionsFileFlag)
+	if err != nil {
+		log.Fatalf("Could not read actions: %v", err)
+	}
+	client, err := auth.ClientFromFile(ctx, *credentialsFileFlag, *impersonatedEmailFlag, admin.AdminDirectoryUserScope)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service, err := admin.New(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	changes := []*reconcile.Change{}
+	for _, a := range actions {
+		if *skipTerminateFlag && a.Kind == provision.Terminate {
+			continue
+		}
+		changes = append(changes, provision.Change(service, a))
+	}
+	log.Printf("%d changes to make", len(changes))
+	existing, err := countUsers(ctx, service, actions)
+	if err != nil {
+		log.Fatalf("Error fetching users: %v", err)
+	}
+	if _, err := reconcile.Apply(changes, reconcile.Options{
+		DryRun:   *dryRunFlag,
+		Canary:   *canaryFlag,
+		Breaker:  breaker.New(*breakerMinCallsFlag, *maxErrorRateFlag),
+		Limits:   limits,
+		Existing: existing,
+		Plan:     plan,
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Complete")
+	return nil
+}
+
+// countUsers returns how many users there are in the domains of the
+// terminations, which -max-delete-fraction measures them against. An
+// empty or truncated roster shows up here as most of a domain leaving.
+func countUsers(ctx context.Context, service *admin.Service, actions []*provision.Action) (int, error) {
+	if limits.Force || limits.MaxDeleteFraction <= 0 {
+		return 0, nil
+	}
+	domains := map[string]bool{}
+	for _, a := range actions {
+		if a.Kind == provision.Terminate && !*skipTerminateFlag {
+			domains[strings.ToLower(a.Email[strings.LastIndex(a.Email, "@")+1:])] = true
+		}
+	}
+	total := 0
+	for domain := range domains {
+		users, err := directory.ListUsers(ctx, service, domain, "")
+		if err != nil {
+			return 0, err
+		}
+		total += len(users)
+	}
+	return total, nil
+}
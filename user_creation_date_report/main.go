@@ -0,0 +1,23 @@
+// Command user_creation_date_report runs the user_creation_date_report
+// tool; see package usercreationdatereport in
+// tools/user_creation_date_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/user_creation_date_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := usercreationdatereport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,24 @@
+// Command user_language_and_timezone_bulk_set runs the
+// user_language_and_timezone_bulk_set tool; see package
+// userlanguageandtimezonebulkset in
+// tools/user_language_and_timezone_bulk_set.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/user_language_and_timezone_bulk_set"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := userlanguageandtimezonebulkset.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
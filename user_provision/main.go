@@ -0,0 +1,22 @@
+// Command user_provision runs the user_provision tool; see package
+// userprovision in tools/user_provision.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/user_provision"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := userprovision.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Command users_report runs the users_report tool; see package usersreport
+// in tools/users_report.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jburnham/google_apps_tools/pkg/version"
+	"github.com/jburnham/google_apps_tools/tools/users_report"
+)
+
+// Should be set by ldflags:
+// godep go build -ldflags "-X main.gitVersion=$(git rev-parse --short HEAD)"
+var gitVersion string
+
+func main() {
+	version.Git = gitVersion
+	if err := usersreport.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}